@@ -61,6 +61,10 @@ func runAdd() error {
 		return err
 	}
 
+	if refuseIfTrashed(label, user) {
+		return nil
+	}
+
 	// check if a credential already exists for the label and user
 	check, err := store.GetCredentialByLabelAndUser(label, user)
 	if check != nil {
@@ -97,12 +101,15 @@ func runAdd() error {
 		logger.Error("%s", constants.ErrSecretDoesNotMatch.Error())
 		return nil
 	}
+	warnIfBreached(secret)
+	reportStrength(secret, label, user)
 
 	// save credential to vault
 	if err := vault.AddCredential(label, user, secret); err != nil {
 		logger.Error("%s", err.Error())
 		return err
 	}
+	vault.RecordAdded(label, user, check != nil)
 	logger.Info(constants.MsgSavedCredential)
 	return nil
 }
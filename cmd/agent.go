@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/agent"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var agentTimeout int
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep the vault unlocked for the browser extension",
+	Long: `Unlock the vault once and keep its key in memory, so the browser extension
+(see "native-host") can fill credentials without asking for the master
+password. The agent runs in the foreground and serves processes of the same
+user over ~/.kosh/agent.sock. It locks itself after the agent.timeout_minutes
+setting, with "kosh lock" or when interrupted.`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		timeout := agentTimeout
+		if timeout <= 0 {
+			timeout = vault.GetIntSetting(constants.SettingAgentTimeout)
+		}
+		return runAgent(time.Duration(timeout) * time.Minute)
+	},
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the unlock agent is running",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAgentStatus()
+	},
+}
+
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock the unlock agent",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLock()
+	},
+}
+
+func init() {
+	agentCmd.Flags().IntVarP(&agentTimeout, "timeout", "t", 0, "minutes until the agent locks itself (default agent.timeout_minutes)")
+
+	agentCmd.AddCommand(agentStatusCmd)
+	rootCmd.AddCommand(agentCmd, lockCmd)
+}
+
+func runAgent(timeout time.Duration) error {
+	socketPath, err := agent.SocketPath()
+	if err != nil {
+		return err
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	privateKey, err := vault.UnlockVault(password)
+	if err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	listener, err := agent.Listen(socketPath)
+	if err != nil {
+		clear(privateKey)
+		logger.Error("%s", err.Error())
+		return nil
+	}
+
+	server := agent.NewServer(privateKey, timeout)
+
+	// lock on Ctrl+C as well, the key must not outlive the process
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		if _, ok := <-signals; ok {
+			server.Lock()
+		}
+	}()
+
+	logger.Info("%s, locks at %s", constants.MsgAgentUnlocked, time.Now().Add(timeout).Format(time.TimeOnly))
+	if err := server.Serve(listener); err != nil {
+		return err
+	}
+	logger.Info(constants.MsgAgentLocked)
+	return nil
+}
+
+func runAgentStatus() error {
+	client, err := agent.NewClient()
+	if err != nil {
+		return err
+	}
+
+	expires, err := client.Status()
+	if err != nil {
+		logger.Warn("%s", err.Error())
+		return nil
+	}
+	logger.Info("%s, locks in %s", constants.MsgAgentUnlocked, time.Until(expires).Round(time.Second))
+	return nil
+}
+
+func runLock() error {
+	client, err := agent.NewClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.Lock(); err != nil {
+		logger.Warn("%s", err.Error())
+		return nil
+	}
+	logger.Info(constants.MsgAgentLocked)
+	return nil
+}
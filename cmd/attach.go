@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	attachName    string
+	attachMaxSize string
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <id> <file>",
+	Short: "Store an encrypted file with a credential",
+	Long: `Encrypt a file (license, kubeconfig, TLS key, ...) and store it with a credential.
+The file is streamed in encrypted chunks and never fully loaded in memory.
+Files larger than the attachment.max_size setting are rejected.`,
+	Example: `	kosh attach 12 ~/.kube/config
+	kosh attach --name tls.key 12 ./server.key`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runAttach(id, args[1])
+	},
+}
+
+func init() {
+	attachCmd.Flags().StringVar(&attachName, "name", "", "attachment name (default: file name)")
+	attachCmd.Flags().StringVar(&attachMaxSize, "max-size", "", "maximum file size for this upload (default: attachment.max_size setting)")
+
+	rootCmd.AddCommand(attachCmd)
+}
+
+func runAttach(id int, path string) error {
+	maxSize := getSizeSetting(constants.SettingAttachmentMaxSize)
+	if attachMaxSize != "" {
+		size, err := parseSize(attachMaxSize)
+		if err != nil {
+			logger.Error("invalid `max-size` flag value")
+			return err
+		}
+		maxSize = size
+	}
+
+	name := strings.TrimSpace(attachName)
+	if name == "" {
+		name = filepath.Base(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Error("unable to open %s", path)
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		logger.Error("%s is not a regular file", path)
+		return nil
+	}
+	if info.Size() > maxSize {
+		logger.Error("%s (%s > %s)", constants.ErrAttachmentTooLarge.Error(), formatSize(info.Size()), formatSize(maxSize))
+		return nil
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	existing, err := store.GetAttachment(credential.Id, name)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+	if existing != nil {
+		logger.Error("%s", constants.ErrAttachmentAlreadyExists.Error())
+		logger.Info("remove it with `attachment rm %d %s` or choose another `--name`", credential.Id, name)
+		return nil
+	}
+
+	attachment, err := vault.AddAttachment(credential.Id, name, file, maxSize)
+	if errors.Is(err, constants.ErrAttachmentTooLarge) {
+		logger.Error("%s (> %s)", err.Error(), formatSize(maxSize))
+		return nil
+	}
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info("%s - %s (%s)", constants.MsgSavedAttachment, attachment.Name, formatSize(attachment.Size))
+	logger.Muted("%s", fmt.Sprintf("retrieve it with `attachment get %d %s -o <file>`", credential.Id, attachment.Name))
+	return nil
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	attachmentOutput string
+	attachmentForce  bool
+)
+
+var attachmentCmd = &cobra.Command{
+	Use:   "attachment",
+	Short: "Manage encrypted file attachments of a credential",
+}
+
+var attachmentGetCmd = &cobra.Command{
+	Use:     "get <id> <name>",
+	Short:   "Decrypt an attachment into a file",
+	Example: `	kosh attachment get 12 config -o ~/.kube/config`,
+	Args:    cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runAttachmentGet(id, args[1], attachmentOutput)
+	},
+}
+
+var attachmentListCmd = &cobra.Command{
+	Use:   "list <id>",
+	Short: "Show the attachments of a credential",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runAttachmentList(id)
+	},
+}
+
+var attachmentRmCmd = &cobra.Command{
+	Use:   "rm <id> <name>",
+	Short: "Delete an attachment of a credential",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runAttachmentRm(id, args[1])
+	},
+}
+
+func init() {
+	attachmentGetCmd.Flags().StringVarP(&attachmentOutput, "output", "o", "", "file to write the decrypted attachment to")
+	attachmentGetCmd.Flags().BoolVar(&attachmentForce, "force", false, "overwrite the output file if it exists")
+	attachmentGetCmd.MarkFlagRequired("output")
+
+	attachmentCmd.AddCommand(attachmentGetCmd, attachmentListCmd, attachmentRmCmd)
+	rootCmd.AddCommand(attachmentCmd)
+}
+
+func runAttachmentGet(id int, name, output string) error {
+	if _, err := os.Stat(output); err == nil && !attachmentForce {
+		logger.Error("%s already exists, use --force to overwrite it", output)
+		return nil
+	}
+
+	attachment, err := getAttachment(id, name)
+	if attachment == nil {
+		return err
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	// decrypt into a private temporary file next to the output and only move it in place once every
+	// chunk has been verified, so a tampered attachment never leaves partial plain text behind
+	file, err := os.CreateTemp(filepath.Dir(output), ".kosh-attachment-*")
+	if err != nil {
+		logger.Error("unable to create %s", output)
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := vault.DecryptAttachment(attachment, password, file); err != nil {
+		file.Close()
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), output); err != nil {
+		logger.Error("unable to write %s", output)
+		return err
+	}
+
+	logger.Info("%s %s (%s)", constants.MsgWroteAttachment, output, formatSize(attachment.Size))
+	recordAccess(id, model.AccessViaAttachment, "")
+	recordEvent(model.AuditEventRead, id)
+	return nil
+}
+
+func runAttachmentList(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	attachments, err := store.GetAttachments(credential.Id)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	logger.Muted("attachments of %s (%s)\n", credential.Label, credential.User)
+	if len(attachments) == 0 {
+		logger.Warn("no attachments found")
+		return nil
+	}
+
+	fmt.Printf("%-32s %-10s %-20s\n", "NAME", "SIZE", "CREATED AT")
+	fmt.Printf("%s\n", strings.Repeat("─", 64))
+	for _, attachment := range attachments {
+		createdAt := attachment.CreatedAt.Local().Format(time.DateTime)
+		fmt.Printf("%-32s %-10s %-20s\n", truncate(attachment.Name, 32), formatSize(attachment.Size), createdAt)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runAttachmentRm(id int, name string) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	attachment, err := getAttachment(id, name)
+	if attachment == nil {
+		return err
+	}
+
+	logger.Warn(constants.MsgOperationIsPermanent)
+	confirm, err := ui.ConfirmWithText(
+		fmt.Sprintf("delete attachment %s? %s", name, constants.MsgAreYouSure),
+		fmt.Sprintf("delete %s", name),
+	)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if !confirm {
+		logger.Info(constants.MsgOperationAborted)
+		return nil
+	}
+
+	if err := store.DeleteAttachment(attachment.Id); err != nil {
+		logger.Error("%s", constants.ErrFailedToDeleteCredential.Error())
+		return err
+	}
+	recordEvent(model.AuditEventUpdate, id)
+	logger.Info(constants.MsgDeletedAttachment)
+	return nil
+}
+
+func getAttachment(id int, name string) (*model.Attachment, error) {
+	attachment, err := store.GetAttachment(id, name)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrAttachmentNotFound.Error())
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return nil, err
+	}
+	return attachment, nil
+}
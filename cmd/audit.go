@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/health"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/strength"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditJSON       bool
+	auditMaxAge     int
+	auditMinEntropy int
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Find weak, reused and old passwords",
+	Long: `Unlock the vault once, decrypt every credential and report:
+
+  reused          the same secret is stored for more than one credential
+  weak            the secret takes fewer than 2^--min-entropy guesses
+  old             the secret was not changed for more than --max-age days
+  never-accessed  the credential was never read since it was added
+  missing-user    the credential has no user
+  breached        the secret is in the breach list of breach.db_path
+
+Secrets are rated by the same estimator as "kosh strength", with the label and
+user of the credential as words an attacker tries first. They are compared
+through keyed hashes that only exist during the audit, no plain text is kept. Secure notes are only checked for reuse. The thresholds
+default to the audit.min_entropy_bits and audit.max_age_days settings, 0
+disables a check.
+
+Exits with status 1 when anything is found, so it can gate scripts.`,
+	Example: `	kosh audit
+	kosh audit --max-age 180 --min-entropy 50
+	kosh audit --json | jq '.findings[] | select(.issues | index("reused"))'`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxAge := vault.GetIntSetting(constants.SettingAuditMaxAgeDays)
+		if cmd.Flags().Changed("max-age") {
+			maxAge = auditMaxAge
+		}
+		minEntropy := vault.GetIntSetting(constants.SettingAuditMinEntropy)
+		if cmd.Flags().Changed("min-entropy") {
+			minEntropy = auditMinEntropy
+		}
+		if maxAge < 0 || minEntropy < 0 {
+			logger.Error("%s: thresholds cannot be negative", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+
+		return runAudit(health.Options{
+			MinEntropy: float64(minEntropy),
+			MaxAge:     time.Duration(maxAge) * 24 * time.Hour,
+			Now:        time.Now(),
+		}, auditJSON)
+	},
+}
+
+func init() {
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "print the findings as JSON")
+	auditCmd.Flags().IntVar(&auditMaxAge, "max-age", 0, "days after which a secret is old (default audit.max_age_days)")
+	auditCmd.Flags().IntVar(&auditMinEntropy, "min-entropy", 0, "bits of guesses below which a secret is weak (default audit.min_entropy_bits)")
+	rootCmd.AddCommand(auditCmd)
+}
+
+// credentialHealthJSON is the --json form of a finding, without the keyed hash of the secret
+type credentialHealthJSON struct {
+	Id              int       `json:"id"`
+	Label           string    `json:"label"`
+	User            string    `json:"user"`
+	Issues          []string  `json:"issues"`
+	Entropy         float64   `json:"entropy"`
+	Score           int       `json:"score"`
+	CrackTime       string    `json:"crackTime"`
+	Breached        int       `json:"breached,omitempty"`
+	SecretChangedAt time.Time `json:"secretChangedAt"`
+	AccessedAt      time.Time `json:"accessedAt"`
+	ReusedWith      []int     `json:"reusedWith,omitempty"`
+}
+
+type healthReportJSON struct {
+	Checked  int                    `json:"checked"`
+	Findings []credentialHealthJSON `json:"findings"`
+	Reused   [][]int                `json:"reused"`
+}
+
+func runAudit(options health.Options, asJSON bool) error {
+	if asJSON {
+		// keep stdout valid JSON, prompts and warnings go to stderr
+		defer logger.Redirect(os.Stderr)()
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	// breached secrets are only flagged with a breach list, see `kosh breach`
+	breaches, err := vault.OpenBreachDB()
+	if err != nil {
+		logger.Warn("unable to open breach list, skipping breach check: %s", err.Error())
+	}
+	if breaches != nil {
+		defer breaches.Close()
+	}
+
+	credentials, err := vault.AuditCredentials(password, breaches)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToDecryptCredential.Error())
+		return err
+	}
+	recordReads(credentials)
+	report := health.Check(credentials, options)
+
+	if asJSON {
+		out := healthReportJSON{Checked: report.Checked, Findings: []credentialHealthJSON{}, Reused: report.Reused}
+		for _, finding := range report.Findings {
+			issues := []string{}
+			for _, issue := range finding.Issues {
+				issues = append(issues, string(issue))
+			}
+			out.Findings = append(out.Findings, credentialHealthJSON{
+				Id:              finding.Id,
+				Label:           finding.Label,
+				User:            finding.User,
+				Issues:          issues,
+				Entropy:         math.Round(finding.Entropy*10) / 10,
+				Score:           finding.Score,
+				CrackTime:       strength.DisplayTime(finding.CrackSeconds),
+				Breached:        finding.Breached,
+				SecretChangedAt: finding.SecretChangedAt,
+				AccessedAt:      finding.AccessedAt,
+				ReusedWith:      finding.ReusedWith,
+			})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+		exitIfUnhealthy(report)
+		return nil
+	}
+
+	if len(report.Findings) == 0 {
+		logger.Info("no issues found in %d credentials", report.Checked)
+		return nil
+	}
+
+	fmt.Printf("%-5s %-20s %-20s %-6s %-20s %-8s %s\n", "ID", "LABEL", "USER", "SCORE", "CRACK TIME", "AGE", "ISSUES")
+	fmt.Printf("%s\n", strings.Repeat("─", 110))
+	for _, finding := range report.Findings {
+		issues := []string{}
+		for _, issue := range finding.Issues {
+			issues = append(issues, string(issue))
+		}
+		fmt.Printf("%-5d %-20s %-20s %-6s %-20s %-8s %s\n",
+			finding.Id,
+			truncate(finding.Label, 20),
+			truncate(finding.User, 20),
+			fmt.Sprintf("%d/4", finding.Score),
+			strength.DisplayTime(finding.CrackSeconds),
+			fmt.Sprintf("%dd", int(options.Now.Sub(finding.SecretChangedAt).Hours()/24)),
+			strings.Join(issues, ", "),
+		)
+	}
+	fmt.Println()
+
+	for _, group := range report.Reused {
+		ids := []string{}
+		for _, id := range group {
+			ids = append(ids, "#"+strconv.Itoa(id))
+		}
+		logger.Warn("same secret: %s", strings.Join(ids, ", "))
+	}
+	logger.Muted("%d of %d credentials need attention", len(report.Findings), report.Checked)
+
+	exitIfUnhealthy(report)
+	return nil
+}
+
+// exitIfUnhealthy exits with status 1 after the report was shown when anything was found
+func exitIfUnhealthy(report *model.HealthReport) {
+	if len(report.Findings) > 0 {
+		store.CloseStore()
+		os.Exit(1)
+	}
+}
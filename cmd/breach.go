@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/breach"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	breachDBPath string
+	breachJSON   bool
+)
+
+var breachCmd = &cobra.Command{
+	Use:   "breach",
+	Short: "Check secrets against a local Pwned Passwords list",
+	Long: `Look up every secret in a downloaded copy of the Pwned Passwords list, the
+SHA-1 version ordered by hash (pwned-passwords-sha1-ordered.txt). The file is
+binary searched where it lies, nothing is sent over the network.
+
+Without --db the breach.db_path setting is used. Once that is set, "add",
+"update" and "generate" warn about breached secrets and "audit" flags them.
+
+Exits with status 1 when a breached secret is found.`,
+	Example: `	kosh breach --db ~/Downloads/pwned-passwords-sha1-ordered.txt
+	kosh config set breach.db_path ~/Downloads/pwned-passwords-sha1-ordered.txt`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBreach(breachDBPath, breachJSON)
+	},
+}
+
+func init() {
+	breachCmd.Flags().StringVar(&breachDBPath, "db", "", "Pwned Passwords file (default breach.db_path)")
+	breachCmd.Flags().BoolVar(&breachJSON, "json", false, "print breached credentials as JSON")
+	rootCmd.AddCommand(breachCmd)
+}
+
+// breachedCredentialJSON is the --json form of a breached credential
+type breachedCredentialJSON struct {
+	Id    int    `json:"id"`
+	Label string `json:"label"`
+	User  string `json:"user"`
+	Count int    `json:"count"`
+}
+
+func runBreach(path string, asJSON bool) error {
+	if asJSON {
+		// keep stdout valid JSON, prompts and warnings go to stderr
+		defer logger.Redirect(os.Stderr)()
+	}
+
+	var breaches *breach.DB
+	var err error
+	if path != "" {
+		breaches, err = breach.Open(path)
+	} else {
+		breaches, err = vault.OpenBreachDB()
+	}
+	if err != nil {
+		logger.Error("unable to open breach list: %s", err.Error())
+		return nil
+	}
+	if breaches == nil {
+		logger.Error("%s: no breach list, pass --db or set breach.db_path", constants.ErrInvalidArguments.Error())
+		return nil
+	}
+	defer breaches.Close()
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	credentials, err := vault.AuditCredentials(password, breaches)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToDecryptCredential.Error())
+		return err
+	}
+	recordReads(credentials)
+
+	breached := []breachedCredentialJSON{}
+	for _, credential := range credentials {
+		if credential.Breached > 0 {
+			breached = append(breached, breachedCredentialJSON{credential.Id, credential.Label, credential.User, credential.Breached})
+		}
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(breached); err != nil {
+			return err
+		}
+		exitIfBreached(len(breached))
+		return nil
+	}
+
+	if len(breached) == 0 {
+		logger.Info("none of %d secrets found in the breach list", len(credentials))
+		return nil
+	}
+
+	fmt.Printf("%-5s %-20s %-20s %s\n", "ID", "LABEL", "USER", "SEEN")
+	fmt.Printf("%s\n", strings.Repeat("─", 60))
+	for _, credential := range breached {
+		fmt.Printf("%-5d %-20s %-20s %d\n", credential.Id, truncate(credential.Label, 20), truncate(credential.User, 20), credential.Count)
+	}
+	fmt.Println()
+	logger.Warn("%d of %d secrets appear in known breaches, change them", len(breached), len(credentials))
+
+	exitIfBreached(len(breached))
+	return nil
+}
+
+// exitIfBreached exits with status 1 after the list was shown when a secret was found
+func exitIfBreached(count int) {
+	if count > 0 {
+		store.CloseStore()
+		os.Exit(1)
+	}
+}
+
+// warnIfBreached warns when a new secret is in the breach list of breach.db_path. The check is
+// advisory, a missing or unreadable list never stops a save.
+func warnIfBreached(secret []byte) {
+	breaches, err := vault.OpenBreachDB()
+	if err != nil {
+		logger.Debug("warnIfBreached:unable to open breach list: %s", err.Error())
+		return
+	}
+	if breaches == nil {
+		return
+	}
+	defer breaches.Close()
+
+	count, err := breaches.Count(secret)
+	if err != nil {
+		logger.Debug("warnIfBreached:unable to search breach list: %s", err.Error())
+		return
+	}
+	if count > 0 {
+		logger.Warn(constants.MsgSecretBreached, count)
+	}
+}
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/breach"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// settingValidators checks the value of every known setting before it is saved
+var settingValidators = map[string]func(value string) error{
+	constants.SettingAttachmentMaxSize: func(value string) error {
+		_, err := parseSize(value)
+		return err
+	},
+	constants.SettingHistoryMaxVersions: validateCount,
+	constants.SettingHistoryMaxAgeDays:  validateCount,
+	constants.SettingAgentTimeout:       validateMinutes,
+	constants.SettingAuditMaxAgeDays:    validateCount,
+	constants.SettingAuditMinEntropy:    validateCount,
+	constants.SettingBreachDBPath:       validateBreachDB,
+	constants.SettingExpiryNoticeDays:   validateCount,
+	constants.SettingStatsRetentionDays: validateCount,
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show or change vault settings",
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show the value of one or all settings",
+	Args:  cobra.RangeArgs(0, 1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys := slices.Sorted(maps.Keys(constants.DefaultSettings))
+		if len(args) == 1 {
+			keys = []string{args[0]}
+		}
+		return runConfigGet(keys)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:     "set <key> [value]",
+	Short:   "Change a setting, omit the value to restore its default",
+	Example: `	kosh config set attachment.max_size 100MB`,
+	Args:    cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var value string
+		if len(args) > 1 {
+			value = args[1]
+		}
+		return runConfigSet(args[0], value)
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd, configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigGet(keys []string) error {
+	for _, key := range keys {
+		if _, ok := constants.DefaultSettings[key]; !ok {
+			logger.Error("%s %s", constants.ErrInvalidSetting.Error(), key)
+			return nil
+		}
+		fmt.Printf("%s = %s\n", key, getSetting(key))
+	}
+	return nil
+}
+
+func runConfigSet(key, value string) error {
+	if _, ok := constants.DefaultSettings[key]; !ok {
+		logger.Error("%s %s", constants.ErrInvalidSetting.Error(), key)
+		return nil
+	}
+
+	value = strings.TrimSpace(value)
+	if validate := settingValidators[key]; value != "" && validate != nil {
+		if err := validate(value); err != nil {
+			logger.Error("%s %s: %s", constants.ErrInvalidSetting.Error(), key, err.Error())
+			return nil
+		}
+	}
+
+	// settings change how the vault behaves, only the owner may change them
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	if err := store.SetSetting(key, value); err != nil {
+		logger.Error("unable to save setting %s", key)
+		return err
+	}
+	vault.RecordEvent(model.AuditEventConfig, 0, key, "")
+	logger.Info(constants.MsgSavedSetting)
+	return nil
+}
+
+// getSetting returns the stored value of a setting, or its default when it has not been set
+func getSetting(key string) string {
+	return vault.GetSetting(key)
+}
+
+// validateCount accepts non-negative integers, zero disables count and age limits
+func validateCount(value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return fmt.Errorf("expected a non-negative number, got %q", value)
+	}
+	return nil
+}
+
+// validateBreachDB accepts an empty path or a readable Pwned Passwords file
+func validateBreachDB(value string) error {
+	if value == "" {
+		return nil
+	}
+	db, err := breach.Open(value)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// validateMinutes accepts positive integers
+func validateMinutes(value string) error {
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes <= 0 {
+		return fmt.Errorf("expected a positive number of minutes, got %q", value)
+	}
+	return nil
+}
+
+// getSizeSetting returns the value of a size setting in bytes
+func getSizeSetting(key string) int64 {
+	size, err := parseSize(getSetting(key))
+	if err != nil {
+		logger.Debug("getSizeSetting:invalid stored value for %s, using default", key)
+		size, _ = parseSize(constants.DefaultSettings[key])
+	}
+	return size
+}
+
+var sizeUnits = map[string]int64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+}
+
+// parseSize parses a human readable size like "512KB" or "25MB" into bytes, units are powers of 1024
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	split := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+	if split == -1 {
+		split = len(value)
+	}
+
+	number, err := strconv.ParseInt(value[:split], 10, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+
+	unit, ok := sizeUnits[strings.TrimSpace(value[split:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size unit %q", value[split:])
+	}
+	return number * unit, nil
+}
+
+// formatSize formats a size in bytes using the largest unit that keeps it readable
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", size)
+}
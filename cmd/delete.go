@@ -7,13 +7,19 @@ import (
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
 	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
 	"git.plutolab.org/plutolab/kosh/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+var deletePermanent bool
+
 var deleteCmd = &cobra.Command{
 	Use:   "delete <id>",
-	Short: "Delete an existing credential by ID",
+	Short: "Move an existing credential to the trash by ID",
+	Long: `Move a credential to the trash. Trashed credentials are hidden from list and
+search and can be brought back with "trash restore" until they are purged, see
+the trash.retention_days setting. Use --permanent to delete right away.`,
 
 	Args: cobra.ExactArgs(1),
 
@@ -29,6 +35,8 @@ var deleteCmd = &cobra.Command{
 }
 
 func init() {
+	deleteCmd.Flags().BoolVar(&deletePermanent, "permanent", false, "delete permanently instead of moving to the trash")
+
 	rootCmd.AddCommand(deleteCmd)
 }
 
@@ -56,7 +64,9 @@ func runDelete(id int) error {
 	}
 
 	// get deletion confirmation
-	logger.Warn(constants.MsgOperationIsPermanent)
+	if deletePermanent {
+		logger.Warn(constants.MsgOperationIsPermanent)
+	}
 	confirm, err := ui.ConfirmWithText(
 		fmt.Sprintf("%s %s", constants.MsgDeleteCredential, constants.MsgAreYouSure),
 		fmt.Sprintf("delete %s %s", credential.Label, credential.User),
@@ -71,10 +81,24 @@ func runDelete(id int) error {
 		return nil
 	}
 
+	if !deletePermanent {
+		if err := store.TrashCredentialById(id); err != nil {
+			logger.Error("%s", constants.ErrFailedToDeleteCredential.Error())
+			return err
+		}
+		vault.SealCredentials(id)
+		vault.RecordEvent(model.AuditEventTrash, credential.Id, credential.Label, credential.User)
+		logger.Info(constants.MsgTrashedCredential)
+		logger.Muted("undo with `trash restore %d`", id)
+		return nil
+	}
+
 	err = store.DeleteCredentialById(id)
 	if err != nil {
 		logger.Error("%s", constants.ErrFailedToDeleteCredential.Error())
 	} else {
+		vault.SealCredentials(id)
+		vault.RecordEvent(model.AuditEventDelete, credential.Id, credential.Label, credential.User)
 		logger.Info(constants.MsgDeletedCredential)
 	}
 	return err
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dueWithin int
+	dueAll    bool
+	dueJSON   bool
+)
+
+var dueCmd = &cobra.Command{
+	Use:   "due",
+	Short: "List credentials whose secret is past or near its max age",
+	Long: `List the credentials whose secret is older than its max age, see "kosh expiry",
+or will be within --within days (default expiry.notice_days). The age counts
+from the last change of the secret.
+
+Exits with status 1 when a secret is overdue, so it can gate scripts.`,
+	Example: `	kosh due
+	kosh due --within 30
+	kosh due --all --json`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		within := vault.GetIntSetting(constants.SettingExpiryNoticeDays)
+		if cmd.Flags().Changed("within") {
+			within = dueWithin
+		}
+		if within < 0 {
+			logger.Error("%s: --within cannot be negative", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+		return runDue(within, dueAll, dueJSON, time.Now())
+	},
+}
+
+func init() {
+	dueCmd.Flags().IntVarP(&dueWithin, "within", "w", 0, "also list secrets due within this many days (default expiry.notice_days)")
+	dueCmd.Flags().BoolVarP(&dueAll, "all", "a", false, "list every credential with a max age")
+	dueCmd.Flags().BoolVar(&dueJSON, "json", false, "print the credentials as JSON")
+	rootCmd.AddCommand(dueCmd)
+}
+
+// credentialExpiryJSON is the --json form of a credential expiry
+type credentialExpiryJSON struct {
+	Id              int       `json:"id"`
+	Label           string    `json:"label"`
+	User            string    `json:"user"`
+	SecretChangedAt time.Time `json:"secretChangedAt"`
+	MaxAgeDays      int       `json:"maxAgeDays"`
+	Tag             string    `json:"tag,omitempty"`
+	DueAt           time.Time `json:"dueAt"`
+	DaysLeft        int       `json:"daysLeft"`
+	Overdue         bool      `json:"overdue"`
+}
+
+func runDue(within int, all, asJSON bool, now time.Time) error {
+	if asJSON {
+		// keep stdout valid JSON, warnings go to stderr
+		defer logger.Redirect(os.Stderr)()
+	}
+
+	expiries, err := store.GetCredentialExpiries()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	due := []model.CredentialExpiry{}
+	overdue := 0
+	for _, expiry := range expiries {
+		if expiry.Overdue(now) {
+			overdue++
+		}
+		if all || expiry.DaysLeft(now) < within || expiry.Overdue(now) {
+			due = append(due, expiry)
+		}
+	}
+
+	if asJSON {
+		out := []credentialExpiryJSON{}
+		for _, expiry := range due {
+			out = append(out, credentialExpiryJSON{
+				Id:              expiry.Id,
+				Label:           expiry.Label,
+				User:            expiry.User,
+				SecretChangedAt: expiry.SecretChangedAt,
+				MaxAgeDays:      expiry.MaxAgeDays,
+				Tag:             expiry.Tag,
+				DueAt:           expiry.DueAt(),
+				DaysLeft:        expiry.DaysLeft(now),
+				Overdue:         expiry.Overdue(now),
+			})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+		exitIfOverdue(overdue)
+		return nil
+	}
+
+	if len(expiries) == 0 {
+		logger.Warn("no credential has a max age")
+		logger.Info("set one with `expiry set <id> <days>` or `expiry set --tag <tag> <days>`")
+		return nil
+	}
+	if len(due) == 0 {
+		logger.Info("no secret is due within %d days", within)
+		return nil
+	}
+
+	fmt.Printf("%-5s %-20s %-20s %-20s %-14s %s\n", "ID", "LABEL", "USER", "SECRET CHANGED", "MAX AGE", "DUE")
+	fmt.Printf("%s\n", strings.Repeat("─", 100))
+	for _, expiry := range due {
+		maxAge := fmt.Sprintf("%dd", expiry.MaxAgeDays)
+		if expiry.Tag != "" {
+			maxAge += " #" + expiry.Tag
+		}
+		fmt.Printf("%-5d %-20s %-20s %-20s %-14s %s\n",
+			expiry.Id,
+			truncate(expiry.Label, 20),
+			truncate(expiry.User, 20),
+			expiry.SecretChangedAt.Local().Format(time.DateTime),
+			truncate(maxAge, 14),
+			describeDue(&expiry, now),
+		)
+	}
+	fmt.Println()
+
+	if overdue > 0 {
+		logger.Warn("%d of %d credentials are overdue, change them with `rotate <id>`", overdue, len(expiries))
+	}
+	exitIfOverdue(overdue)
+	return nil
+}
+
+// describeDue tells in how many days a secret is due, or how long it is overdue
+func describeDue(expiry *model.CredentialExpiry, now time.Time) string {
+	days := expiry.DaysLeft(now)
+	switch {
+	case expiry.Overdue(now) && days == 0:
+		return "overdue today"
+	case expiry.Overdue(now):
+		return fmt.Sprintf("overdue by %dd", -days)
+	case days == 0:
+		return "today"
+	}
+	return fmt.Sprintf("in %dd", days)
+}
+
+// exitIfOverdue exits with status 1 after the list was shown when a secret is overdue
+func exitIfOverdue(overdue int) {
+	if overdue > 0 {
+		store.CloseStore()
+		os.Exit(1)
+	}
+}
+
+// warnIfOverdue warns when the secret of a credential that was just retrieved is past its max age
+func warnIfOverdue(credential *model.Credential) {
+	expiry, err := store.GetCredentialExpiry(credential.Id)
+	if err != nil || !expiry.Overdue(time.Now()) {
+		return
+	}
+	logger.Warn("the secret of %s (%s) is older than its max age of %d days since %s, change it with `rotate %d`",
+		credential.Label, credential.User, expiry.MaxAgeDays, expiry.DueAt().Local().Format(time.DateOnly), credential.Id)
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var expiryTag string
+
+var expiryCmd = &cobra.Command{
+	Use:   "expiry",
+	Short: "Manage how often secrets have to be changed",
+	Long: `A max age says a secret has to be changed at least every so many days. Set it on
+a credential, or on a tag to cover every credential carrying it. A max age on
+the credential wins over its tags, and of several tags the shortest applies.
+
+The age counts from the last change of the secret, not from other edits or
+access. "kosh due" lists credentials past or near their max age, and "get" and
+"search" warn when the secret they copy is overdue.`,
+}
+
+var expirySetCmd = &cobra.Command{
+	Use:   "set [<id>] <days>",
+	Short: "Set the max age of a credential, or of a tag with --tag",
+	Example: `	kosh expiry set 12 90
+	kosh expiry set --tag pci 90`,
+	Args: cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days, err := strconv.Atoi(args[len(args)-1])
+		if err != nil || days <= 0 {
+			logger.Error("%s: days must be a positive number", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+		if expiryTag != "" {
+			if len(args) != 1 {
+				logger.Error("%s: give either an id or --tag", constants.ErrInvalidArguments.Error())
+				return nil
+			}
+			return runExpirySetTag(expiryTag, days)
+		}
+		if len(args) != 2 {
+			logger.Error("%s: give an id or --tag", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runExpirySet(id, days)
+	},
+}
+
+var expiryRmCmd = &cobra.Command{
+	Use:   "rm [<id>]",
+	Short: "Remove the max age of a credential, or of a tag with --tag",
+	Example: `	kosh expiry rm 12
+	kosh expiry rm --tag pci`,
+	Args: cobra.RangeArgs(0, 1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (expiryTag == "") == (len(args) == 0) {
+			logger.Error("%s: give either an id or --tag", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+		if expiryTag != "" {
+			return runExpiryRmTag(expiryTag)
+		}
+
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runExpiryRm(id)
+	},
+}
+
+var expiryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the max ages set on tags and credentials",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExpiryList()
+	},
+}
+
+func init() {
+	expirySetCmd.Flags().StringVarP(&expiryTag, "tag", "t", "", "set the max age of every credential with this tag")
+	expiryRmCmd.Flags().StringVarP(&expiryTag, "tag", "t", "", "remove the max age of this tag")
+
+	expiryCmd.AddCommand(expirySetCmd, expiryRmCmd, expiryListCmd)
+	rootCmd.AddCommand(expiryCmd)
+}
+
+func runExpirySet(id, days int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	if err := store.SetCredentialMaxAge(credential.Id, days); err != nil {
+		logger.Error("unable to save max age")
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info("the secret of %s (%s) has to be changed every %d days", credential.Label, credential.User, days)
+	return nil
+}
+
+func runExpirySetTag(name string, days int) error {
+	tags, err := parseTags([]string{name})
+	if err != nil {
+		return err
+	}
+
+	if err := store.SetTagMaxAge(tags[0], days); err != nil {
+		logger.Error("unable to save max age")
+		return err
+	}
+	vault.RecordEvent(model.AuditEventConfig, 0, "max age #"+tags[0], "")
+	logger.Info("secrets tagged #%s have to be changed every %d days", tags[0], days)
+	return nil
+}
+
+func runExpiryRm(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	err = store.ClearCredentialMaxAge(credential.Id)
+	if err == sql.ErrNoRows {
+		logger.Warn("%s (%s) has no max age of its own", credential.Label, credential.User)
+		return nil
+	}
+	if err != nil {
+		logger.Error("unable to remove max age")
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info("removed the max age of %s (%s)", credential.Label, credential.User)
+	return nil
+}
+
+func runExpiryRmTag(name string) error {
+	tags, err := parseTags([]string{name})
+	if err != nil {
+		return err
+	}
+
+	err = store.ClearTagMaxAge(tags[0])
+	if err == sql.ErrNoRows {
+		logger.Warn("#%s has no max age", tags[0])
+		return nil
+	}
+	if err != nil {
+		logger.Error("unable to remove max age")
+		return err
+	}
+	vault.RecordEvent(model.AuditEventConfig, 0, "max age #"+tags[0], "")
+	logger.Info("removed the max age of #%s", tags[0])
+	return nil
+}
+
+func runExpiryList() error {
+	maxAges, err := store.GetMaxAges()
+	if err != nil {
+		logger.Error("unable to fetch max ages")
+		return err
+	}
+
+	if len(maxAges) == 0 {
+		logger.Warn("no max ages set")
+		logger.Info("set one with `expiry set <id> <days>` or `expiry set --tag <tag> <days>`")
+		return nil
+	}
+
+	fmt.Printf("%-6s %-40s %-8s\n", "ID", "TAG / CREDENTIAL", "DAYS")
+	fmt.Printf("%s\n", strings.Repeat("─", 56))
+	for _, maxAge := range maxAges {
+		fmt.Printf("%-6s %-40s %-8d\n", maxAgeId(maxAge), truncate(maxAgeSubject(maxAge), 40), maxAge.Days)
+	}
+	fmt.Println()
+	return nil
+}
+
+func maxAgeId(maxAge model.MaxAge) string {
+	if maxAge.Tag != "" {
+		return ""
+	}
+	return strconv.Itoa(maxAge.CredentialId)
+}
+
+func maxAgeSubject(maxAge model.MaxAge) string {
+	if maxAge.Tag != "" {
+		return "#" + maxAge.Tag
+	}
+	return fmt.Sprintf("%s (%s)", maxAge.Label, maxAge.User)
+}
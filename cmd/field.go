@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/otp"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var fieldType string
+
+var fieldCmd = &cobra.Command{
+	Use:   "field",
+	Short: "Manage custom fields of a credential",
+	Long: `Manage additional named values of a credential such as recovery codes,
+security answers, PINs, API key ids or notes.
+
+Field types:
+  text     plain text, stored unencrypted like label and user
+  url      plain url, stored unencrypted
+  secret   encrypted secret value (default)
+  otp      encrypted otpauth:// uri, "get" copies the current code
+  note     encrypted free text`,
+}
+
+var fieldSetCmd = &cobra.Command{
+	Use:   "set <id> <name>",
+	Short: "Add or replace a field of a credential",
+	Example: `	kosh field set 12 recovery-codes
+	kosh field set --type url 12 login-page`,
+	Args: cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runFieldSet(id, args[1], model.FieldType(fieldType))
+	},
+}
+
+var fieldGetCmd = &cobra.Command{
+	Use:   "get <id> <name>",
+	Short: "Copy the value of a credential field",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runFieldGet(id, args[1])
+	},
+}
+
+var fieldRmCmd = &cobra.Command{
+	Use:   "rm <id> <name>",
+	Short: "Remove a field from a credential",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runFieldRm(id, args[1])
+	},
+}
+
+var fieldListCmd = &cobra.Command{
+	Use:   "list <id>",
+	Short: "Show the fields of a credential",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runFieldList(id)
+	},
+}
+
+func init() {
+	fieldSetCmd.Flags().StringVarP(&fieldType, "type", "t", string(model.FieldTypeSecret), "field type (text, secret, url, otp, note)")
+
+	fieldCmd.AddCommand(fieldSetCmd, fieldGetCmd, fieldRmCmd, fieldListCmd)
+	rootCmd.AddCommand(fieldCmd)
+}
+
+func runFieldSet(id int, name string, fieldType model.FieldType) error {
+	name = strings.TrimSpace(name)
+	if name == "" || !fieldType.IsValid() {
+		logger.Error("%s", constants.ErrInvalidFieldType.Error())
+		return nil
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	existing, err := store.GetCredentialField(id, name)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+	if existing != nil {
+		logger.Warn(constants.MsgOperationIsPermanent)
+		confirm, err := ui.ConfirmWithText(
+			fmt.Sprintf("%s %s", constants.MsgOverwriteField, constants.MsgAreYouSure),
+			fmt.Sprintf("overwrite %s", name),
+		)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+		if !confirm {
+			logger.Info(constants.MsgOperationAborted)
+			return nil
+		}
+	}
+
+	var value []byte
+	if fieldType.IsSensitive() {
+		value, err = ui.ReadSecretField(constants.MsgEnterFieldValue)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+		confirm, err := ui.ReadSecretField(constants.MsgConfirmFieldValue)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+		if subtle.ConstantTimeCompare(value, confirm) == 0 {
+			logger.Error("%s", constants.ErrSecretDoesNotMatch.Error())
+			return nil
+		}
+	} else {
+		text, err := ui.ReadStringField(constants.MsgEnterFieldValue)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+		value = []byte(text)
+	}
+
+	if err := vault.SetCredentialField(credential.Id, name, fieldType, value); err != nil {
+		logger.Error("%s", err.Error())
+		return nil
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info(constants.MsgSavedField)
+	return nil
+}
+
+func runFieldGet(id int, name string) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	if err := copyCredentialField(credential, name); err != nil {
+		return err
+	}
+
+	recordAccess(credential.Id, model.AccessViaField, "")
+	vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+	return nil
+}
+
+func runFieldRm(id int, name string) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	logger.Warn(constants.MsgOperationIsPermanent)
+	confirm, err := ui.ConfirmWithText(
+		fmt.Sprintf("delete field %s of %s (%s)? %s", name, credential.Label, credential.User, constants.MsgAreYouSure),
+		fmt.Sprintf("delete %s", name),
+	)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if !confirm {
+		logger.Info(constants.MsgOperationAborted)
+		return nil
+	}
+
+	err = store.DeleteCredentialField(credential.Id, name)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrFieldNotFound.Error())
+		return nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToDeleteCredential.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info(constants.MsgDeletedField)
+	return nil
+}
+
+func runFieldList(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	fields, err := store.GetCredentialFields(credential.Id)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	logger.Muted("fields of %s (%s)\n", credential.Label, credential.User)
+	if len(fields) == 0 {
+		logger.Warn("no fields found")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-8s %-40s %-20s\n", "NAME", "TYPE", "VALUE", "UPDATED AT")
+	fmt.Printf("%s\n", strings.Repeat("─", 95))
+	for _, field := range fields {
+		value := "********"
+		if !field.Type.IsSensitive() {
+			value = truncate(field.Value, 40)
+		}
+		updatedAt := field.UpdatedAt.Local().Format(time.DateTime)
+		fmt.Printf("%-24s %-8s %-40s %-20s\n", truncate(field.Name, 24), field.Type, value, updatedAt)
+	}
+	fmt.Println()
+	return nil
+}
+
+// copyCredentialField copies the value of a credential field to the clipboard, asking for the master
+// password when the field is encrypted. For otp fields the current code is copied instead of the uri.
+func copyCredentialField(credential *model.Credential, name string) error {
+	field, err := store.GetCredentialField(credential.Id, name)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrFieldNotFound.Error())
+		return nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	var password []byte
+	if field.Type.IsSensitive() {
+		password, err = ui.ReadSecretField(constants.MsgEnterMasterPassword)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+	}
+
+	value, err := vault.DecryptCredentialField(field, password)
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	switch field.Type {
+	case model.FieldTypeOTP:
+		key, err := otp.ParseURI(string(value))
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToGenerateOTP.Error())
+			return err
+		}
+		return copyOTPCode(key, func(uri string) error {
+			return vault.SetCredentialField(credential.Id, field.Name, field.Type, []byte(uri))
+		})
+	case model.FieldTypeText, model.FieldTypeURL:
+		fmt.Println(string(value))
+		ui.CopyToClipboard(value)
+		logger.Info(constants.MsgCopiedField)
+	default:
+		ui.CopyToClipboard(value)
+		logger.Info(constants.MsgCopiedField)
+	}
+	return nil
+}
+
+func getCredentialForField(id int) (*model.Credential, error) {
+	credential, err := store.GetCredentialById(id)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrCredentialNotFound.Error())
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return nil, err
+	}
+	return credential, nil
+}
+
+func parseCredentialId(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		logger.Error("%s", constants.ErrIdMustBeInteger.Error())
+		return 0, err
+	}
+	return id, nil
+}
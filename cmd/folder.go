@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var folderCmd = &cobra.Command{
+	Use:   "folder",
+	Short: "Organize credentials in folders",
+	Long: `Every credential lives in one folder, a "/" separated path like clients/acme.
+Filter by folder with "list --folder <path>", which includes sub-folders;
+search matches folder names as well.`,
+}
+
+var folderSetCmd = &cobra.Command{
+	Use:     "set <id> [path]",
+	Short:   "Move a credential to a folder, omit the path to move it to the root",
+	Example: `	kosh folder set 12 clients/acme`,
+	Args:    cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		var path string
+		if len(args) > 1 {
+			path = args[1]
+		}
+		return runFolderSet(id, path)
+	},
+}
+
+var folderListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show all folders with the number of credentials in them",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runFolderList()
+	},
+}
+
+func init() {
+	folderCmd.AddCommand(folderSetCmd, folderListCmd)
+	rootCmd.AddCommand(folderCmd)
+}
+
+func runFolderSet(id int, path string) error {
+	folder := model.NormalizeFolder(path)
+
+	err := store.SetCredentialFolder(id, folder)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrCredentialMatchNotFound.Error())
+		return nil
+	}
+	if err != nil {
+		logger.Error("unable to move credential")
+		return err
+	}
+	recordEvent(model.AuditEventUpdate, id)
+
+	if folder == "" {
+		folder = "/"
+	}
+	logger.Info("moved credential to %s", folder)
+	return nil
+}
+
+func runFolderList() error {
+	credentials, err := store.SearchCredentialByLabelOrUser("", "", nil, "")
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	// count every credential in its folder and all parent folders
+	counts := map[string]int{}
+	for _, credential := range credentials {
+		if credential.Folder == "" {
+			continue
+		}
+		segments := strings.Split(credential.Folder, "/")
+		for i := range segments {
+			counts[strings.Join(segments[:i+1], "/")]++
+		}
+	}
+
+	if len(counts) == 0 {
+		logger.Warn("no folders found")
+		logger.Info("move a credential with `folder set <id> <path>`")
+		return nil
+	}
+
+	fmt.Printf("%-40s %-6s\n", "FOLDER", "COUNT")
+	fmt.Printf("%s\n", strings.Repeat("─", 47))
+	for _, folder := range slices.Sorted(maps.Keys(counts)) {
+		depth := strings.Count(folder, "/")
+		name := strings.Repeat("  ", depth) + folder[strings.LastIndex(folder, "/")+1:] + "/"
+		fmt.Printf("%-40s %-6d\n", truncate(name, 40), counts[folder])
+	}
+	fmt.Println()
+	return nil
+}
@@ -1,22 +1,20 @@
 package cmd
 
 import (
-	"crypto/rand"
 	"fmt"
-	"math/big"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/generator"
 	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
 	"git.plutolab.org/plutolab/kosh/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-type CharGroup string
-type RequireConfig map[CharGroup]int
-
 var (
 	genLength  int
 	genUpper   bool
@@ -25,20 +23,59 @@ var (
 	genSymbol  bool
 	genRequire string
 	genNoSave  bool
+
+	genPassphrase bool
+	genWords      int
+	genSeparator  string
+	genCapitalize bool
+	genWordlist   string
+
+	genPattern          string
+	genExcludeAmbiguous bool
+	genSymbols          string
+	genNoRepeat         bool
+	genStartWith        string
+
+	genPolicy string
 )
 
-const (
-	LowerCharGroup  = "lower"
-	UpperCharGroup  = "upper"
-	DigitCharGroup  = "digit"
-	SymbolCharGroup = "symbol"
+// flags of the character group generator, which neither passphrases nor patterns use
+var characterGroupFlags = []string{"length", "require", "upper", "lower", "symbol"}
+
+// generatorFlags are all flags a policy stores
+var generatorFlags = append(slices.Clone(characterGroupFlags),
+	"digit", "exclude-ambiguous", "symbols", "no-repeat", "start-with", "pattern",
+	"passphrase", "words", "separator", "capitalize", "wordlist",
 )
 
 var generateCmd = &cobra.Command{
 	Use:   "generate <label> <user>",
 	Short: "Generate a strong password with specified restrictions",
 	Long: `Generate a strong random password and store it securely in the vault.
-The generated password is encrypted and copied to the clipboard.`,
+The generated password is encrypted and copied to the clipboard.
+
+With --passphrase, words are drawn at random from the EFF large wordlist, or
+from a --wordlist file with one word per line (diceware lists with the dice
+rolls in front of each word work too). --digit then appends a random digit to
+one of the words instead of selecting a character group.
+
+With --pattern, every character of the pattern is one position of the password:
+
+  c C  lower / upper consonant     a A  lower / upper letter     9  digit
+  v V  lower / upper vowel         L    any letter               @  symbol
+  #    letter or digit             *    any character            [..]  one of a set
+
+"{n}" repeats the position before it, "\" makes the next character literal and
+all other characters are literal, so "Cvcc-9{4}-@@" gives e.g. "Kafm-4071-#!".
+
+--exclude-ambiguous, --symbols, --no-repeat and --start-with apply to patterns
+and character groups alike. Candidates that repeat a character or start wrong
+are thrown away and drawn again, so every password allowed stays equally
+likely.
+
+--policy generates with the options of a policy saved by "kosh policy add" and
+links the credential to it. Generating again for a linked credential without
+any generator flags uses its policy.`,
 
 	Example: `	Generate a default password:
 	kosh generate github alice
@@ -47,7 +84,20 @@ The generated password is encrypted and copied to the clipboard.`,
     	kosh generate -l 32 --require "upper=2,lower=10,digit=5,symbol=3" email alice
 
 	Generate a password without symbols:
-    	kosh generate --symbol=false server root`,
+    	kosh generate --symbol=false server root
+
+	Generate a six word passphrase like "Unfasten-Spoof7-Mousy-Gap-Crisping-Ivory":
+    	kosh generate --passphrase --words 6 --separator - --capitalize --digit laptop alice
+
+	Generate for a site that wants exactly 8 characters starting with a letter, only !#$ symbols
+	and no repeated characters:
+    	kosh generate -l 8 --start-with letter --symbols '!#$' --no-repeat bank alice
+
+	Generate from a pattern:
+    	kosh generate --pattern 'Cvcc-9999-@@' --exclude-ambiguous router admin
+
+	Generate with a saved policy:
+    	kosh generate --policy corp-ad intranet alice`,
 
 	Args: cobra.RangeArgs(0, 2),
 
@@ -57,45 +107,79 @@ The generated password is encrypted and copied to the clipboard.`,
 			return fmt.Errorf("wrong arguments got %d, want 2 (unless --no-save is used)", len(args))
 		}
 
-		return runGenerate(args...)
+		var label, user string
+		if len(args) == 2 {
+			label, user = args[0], args[1]
+		}
+		policy, err := selectPolicy(cmd, label, user)
+		if policy == nil {
+			return err
+		}
+		return runGenerate(policy, args...)
 	},
 }
 
 func init() {
-	generateCmd.Flags().IntVarP(&genLength, "length", "l", 20, "length of the password")
-	generateCmd.Flags().BoolVar(&genUpper, "upper", true, "include uppercase letters")
-	generateCmd.Flags().BoolVar(&genLower, "lower", true, "include uppercase letters")
-	generateCmd.Flags().BoolVar(&genDigit, "digit", true, "include digits")
-	generateCmd.Flags().BoolVar(&genSymbol, "symbol", true, "include special symbols")
-	generateCmd.Flags().StringVarP(&genRequire, "require", "r", "", "password requirements (e.g., upper=2,digit=3)")
+	addGeneratorFlags(generateCmd)
 	generateCmd.Flags().BoolVarP(&genNoSave, "no-save", "n", false, "generate password but do not save it")
+	generateCmd.Flags().StringVar(&genPolicy, "policy", "", "generate with a saved policy and link the credential to it")
 
 	rootCmd.AddCommand(generateCmd)
 }
 
-func runGenerate(args ...string) error {
+// addGeneratorFlags registers the flags describing how to generate, shared by generate and policy add
+func addGeneratorFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&genLength, "length", "l", 20, "length of the password")
+	cmd.Flags().BoolVar(&genUpper, "upper", true, "include uppercase letters")
+	cmd.Flags().BoolVar(&genLower, "lower", true, "include uppercase letters")
+	cmd.Flags().BoolVar(&genDigit, "digit", true, "include digits")
+	cmd.Flags().BoolVar(&genSymbol, "symbol", true, "include special symbols")
+	cmd.Flags().StringVarP(&genRequire, "require", "r", "", "password requirements (e.g., upper=2,digit=3)")
+	cmd.Flags().BoolVar(&genPassphrase, "passphrase", false, "generate a passphrase of random words")
+	cmd.Flags().IntVar(&genWords, "words", 6, "number of words in the passphrase")
+	cmd.Flags().StringVar(&genSeparator, "separator", "-", "text between the words of the passphrase")
+	cmd.Flags().BoolVar(&genCapitalize, "capitalize", false, "capitalize every word of the passphrase")
+	cmd.Flags().StringVar(&genWordlist, "wordlist", "", "wordlist file for the passphrase (default EFF large wordlist)")
+	cmd.Flags().StringVarP(&genPattern, "pattern", "p", "", "generate from a pattern like Cvcc-9999-@@")
+	cmd.Flags().BoolVar(&genExcludeAmbiguous, "exclude-ambiguous", false, "leave out look-alike characters (Il1|O0o)")
+	cmd.Flags().StringVar(&genSymbols, "symbols", "", "symbols to draw from instead of the default set")
+	cmd.Flags().BoolVar(&genNoRepeat, "no-repeat", false, "use every character at most once")
+	cmd.Flags().StringVar(&genStartWith, "start-with", "", "group of the first character: letter, upper, lower, digit or symbol")
+}
 
-	requirement, err := parseRequirement(genUpper, genLower, genDigit, genSymbol, genRequire)
-	if err != nil {
-		logger.Error("invalid `require` flag values")
-		return err
+// selectPolicy picks how to generate from the flags: the policy named by --policy, the policy linked
+// to the credential with label and user when no generator flag is set, or else the generator flags.
+// Returns nil after logging when the flags are invalid or the user declines.
+func selectPolicy(cmd *cobra.Command, label, user string) (*model.Policy, error) {
+	if genPolicy != "" {
+		for _, name := range generatorFlags {
+			if cmd.Flags().Changed(name) {
+				logger.Error("%s: --%s cannot be combined with --policy", constants.ErrInvalidArguments.Error(), name)
+				return nil, nil
+			}
+		}
+		return getPolicy(genPolicy)
 	}
 
-	// check length and get confirmation
-	requiredLength := 0
-	for key, value := range requirement {
-		validKey := slices.Contains(
-			[]CharGroup{LowerCharGroup, UpperCharGroup, DigitCharGroup, SymbolCharGroup},
-			key,
-		)
-
-		if validKey {
-			requiredLength += value
+	// a credential linked to a policy is regenerated with it unless told otherwise
+	if label != "" && !slices.ContainsFunc(generatorFlags, cmd.Flags().Changed) {
+		policy, err := getLinkedPolicy(label, user)
+		if err != nil {
+			return nil, err
+		}
+		if policy != nil {
+			logger.Muted("generating with policy %s", policy.Name)
+			return policy, nil
 		}
 	}
 
-	if requiredLength > genLength {
-		logger.Warn("required length (%d characters) is greater than password length (%d characters)", requiredLength, genLength)
+	policy := policyFromFlags(cmd)
+	if policy == nil {
+		return nil, nil
+	}
+
+	if required := requiredLength(policy); required > policy.Length {
+		logger.Warn("required length (%d characters) is greater than password length (%d characters)", required, policy.Length)
 		confirm, err := ui.ConfirmYesNo(
 			"generate password with the required length?",
 			false,
@@ -103,22 +187,29 @@ func runGenerate(args ...string) error {
 
 		if err != nil {
 			logger.Error("%s", err.Error())
-			return err
+			return nil, err
 		}
 
 		if !confirm {
 			logger.Info(constants.MsgOperationAborted)
-			return nil
+			return nil, nil
 		}
 
-		genLength = requiredLength
+		policy.Length = required
 	}
+	return policy, nil
+}
 
-	generatedSecret, err := generatePassword(genLength, genUpper, genLower, genDigit, genSymbol, requirement)
+// runGenerate generates a secret the way policy asks for and saves it unless --no-save is set. A
+// credential saved with a stored policy is linked to it.
+func runGenerate(policy *model.Policy, args ...string) error {
+	generatedSecret, err := generateFromPolicy(policy)
 	if err != nil {
-		logger.Error("unable to generate credential")
-		return err
+		logger.Error("unable to generate credential: %s", err.Error())
+		return nil
 	}
+	warnIfBreached(generatedSecret)
+	reportStrength(generatedSecret, args...)
 
 	// In case `--no-save` copy the password to clipboard, no need to fetch vault data or verify password
 	if genNoSave {
@@ -129,6 +220,9 @@ func runGenerate(args ...string) error {
 
 	label := args[0]
 	user := args[1]
+	if refuseIfTrashed(label, user) {
+		return nil
+	}
 
 	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
 	if err != nil {
@@ -140,18 +234,208 @@ func runGenerate(args ...string) error {
 		return err
 	}
 
+	_, err = store.GetCredentialByLabelAndUser(label, user)
+	existed := err == nil
+
 	err = vault.AddCredential(label, user, generatedSecret)
 	if err != nil {
 		logger.Debug("runGenerate:failed to add generated credential:%s", err.Error())
 		return err
 	}
+	vault.RecordAdded(label, user, existed)
+
+	if policy.Id != 0 {
+		credential, err := store.GetCredentialByLabelAndUser(label, user)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+			return err
+		}
+		return linkPolicy(credential, policy)
+	}
+
+	return nil
+}
 
+// linkPolicy links a credential to the stored policy it was generated with
+func linkPolicy(credential *model.Credential, policy *model.Policy) error {
+	if err := store.SetCredentialPolicy(credential.Id, policy.Id); err != nil {
+		logger.Error("unable to link %s (%s) to policy %s", credential.Label, credential.User, policy.Name)
+		return err
+	}
 	return nil
 }
 
-func parseRequirement(upper, lower, digit, symbol bool, requireStr string) (RequireConfig, error) {
+// policyFromFlags collects the generator flags into an unsaved policy, nil after logging when they
+// contradict each other
+func policyFromFlags(cmd *cobra.Command) *model.Policy {
+	if genPassphrase && genPattern != "" {
+		logger.Error("%s: --passphrase and --pattern cannot be combined", constants.ErrInvalidArguments.Error())
+		return nil
+	}
+
+	policy := &model.Policy{
+		Length:           genLength,
+		Upper:            genUpper,
+		Lower:            genLower,
+		Digit:            genDigit,
+		Symbol:           genSymbol,
+		Require:          genRequire,
+		ExcludeAmbiguous: genExcludeAmbiguous,
+		Symbols:          genSymbols,
+		NoRepeat:         genNoRepeat,
+		StartWith:        genStartWith,
+	}
+
+	if genPassphrase {
+		for _, name := range append(characterGroupFlags, "exclude-ambiguous", "symbols", "no-repeat", "start-with") {
+			if cmd.Flags().Changed(name) {
+				logger.Error("%s: --%s does not apply to passphrases", constants.ErrInvalidArguments.Error(), name)
+				return nil
+			}
+		}
+		if genWords <= 0 {
+			logger.Error("%s: --words must be at least 1", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+		wordlist := genWordlist
+		if wordlist != "" {
+			// a policy may be used from another directory
+			var err error
+			if wordlist, err = filepath.Abs(wordlist); err != nil {
+				logger.Error("%s: %s", constants.ErrInvalidArguments.Error(), err.Error())
+				return nil
+			}
+		}
+		// --digit defaults to true for character passwords, a passphrase only gets one when asked
+		return &model.Policy{
+			Digit:      cmd.Flags().Changed("digit") && genDigit,
+			Passphrase: true,
+			Words:      genWords,
+			Separator:  genSeparator,
+			Capitalize: genCapitalize,
+			Wordlist:   wordlist,
+		}
+	}
+
+	if genPattern != "" {
+		for _, name := range append(characterGroupFlags, "digit") {
+			if cmd.Flags().Changed(name) {
+				logger.Error("%s: --%s does not apply to patterns", constants.ErrInvalidArguments.Error(), name)
+				return nil
+			}
+		}
+		if _, err := generator.ParsePattern(genPattern); err != nil {
+			logger.Error("%s: %s", constants.ErrInvalidArguments.Error(), err.Error())
+			return nil
+		}
+		policy.Length, policy.Upper, policy.Lower, policy.Digit, policy.Symbol, policy.Require = 0, false, false, false, false, ""
+		policy.Pattern = genPattern
+		return policy
+	}
+
+	if _, err := parseRequirement(policy.Upper, policy.Lower, policy.Digit, policy.Symbol, policy.Require); err != nil {
+		logger.Error("invalid `require` flag values")
+		return nil
+	}
+	return policy
+}
+
+// requiredLength is the number of characters the require counts of a character group policy add
+// up to
+func requiredLength(policy *model.Policy) int {
+	if policy.Passphrase || policy.Pattern != "" {
+		return 0
+	}
+	requirement, err := parseRequirement(policy.Upper, policy.Lower, policy.Digit, policy.Symbol, policy.Require)
+	if err != nil {
+		return 0
+	}
+
+	required := 0
+	for key, value := range requirement {
+		validKey := slices.Contains(
+			[]generator.CharGroup{generator.LowerCharGroup, generator.UpperCharGroup, generator.DigitCharGroup, generator.SymbolCharGroup},
+			key,
+		)
+
+		if validKey {
+			required += value
+		}
+	}
+	return required
+}
+
+// generateFromPolicy generates a password, pattern password or passphrase as the policy says, and
+// shows the entropy of patterns and passphrases
+func generateFromPolicy(policy *model.Policy) ([]byte, error) {
+	constraints := generator.Constraints{
+		ExcludeAmbiguous: policy.ExcludeAmbiguous,
+		Symbols:          policy.Symbols,
+		NoRepeat:         policy.NoRepeat,
+		StartWith:        generator.CharGroup(policy.StartWith),
+	}
+
+	switch {
+	case policy.Passphrase:
+		return generatePassphrase(policy.Wordlist, generator.PassphraseOptions{
+			Words:      policy.Words,
+			Separator:  policy.Separator,
+			Capitalize: policy.Capitalize,
+			Digit:      policy.Digit,
+		})
+
+	case policy.Pattern != "":
+		return generateFromPattern(policy.Pattern, constraints)
+	}
+
+	requirement, err := parseRequirement(policy.Upper, policy.Lower, policy.Digit, policy.Symbol, policy.Require)
+	if err != nil {
+		return nil, err
+	}
+	return generator.ConstrainedPassword(policy.Length, policy.Upper, policy.Lower, policy.Digit, policy.Symbol, requirement, constraints)
+}
+
+// generateFromPattern generates a password from a pattern and shows its entropy
+func generateFromPattern(text string, constraints generator.Constraints) ([]byte, error) {
+	pattern, err := generator.ParsePattern(text)
+	if err != nil {
+		return nil, err
+	}
+	bits, err := pattern.Entropy(constraints)
+	if err != nil {
+		return nil, err
+	}
+
+	generatedSecret, err := pattern.Generate(constraints)
+	if err != nil {
+		return nil, err
+	}
+	logger.Muted("pattern entropy: %.0f bits", bits)
+	return generatedSecret, nil
+}
+
+// generatePassphrase draws a passphrase from a wordlist file, or the EFF large wordlist when path is
+// empty, and shows its entropy
+func generatePassphrase(path string, options generator.PassphraseOptions) ([]byte, error) {
+	wordlist := generator.EFFLargeWordlist()
+	if path != "" {
+		var err error
+		if wordlist, err = generator.LoadWordlist(path); err != nil {
+			return nil, fmt.Errorf("unable to read wordlist: %w", err)
+		}
+	}
+
+	passphrase, err := generator.Passphrase(wordlist, options)
+	if err != nil {
+		return nil, err
+	}
+	logger.Muted("passphrase entropy: %.0f bits, %d words from a list of %d", generator.PassphraseEntropy(len(wordlist), options), options.Words, len(wordlist))
+	return passphrase, nil
+}
+
+func parseRequirement(upper, lower, digit, symbol bool, requireStr string) (generator.RequireConfig, error) {
 	requireList := strings.Split(requireStr, ",")
-	requirement := make(map[CharGroup]int)
+	requirement := make(generator.RequireConfig)
 
 	if strings.TrimSpace(requireStr) == "" {
 		return requirement, nil
@@ -163,7 +447,7 @@ func parseRequirement(upper, lower, digit, symbol bool, requireStr string) (Requ
 			logger.Error("invalid requirement field %s", param)
 			return nil, fmt.Errorf("invalid requirement field %s", param)
 		}
-		group := CharGroup(strings.TrimSpace(fields[0]))
+		group := generator.CharGroup(strings.TrimSpace(fields[0]))
 		str := strings.TrimSpace(fields[1])
 
 		val, err := strconv.Atoi(str)
@@ -174,19 +458,19 @@ func parseRequirement(upper, lower, digit, symbol bool, requireStr string) (Requ
 
 		var errMsg string
 		switch group {
-		case LowerCharGroup:
+		case generator.LowerCharGroup:
 			if !lower && val > 0 {
 				errMsg = "lowercase letters not allowed but required"
 			}
-		case UpperCharGroup:
+		case generator.UpperCharGroup:
 			if !upper && val > 0 {
 				errMsg = "uppercase letters not allowed but required"
 			}
-		case DigitCharGroup:
+		case generator.DigitCharGroup:
 			if !digit && val > 0 {
 				errMsg = "digits not allowed but required"
 			}
-		case SymbolCharGroup:
+		case generator.SymbolCharGroup:
 			if !symbol && val > 0 {
 				errMsg = "symbols not allowed but required"
 			}
@@ -204,96 +488,3 @@ func parseRequirement(upper, lower, digit, symbol bool, requireStr string) (Requ
 
 	return requirement, nil
 }
-
-func generatePassword(length int, upper, lower, digit, symbol bool, require RequireConfig) ([]byte, error) {
-	var (
-		lowerChars  = "abcdefghijklmnopqrstuvwxyz"
-		upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-		digitChars  = "0123456789"
-		symbolChars = "!@#$%^&*()-_=+[]{}<>?/|"
-	)
-
-	var password []byte
-	var pool string
-
-	// required characters
-	addRequired := func(group CharGroup, chars string) error {
-		count := require[group]
-		for range count {
-			c, err := randomChar(chars)
-			if err != nil {
-				return err
-			}
-			password = append(password, c)
-		}
-		return nil
-	}
-
-	if lower {
-		pool += lowerChars
-		if err := addRequired(LowerCharGroup, lowerChars); err != nil {
-			return nil, err
-		}
-	}
-
-	if upper {
-		pool += upperChars
-		if err := addRequired(UpperCharGroup, upperChars); err != nil {
-			return nil, err
-		}
-	}
-
-	if digit {
-		pool += digitChars
-		if err := addRequired(DigitCharGroup, digitChars); err != nil {
-			return nil, err
-		}
-	}
-
-	if symbol {
-		pool += symbolChars
-		if err := addRequired(SymbolCharGroup, symbolChars); err != nil {
-			return nil, err
-		}
-	}
-
-	// fill remaining length
-	for len(password) < length {
-		c, err := randomChar(pool)
-		if err != nil {
-			return nil, err
-		}
-		password = append(password, c)
-	}
-
-	// shuffle everything
-	for i := len(password) - 1; i > 0; i-- {
-		j, err := randomInt(i + 1)
-		if err != nil {
-			return nil, err
-		}
-		password[i], password[j] = password[j], password[i]
-	}
-
-	return password, nil
-}
-
-func randomInt(max int) (int, error) {
-	if max <= 0 {
-		return 0, fmt.Errorf("max must be greater than 0")
-	}
-	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
-	if err != nil {
-		// extremely rare: crypto/rand failure (system entropy issue)
-		return 0, err
-	}
-	return int(n.Int64()), nil
-}
-
-func randomChar(chars string) (byte, error) {
-	i, err := randomInt(len(chars))
-	if err != nil {
-		return 0, err
-	}
-	return chars[i], nil
-}
@@ -1,75 +1,10 @@
 package cmd
 
 import (
-	"strings"
 	"testing"
-)
-
-func TestRandomInt(t *testing.T) {
-
-	t.Run("normal range", func(t *testing.T) {
-		n, err := randomInt(10)
-		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
-		}
-		if n < 0 || n >= 10 {
-			t.Errorf("randomInt(10) = %d, want between 0-9", n)
-		}
-	})
-
-	t.Run("edge case zero or negative", func(t *testing.T) {
-		_, err := randomInt(0)
-		if err == nil {
-			t.Errorf("expected error for max=0, got nil")
-		}
-
-		_, err = randomInt(-5)
-		if err == nil {
-			t.Errorf("expected error for max=-5, got nil")
-		}
-	})
-
-	t.Run("edge case max=1", func(t *testing.T) {
-		n, err := randomInt(1)
-		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
-		}
-
-		if n != 0 {
-			t.Errorf("randomInt(1)=%d, want 0", n)
-		}
-	})
-}
-
-func TestRandomChar(t *testing.T) {
-	t.Run("normal string", func(t *testing.T) {
-		c, err := randomChar("abc")
-		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
-		}
-
-		if !strings.Contains("abc", string(c)) {
-			t.Errorf("randomChar(abc)=%s, want a, b or c", string(c))
-		}
-	})
 
-	t.Run("edge case empty string", func(t *testing.T) {
-		_, err := randomChar("")
-		if err == nil {
-			t.Errorf("expected an error, but got nil")
-		}
-	})
-
-	t.Run("edge case single character", func(t *testing.T) {
-		c, err := randomChar("a")
-		if err != nil {
-			t.Fatalf("unexpected error: %v", err)
-		}
-		if string(c) != "a" {
-			t.Errorf("randomChar(a)=%s, want a", string(c))
-		}
-	})
-}
+	"git.plutolab.org/plutolab/kosh/internal/generator"
+)
 
 func TestParseRequirement(t *testing.T) {
 	tests := []struct {
@@ -79,7 +14,7 @@ func TestParseRequirement(t *testing.T) {
 		digit       bool
 		symbol      bool
 		requireStr  string
-		want        RequireConfig
+		want        generator.RequireConfig
 		expectError bool
 	}{
 		{
@@ -89,7 +24,7 @@ func TestParseRequirement(t *testing.T) {
 			digit:      true,
 			symbol:     true,
 			requireStr: "",
-			want:       RequireConfig{},
+			want:       generator.RequireConfig{},
 		},
 		{
 			name:       "single requirement",
@@ -98,8 +33,8 @@ func TestParseRequirement(t *testing.T) {
 			digit:      true,
 			symbol:     true,
 			requireStr: "upper=2",
-			want: RequireConfig{
-				UpperCharGroup: 2,
+			want: generator.RequireConfig{
+				generator.UpperCharGroup: 2,
 			},
 		},
 		{
@@ -109,11 +44,11 @@ func TestParseRequirement(t *testing.T) {
 			digit:      true,
 			symbol:     true,
 			requireStr: "upper=1,lower=2,symbol=2,digit=3",
-			want: RequireConfig{
-				UpperCharGroup:  1,
-				LowerCharGroup:  2,
-				SymbolCharGroup: 2,
-				DigitCharGroup:  3,
+			want: generator.RequireConfig{
+				generator.UpperCharGroup:  1,
+				generator.LowerCharGroup:  2,
+				generator.SymbolCharGroup: 2,
+				generator.DigitCharGroup:  3,
 			},
 		},
 		{
@@ -123,9 +58,9 @@ func TestParseRequirement(t *testing.T) {
 			digit:      true,
 			symbol:     true,
 			requireStr: "upper=0,lower=0",
-			want: RequireConfig{
-				UpperCharGroup: 0,
-				LowerCharGroup: 0,
+			want: generator.RequireConfig{
+				generator.UpperCharGroup: 0,
+				generator.LowerCharGroup: 0,
 			},
 		},
 		{
@@ -180,8 +115,8 @@ func TestParseRequirement(t *testing.T) {
 			digit:      true,
 			symbol:     true,
 			requireStr: "foo=2",
-			want: RequireConfig{
-				CharGroup("foo"): 2,
+			want: generator.RequireConfig{
+				generator.CharGroup("foo"): 2,
 			},
 		},
 		{
@@ -191,8 +126,8 @@ func TestParseRequirement(t *testing.T) {
 			digit:      true,
 			symbol:     true,
 			requireStr: "upper=1,upper=3",
-			want: RequireConfig{
-				UpperCharGroup: 3,
+			want: generator.RequireConfig{
+				generator.UpperCharGroup: 3,
 			},
 		},
 	}
@@ -224,7 +159,7 @@ func TestParseRequirement(t *testing.T) {
 }
 
 // Helpers
-func requirementEqual(t *testing.T, got, want RequireConfig) {
+func requirementEqual(t *testing.T, got, want generator.RequireConfig) {
 	t.Helper()
 
 	if len(got) != len(want) {
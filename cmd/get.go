@@ -2,14 +2,16 @@ package cmd
 
 import (
 	"database/sql"
-	"time"
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
 	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
 	"git.plutolab.org/plutolab/kosh/internal/ui"
 	"github.com/spf13/cobra"
 )
 
+var getField string
+
 var getCmd = &cobra.Command{
 	Use:   "get <label> <user>",
 	Short: "Retrieve credential by exact label and user",
@@ -21,6 +23,8 @@ var getCmd = &cobra.Command{
 }
 
 func init() {
+	getCmd.Flags().StringVarP(&getField, "field", "f", "", "copy the named custom field instead of the secret")
+
 	rootCmd.AddCommand(getCmd)
 }
 
@@ -37,6 +41,15 @@ func runGet(desiredGroup string, desiredUser string) error {
 		return err
 	}
 
+	if getField != "" {
+		if err := copyCredentialField(credential, getField); err != nil {
+			return err
+		}
+		recordAccess(credential.Id, model.AccessViaField, "")
+		vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+		return nil
+	}
+
 	// get password from user
 	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
 	if err != nil {
@@ -51,10 +64,12 @@ func runGet(desiredGroup string, desiredUser string) error {
 
 	ui.CopyToClipboard([]byte(secret))
 	logger.Info(constants.MsgCopiedCredential)
+	warnIfOverdue(credential)
 	
 	// on successful access update the access info for the credential,
-	// increment access count by 2 on get because it has been fetched
-	// with intention meaning that user might be wanting this more
-	store.UpdateCredentialAccessCount(credential.Id, 2, time.Now())
+	// a get counts double because it has been fetched with intention
+	// meaning that user might be wanting this more
+	recordAccess(credential.Id, model.AccessViaGet, "")
+	vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
 	return nil
 }
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <id>",
+	Short: "Show the previous secrets of a credential",
+	Long: `Every time the secret of a credential is replaced, by update, generate or by
+adding a credential with the same label and user, the previous secret is kept
+encrypted as a numbered version. Old versions are pruned according to the
+history.max_versions and history.max_age_days settings.`,
+	Example: `	kosh history 12
+	kosh history show 12 3
+	kosh history restore 12 3`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runHistory(id)
+	},
+}
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <id> <version>",
+	Short: "Copy a previous secret to the clipboard",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, version, err := parseVersionArgs(args)
+		if err != nil {
+			return err
+		}
+		return runHistoryShow(id, version)
+	},
+}
+
+var historyRestoreCmd = &cobra.Command{
+	Use:   "restore <id> <version>",
+	Short: "Make a previous secret the current secret again",
+	Args:  cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, version, err := parseVersionArgs(args)
+		if err != nil {
+			return err
+		}
+		return runHistoryRestore(id, version)
+	},
+}
+
+func init() {
+	historyCmd.AddCommand(historyShowCmd, historyRestoreCmd)
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	versions, err := store.GetCredentialHistory(credential.Id)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	logger.Muted("history of %s (%s)\n", credential.Label, credential.User)
+	if pending, err := store.GetPendingSecret(credential.Id); err == nil {
+		logger.Warn("a new secret is pending since %s, see `rotate %d`", pending.CreatedAt.Local().Format(time.DateTime), credential.Id)
+	}
+	if len(versions) == 0 {
+		logger.Warn("no previous secrets found")
+		return nil
+	}
+
+	fmt.Printf("%-8s %-20s\n", "VERSION", "REPLACED AT")
+	fmt.Printf("%s\n", strings.Repeat("─", 30))
+	for _, version := range versions {
+		fmt.Printf("%-8d %-20s\n", version.Version, version.ReplacedAt.Local().Format(time.DateTime))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runHistoryShow(id, number int) error {
+	version, err := getCredentialVersion(id, number)
+	if version == nil {
+		return err
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	secret, err := vault.DecryptCredentialVersion(version, password)
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	ui.CopyToClipboard([]byte(secret))
+	recordEvent(model.AuditEventRead, id)
+	logger.Info(constants.MsgCopiedVersion)
+	return nil
+}
+
+func runHistoryRestore(id, number int) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	version, err := getCredentialVersion(id, number)
+	if version == nil {
+		return err
+	}
+
+	confirm, err := ui.ConfirmWithText(
+		fmt.Sprintf("replace the current secret with version %d? %s", version.Version, constants.MsgAreYouSure),
+		fmt.Sprintf("restore %d", version.Version),
+	)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if !confirm {
+		logger.Info(constants.MsgOperationAborted)
+		return nil
+	}
+
+	if err := vault.RestoreCredentialVersion(version); err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+	recordEvent(model.AuditEventUpdate, id)
+	logger.Info(constants.MsgRestoredVersion)
+	logger.Muted("the replaced secret was kept in history, see `history %d`", id)
+	return nil
+}
+
+// getCredentialVersion fetches a previous secret, logging and returning nil when it does not exist
+func getCredentialVersion(id, number int) (*model.CredentialVersion, error) {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return nil, err
+	}
+
+	version, err := store.GetCredentialVersion(credential.Id, number)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrVersionNotFound.Error())
+		logger.Info("list versions with `history %d`", credential.Id)
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return nil, err
+	}
+	return version, nil
+}
+
+func parseVersionArgs(args []string) (int, int, error) {
+	id, err := parseCredentialId(args[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	version, err := strconv.Atoi(args[1])
+	if err != nil || version <= 0 {
+		logger.Error("invalid version %q", args[1])
+		return 0, 0, fmt.Errorf("invalid version %q", args[1])
+	}
+	return id, version, nil
+}
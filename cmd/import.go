@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/otp"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ImportFormatOTPAuth = "otpauth"
+)
+
+var (
+	importFormat    string
+	importOverwrite bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import credentials from a file",
+	Long: `Import credentials from a file.
+
+Formats:
+  otpauth   one uri per line, either otpauth:// uris or Google Authenticator
+            otpauth-migration://offline?data=... export payloads. Each seed is
+            attached to the credential matching issuer (label) and account (user),
+            the credential is created when it does not exist yet.`,
+	Example: `	kosh import --format otpauth exported-uris.txt`,
+	Args:    cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch importFormat {
+		case ImportFormatOTPAuth:
+			return runImportOTPAuth(args[0])
+		default:
+			logger.Error("%s", constants.ErrUnsupportedImportFormat.Error())
+			return fmt.Errorf("unsupported format %q", importFormat)
+		}
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", ImportFormatOTPAuth, "format of the imported file (otpauth)")
+	importCmd.Flags().BoolVar(&importOverwrite, "overwrite", false, "replace one-time passwords already attached to a credential")
+
+	rootCmd.AddCommand(importCmd)
+}
+
+func runImportOTPAuth(path string) error {
+	keys, err := readOTPAuthFile(path)
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+	if len(keys) == 0 {
+		logger.Warn("no one-time passwords found in %s", path)
+		return nil
+	}
+	logger.Info("found %d one-time password/s", len(keys))
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	credentials, err := store.GetAllCredentials()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	// issuers are usually capitalized ("GitHub") while labels are whatever the user typed, match
+	// existing credentials ignoring case so an import doesn't create near-duplicates
+	existing := make(map[string]*model.Credential, len(credentials))
+	for i := range credentials {
+		existing[importKey(credentials[i].Label, credentials[i].User)] = &credentials[i]
+	}
+
+	imported, skipped := 0, 0
+	for _, key := range keys {
+		label, user := key.Issuer, key.Account
+		if label == "" {
+			label, user = key.Account, ""
+		}
+
+		if label == "" || isKnownCommand(label) {
+			logger.Warn("skipped %s (%s): invalid label", label, user)
+			skipped++
+			continue
+		}
+
+		if credential, ok := existing[importKey(label, user)]; ok {
+			label, user = credential.Label, credential.User
+		}
+
+		if err := importOTPKey(label, user, key); err != nil {
+			logger.Warn("skipped %s (%s): %s", label, user, err.Error())
+			skipped++
+			continue
+		}
+
+		logger.Muted("imported %s (%s)", label, user)
+		imported++
+	}
+
+	logger.Info("imported %d one-time password/s, skipped %d", imported, skipped)
+	return nil
+}
+
+func importKey(label, user string) string {
+	return strings.ToLower(label) + "\x00" + strings.ToLower(user)
+}
+
+// importOTPKey attaches a key to the credential with the given label and user, creating an empty
+// credential first when none exists.
+func importOTPKey(label, user string, key *otp.Key) error {
+	credential, err := store.GetCredentialByLabelAndUser(label, user)
+	if err != nil && err != sql.ErrNoRows {
+		return constants.ErrFailedToFetchCredential
+	}
+
+	if credential == nil {
+		if err := vault.AddCredential(label, user, nil); err != nil {
+			return err
+		}
+		if credential, err = store.GetCredentialByLabelAndUser(label, user); err != nil {
+			return constants.ErrFailedToFetchCredential
+		}
+		vault.RecordEvent(model.AuditEventAdd, credential.Id, credential.Label, credential.User)
+	} else if credential.HasOTP() && !importOverwrite {
+		return constants.ErrCredentialAlreadyHasOTP
+	}
+
+	if err := vault.SetCredentialOTP(credential.Id, key.URI()); err != nil {
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	return nil
+}
+
+// readOTPAuthFile parses every otpauth:// and otpauth-migration:// uri in a file. Blank lines and
+// lines starting with '#' are ignored. Nothing is imported if any line fails to parse.
+func readOTPAuthFile(path string) ([]*otp.Key, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []*otp.Key
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // migration payloads can get long
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "otpauth-migration:") {
+			batch, err := otp.ParseMigrationURI(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			keys = append(keys, batch...)
+			continue
+		}
+
+		key, err := otp.ParseURI(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, scanner.Err()
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var integrityAccept bool
+
+var integrityCmd = &cobra.Command{
+	Use:   "integrity",
+	Short: "Check the vault file for changes made outside kosh",
+	Long: `Every credential row is sealed with a MAC keyed from the vault private key,
+and the seal carries a counter that grows with every change kosh makes. On
+each unlock the rows are checked against the seal, so credentials added,
+altered or removed by editing the vault file, or an older copy of the file put
+in its place, are reported.
+
+Once the changes are understood, e.g. after restoring a backup on purpose,
+--accept seals the vault file as it is now.`,
+	Example: `	kosh integrity
+	kosh integrity --accept`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIntegrity(integrityAccept)
+	},
+}
+
+func init() {
+	integrityCmd.Flags().BoolVar(&integrityAccept, "accept", false, "seal the vault file as it is now")
+	rootCmd.AddCommand(integrityCmd)
+}
+
+func runIntegrity(accept bool) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	// the report is shown below, not by the unlock
+	vault.SetIntegrityReporter(nil)
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	report := vault.IntegrityReport()
+	if report == nil {
+		logger.Error("unable to check vault integrity")
+		return nil
+	}
+
+	if report.OK() {
+		logger.Info(constants.MsgVaultIntact)
+		logger.Muted("sealed with counter %d", report.Counter)
+		return nil
+	}
+
+	if !accept {
+		reportIntegrity(report)
+		// like `kosh log`, scripts notice without parsing the output
+		store.CloseStore()
+		os.Exit(1)
+	}
+
+	if _, err := vault.AcceptIntegrity(); err != nil {
+		logger.Error("unable to seal vault file")
+		logger.Debug("%s", err.Error())
+		return err
+	}
+	logger.Info(constants.MsgAcceptedIntegrity)
+	return nil
+}
+
+// reportIntegrity warns about a vault file that failed its integrity check on unlock
+func reportIntegrity(report *model.IntegrityReport) {
+	logger.Error("%s", constants.ErrVaultTampered.Error())
+	if report.RolledBack {
+		logger.Muted("  older copy of the vault file: counter %d is lower than %d seen last", report.Counter, report.KnownCounter)
+	}
+	if report.RecordInvalid {
+		logger.Muted("  the integrity record itself was altered or removed")
+	}
+	for _, credential := range report.Added {
+		logger.Muted("  added:    #%d %s (%s)", credential.CredentialId, credential.Label, credential.User)
+	}
+	for _, credential := range report.Modified {
+		logger.Muted("  modified: #%d %s (%s)", credential.CredentialId, credential.Label, credential.User)
+	}
+	for _, credential := range report.Removed {
+		logger.Muted("  removed:  #%d %s (%s)", credential.CredentialId, credential.Label, credential.User)
+	}
+	logger.Muted("changes are not sealed until accepted with `kosh integrity --accept`")
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"cmp"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,50 +15,102 @@ import (
 )
 
 var (
-	listLabel string
-	listUser  string
+	listLabel  string
+	listUser   string
+	listTags   []string
+	listFolder string
+	listSort   string
+	listRev    bool
 )
 
+// listSortKeys orders credentials for `list --sort`, times most recent first
+var listSortKeys = map[string]func(a, b model.CredentialSummary) int{
+	"id":       func(a, b model.CredentialSummary) int { return cmp.Compare(a.Id, b.Id) },
+	"label":    func(a, b model.CredentialSummary) int { return compareFold(a.Label, b.Label) },
+	"user":     func(a, b model.CredentialSummary) int { return compareFold(a.User, b.User) },
+	"created":  func(a, b model.CredentialSummary) int { return b.CreatedAt.Compare(a.CreatedAt) },
+	"secret":   func(a, b model.CredentialSummary) int { return b.SecretChangedAt.Compare(a.SecretChangedAt) },
+	"metadata": func(a, b model.CredentialSummary) int { return b.MetadataChangedAt.Compare(a.MetadataChangedAt) },
+	"accessed": func(a, b model.CredentialSummary) int { return b.AccessedAt.Compare(a.AccessedAt) },
+	"count":    func(a, b model.CredentialSummary) int { return cmp.Compare(b.AccessCount, a.AccessCount) },
+}
+
+// compareFold compares strings ignoring case
+func compareFold(a, b string) int {
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "Show a list of saved credentials",
-	Args:  cobra.ExactArgs(0),
+	Long: `Show the saved credentials outside the trash, filtered by label, user, tags and
+folder. --sort orders them by id, label, user, created, secret (last change of
+the secret), metadata (last change of anything else), accessed or count. Times
+and counts sort most recent or highest first, --reverse flips the order.`,
+	Example: `	kosh list --tag work
+	kosh list --sort secret --reverse`,
+	Args: cobra.ExactArgs(0),
 
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runList(listLabel, listUser)
+		compare, ok := listSortKeys[listSort]
+		if !ok {
+			keys := slices.Sorted(maps.Keys(listSortKeys))
+			logger.Error("%s: --sort must be one of %s", constants.ErrInvalidArguments.Error(), strings.Join(keys, ", "))
+			return nil
+		}
+		return runList(listLabel, listUser, listTags, listFolder, compare, listRev)
 	},
 }
 
 func init() {
 	listCmd.Flags().StringVarP(&listLabel, "label", "l", "", "filter creds that contain label string")
 	listCmd.Flags().StringVarP(&listUser, "user", "u", "", "filter creds that contain user string")
+	listCmd.Flags().StringArrayVarP(&listTags, "tag", "t", nil, "filter creds with tag, repeat to require several tags")
+	listCmd.Flags().StringVarP(&listFolder, "folder", "f", "", "filter creds in folder or its sub-folders")
+	listCmd.Flags().StringVarP(&listSort, "sort", "s", "id", "order by id, label, user, created, secret, metadata, accessed or count")
+	listCmd.Flags().BoolVarP(&listRev, "reverse", "r", false, "reverse the order")
 
 	rootCmd.AddCommand(listCmd)
 }
 
-func runList(label string, user string) error {
+func runList(label string, user string, tagNames []string, folder string, compare func(a, b model.CredentialSummary) int, reverse bool) error {
+	tags, err := parseTags(tagNames)
+	if err != nil {
+		return nil
+	}
+	folder = model.NormalizeFolder(folder)
 
-	credentials, err := store.SearchCredentialByLabelOrUser(label, user)
+	credentials, err := store.SearchCredentialByLabelOrUser(label, user, tags, folder)
 	if err != nil {
 		logger.Error("%s", constants.ErrCredentialMatchNotFound.Error())
 		return err
 	}
 
-	displayCredentials(credentials, label, user)
+	slices.SortStableFunc(credentials, compare)
+	if reverse {
+		slices.Reverse(credentials)
+	}
+	displayCredentials(credentials, label, user, tags, folder)
 
 	return nil
 }
 
-func displayCredentials(credentials []model.CredentialSummary, filterLabel, filterUser string) {
+func displayCredentials(credentials []model.CredentialSummary, filterLabel, filterUser string, filterTags []string, filterFolder string) {
 	// Show active filters
 	filters := []string{}
-	if filterLabel != "" || filterUser != "" {
+	if filterLabel != "" || filterUser != "" || len(filterTags) > 0 || filterFolder != "" {
 		if filterLabel != "" {
 			filters = append(filters, fmt.Sprintf("label contains '%s'", filterLabel))
 		}
 		if filterUser != "" {
 			filters = append(filters, fmt.Sprintf("user contains '%s'", filterUser))
 		}
+		if len(filterTags) > 0 {
+			filters = append(filters, fmt.Sprintf("tagged %s", formatTags(filterTags)))
+		}
+		if filterFolder != "" {
+			filters = append(filters, fmt.Sprintf("in folder '%s'", filterFolder))
+		}
 	} else {
 		filters = []string{"none"}
 	}
@@ -67,17 +122,20 @@ func displayCredentials(credentials []model.CredentialSummary, filterLabel, filt
 	}
 
 	// Table header with separator
-	fmt.Printf("%-4s %-18s %-18s %-20s %-20s %-20s %-6s\n", "ID", "LABEL", "USER", "CREATED AT", "UPDATED AT", "ACCESSED AT", "ACCESS COUNT")
-	fmt.Printf("%s\n", strings.Repeat("─", 120))
+	fmt.Printf("%-4s %-18s %-18s %-20s %-20s %-20s %-20s %-12s %-18s %s\n", "ID", "LABEL", "USER", "CREATED AT", "SECRET CHANGED", "METADATA CHANGED", "ACCESSED AT", "ACCESS COUNT", "FOLDER", "TAGS")
+	fmt.Printf("%s\n", strings.Repeat("─", 180))
 
 	// Table rows
 	for _, cred := range credentials {
 		label := truncate(cred.Label, 18)
 		user := truncate(cred.User, 18)
 		createdAt := truncate(cred.CreatedAt.Local().Format(time.DateTime), 20)
-		updatedAt := truncate(cred.UpdatedAt.Local().Format(time.DateTime), 20)
+		secretChangedAt := truncate(cred.SecretChangedAt.Local().Format(time.DateTime), 20)
+		metadataChangedAt := truncate(cred.MetadataChangedAt.Local().Format(time.DateTime), 20)
 		accessedAt := truncate(cred.AccessedAt.Local().Format(time.DateTime), 20)
-		fmt.Printf("%-4d %-18s %-18s %-20s %-20s %-20s %-6d\n", cred.Id, label, user, createdAt, updatedAt, accessedAt, cred.AccessCount)
+		folder := truncate(cred.Folder, 18)
+		tags := formatTags(cred.Tags)
+		fmt.Printf("%-4d %-18s %-18s %-20s %-20s %-20s %-20s %-12d %-18s %s\n", cred.Id, label, user, createdAt, secretChangedAt, metadataChangedAt, accessedAt, cred.AccessCount, folder, tags)
 	}
 
 	fmt.Println()
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logSince string
+	logId    int
+	logJSON  bool
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the audit log of vault operations",
+	Long: `Show when credentials were added, read, updated, trashed, restored and deleted,
+by which command and process. The log is append-only and hash-chained: every
+entry includes the hash of the one before it and is keyed from the vault
+private key, and the integrity seal records how long the log was. The whole
+chain is verified with the master password each time it is shown, so edited,
+inserted or removed entries are reported, including entries cut off the end.
+Entries recorded by commands that did not ask for the master password are
+hashed without the key and only covered once a keyed entry follows them.
+
+--since takes a date (2006-01-02), a time (RFC 3339) or a duration back from
+now, e.g. 36h or 7d.`,
+	Example: `	kosh log --since 7d
+	kosh log --id 12
+	kosh log --json | jq '.[] | select(.event == "read")'`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var since time.Time
+		if logSince != "" {
+			var err error
+			if since, err = parseSince(logSince, time.Now()); err != nil {
+				logger.Error("%s: %s", constants.ErrInvalidArguments.Error(), err.Error())
+				return nil
+			}
+		}
+		return runLog(since, logId, logJSON)
+	},
+}
+
+func init() {
+	logCmd.Flags().StringVar(&logSince, "since", "", "only show events after a date, time or duration ago")
+	logCmd.Flags().IntVar(&logId, "id", 0, "only show events of a credential")
+	logCmd.Flags().BoolVar(&logJSON, "json", false, "print events as JSON")
+	rootCmd.AddCommand(logCmd)
+}
+
+// auditEventJSON is the --json form of an audit log entry
+type auditEventJSON struct {
+	Id           int       `json:"id"`
+	Event        string    `json:"event"`
+	CredentialId int       `json:"credentialId"`
+	Label        string    `json:"label"`
+	User         string    `json:"user"`
+	Command      string    `json:"command"`
+	PID          int       `json:"pid"`
+	At           time.Time `json:"at"`
+	Hash         string    `json:"hash"`
+	Keyed        bool      `json:"keyed"`
+	Verified     bool      `json:"verified"`
+}
+
+func runLog(since time.Time, id int, asJSON bool) error {
+	if asJSON {
+		// keep stdout valid JSON, warnings go to stderr
+		defer logger.Redirect(os.Stderr)()
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	events, broken, missing, err := vault.VerifyAuditLog()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+	if missing > 0 {
+		logger.Error("%s: %d", constants.ErrAuditLogTruncated.Error(), missing)
+	}
+
+	if len(broken) > 0 {
+		ids := []string{}
+		for _, brokenId := range broken {
+			ids = append(ids, strconv.Itoa(brokenId))
+		}
+		logger.Error("%s, affected entries: %s", constants.ErrAuditLogTampered.Error(), strings.Join(ids, ", "))
+	}
+
+	brokenIds := map[int]bool{}
+	for _, brokenId := range broken {
+		brokenIds[brokenId] = true
+	}
+
+	filtered := []model.AuditEvent{}
+	for _, event := range events {
+		if (id == 0 || event.CredentialId == id) && !event.At.Before(since) {
+			filtered = append(filtered, event)
+		}
+	}
+
+	if asJSON {
+		out := make([]auditEventJSON, 0, len(filtered))
+		for _, event := range filtered {
+			out = append(out, auditEventJSON{
+				Id:           event.Id,
+				Event:        string(event.Event),
+				CredentialId: event.CredentialId,
+				Label:        event.Label,
+				User:         event.User,
+				Command:      event.Command,
+				PID:          event.PID,
+				At:           event.At,
+				Hash:         event.Hash,
+				Keyed:        event.Keyed,
+				Verified:     !brokenIds[event.Id],
+			})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+		exitIfTampered(broken, missing)
+		return nil
+	}
+
+	if len(filtered) == 0 {
+		logger.Warn("no events recorded")
+		exitIfTampered(broken, missing)
+		return nil
+	}
+
+	fmt.Printf("%-6s %-20s %-8s %-5s %-20s %-20s %-22s %s\n", "ENTRY", "TIME", "EVENT", "ID", "LABEL", "USER", "COMMAND", "PID")
+	fmt.Printf("%s\n", strings.Repeat("─", 116))
+	for _, event := range filtered {
+		entry := strconv.Itoa(event.Id)
+		if brokenIds[event.Id] {
+			entry += "!"
+		}
+		fmt.Printf("%-6s %-20s %-8s %-5d %-20s %-20s %-22s %d\n",
+			entry,
+			event.At.Local().Format(time.DateTime),
+			event.Event,
+			event.CredentialId,
+			truncate(event.Label, 20),
+			truncate(event.User, 20),
+			truncate(event.Command, 22),
+			event.PID,
+		)
+	}
+	fmt.Println()
+
+	exitIfTampered(broken, missing)
+	return nil
+}
+
+// exitIfTampered exits with status 1 after the log was shown when its chain is broken or entries are
+// missing from its end, so scripts notice without parsing the output
+func exitIfTampered(broken []int, missing int) {
+	if len(broken) > 0 || missing > 0 {
+		store.CloseStore()
+		os.Exit(1)
+	}
+}
+
+// recordEvent records an event in the audit log for a credential known only by its id
+func recordEvent(event model.AuditEventType, id int) {
+	credential, err := store.GetCredentialById(id)
+	if err != nil {
+		logger.Debug("recordEvent:unable to fetch credential %d for the audit log", id)
+		vault.RecordEvent(event, id, "", "")
+		return
+	}
+	vault.RecordEvent(event, id, credential.Label, credential.User)
+}
+
+// recordReads records a read in the audit log for each credential whose secret was decrypted
+func recordReads(credentials []model.CredentialHealth) {
+	events := []model.AuditEvent{}
+	for _, credential := range credentials {
+		if credential.HasSecret {
+			events = append(events, model.AuditEvent{
+				Event:        model.AuditEventRead,
+				CredentialId: credential.Id,
+				Label:        credential.Label,
+				User:         credential.User,
+			})
+		}
+	}
+	vault.RecordEvents(events)
+}
+
+// parseSince parses a date, an RFC 3339 time or a duration before now. Durations accept a d suffix for
+// days on top of the units of time.ParseDuration.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid duration %q", value)
+		}
+		return now.AddDate(0, 0, -n), nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return time.Time{}, fmt.Errorf("expected a date, a time or a duration like 7d, got %q", value)
+	}
+	return now.Add(-duration), nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/search"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
+	"github.com/spf13/cobra"
+)
+
+var matchList bool
+
+var matchCmd = &cobra.Command{
+	Use:   "match <url>",
+	Short: "Retrieve the credential saved for a website or app",
+	Long: `Find the credentials whose saved URLs match the given URL and copy the secret of
+the best one. Hosts match exactly, as sub-domain of a saved host or by
+registrable domain (public suffix aware); explicit ports must agree. Among
+equally good matches the most recently and frequently used credential wins.`,
+	Example: `	kosh match https://login.github.com/session
+	kosh match androidapp://com.example.app
+	kosh match --list example.com`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMatch(args[0])
+	},
+}
+
+func init() {
+	matchCmd.Flags().BoolVarP(&matchList, "list", "l", false, "only list the matching credentials")
+
+	rootCmd.AddCommand(matchCmd)
+}
+
+func runMatch(raw string) error {
+	visited, err := urlmatch.Parse(raw)
+	if err != nil {
+		logger.Error("%s %q", urlmatch.ErrInvalidURL.Error(), raw)
+		return nil
+	}
+
+	results, err := findURLMatches(visited)
+	if err != nil {
+		return err
+	}
+
+	if matchList {
+		if len(results) == 0 {
+			logger.Warn("%s", constants.ErrCredentialMatchNotFound.Error())
+			return nil
+		}
+		fmt.Printf("%-4s %-18s %-18s %-6s %s\n", "ID", "LABEL", "USER", "SCORE", "URLS")
+		fmt.Printf("%s\n", strings.Repeat("─", 80))
+		for _, result := range results {
+			fmt.Printf("%-4d %-18s %-18s %-6.3f %s\n",
+				result.Credential.Id,
+				truncate(result.Credential.Label, 18),
+				truncate(result.Credential.User, 18),
+				result.Score,
+				strings.Join(result.Credential.URLs, " "),
+			)
+		}
+		fmt.Println()
+		return nil
+	}
+
+	if len(results) == 0 {
+		return runSearch(nil, ui.SearchActionSelect, model.AccessViaMatch, "", "")
+	}
+	for _, other := range results[1:min(len(results), 5)] {
+		logger.Muted("also matches %s (%s)", other.Credential.Label, other.Credential.User)
+	}
+	return runSearch(&results[0], ui.SearchActionSelect, model.AccessViaMatch, "", "")
+}
+
+// findURLMatches ranks the credentials with a URL matching the visited target
+func findURLMatches(visited *urlmatch.Target) ([]search.SearchResult, error) {
+	credentials, err := store.GetCredentialsWithURLs()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return nil, err
+	}
+	return search.BestURLMatches(visited, credentials, time.Now()), nil
+}
@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/agent"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/generator"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/nativehost"
+	"git.plutolab.org/plutolab/kosh/internal/otp"
+	"git.plutolab.org/plutolab/kosh/internal/search"
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
+	"github.com/spf13/cobra"
+)
+
+// NATIVE_HOST_NAME is the name browsers know the host by, it must match the manifest file name
+const NATIVE_HOST_NAME = "org.plutolab.kosh"
+
+var (
+	manifestBrowser     string
+	manifestExtensionId string
+)
+
+var nativeHostCmd = &cobra.Command{
+	Use:   "native-host",
+	Short: "Serve the browser extension over native messaging",
+	Long: `Answer lookup, fill, save and generate requests from the kosh browser
+extension. Browsers start this command themselves and talk to it over stdin
+and stdout, see "native-host manifest" for registering it.
+
+Requests only work while "kosh agent" keeps the vault unlocked, and lookup,
+fill and save only for origins allowed with "native-host allow".`,
+	// browsers append their own arguments, like the calling extension or --parent-window
+	Args:               cobra.ArbitraryArgs,
+	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNativeHost()
+	},
+}
+
+var nativeHostAllowCmd = &cobra.Command{
+	Use:     "allow <url>...",
+	Short:   "Allow the browser extension to use credentials on websites",
+	Example: `	kosh native-host allow github.com https://gitlab.example.com:8443`,
+	Args:    cobra.MinimumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNativeHostAllow(args)
+	},
+}
+
+var nativeHostRevokeCmd = &cobra.Command{
+	Use:   "revoke <url>...",
+	Short: "Stop the browser extension from using credentials on websites",
+	Args:  cobra.MinimumNArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNativeHostRevoke(args)
+	},
+}
+
+var nativeHostOriginsCmd = &cobra.Command{
+	Use:   "origins",
+	Short: "Show the websites the browser extension may use",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNativeHostOrigins()
+	},
+}
+
+var nativeHostManifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Print the native messaging manifest for a browser",
+	Long: `Print the manifest that registers kosh as native messaging host. Save it as
+` + NATIVE_HOST_NAME + `.json in the NativeMessagingHosts directory of the browser, e.g.
+~/.config/google-chrome/NativeMessagingHosts or ~/.mozilla/native-messaging-hosts.`,
+	Example: `	kosh native-host manifest --browser chrome --extension-id abcdefghijklmnopabcdefghijklmnop
+	kosh native-host manifest --browser firefox --extension-id kosh@plutolab.org`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNativeHostManifest(manifestBrowser, manifestExtensionId)
+	},
+}
+
+func init() {
+	nativeHostManifestCmd.Flags().StringVarP(&manifestBrowser, "browser", "b", "chrome", "browser to register with, chrome or firefox")
+	nativeHostManifestCmd.Flags().StringVarP(&manifestExtensionId, "extension-id", "e", "", "id of the kosh extension")
+	nativeHostManifestCmd.MarkFlagRequired("extension-id")
+
+	nativeHostCmd.AddCommand(nativeHostAllowCmd, nativeHostRevokeCmd, nativeHostOriginsCmd, nativeHostManifestCmd)
+	rootCmd.AddCommand(nativeHostCmd)
+}
+
+func runNativeHost() error {
+	client, err := agent.NewClient()
+	if err != nil {
+		return err
+	}
+
+	host := nativehost.NewHost(&nativeHostBackend{client})
+	return host.Serve(os.Stdin, os.Stdout)
+}
+
+func runNativeHostAllow(raws []string) error {
+	origins, err := parseURLs(raws)
+	if err != nil {
+		return nil
+	}
+
+	for _, origin := range origins {
+		if err := store.AllowNativeHostOrigin(origin); err != nil {
+			logger.Error("%s", constants.ErrFailedToSaveCredential.Error())
+			return err
+		}
+		logger.Info("%s %s", constants.MsgAllowedOrigin, origin)
+	}
+	return nil
+}
+
+func runNativeHostRevoke(raws []string) error {
+	origins, err := parseURLs(raws)
+	if err != nil {
+		return nil
+	}
+
+	for _, origin := range origins {
+		err := store.RevokeNativeHostOrigin(origin)
+		if err == sql.ErrNoRows {
+			logger.Warn("%s %s", constants.ErrOriginNotAllowed.Error(), origin)
+			continue
+		}
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToSaveCredential.Error())
+			return err
+		}
+		logger.Info("%s %s", constants.MsgRevokedOrigin, origin)
+	}
+	return nil
+}
+
+func runNativeHostOrigins() error {
+	origins, err := store.GetNativeHostOrigins()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	if len(origins) == 0 {
+		logger.Warn("no origins allowed")
+		return nil
+	}
+	for _, origin := range origins {
+		fmt.Println(origin)
+	}
+	return nil
+}
+
+func runNativeHostManifest(browser, extensionId string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+
+	manifest := map[string]any{
+		"name":        NATIVE_HOST_NAME,
+		"description": "kosh password manager",
+		"path":        executable,
+		"type":        "stdio",
+	}
+	switch browser {
+	case "chrome", "chromium":
+		manifest["allowed_origins"] = []string{"chrome-extension://" + extensionId + "/"}
+	case "firefox":
+		manifest["allowed_extensions"] = []string{extensionId}
+	default:
+		logger.Error("%s: unknown browser %q", constants.ErrInvalidArguments.Error(), browser)
+		return nil
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// isNativeHostLaunch reports whether the browser started kosh directly, Chrome passes the calling
+// extension as first argument, Firefox the path of the manifest followed by the extension id
+func isNativeHostLaunch(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if strings.HasPrefix(args[0], "chrome-extension://") {
+		return true
+	}
+	return len(args) == 2 && filepath.Base(args[0]) == NATIVE_HOST_NAME+".json"
+}
+
+// nativeHostBackend answers browser requests from the vault, decrypting through the unlock agent
+type nativeHostBackend struct {
+	agent *agent.Client
+}
+
+func (b *nativeHostBackend) Unlocked() bool {
+	_, err := b.agent.Status()
+	return err == nil
+}
+
+func (b *nativeHostBackend) OriginAllowed(origin string) bool {
+	allowed, err := store.IsNativeHostOriginAllowed(origin)
+	return err == nil && allowed
+}
+
+func (b *nativeHostBackend) Lookup(visited *urlmatch.Target) ([]nativehost.Login, error) {
+	results, err := findURLMatches(visited)
+	if err != nil {
+		return nil, err
+	}
+
+	logins := make([]nativehost.Login, 0, len(results))
+	for _, result := range results {
+		logins = append(logins, nativehost.Login{
+			Id:    result.Credential.Id,
+			Label: result.Credential.Label,
+			User:  result.Credential.User,
+			Score: result.Score,
+		})
+	}
+	return logins, nil
+}
+
+func (b *nativeHostBackend) Fill(credentialId int, visited *urlmatch.Target) (*nativehost.Login, error) {
+	credential, err := store.GetCredentialById(credentialId)
+	if err == sql.ErrNoRows {
+		return nil, nativehost.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the extension only gets secrets of credentials saved for the page it is on
+	urls, err := store.GetCredentialURLs(credentialId)
+	if err != nil {
+		return nil, err
+	}
+	if search.ScoreURL(visited, urls, 0, time.Time{}, time.Now()) <= 0 {
+		return nil, nativehost.ErrNotFound
+	}
+
+	credData := credential.GetRawData()
+	secret, err := b.agent.Decrypt(credData.Ephemeral, credData.Secret, credData.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	login := &nativehost.Login{
+		Id:     credential.Id,
+		Label:  credential.Label,
+		User:   credential.User,
+		Secret: string(secret),
+	}
+
+	if credential.HasOTP() {
+		uri, err := b.agent.Decrypt(credData.OtpEphemeral, credData.Otp, credData.OtpNonce)
+		if err != nil {
+			return nil, err
+		}
+		if login.OTP, err = nativeHostOTPCode(credential.Id, string(uri)); err != nil {
+			return nil, err
+		}
+	}
+
+	recordAccess(credential.Id, model.AccessViaBrowser, "")
+	vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+	return login, nil
+}
+
+func (b *nativeHostBackend) Save(visited *urlmatch.Target, label, user, secret string) (*nativehost.Login, error) {
+	if label == "" {
+		label = visited.Domain
+		if label == "" {
+			label = visited.Host + visited.App
+		}
+	}
+	if isKnownCommand(label) {
+		return nil, fmt.Errorf("%w: %s", nativehost.ErrInvalidRequest, constants.ErrLabelCannotBeCommand.Error())
+	}
+
+	// never overwrite from the browser, updating a secret stays a deliberate CLI action
+	if existing, _ := store.GetCredentialByLabelAndUser(label, user); existing != nil {
+		return nil, fmt.Errorf("%w: %s", nativehost.ErrInvalidRequest, constants.ErrCredentialAlreadyExists.Error())
+	}
+
+	// the host never sees the vault key, the agent computes the MACs sealing the new row
+	vault.SetIntegrityMAC(b.agent.MAC)
+	if err := vault.AddCredential(label, user, []byte(secret)); err != nil {
+		return nil, err
+	}
+	credential, err := store.GetCredentialByLabelAndUser(label, user)
+	if err != nil {
+		return nil, err
+	}
+	vault.RecordEvent(model.AuditEventAdd, credential.Id, credential.Label, credential.User)
+	if err := store.AddCredentialURL(credential.Id, visited.String()); err != nil {
+		return nil, err
+	}
+
+	return &nativehost.Login{Id: credential.Id, Label: credential.Label, User: credential.User}, nil
+}
+
+func (b *nativeHostBackend) Generate(length int) (string, error) {
+	if length <= 0 {
+		length = 20
+	}
+	length = min(max(length, 8), 128)
+
+	password, err := generator.Password(length, true, true, true, true, generator.RequireConfig{})
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// nativeHostOTPCode returns the current code of an otpauth:// URI, HOTP counters are advanced and saved
+func nativeHostOTPCode(credentialId int, uri string) (string, error) {
+	key, err := otp.ParseURI(uri)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := key.Code(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	if key.Type == otp.TypeHOTP {
+		key.Counter++
+		if err := vault.SetCredentialOTP(credentialId, key.URI()); err != nil {
+			return "", err
+		}
+	}
+	return code, nil
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var noteCmd = &cobra.Command{
+	Use:   "note",
+	Short: "Manage secure notes",
+	Long: `Secure notes are credentials without a user whose secret is free text.
+They show up in search and list like any other credential; search copies the
+note to the clipboard, "note show" prints it and "note list" lists only notes.`,
+}
+
+var noteAddCmd = &cobra.Command{
+	Use:   "add [label]",
+	Short: "Interactively add a secure note to the vault",
+	Args:  cobra.RangeArgs(0, 1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var label string
+		if len(args) > 0 {
+			label = args[0]
+		}
+		return runNoteAdd(label)
+	},
+}
+
+var noteShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print a secure note",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runNoteShow(id)
+	},
+}
+
+var noteListLabel string
+
+var noteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show a list of saved secure notes",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runNoteList(noteListLabel)
+	},
+}
+
+func init() {
+	noteListCmd.Flags().StringVarP(&noteListLabel, "label", "l", "", "filter notes that contain label string")
+	noteCmd.AddCommand(noteAddCmd, noteShowCmd, noteListCmd)
+	rootCmd.AddCommand(noteCmd)
+}
+
+func runNoteAdd(label string) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return nil
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Debug("wrong master password provided")
+		return err
+	}
+
+	if strings.TrimSpace(label) == "" {
+		label, err = ui.ReadStringField(constants.MsgEnterNoteLabel)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+	}
+
+	if isKnownCommand(label) {
+		logger.Error("%s", constants.ErrLabelCannotBeCommand.Error())
+		logger.Info(constants.MsgListCommandsWithHelp)
+		return nil
+	}
+
+	if refuseIfTrashed(label, "") {
+		return nil
+	}
+
+	check, err := store.GetCredentialByLabelAndUser(label, "")
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if check != nil {
+		logger.Warn(constants.MsgOperationIsPermanent)
+		confirm, err := ui.ConfirmWithText(
+			fmt.Sprintf("%s %s", constants.MsgOverwriteCredential, constants.MsgAreYouSure),
+			fmt.Sprintf("overwrite %s", label),
+		)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+		if !confirm {
+			logger.Info(constants.MsgOperationAborted)
+			return nil
+		}
+	}
+
+	body, err := ui.ReadMultilineField(constants.MsgEnterNoteBody)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	if err := vault.AddNote(label, []byte(body)); err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+	vault.RecordAdded(label, "", check != nil)
+	logger.Info(constants.MsgSavedNote)
+	return nil
+}
+
+func runNoteList(label string) error {
+	credentials, err := store.SearchCredentialByLabelOrUser(label, "", nil, "")
+	if err != nil {
+		logger.Error("%s", constants.ErrCredentialMatchNotFound.Error())
+		return err
+	}
+
+	notes := slices.DeleteFunc(credentials, func(c model.CredentialSummary) bool {
+		return c.Kind != model.CredentialKindNote
+	})
+	if len(notes) == 0 {
+		logger.Warn("no secure note found")
+		logger.Info("add one with `note add <label>`")
+		return nil
+	}
+
+	fmt.Printf("%-4s %-30s %-20s %-20s %-18s %s\n", "ID", "LABEL", "CREATED AT", "CHANGED AT", "FOLDER", "TAGS")
+	fmt.Printf("%s\n", strings.Repeat("─", 110))
+	for _, note := range notes {
+		fmt.Printf("%-4d %-30s %-20s %-20s %-18s %s\n",
+			note.Id,
+			truncate(note.Label, 30),
+			note.CreatedAt.Local().Format(time.DateTime),
+			note.SecretChangedAt.Local().Format(time.DateTime),
+			truncate(note.Folder, 18),
+			formatTags(note.Tags),
+		)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runNoteShow(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	note, err := vault.DecryptCredential(credential, password)
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	logger.Muted("%s\n", credential.Label)
+	fmt.Println(note)
+
+	recordAccess(credential.Id, model.AccessViaNote, "")
+	vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+	return nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"slices"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/otp"
+	"git.plutolab.org/plutolab/kosh/internal/search"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var otpCmd = &cobra.Command{
+	Use:   "otp <label> [user]",
+	Short: "Copy the current one-time password of a credential",
+	Long: `Generate the current TOTP/HOTP code for a credential and copy it to the clipboard.
+Attach an otpauth:// uri to a credential with the "otp" option of the update command.`,
+	Args: cobra.RangeArgs(1, 2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var user string
+		if len(args) > 1 {
+			user = args[1]
+		}
+		return runOTP(args[0], user)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(otpCmd)
+}
+
+func runOTP(label, user string) error {
+	credentials, err := store.GetAllCredentials()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return nil
+	}
+
+	// only credentials with a one-time password are candidates
+	credentials = slices.DeleteFunc(credentials, func(c model.Credential) bool {
+		return !c.HasOTP()
+	})
+	loadContextFrequencies(credentials)
+
+	result := runSearchByLabelAndUser(credentials, label, user)
+	if result == nil {
+		logger.Warn("%s", constants.ErrCredentialMatchNotFound.Error())
+		logger.Info(constants.MsgListCredentialWithList)
+		return nil
+	}
+	logger.Info("found credential - %s (%s)", result.Credential.Label, result.Credential.User)
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	if err := copyOTP(&result.Credential, password); err != nil {
+		return err
+	}
+
+	recordAccess(result.Credential.Id, model.AccessViaOTP, search.QueryHash(recordingQueryKey(), label, user))
+	recordSelection(result.Credential.Id, label, user)
+	vault.RecordEvent(model.AuditEventRead, result.Credential.Id, result.Credential.Label, result.Credential.User)
+	return nil
+}
+
+// copyOTP generates the current one-time password of a credential and copies it to the clipboard.
+func copyOTP(credential *model.Credential, password []byte) error {
+	key, err := vault.DecryptCredentialOTP(credential, password)
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	return copyOTPCode(key, func(uri string) error {
+		return vault.SetCredentialOTP(credential.Id, uri)
+	})
+}
+
+// copyOTPCode copies the current code of key to the clipboard. HOTP counters are advanced and the
+// updated uri is handed to save, so the same code is never handed out twice.
+func copyOTPCode(key *otp.Key, save func(uri string) error) error {
+	now := time.Now()
+	code, err := key.Code(now)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToGenerateOTP.Error())
+		logger.Debug("copyOTPCode:%s", err.Error())
+		return err
+	}
+
+	if key.Type == otp.TypeHOTP {
+		key.Counter++
+		if err := save(key.URI()); err != nil {
+			logger.Error("%s", constants.ErrFailedToSaveCredential.Error())
+			return err
+		}
+	}
+
+	ui.CopyToClipboard([]byte(code))
+	if remaining := key.Remaining(now); remaining > 0 {
+		logger.Info("%s (%ds remaining)", constants.MsgCopiedOTP, int(remaining.Seconds()))
+	} else {
+		logger.Info(constants.MsgCopiedOTP)
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage named password generation policies",
+	Long: `A policy saves the options of "kosh generate" under a name, so the password
+rules of a system are typed once:
+
+  kosh policy add corp-ad -l 16 --require "upper=1,digit=1" --symbols '!#$'
+  kosh generate --policy corp-ad intranet alice
+
+A credential generated with --policy is linked to it and regenerated with it
+later, link others with "policy set". Policy names are case-insensitive.`,
+}
+
+var policyAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Save a policy from the flags of generate",
+	Example: `	kosh policy add corp-ad -l 16 --require "upper=1,lower=1,digit=1" --no-repeat
+	kosh policy add router --pattern 'Cvcc-9999-@@' --exclude-ambiguous
+	kosh policy add laptop --passphrase --words 7 --capitalize`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyAdd(cmd, args[0])
+	},
+}
+
+var policyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the policies with the number of linked credentials",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyList()
+	},
+}
+
+var policyRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Delete a policy, its credentials are unlinked",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPolicyRm(args[0])
+	},
+}
+
+var policySetCmd = &cobra.Command{
+	Use:     "set <id> <name>",
+	Short:   "Link a credential to a policy",
+	Example: `	kosh policy set 12 corp-ad`,
+	Args:    cobra.ExactArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runPolicySet(id, args[1])
+	},
+}
+
+var policyUnsetCmd = &cobra.Command{
+	Use:   "unset <id>",
+	Short: "Unlink a credential from its policy",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runPolicyUnset(id)
+	},
+}
+
+func init() {
+	addGeneratorFlags(policyAddCmd)
+
+	policyCmd.AddCommand(policyAddCmd, policyListCmd, policyRmCmd, policySetCmd, policyUnsetCmd)
+	rootCmd.AddCommand(policyCmd)
+}
+
+func runPolicyAdd(cmd *cobra.Command, name string) error {
+	if !model.ValidPolicyName(name) {
+		logger.Error("%s", constants.ErrInvalidPolicyName.Error())
+		return nil
+	}
+
+	_, err := store.GetPolicy(name)
+	if err == nil {
+		logger.Error("%s: %s", constants.ErrPolicyAlreadyExists.Error(), name)
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		logger.Error("%s", constants.ErrFailedToSavePolicy.Error())
+		return err
+	}
+
+	policy := policyFromFlags(cmd)
+	if policy == nil {
+		return nil
+	}
+	if required := requiredLength(policy); required > policy.Length {
+		logger.Error("%s: required length (%d characters) is greater than password length (%d characters)", constants.ErrInvalidArguments.Error(), required, policy.Length)
+		return nil
+	}
+
+	// a policy that cannot generate is refused now rather than when it is used
+	sample, err := generateFromPolicy(policy)
+	if err != nil {
+		logger.Error("unable to generate with this policy: %s", err.Error())
+		return nil
+	}
+	clear(sample)
+
+	policy.Name = name
+	if err := store.AddPolicy(policy); err != nil {
+		logger.Error("%s", constants.ErrFailedToSavePolicy.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventConfig, 0, "policy "+policy.Name, "")
+	logger.Info("%s %s: %s", constants.MsgSavedPolicy, policy.Name, describePolicy(policy))
+	return nil
+}
+
+func runPolicyList() error {
+	policies, err := store.GetPolicies()
+	if err != nil {
+		logger.Error("unable to fetch policies")
+		return err
+	}
+
+	if len(policies) == 0 {
+		logger.Warn("no policies found")
+		logger.Info("add one with `policy add <name>`")
+		return nil
+	}
+
+	fmt.Printf("%-18s %-11s %s\n", "NAME", "CREDENTIALS", "RULES")
+	fmt.Printf("%s\n", strings.Repeat("─", 90))
+	for _, policy := range policies {
+		fmt.Printf("%-18s %-11d %s\n", truncate(policy.Name, 18), policy.Credentials, describePolicy(&policy))
+	}
+	fmt.Println()
+	return nil
+}
+
+func runPolicyRm(name string) error {
+	err := store.DeletePolicy(name)
+	if err == sql.ErrNoRows {
+		logger.Error("%s: %s", constants.ErrPolicyNotFound.Error(), name)
+		return nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToSavePolicy.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventConfig, 0, "policy "+name, "")
+	logger.Info("%s %s", constants.MsgDeletedPolicy, name)
+	return nil
+}
+
+func runPolicySet(id int, name string) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+	policy, err := getPolicy(name)
+	if policy == nil {
+		return err
+	}
+
+	if err := store.SetCredentialPolicy(credential.Id, policy.Id); err != nil {
+		logger.Error("%s", constants.ErrFailedToSavePolicy.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info("linked %s (%s) to policy %s", credential.Label, credential.User, policy.Name)
+	return nil
+}
+
+func runPolicyUnset(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	err = store.ClearCredentialPolicy(credential.Id)
+	if err == sql.ErrNoRows {
+		logger.Warn("%s (%s) has no policy", credential.Label, credential.User)
+		return nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToSavePolicy.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info("unlinked %s (%s) from its policy", credential.Label, credential.User)
+	return nil
+}
+
+// getPolicy fetches a policy by name, nil after logging when it does not exist
+func getPolicy(name string) (*model.Policy, error) {
+	policy, err := store.GetPolicy(name)
+	if err == sql.ErrNoRows {
+		logger.Error("%s: %s", constants.ErrPolicyNotFound.Error(), name)
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("unable to fetch policy")
+		return nil, err
+	}
+	return policy, nil
+}
+
+// getLinkedPolicy fetches the policy of the credential with label and user, nil when there is no
+// such credential or it has no policy
+func getLinkedPolicy(label, user string) (*model.Policy, error) {
+	credential, err := store.GetCredentialByLabelAndUser(label, user)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return nil, err
+	}
+
+	policy, err := store.GetCredentialPolicy(credential.Id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("unable to fetch policy")
+		return nil, err
+	}
+	return policy, nil
+}
+
+// describePolicy sums up the rules of a policy in one line
+func describePolicy(policy *model.Policy) string {
+	var rules []string
+	switch {
+	case policy.Passphrase:
+		rules = append(rules, fmt.Sprintf("passphrase of %d words separated by %q", policy.Words, policy.Separator))
+		if policy.Capitalize {
+			rules = append(rules, "capitalized")
+		}
+		if policy.Digit {
+			rules = append(rules, "a digit")
+		}
+		if policy.Wordlist != "" {
+			rules = append(rules, "words from "+policy.Wordlist)
+		}
+		return strings.Join(rules, ", ")
+
+	case policy.Pattern != "":
+		rules = append(rules, "pattern "+policy.Pattern)
+
+	default:
+		var groups []string
+		for _, group := range []struct {
+			name    string
+			enabled bool
+		}{{"upper", policy.Upper}, {"lower", policy.Lower}, {"digit", policy.Digit}, {"symbol", policy.Symbol}} {
+			if group.enabled {
+				groups = append(groups, group.name)
+			}
+		}
+		rules = append(rules, fmt.Sprintf("%d characters of %s", policy.Length, strings.Join(groups, "/")))
+		if policy.Require != "" {
+			rules = append(rules, "require "+policy.Require)
+		}
+	}
+
+	if policy.ExcludeAmbiguous {
+		rules = append(rules, "no ambiguous")
+	}
+	if policy.Symbols != "" {
+		rules = append(rules, "symbols "+policy.Symbols)
+	}
+	if policy.NoRepeat {
+		rules = append(rules, "no repeat")
+	}
+	if policy.StartWith != "" {
+		rules = append(rules, "start with "+policy.StartWith)
+	}
+	return strings.Join(rules, ", ")
+}
@@ -36,6 +36,11 @@ var rootCmd = &cobra.Command{
 	Long:    "Kosh is a secure, local vault for storing and generating credentials.",
 	Version: AppVersion,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// stdout belongs to the browser protocol, messages for the user go to stderr
+		if cmd == nativeHostCmd {
+			logger.Redirect(os.Stderr)
+		}
+
 		var err error
 		store, err = storage.InitializeStore()
 		if err != nil {
@@ -46,6 +51,22 @@ var rootCmd = &cobra.Command{
 
 		// Initialize Services
 		vault = core.NewVaultService(store)
+		vault.SetAuditCommand(cmd.CommandPath())
+		vault.SetIntegrityReporter(reportIntegrity)
+
+		// Drop credentials that outlived the trash retention
+		if purged, err := vault.PurgeExpiredTrash(); err != nil {
+			logger.Debug("unable to purge expired trash: %s", err.Error())
+		} else if purged > 0 {
+			logger.Debug("purged %d expired credentials from trash", purged)
+		}
+
+		// Drop access events that outlived the stats retention
+		if pruned, err := vault.PruneAccessEvents(); err != nil {
+			logger.Debug("unable to prune access events: %s", err.Error())
+		} else if pruned > 0 {
+			logger.Debug("pruned %d expired access events", pruned)
+		}
 	},
 
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
@@ -57,6 +78,9 @@ func Execute() {
 	// Intercept os.Args to support shorthand `kosh <credential>`
 	if len(os.Args) == 1 {
 		os.Args = append(os.Args, DEFAULT_COMMAND)
+	} else if isNativeHostLaunch(os.Args[1:]) {
+		// Started by a browser, which passes its own arguments instead of a command
+		os.Args = append(os.Args[:1], append([]string{nativeHostCmd.Name()}, os.Args[1:]...)...)
 	} else {
 		firstArg := os.Args[1]
 
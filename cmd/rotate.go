@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rotateCommit bool
+	rotateAbort  bool
+)
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate <id>",
+	Short: "Change the secret of a credential in two steps",
+	Long: `Rotate generates a new secret for a credential and copies it, but keeps it
+pending next to the current secret instead of replacing it. Change the password
+on the site, then finish with --commit once the site accepted it, or --abort if
+it did not. Until then "get" and "search" still give the current secret and
+"rotate <id>" copies the pending one again.
+
+The new secret is generated with the policy linked to the credential, or like
+"kosh generate" from --policy or the generator flags. Committing keeps the
+replaced secret in history.`,
+	Example: `	kosh rotate 12
+	kosh rotate --commit 12
+	kosh rotate --abort 12
+	kosh rotate --policy corp-ad 12`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runRotate(cmd, id)
+	},
+}
+
+func init() {
+	addGeneratorFlags(rotateCmd)
+	rotateCmd.Flags().StringVar(&genPolicy, "policy", "", "generate with a saved policy and link the credential to it")
+	rotateCmd.Flags().BoolVar(&rotateCommit, "commit", false, "make the pending secret the current secret")
+	rotateCmd.Flags().BoolVar(&rotateAbort, "abort", false, "discard the pending secret")
+	rotateCmd.MarkFlagsMutuallyExclusive("commit", "abort")
+
+	rootCmd.AddCommand(rotateCmd)
+}
+
+func runRotate(cmd *cobra.Command, id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	pending, err := store.GetPendingSecret(credential.Id)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	generating := slices.ContainsFunc(append(generatorFlags, "policy"), cmd.Flags().Changed)
+	if (rotateCommit || rotateAbort) && generating {
+		logger.Error("%s: --commit and --abort take no generator flags", constants.ErrInvalidArguments.Error())
+		return nil
+	}
+
+	switch {
+	case rotateCommit:
+		return runRotateCommit(credential, pending)
+	case rotateAbort:
+		return runRotateAbort(credential, pending)
+	case pending != nil && generating:
+		logger.Error("a rotation of %s (%s) is pending since %s", credential.Label, credential.User, pending.CreatedAt.Local().Format(time.DateTime))
+		logger.Info("commit or abort it first with `rotate --commit %d` or `rotate --abort %d`", credential.Id, credential.Id)
+		return nil
+	case pending != nil:
+		return copyPendingSecret(credential, pending)
+	}
+
+	policy, err := selectPolicy(cmd, credential.Label, credential.User)
+	if policy == nil {
+		return err
+	}
+	newSecret, err := generateFromPolicy(policy)
+	if err != nil {
+		logger.Error("unable to generate credential: %s", err.Error())
+		return nil
+	}
+	defer clear(newSecret)
+	warnIfBreached(newSecret)
+	reportStrength(newSecret, credential.Label, credential.User)
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	if err := vault.StartRotation(credential.Id, newSecret); err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+	if policy.Id != 0 {
+		if err := linkPolicy(credential, policy); err != nil {
+			return err
+		}
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+
+	ui.CopyToClipboard(newSecret)
+	logger.Info(constants.MsgStartedRotation)
+	logger.Muted("change it on the site, then run `rotate --commit %d`, or `rotate --abort %d` if it was refused", credential.Id, credential.Id)
+	return nil
+}
+
+// copyPendingSecret copies the secret of an unfinished rotation again
+func copyPendingSecret(credential *model.Credential, pending *model.PendingSecret) error {
+	logger.Warn("a rotation of %s (%s) is pending since %s", credential.Label, credential.User, pending.CreatedAt.Local().Format(time.DateTime))
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	secret, err := vault.DecryptPendingSecret(pending, password)
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+
+	ui.CopyToClipboard([]byte(secret))
+	vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+	logger.Info("copied the pending secret to clipboard")
+	logger.Muted("finish with `rotate --commit %d` or `rotate --abort %d`", credential.Id, credential.Id)
+	return nil
+}
+
+func runRotateCommit(credential *model.Credential, pending *model.PendingSecret) error {
+	if pending == nil {
+		logger.Error("%s for %s (%s)", constants.ErrNoRotationPending.Error(), credential.Label, credential.User)
+		return nil
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", constants.ErrIncorrectMasterPassword.Error())
+		return err
+	}
+
+	if err := vault.CommitRotation(pending); err != nil {
+		logger.Error("%s", err.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info(constants.MsgCommittedRotation)
+	logger.Muted("the replaced secret was kept in history, see `history %d`", credential.Id)
+	return nil
+}
+
+func runRotateAbort(credential *model.Credential, pending *model.PendingSecret) error {
+	if pending == nil {
+		logger.Error("%s for %s (%s)", constants.ErrNoRotationPending.Error(), credential.Label, credential.User)
+		return nil
+	}
+
+	// the site may have taken the new secret after all, it is gone once discarded
+	confirm, err := ui.ConfirmYesNo("discard the new secret? it cannot be recovered", false)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if !confirm {
+		logger.Info(constants.MsgOperationAborted)
+		return nil
+	}
+
+	if err := store.DeletePendingSecret(credential.Id); err != nil {
+		logger.Error("%s", constants.ErrFailedToSaveCredential.Error())
+		return err
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info(constants.MsgAbortedRotation)
+	return nil
+}
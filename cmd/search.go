@@ -3,6 +3,7 @@ package cmd
 import (
 	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
@@ -13,6 +14,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var searchOTP bool
+
 var searchCmd = &cobra.Command{
 	Use:   "search <label> <user>",
 	Short: "Retrieve a credential via fuzzy search",
@@ -24,10 +27,14 @@ var searchCmd = &cobra.Command{
 			logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
 			return nil
 		}
-
+		loadContextFrequencies(credentials)
 		var result *search.SearchResult
+		var label, user string
+		action := ui.SearchActionSelect
+
 		if len(args) == 0 { // Interactive Search
-			result, err = runInteractiveSearch(credentials)
+			var query string
+			result, action, query, err = runInteractiveSearch(credentials)
 			if err != nil {
 				if errors.Is(err, constants.ErrSearchCancelled) {
 					logger.Warn(constants.MsgOperationAborted)
@@ -35,24 +42,37 @@ var searchCmd = &cobra.Command{
 				}
 				return err
 			}
+			label, user = splitQuery(query)
 		} else { // Search by command args
-			var label, user string
 			label = args[0]
 			if len(args) > 1 {
 				user = args[1]
 			}
 			result = runSearchByLabelAndUser(credentials, label, user)
 		}
-		
-		return runSearch(result)
+
+		return runSearch(result, searchAction(action, searchOTP), model.AccessViaSearch, label, user)
 	},
 }
 
+// searchAction is what to copy for a credential found with action, the one-time password when --otp
+// was given however it was picked
+func searchAction(action ui.SearchAction, otp bool) ui.SearchAction {
+	if otp {
+		return ui.SearchActionOTP
+	}
+	return action
+}
+
 func init() {
+	searchCmd.Flags().BoolVarP(&searchOTP, "otp", "o", false, "copy the one-time password instead of the secret")
+
 	rootCmd.AddCommand(searchCmd)
 }
 
-func runSearch(result *search.SearchResult) error {
+// runSearch copies the secret or one-time password of the credential found, label and user are the
+// query it was found with, empty for a URL match
+func runSearch(result *search.SearchResult, action ui.SearchAction, via model.AccessVia, label, user string) error {
 	if result == nil {
 		logger.Warn("%s", constants.ErrCredentialMatchNotFound.Error())
 		logger.Info(constants.MsgListCredentialWithList)
@@ -69,55 +89,149 @@ func runSearch(result *search.SearchResult) error {
 		return err
 	}
 
-	// decrypt secret using master password
-	secret, err := vault.DecryptCredential(&result.Credential, password)
-	if err != nil {
-		logger.Debug("runSearch:failed to decrypt credential")
-		return err
+	if action == ui.SearchActionOTP {
+		if err := copyOTP(&result.Credential, password); err != nil {
+			return err
+		}
+	} else {
+		// decrypt secret using master password
+		secret, err := vault.DecryptCredential(&result.Credential, password)
+		if err != nil {
+			logger.Debug("runSearch:failed to decrypt credential")
+			return err
+		}
+
+		ui.CopyToClipboard([]byte(secret))
+		logger.Info(constants.MsgCopiedCredential)
+		warnIfOverdue(&result.Credential)
 	}
-	
-	ui.CopyToClipboard([]byte(secret))
-	logger.Info(constants.MsgCopiedCredential)
-	
-	// increment access count by 1 on successful search
-	store.UpdateCredentialAccessCount(result.Credential.Id, 1, time.Now())
+
+	// record the access on successful search
+	recordAccess(result.Credential.Id, via, search.QueryHash(recordingQueryKey(), label, user))
+	recordSelection(result.Credential.Id, label, user)
+	vault.RecordEvent(model.AuditEventRead, result.Credential.Id, result.Credential.Label, result.Credential.User)
 	return nil
 }
 
+// loadContextFrequencies sets how often each credential was used from the current working directory
+// and git repository, so search prefers them there. Without them search still works, only unaware of
+// the context.
+func loadContextFrequencies(credentials []model.Credential) {
+	frequencies, err := store.GetContextFrequencies(accessContext())
+	if err != nil {
+		logger.Debug("loadContextFrequencies:unable to fetch context frequencies: %s", err.Error())
+		return
+	}
+	for i := range credentials {
+		credentials[i].ContextFrequency = frequencies[credentials[i].Id]
+	}
+}
+
+// queryKey is the key search queries are hashed with, read once. Until a pick is recorded there is
+// none and nothing is looked up, so searching alone leaves no file behind.
+var queryKey = sync.OnceValue(func() []byte {
+	key, err := store.GetQueryKey()
+	if err != nil {
+		logger.Debug("queryKey:unable to load query key: %s", err.Error())
+		return nil
+	}
+	return key
+})
+
+// recordingQueryKey is the query key to record a pick with, created if there is none yet. Without it
+// the pick is not recorded.
+var recordingQueryKey = sync.OnceValue(func() []byte {
+	if key := queryKey(); key != nil {
+		return key
+	}
+	key, err := store.CreateQueryKey()
+	if err != nil {
+		logger.Debug("recordingQueryKey:unable to create query key: %s", err.Error())
+		return nil
+	}
+	return key
+})
+
+// loadQuerySelections fetches which credentials were picked before for a query or queries starting
+// with it, so search prefers them. Without them search still works, only without learning from picks.
+func loadQuerySelections(label, user string) model.QuerySelections {
+	hash := search.QueryHash(queryKey(), label, user)
+	if hash == "" {
+		return nil
+	}
+	selections, err := store.GetQuerySelections(hash)
+	if err != nil {
+		logger.Debug("loadQuerySelections:unable to fetch query selections: %s", err.Error())
+		return nil
+	}
+	return selections
+}
+
+// recordSelection remembers that a credential was picked for a query, by the hashes of the query's
+// prefixes. Failures are only logged, like in recordAccess.
+func recordSelection(credentialId int, label, user string) {
+	if err := store.RecordQuerySelection(credentialId, search.QueryPrefixHashes(recordingQueryKey(), label, user), time.Now()); err != nil {
+		logger.Debug("recordSelection:unable to record selection of %d: %s", credentialId, err.Error())
+	}
+}
+
 func runSearchByLabelAndUser(credentials []model.Credential, queryLabel, queryUser string) *search.SearchResult {
 	// find matches and return the best match
-	result := search.BestMatches(queryLabel, queryUser, credentials, time.Now())
+	result := search.BestMatches(queryLabel, queryUser, credentials, loadQuerySelections(queryLabel, queryUser), time.Now())
 	if len(result) == 0 {
 		return nil
 	}
 	return &result[0]
 }
 
-func runInteractiveSearch(credentials []model.Credential) (*search.SearchResult, error) {
-	result, err := ui.InteractiveSearch(
+// runInteractiveSearch lets the user pick a credential, and returns the query it was picked with
+func runInteractiveSearch(credentials []model.Credential) (*search.SearchResult, ui.SearchAction, string, error) {
+	var lastQuery string
+	selections := selectionCache{}
+	result, action, err := ui.InteractiveSearch(
 		constants.MsgCredentialSearch,
 		func (query string) []search.SearchResult {
-			return searchCredentialsFromList(query, credentials)
+			lastQuery = query
+			return searchCredentialsFromList(query, credentials, selections)
 		},
 	)
 	if err != nil {
 		logger.Debug("runInteractiveSearch:failed run interactive search:%s", err.Error())
-		return nil, err
+		return nil, action, "", err
 	}
 
-	return &result, nil
+	return &result, action, lastQuery, nil
 }
 
-func searchCredentialsFromList(query string, list []model.Credential) []search.SearchResult {
+// selectionCache holds the query selections loaded during one picker session, by label and user
+// query, so typing and deleting characters looks each query up only once
+type selectionCache map[[2]string]model.QuerySelections
+
+func (c selectionCache) get(label, user string) model.QuerySelections {
+	query := [2]string{label, user}
+	selections, ok := c[query]
+	if !ok {
+		selections = loadQuerySelections(label, user)
+		c[query] = selections
+	}
+	return selections
+}
+
+func searchCredentialsFromList(query string, list []model.Credential, selections selectionCache) []search.SearchResult {
 	if strings.TrimSpace(query) == "" {
 		return nil
 	}
-	var label, user string
+	label, user := splitQuery(query)
+	result := search.BestMatches(label, user, list, selections.get(label, user), time.Now())
+	return result[:min(len(result), 5)] // filter out top 5 results
+}
+
+// splitQuery splits an interactive query into the label and user parts
+func splitQuery(query string) (label, user string) {
 	parts := strings.Split(query, " ")
 	label = parts[0]
 	if len(parts) > 1 {
 		user = parts[1]
 	}
-	result := search.BestMatches(label, user, list, time.Now())
-	return result[:min(len(result), 5)] // filter out top 5 results
+	return label, user
 }
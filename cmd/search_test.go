@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+)
+
+func TestSearchAction(t *testing.T) {
+	tests := []struct {
+		name   string
+		action ui.SearchAction
+		otp    bool
+		want   ui.SearchAction
+	}{
+		{"enter", ui.SearchActionSelect, false, ui.SearchActionSelect},
+		{"tab", ui.SearchActionOTP, false, ui.SearchActionOTP},
+		{"enter with --otp", ui.SearchActionSelect, true, ui.SearchActionOTP},
+		{"tab with --otp", ui.SearchActionOTP, true, ui.SearchActionOTP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := searchAction(tt.action, tt.otp); got != tt.want {
+				t.Errorf("searchAction(%d, %v) = %d, want %d", tt.action, tt.otp, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/api"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/peercred"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveSocket      string
+	serveNoPeerCheck bool
+	serveAuditLimit  int
+	tokenLabels      []string
+	tokenRead        bool
+	tokenWrite       bool
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the local JSON API over a Unix socket",
+	Long: `Unlock the vault once and answer JSON/HTTP requests on a Unix socket that only
+the vault owner can open, so local tools can read and change credentials
+without the CLI. Connections from processes that cannot be identified as the
+vault owner are refused; where the platform cannot tell (no SO_PEERCRED),
+--no-peer-check serves them, relying on the socket permissions alone.
+Routes are versioned under /v1:
+
+  GET    /v1/credentials?label=&user=&tag=&folder=   list
+  GET    /v1/search?q=&user=&limit=                  fuzzy search
+  GET    /v1/credentials/{id}                        get, with secret
+  POST   /v1/credentials                             add
+  PATCH  /v1/credentials/{id}                        update label, user or secret
+  DELETE /v1/credentials/{id}                        move to trash
+  POST   /v1/generate                                generate, optionally save
+
+Every request needs "Authorization: Bearer <token>" with a token created by
+"kosh serve token add", and every call is recorded, see "kosh serve audit".`,
+	Example: `	kosh serve --socket ~/.kosh/api.sock
+	curl --unix-socket ~/.kosh/api.sock -H "Authorization: Bearer $KOSH_TOKEN" http://kosh/v1/search?q=github`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe(serveSocket, serveNoPeerCheck)
+	},
+}
+
+var serveTokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage the client tokens of the local API",
+}
+
+var serveTokenAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Create a client token scoped to label patterns",
+	Long: `Create a token for an API client. The token can only see and change
+credentials whose label matches one of the --label patterns (* matches any
+characters, ? a single one) and only with the given rights. The token is shown
+once; only its hash is stored.`,
+	Example: `	kosh serve token add deploy --label "ci/*" --label "aws-*" --read
+	kosh serve token add provisioner --label "db-*" --read --write`,
+	Args: cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServeTokenAdd(args[0], tokenLabels, tokenRead, tokenWrite)
+	},
+}
+
+var serveTokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the client tokens",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServeTokenList()
+	},
+}
+
+var serveTokenRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Revoke a client token",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServeTokenRm(args[0])
+	},
+}
+
+var serveAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the most recent API calls",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServeAudit(serveAuditLimit)
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVarP(&serveSocket, "socket", "s", "", "path of the Unix socket (default ~/.kosh/api.sock)")
+	serveCmd.Flags().BoolVar(&serveNoPeerCheck, "no-peer-check", false, "serve processes that cannot be identified, relying on the socket permissions")
+
+	serveTokenAddCmd.Flags().StringArrayVarP(&tokenLabels, "label", "l", nil, "label pattern the token may access, repeat for several")
+	serveTokenAddCmd.Flags().BoolVar(&tokenRead, "read", false, "allow list, search, get and generate")
+	serveTokenAddCmd.Flags().BoolVar(&tokenWrite, "write", false, "allow add, update and delete")
+	serveTokenAddCmd.MarkFlagRequired("label")
+
+	serveAuditCmd.Flags().IntVarP(&serveAuditLimit, "number", "n", 50, "number of calls to show")
+
+	serveTokenCmd.AddCommand(serveTokenAddCmd, serveTokenListCmd, serveTokenRmCmd)
+	serveCmd.AddCommand(serveTokenCmd, serveAuditCmd)
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(socketPath string, noPeerCheck bool) error {
+	if !peercred.Supported && !noPeerCheck {
+		logger.Error("the peer process of a connection cannot be identified on this platform, every request would be refused")
+		logger.Info("serve with --no-peer-check to rely on the permissions of the socket alone")
+		return nil
+	}
+
+	if socketPath == "" {
+		userDir, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		socketPath = filepath.Join(userDir, ".kosh", "api.sock")
+	}
+
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	privateKey, err := vault.UnlockVault(password)
+	if err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	listener, err := api.Listen(socketPath)
+	if err != nil {
+		clear(privateKey)
+		logger.Error("%s", err.Error())
+		return nil
+	}
+
+	// stop and wipe the key on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("%s %s", constants.MsgServingAPI, socketPath)
+	server := api.NewServer(store, vault, privateKey)
+	if noPeerCheck {
+		server.SkipPeerCheck()
+		logger.Warn("peer check is off, any process that can open %s is served", socketPath)
+	}
+	if err := server.Serve(ctx, listener); err != nil {
+		return err
+	}
+	logger.Info(constants.MsgStoppedAPI)
+	return nil
+}
+
+func runServeTokenAdd(name string, labels []string, read, write bool) error {
+	if !read && !write {
+		logger.Error("%s: give --read, --write or both", constants.ErrInvalidArguments.Error())
+		return nil
+	}
+	for _, label := range labels {
+		if strings.TrimSpace(label) == "" || strings.Contains(label, ",") {
+			logger.Error("%s: invalid label pattern %q", constants.ErrInvalidArguments.Error(), label)
+			return nil
+		}
+	}
+
+	token, hash, err := api.NewToken()
+	if err != nil {
+		return err
+	}
+
+	err = store.AddAPIClient(&model.APIClient{Name: name, TokenHash: hash, Labels: labels, Read: read, Write: write})
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToSaveAPIClient.Error())
+		return err
+	}
+
+	logger.Info(constants.MsgCreatedAPIToken)
+	fmt.Println(token)
+	logger.Muted("the token is shown only once")
+	return nil
+}
+
+func runServeTokenList() error {
+	clients, err := store.GetAPIClients()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	if len(clients) == 0 {
+		logger.Warn("no api tokens")
+		return nil
+	}
+
+	fmt.Printf("%-18s %-12s %-20s %-20s %s\n", "NAME", "RIGHTS", "CREATED AT", "LAST USED AT", "LABELS")
+	fmt.Printf("%s\n", strings.Repeat("─", 100))
+	for _, client := range clients {
+		lastUsedAt := "never"
+		if !client.LastUsedAt.IsZero() {
+			lastUsedAt = client.LastUsedAt.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%-18s %-12s %-20s %-20s %s\n",
+			truncate(client.Name, 18),
+			formatRights(client.Read, client.Write),
+			client.CreatedAt.Local().Format(time.DateTime),
+			lastUsedAt,
+			strings.Join(client.Labels, " "),
+		)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runServeTokenRm(name string) error {
+	err := store.DeleteAPIClient(name)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrAPIClientNotFound.Error())
+		return nil
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToSaveAPIClient.Error())
+		return err
+	}
+	logger.Info(constants.MsgRevokedAPIToken)
+	return nil
+}
+
+func runServeAudit(limit int) error {
+	entries, err := store.GetAPIAuditEntries(limit)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	if len(entries) == 0 {
+		logger.Warn("no api calls recorded")
+		return nil
+	}
+
+	fmt.Printf("%-20s %-18s %-7s %-30s %-6s %-8s %-8s %s\n", "TIME", "CLIENT", "METHOD", "PATH", "STATUS", "PID", "UID", "CREDENTIAL")
+	fmt.Printf("%s\n", strings.Repeat("─", 120))
+	for _, entry := range entries {
+		client := entry.Client
+		if client == "" {
+			client = "-"
+		}
+		credential := "-"
+		if entry.CredentialId != 0 {
+			credential = fmt.Sprint(entry.CredentialId)
+		}
+		fmt.Printf("%-20s %-18s %-7s %-30s %-6d %-8d %-8d %s\n",
+			entry.At.Local().Format(time.DateTime),
+			truncate(client, 18),
+			entry.Method,
+			truncate(entry.Path, 30),
+			entry.Status,
+			entry.PID,
+			entry.UID,
+			credential,
+		)
+	}
+	fmt.Println()
+	return nil
+}
+
+func formatRights(read, write bool) string {
+	rights := []string{}
+	if read {
+		rights = append(rights, "read")
+	}
+	if write {
+		rights = append(rights, "write")
+	}
+	return strings.Join(rights, ",")
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/workdir"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsSince string
+	statsTop   int
+	statsJSON  bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show which credentials are used, and how much",
+	Long: `Show the most used credentials, the ones never used since they were added, and
+the number of accesses per day (per week for periods over a month).
+
+Most used ranks by the frequency search uses: every access counts, and counts
+half as much every 30 days. Picking a search or URL match counts once, other
+reads twice. Access events are kept for stats.retention_days days (default 365).
+
+--since takes a date (2006-01-02), a time (RFC 3339) or a duration back from
+now, e.g. 36h or 7d.`,
+	Example: `	kosh stats
+	kosh stats --since 90d --top 20
+	kosh stats --json`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		now := time.Now()
+		since, err := parseSince(statsSince, now)
+		if err != nil {
+			logger.Error("%s: %s", constants.ErrInvalidArguments.Error(), err.Error())
+			return nil
+		}
+		if statsTop <= 0 {
+			logger.Error("%s: --top must be positive", constants.ErrInvalidArguments.Error())
+			return nil
+		}
+		return runStats(since, statsTop, statsJSON, now)
+	},
+}
+
+func init() {
+	statsCmd.Flags().StringVar(&statsSince, "since", "30d", "count accesses after a date, time or duration ago")
+	statsCmd.Flags().IntVar(&statsTop, "top", 10, "number of most used credentials to show")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "print the statistics as JSON")
+	rootCmd.AddCommand(statsCmd)
+}
+
+// accessContext is the working directory and git remote the command runs in, looked up once
+var accessContext = sync.OnceValue(workdir.Current)
+
+// recordAccess saves an access of a credential, with where it came from, for search ranking and
+// `kosh stats`. Failures are only logged, they must not fail the read itself.
+func recordAccess(credentialId int, via model.AccessVia, queryHash string) {
+	event := &model.AccessEvent{
+		CredentialId: credentialId,
+		Via:          via,
+		QueryHash:    queryHash,
+		Context:      accessContext(),
+		At:           time.Now(),
+	}
+	if err := store.RecordAccess(event); err != nil {
+		logger.Debug("recordAccess:unable to record access of %d: %s", credentialId, err.Error())
+	}
+}
+
+// usageBucket is the number of accesses in a day or week
+type usageBucket struct {
+	From     time.Time               `json:"from"`
+	Accesses int                     `json:"accesses"`
+	Via      map[model.AccessVia]int `json:"via"`
+}
+
+type credentialUsageJSON struct {
+	Id          int       `json:"id"`
+	Label       string    `json:"label"`
+	User        string    `json:"user"`
+	Accesses    int       `json:"accesses"`
+	AccessCount int       `json:"accessCount"`
+	Frequency   float64   `json:"frequency"`
+	CreatedAt   time.Time `json:"createdAt"`
+	AccessedAt  time.Time `json:"accessedAt"`
+}
+
+type statsJSONOutput struct {
+	Since     time.Time             `json:"since"`
+	MostUsed  []credentialUsageJSON `json:"mostUsed"`
+	NeverUsed []credentialUsageJSON `json:"neverUsed"`
+	Usage     []usageBucket         `json:"usage"`
+}
+
+func runStats(since time.Time, top int, asJSON bool, now time.Time) error {
+	if asJSON {
+		// keep stdout valid JSON, warnings go to stderr
+		defer logger.Redirect(os.Stderr)()
+	}
+
+	usages, err := store.GetCredentialUsage(since)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+	counts, err := store.GetAccessCounts(since)
+	if err != nil {
+		logger.Error("unable to fetch access events")
+		return err
+	}
+
+	// usages come most frequently used first
+	mostUsed := slices.DeleteFunc(slices.Clone(usages), func(u model.CredentialUsage) bool { return u.Frequency <= 0 })
+	mostUsed = mostUsed[:min(len(mostUsed), top)]
+	neverUsed := slices.DeleteFunc(slices.Clone(usages), func(u model.CredentialUsage) bool { return !u.NeverUsed() })
+	buckets := usageBuckets(counts, since, now)
+
+	if asJSON {
+		out := statsJSONOutput{Since: since, MostUsed: []credentialUsageJSON{}, NeverUsed: []credentialUsageJSON{}, Usage: buckets}
+		for _, usage := range mostUsed {
+			out.MostUsed = append(out.MostUsed, usageJSON(usage))
+		}
+		for _, usage := range neverUsed {
+			out.NeverUsed = append(out.NeverUsed, usageJSON(usage))
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	}
+
+	if len(usages) == 0 {
+		logger.Warn("%s", constants.ErrCredentialNotFound.Error())
+		return nil
+	}
+
+	logger.Muted("most used, accesses since %s\n", since.Local().Format(time.DateOnly))
+	if len(mostUsed) == 0 {
+		logger.Warn("no credential was used yet")
+	} else {
+		fmt.Printf("%-5s %-20s %-20s %-9s %-10s %s\n", "ID", "LABEL", "USER", "ACCESSES", "FREQUENCY", "LAST ACCESS")
+		fmt.Printf("%s\n", strings.Repeat("─", 90))
+		for _, usage := range mostUsed {
+			fmt.Printf("%-5d %-20s %-20s %-9d %-10.1f %s\n",
+				usage.Id,
+				truncate(usage.Label, 20),
+				truncate(usage.User, 20),
+				usage.Accesses,
+				usage.Frequency,
+				usage.AccessedAt.Local().Format(time.DateTime),
+			)
+		}
+	}
+	fmt.Println()
+
+	if len(neverUsed) > 0 {
+		logger.Muted("never used\n")
+		fmt.Printf("%-5s %-20s %-20s %s\n", "ID", "LABEL", "USER", "ADDED")
+		fmt.Printf("%s\n", strings.Repeat("─", 70))
+		for _, usage := range neverUsed {
+			fmt.Printf("%-5d %-20s %-20s %s\n",
+				usage.Id,
+				truncate(usage.Label, 20),
+				truncate(usage.User, 20),
+				usage.CreatedAt.Local().Format(time.DateOnly),
+			)
+		}
+		fmt.Println()
+	}
+
+	logger.Muted("accesses per %s\n", bucketName(since, now))
+	printUsage(buckets)
+	return nil
+}
+
+func usageJSON(usage model.CredentialUsage) credentialUsageJSON {
+	return credentialUsageJSON{
+		Id:          usage.Id,
+		Label:       usage.Label,
+		User:        usage.User,
+		Accesses:    usage.Accesses,
+		AccessCount: usage.AccessCount,
+		Frequency:   math.Round(usage.Frequency*10) / 10,
+		CreatedAt:   usage.CreatedAt,
+		AccessedAt:  usage.AccessedAt,
+	}
+}
+
+// bucketDays is the length of a usage bucket, a day for periods up to a month and a week beyond
+func bucketDays(since, now time.Time) int {
+	if now.Sub(since) > 31*24*time.Hour {
+		return 7
+	}
+	return 1
+}
+
+func bucketName(since, now time.Time) string {
+	if bucketDays(since, now) == 7 {
+		return "week"
+	}
+	return "day"
+}
+
+// usageBuckets sums the daily access counts into days or weeks from the day of since up to now,
+// including the empty ones
+func usageBuckets(counts []model.AccessCount, since, now time.Time) []usageBucket {
+	days := bucketDays(since, now)
+	since = since.Local()
+	first := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.Local)
+
+	buckets := []usageBucket{}
+	for from := first; !from.After(now); from = from.AddDate(0, 0, days) {
+		buckets = append(buckets, usageBucket{From: from, Via: map[model.AccessVia]int{}})
+	}
+
+	for _, count := range counts {
+		for i := len(buckets) - 1; i >= 0; i-- {
+			if !count.Day.Before(buckets[i].From) {
+				buckets[i].Accesses += count.Count
+				buckets[i].Via[count.Via] += count.Count
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+// printUsage draws a bar per bucket, followed by the totals per kind of access
+func printUsage(buckets []usageBucket) {
+	const barWidth = 40
+
+	most := 0
+	totals := map[model.AccessVia]int{}
+	for _, bucket := range buckets {
+		most = max(most, bucket.Accesses)
+		for via, count := range bucket.Via {
+			totals[via] += count
+		}
+	}
+
+	for _, bucket := range buckets {
+		bar := 0
+		if most > 0 {
+			bar = int(math.Ceil(float64(bucket.Accesses) * barWidth / float64(most)))
+		}
+		fmt.Printf("%s  %-*s %d\n", bucket.From.Format(time.DateOnly), barWidth, strings.Repeat("█", bar), bucket.Accesses)
+	}
+	fmt.Println()
+
+	if len(totals) == 0 {
+		logger.Warn("no accesses in this period")
+		return
+	}
+	kinds := []string{}
+	for _, via := range slices.Sorted(maps.Keys(totals)) {
+		kinds = append(kinds, fmt.Sprintf("%s %d", via, totals[via]))
+	}
+	logger.Muted("by kind: %s", strings.Join(kinds, ", "))
+}
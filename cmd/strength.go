@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"unicode/utf8"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/strength"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var strengthJSON bool
+
+var strengthCmd = &cobra.Command{
+	Use:   "strength",
+	Short: "Estimate how hard secrets read from stdin are to guess",
+	Long: `Estimate how many guesses an attacker needs for a secret, looking for common
+passwords, words and names, keyboard walks, repeats, sequences, years and dates,
+also reversed or with l33t substitutions like "p@ssw0rd".
+
+On a terminal the secret is prompted for without echo. Otherwise every line of
+stdin is rated on its own and reported by line number, secrets are never
+printed. Nothing is read from or written to the vault.
+
+The score goes from 0 (very weak) to 4 (very strong). The crack time assumes
+an attacker with a copy of the vault file making 10,000 guesses per second.`,
+	Example: `	kosh strength
+	pass show old | kosh strength
+	kosh strength --json < candidates.txt`,
+	Args: cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStrength(strengthJSON)
+	},
+}
+
+func init() {
+	strengthCmd.Flags().BoolVar(&strengthJSON, "json", false, "print the estimates as JSON")
+	rootCmd.AddCommand(strengthCmd)
+}
+
+// strengthJSONResult is the --json form of an estimate
+type strengthJSONResult struct {
+	Line         int      `json:"line"`
+	Score        int      `json:"score"`
+	Label        string   `json:"label"`
+	Bits         float64  `json:"bits"`
+	CrackSeconds float64  `json:"crackSeconds"`
+	CrackTime    string   `json:"crackTime"`
+	Warning      string   `json:"warning,omitempty"`
+	Suggestions  []string `json:"suggestions,omitempty"`
+}
+
+func runStrength(asJSON bool) error {
+	if asJSON {
+		// keep stdout valid JSON, the prompt goes to stderr
+		defer logger.Redirect(os.Stderr)()
+	}
+
+	var secrets [][]byte
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		secret, err := ui.ReadSecretField(constants.MsgEnterCredentialSecret)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+		secrets = append(secrets, secret)
+	} else {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			secrets = append(secrets, []byte(scanner.Text()))
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+	}
+	defer func() {
+		for _, secret := range secrets {
+			clear(secret)
+		}
+	}()
+
+	results := []strengthJSONResult{}
+	for i, secret := range secrets {
+		result := strength.Estimate(secret)
+		results = append(results, strengthJSONResult{
+			Line:         i + 1,
+			Score:        result.Score,
+			Label:        result.Label(),
+			Bits:         math.Round(result.Bits()*10) / 10,
+			CrackSeconds: result.CrackSeconds,
+			CrackTime:    result.CrackTime(),
+			Warning:      result.Warning,
+			Suggestions:  result.Suggestions,
+		})
+
+		if asJSON {
+			continue
+		}
+		if len(secrets) > 1 {
+			fmt.Printf("%-5d %d/4 %-12s %-20s %s\n", i+1, result.Score, result.Label(), result.CrackTime(), result.Warning)
+			continue
+		}
+		printStrength(result)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+	return nil
+}
+
+// printStrength shows an estimate with the patterns it found, without the matched parts
+func printStrength(result strength.Result) {
+	fmt.Printf("score:      %d/4 (%s)\n", result.Score, result.Label())
+	fmt.Printf("guesses:    10^%.1f (%.0f bits)\n", math.Log10(result.Guesses), result.Bits())
+	fmt.Printf("crack time: %s offline\n", result.CrackTime())
+	fmt.Println()
+
+	for _, match := range result.Sequence {
+		detail := ""
+		switch match.Pattern {
+		case strength.PatternDictionary:
+			detail = fmt.Sprintf("%s word #%d", match.Dictionary, match.Rank)
+			if match.Reversed {
+				detail += ", reversed"
+			}
+			if match.L33t {
+				detail += ", l33t"
+			}
+		case strength.PatternSpatial:
+			detail = fmt.Sprintf("%s walk, %d turns", match.Graph, match.Turns)
+		case strength.PatternRepeat:
+			detail = fmt.Sprintf("%d repeats", match.RepeatCount)
+		case strength.PatternSequence:
+			detail = fmt.Sprintf("%s sequence", match.SequenceName)
+		case strength.PatternRegex:
+			detail = "recent year"
+		}
+		logger.Muted("  %-10s %2d chars  %s", match.Pattern, utf8.RuneCountInString(match.Token), detail)
+	}
+
+	if result.Warning != "" {
+		fmt.Println()
+		logger.Warn("%s", result.Warning)
+	}
+	for _, suggestion := range result.Suggestions {
+		logger.Muted("  - %s", suggestion)
+	}
+}
+
+// reportStrength shows the score of a new secret, the label and user count as known to an attacker
+func reportStrength(secret []byte, inputs ...string) {
+	result := strength.Estimate(secret, inputs...)
+	logger.Muted(constants.MsgSecretStrength, result.Score, result.Label(), result.CrackTime())
+	if result.Warning != "" {
+		logger.Warn("%s", result.Warning)
+	}
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Organize credentials with tags",
+	Long: `Tags group credentials across folders. Tag names are case-insensitive and
+cannot contain whitespace or commas. Filter by tag with "list --tag <name>";
+search matches tag names as well.`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:     "add <id> <tag>...",
+	Short:   "Add one or more tags to a credential",
+	Example: `	kosh tag add 12 work ssh`,
+	Args:    cobra.MinimumNArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runTagAdd(id, args[1:])
+	},
+}
+
+var tagRmCmd = &cobra.Command{
+	Use:   "rm <id> <tag>...",
+	Short: "Remove one or more tags from a credential",
+	Args:  cobra.MinimumNArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runTagRm(id, args[1:])
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show all tags with the number of tagged credentials",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTagList()
+	},
+}
+
+func init() {
+	tagCmd.AddCommand(tagAddCmd, tagRmCmd, tagListCmd)
+	rootCmd.AddCommand(tagCmd)
+}
+
+func runTagAdd(id int, names []string) error {
+	tags, err := parseTags(names)
+	if err != nil {
+		return err
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if err := store.AddCredentialTag(credential.Id, tag); err != nil {
+			logger.Error("unable to add tag %s", tag)
+			return err
+		}
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	logger.Info("tagged %s (%s) with %s", credential.Label, credential.User, formatTags(tags))
+	return nil
+}
+
+func runTagRm(id int, names []string) error {
+	tags, err := parseTags(names)
+	if err != nil {
+		return err
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		err := store.RemoveCredentialTag(credential.Id, tag)
+		if err == sql.ErrNoRows {
+			logger.Warn("%s (%s) is not tagged with #%s", credential.Label, credential.User, tag)
+			continue
+		}
+		if err != nil {
+			logger.Error("unable to remove tag %s", tag)
+			return err
+		}
+		logger.Info("removed #%s from %s (%s)", tag, credential.Label, credential.User)
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	return nil
+}
+
+func runTagList() error {
+	tags, err := store.GetTags()
+	if err != nil {
+		logger.Error("unable to fetch tags")
+		return err
+	}
+
+	if len(tags) == 0 {
+		logger.Warn("no tags found")
+		logger.Info("add one with `tag add <id> <tag>`")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-6s\n", "TAG", "COUNT")
+	fmt.Printf("%s\n", strings.Repeat("─", 31))
+	for _, tag := range tags {
+		fmt.Printf("%-24s %-6d\n", truncate(tag.Name, 24), tag.Count)
+	}
+	fmt.Println()
+	return nil
+}
+
+// parseTags normalizes tag names given on the command line
+func parseTags(names []string) ([]string, error) {
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		tag, ok := model.NormalizeTag(name)
+		if !ok {
+			logger.Error("%s %q", constants.ErrInvalidTag.Error(), name)
+			return nil, constants.ErrInvalidTag
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func formatTags(tags []string) string {
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		formatted[i] = "#" + tag
+	}
+	return strings.Join(formatted, " ")
+}
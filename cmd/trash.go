@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// refuseIfTrashed tells whether a credential with the label and user is in the trash, and explains how to
+// get it out of the way. Adding over it would otherwise fail only after the secret was typed.
+func refuseIfTrashed(label, user string) bool {
+	id, err := store.GetTrashedCredentialId(label, user)
+	if err != nil {
+		return false
+	}
+	logger.Error("%s", constants.ErrCredentialInTrash.Error())
+	logger.Info("restore it with `trash restore %d` or delete it for good with `trash purge %d`", id, id)
+	return true
+}
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Show, restore or purge deleted credentials",
+	Long: `Credentials removed with "delete" are kept in the trash, hidden from list and
+search, until they are restored or purged. Credentials older than the
+trash.retention_days setting are purged automatically, 0 keeps them forever.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show the credentials in the trash",
+	Args:  cobra.ExactArgs(0),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTrashList()
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Take a credential out of the trash",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runTrashRestore(id)
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge [id]",
+	Short: "Permanently delete one or all credentials in the trash",
+	Args:  cobra.RangeArgs(0, 1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := 0
+		if len(args) > 0 {
+			var err error
+			if id, err = parseCredentialId(args[0]); err != nil {
+				return err
+			}
+		}
+		return runTrashPurge(id)
+	},
+}
+
+func init() {
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashPurgeCmd)
+	rootCmd.AddCommand(trashCmd)
+}
+
+func runTrashList() error {
+	credentials, err := store.GetTrashedCredentials()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	if len(credentials) == 0 {
+		logger.Warn("trash is empty")
+		return nil
+	}
+
+	retention := vault.TrashRetention()
+
+	fmt.Printf("%-4s %-18s %-18s %-20s %-20s\n", "ID", "LABEL", "USER", "DELETED AT", "PURGED AT")
+	fmt.Printf("%s\n", strings.Repeat("─", 84))
+	for _, credential := range credentials {
+		purgedAt := "never"
+		if retention > 0 {
+			purgedAt = credential.DeletedAt.Add(retention).Local().Format(time.DateTime)
+		}
+		fmt.Printf("%-4d %-18s %-18s %-20s %-20s\n",
+			credential.Id,
+			truncate(credential.Label, 18),
+			truncate(credential.User, 18),
+			credential.DeletedAt.Local().Format(time.DateTime),
+			purgedAt,
+		)
+	}
+	fmt.Println()
+	return nil
+}
+
+func runTrashRestore(id int) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	err = store.RestoreCredentialById(id)
+	if err == sql.ErrNoRows {
+		logger.Error("%s", constants.ErrCredentialNotInTrash.Error())
+		return nil
+	}
+	if err != nil {
+		logger.Error("unable to restore credential")
+		return err
+	}
+	vault.SealCredentials(id)
+	recordEvent(model.AuditEventRestore, id)
+	logger.Info(constants.MsgRestoredCredential)
+	return nil
+}
+
+// runTrashPurge permanently deletes the credential with the given id from the trash, or every
+// credential in the trash when id is 0
+func runTrashPurge(id int) error {
+	password, err := ui.ReadSecretField(constants.MsgEnterMasterPassword)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if err := vault.VerifyMasterPassword(password); err != nil {
+		logger.Error("%s", err)
+		return err
+	}
+
+	credentials, err := store.GetTrashedCredentials()
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	var target *model.CredentialSummary
+	if id != 0 {
+		for i := range credentials {
+			if credentials[i].Id == id {
+				target = &credentials[i]
+				break
+			}
+		}
+		if target == nil {
+			logger.Error("%s", constants.ErrCredentialNotInTrash.Error())
+			return nil
+		}
+	} else if len(credentials) == 0 {
+		logger.Warn("trash is empty")
+		return nil
+	}
+
+	prompt := fmt.Sprintf("permanently delete %d credentials in trash? %s", len(credentials), constants.MsgAreYouSure)
+	confirmation := "purge trash"
+	if target != nil {
+		prompt = fmt.Sprintf("permanently delete %s (%s)? %s", target.Label, target.User, constants.MsgAreYouSure)
+		confirmation = fmt.Sprintf("purge %s %s", target.Label, target.User)
+	}
+
+	logger.Warn(constants.MsgOperationIsPermanent)
+	confirm, err := ui.ConfirmWithText(prompt, confirmation)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+	if !confirm {
+		logger.Info(constants.MsgOperationAborted)
+		return nil
+	}
+
+	if target != nil {
+		err = store.DeleteCredentialById(target.Id)
+	} else {
+		_, err = store.PurgeTrashedCredentials(time.Time{})
+	}
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToDeleteCredential.Error())
+		return err
+	}
+
+	for _, credential := range credentials {
+		if target == nil || credential.Id == target.Id {
+			vault.RecordEvent(model.AuditEventDelete, credential.Id, credential.Label, credential.User)
+		}
+	}
+	logger.Info(constants.MsgPurgedTrash)
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
 	"git.plutolab.org/plutolab/kosh/internal/logger"
@@ -57,11 +58,11 @@ func runUpdate(id int) error {
 		return err
 	}
 
-	updateOptions := []string{"label", "user", "secret", "abort"}
+	updateOptions := []string{"label", "user", "secret", "otp", "abort"}
 	option := ui.GetOptionFieldWithRetry(
 		constants.MsgSelectCredentialFieldToUpdate,
 		updateOptions,
-		3,
+		len(updateOptions)-1, // abort
 	)
 
 	switch option {
@@ -72,6 +73,8 @@ func runUpdate(id int) error {
 	case 2:
 		err = updateSecret(credential)
 	case 3:
+		err = updateOTP(credential)
+	case 4:
 		logger.Info(constants.MsgOperationAborted)
 		return nil
 	default:
@@ -130,6 +133,8 @@ func updateLabel(credential *model.Credential) error {
 	})
 
 	if err == nil {
+		vault.SealCredentials(credential.Id)
+		vault.RecordEvent(model.AuditEventUpdate, credential.Id, newLabel, credential.User)
 		logger.Info("%s", constants.MsgUpdatedCredential)
 	}
 
@@ -180,6 +185,8 @@ func updateUser(credential *model.Credential) error {
 	})
 
 	if err == nil {
+		vault.SealCredentials(credential.Id)
+		vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, newUser)
 		logger.Info("%s", constants.MsgUpdatedCredential)
 	}
 
@@ -203,6 +210,8 @@ func updateSecret(credential *model.Credential) error {
 		logger.Error("%s", constants.ErrSecretDoesNotMatch.Error())
 		return nil
 	}
+	warnIfBreached(newSecret)
+	reportStrength(newSecret, credential.Label, credential.User)
 
 	logger.Warn(constants.MsgOverwriteCredential)
 	confirm, err := ui.ConfirmWithText(
@@ -223,6 +232,47 @@ func updateSecret(credential *model.Credential) error {
 	if err != nil {
 		logger.Error("%s", constants.ErrFailedToSaveCredential.Error())
 		logger.Debug("%v", err)
+	} else {
+		vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+		logger.Info("%s", constants.MsgUpdatedCredential)
+	}
+
+	return nil
+}
+
+func updateOTP(credential *model.Credential) error {
+	uri, err := ui.ReadSecretField(constants.MsgEnterCredentialOTPURI)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToReadInput.Error())
+		return err
+	}
+
+	if credential.HasOTP() {
+		logger.Warn(constants.MsgOverwriteCredential)
+		confirm, err := ui.ConfirmWithText(
+			constants.MsgOperationIsPermanent,
+			fmt.Sprintf("update %s one-time password", credential.Label),
+		)
+		if err != nil {
+			logger.Error("%s", constants.ErrFailedToReadInput.Error())
+			return err
+		}
+
+		if !confirm {
+			logger.Info(constants.MsgOperationAborted)
+			return nil
+		}
+	}
+
+	err = vault.SetCredentialOTP(credential.Id, strings.TrimSpace(string(uri)))
+	if err != nil {
+		logger.Error("%s", err.Error())
+		return nil
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+
+	if len(strings.TrimSpace(string(uri))) == 0 {
+		logger.Info("%s", constants.MsgRemovedOTP)
 	} else {
 		logger.Info("%s", constants.MsgUpdatedCredential)
 	}
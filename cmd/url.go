@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
+	"github.com/spf13/cobra"
+)
+
+var urlCmd = &cobra.Command{
+	Use:   "url",
+	Short: "Manage the websites and apps a credential is used for",
+	Long: `Save the websites and Android apps a credential belongs to, "match" finds the
+credential for a URL later. URLs are normalized to scheme, host and port,
+apps are given as androidapp://<package name>.`,
+}
+
+var urlAddCmd = &cobra.Command{
+	Use:   "add <id> <url>...",
+	Short: "Save one or more URLs with a credential",
+	Example: `	kosh url add 12 github.com
+	kosh url add 12 https://gitlab.example.com:8443 androidapp://com.example.app`,
+	Args: cobra.MinimumNArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runURLAdd(id, args[1:])
+	},
+}
+
+var urlRmCmd = &cobra.Command{
+	Use:   "rm <id> <url>...",
+	Short: "Remove one or more URLs from a credential",
+	Args:  cobra.MinimumNArgs(2),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runURLRm(id, args[1:])
+	},
+}
+
+var urlListCmd = &cobra.Command{
+	Use:   "list <id>",
+	Short: "Show the URLs of a credential",
+	Args:  cobra.ExactArgs(1),
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := parseCredentialId(args[0])
+		if err != nil {
+			return err
+		}
+		return runURLList(id)
+	},
+}
+
+func init() {
+	urlCmd.AddCommand(urlAddCmd, urlRmCmd, urlListCmd)
+	rootCmd.AddCommand(urlCmd)
+}
+
+func runURLAdd(id int, raws []string) error {
+	urls, err := parseURLs(raws)
+	if err != nil {
+		return nil
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if err := store.AddCredentialURL(credential.Id, url); err != nil {
+			logger.Error("unable to save url %s", url)
+			return err
+		}
+		logger.Info("saved %s with %s (%s)", url, credential.Label, credential.User)
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	return nil
+}
+
+func runURLRm(id int, raws []string) error {
+	urls, err := parseURLs(raws)
+	if err != nil {
+		return nil
+	}
+
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	for _, url := range urls {
+		err := store.RemoveCredentialURL(credential.Id, url)
+		if err == sql.ErrNoRows {
+			logger.Warn("%s is not saved with %s (%s)", url, credential.Label, credential.User)
+			continue
+		}
+		if err != nil {
+			logger.Error("unable to remove url %s", url)
+			return err
+		}
+		logger.Info("removed %s from %s (%s)", url, credential.Label, credential.User)
+	}
+	vault.RecordEvent(model.AuditEventUpdate, credential.Id, credential.Label, credential.User)
+	return nil
+}
+
+func runURLList(id int) error {
+	credential, err := getCredentialForField(id)
+	if credential == nil {
+		return err
+	}
+
+	urls, err := store.GetCredentialURLs(credential.Id)
+	if err != nil {
+		logger.Error("%s", constants.ErrFailedToFetchCredential.Error())
+		return err
+	}
+
+	logger.Muted("urls of %s (%s)\n", credential.Label, credential.User)
+	if len(urls) == 0 {
+		logger.Warn("no urls found")
+		return nil
+	}
+	for _, url := range urls {
+		fmt.Println(url)
+	}
+	fmt.Println()
+	return nil
+}
+
+// parseURLs normalizes URLs given on the command line
+func parseURLs(raws []string) ([]string, error) {
+	urls := make([]string, 0, len(raws))
+	for _, raw := range raws {
+		target, err := urlmatch.Parse(raw)
+		if err != nil {
+			logger.Error("%s %q", urlmatch.ErrInvalidURL.Error(), raw)
+			return nil, err
+		}
+		urls = append(urls, target.String())
+	}
+	return urls, nil
+}
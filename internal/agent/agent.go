@@ -0,0 +1,55 @@
+// Package agent implements the unlock agent: a local process that keeps the vault private key in
+// memory for a limited time and decrypts secrets on behalf of headless callers, like the browser
+// native messaging host, that cannot prompt for the master password. The key never leaves the agent;
+// callers send sealed secrets over a Unix socket and get the plain text back.
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const SocketName = "agent.sock"
+
+// maxMessageSize bounds a single request or response line, large enough for long secure notes
+const maxMessageSize = 4 << 20
+
+var (
+	ErrNotRunning = errors.New("unlock agent is not running, start it with `kosh agent`")
+	ErrLocked     = errors.New("unlock agent is locked")
+)
+
+// operations understood by the agent
+const (
+	opStatus  = "status"
+	opDecrypt = "decrypt"
+	opMAC     = "mac"
+	opLock    = "lock"
+)
+
+// request and response are exchanged as one JSON document per line, []byte fields are base64
+type request struct {
+	Op        string `json:"op"`
+	Ephemeral []byte `json:"ephemeral,omitempty"`
+	Secret    []byte `json:"secret,omitempty"`
+	Nonce     []byte `json:"nonce,omitempty"`
+	Data      []byte `json:"data,omitempty"`
+}
+
+type response struct {
+	Error     string    `json:"error,omitempty"`
+	Plaintext []byte    `json:"plaintext,omitempty"`
+	MAC       []byte    `json:"mac,omitempty"`
+	Expires   time.Time `json:"expires,omitzero"`
+}
+
+// SocketPath returns the path of the agent socket, ~/.kosh/agent.sock
+func SocketPath() (string, error) {
+	userDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, ".kosh", SocketName), nil
+}
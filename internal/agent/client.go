@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+)
+
+// Client talks to a running unlock agent
+type Client struct {
+	path string
+}
+
+// NewClient creates a client for the agent socket of the current user
+func NewClient() (*Client, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{path}, nil
+}
+
+// Status returns when the agent locks itself, ErrNotRunning if no agent is listening
+func (c *Client) Status() (time.Time, error) {
+	res, err := c.call(&request{Op: opStatus})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return res.Expires, nil
+}
+
+// Decrypt opens a secret sealed for the vault public key
+func (c *Client) Decrypt(ephemeral, secret, nonce []byte) ([]byte, error) {
+	res, err := c.call(&request{Op: opDecrypt, Ephemeral: ephemeral, Secret: secret, Nonce: nonce})
+	if err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+// MAC computes the vault integrity MAC of data, it satisfies integrity.MACFunc
+func (c *Client) MAC(data []byte) ([]byte, error) {
+	res, err := c.call(&request{Op: opMAC, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	return res.MAC, nil
+}
+
+// Lock makes the agent wipe the key and exit
+func (c *Client) Lock() error {
+	_, err := c.call(&request{Op: opLock})
+	return err
+}
+
+func (c *Client) call(req *request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.path, time.Second)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxMessageSize)
+	if !scanner.Scan() {
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+		// the agent closed the connection without answering, it refused the peer
+		return nil, ErrNotRunning
+	}
+
+	var res response
+	if err := json.Unmarshal(scanner.Bytes(), &res); err != nil {
+		return nil, err
+	}
+	if res.Error == ErrLocked.Error() {
+		return nil, ErrLocked
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	return &res, nil
+}
@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/core"
+	"git.plutolab.org/plutolab/kosh/internal/integrity"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/peercred"
+)
+
+// Server holds the unlocked vault private key until it expires or is locked
+type Server struct {
+	mu       sync.Mutex
+	key      []byte
+	expires  time.Time
+	listener net.Listener
+}
+
+// NewServer creates an agent for an unlocked vault private key, the key is wiped once the agent is
+// locked or the timeout has passed
+func NewServer(privateKey []byte, timeout time.Duration) *Server {
+	return &Server{key: privateKey, expires: time.Now().Add(timeout)}
+}
+
+// Listen creates the agent socket with owner-only permissions. A socket left behind by an agent that
+// is no longer running is replaced, a live agent is reported with an error.
+func Listen(path string) (net.Listener, error) {
+	if _, err := (&Client{path}).Status(); err == nil {
+		return nil, errors.New("unlock agent is already running")
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// Serve answers requests until the agent expires or is locked, then wipes the key and returns
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	timer := time.AfterFunc(time.Until(s.expires), s.Lock)
+	defer timer.Stop()
+	defer s.Lock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.locked() {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	// only processes of the vault owner may use the key
+	cred, err := peercred.Get(conn)
+	if err == nil && !cred.SameUser() {
+		logger.Debug("agent:refused connection from uid %d pid %d", cred.UID, cred.PID)
+		return
+	}
+	if err != nil && err != peercred.ErrUnsupported {
+		logger.Debug("agent:unable to read peer credentials: %s", err.Error())
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, maxMessageSize)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			encoder.Encode(response{Error: "invalid request"})
+			return
+		}
+		if err := encoder.Encode(s.answer(&req)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) answer(req *request) response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.key == nil {
+		return response{Error: ErrLocked.Error()}
+	}
+
+	switch req.Op {
+	case opStatus:
+		return response{Expires: s.expires}
+	case opDecrypt:
+		plainText, err := core.OpenSecret(s.key, req.Ephemeral, req.Secret, req.Nonce)
+		if err != nil {
+			return response{Error: "unable to decrypt secret"}
+		}
+		return response{Plaintext: plainText, Expires: s.expires}
+	case opMAC:
+		// the integrity key is derived per request so only the vault private key is kept in memory
+		key, err := integrity.Key(s.key)
+		if err != nil {
+			return response{Error: "unable to derive integrity key"}
+		}
+		mac, _ := integrity.Keyed(key)(req.Data)
+		clear(key)
+		return response{MAC: mac, Expires: s.expires}
+	case opLock:
+		s.wipe()
+		return response{}
+	}
+	return response{Error: "unknown operation " + req.Op}
+}
+
+// Lock wipes the key and makes Serve return
+func (s *Server) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wipe()
+}
+
+// wipe clears the key and stops accepting connections, s.mu must be held
+func (s *Server) wipe() {
+	if s.key != nil {
+		clear(s.key)
+		s.key = nil
+	}
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+func (s *Server) locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.key == nil
+}
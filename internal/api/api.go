@@ -0,0 +1,124 @@
+// Package api implements the local JSON API served by `kosh serve` over a Unix socket. Every route is
+// versioned under /v1, authenticated with a per-client bearer token whose scope is a set of label
+// patterns plus read and write rights, and recorded in the audit trail.
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const (
+	Version = "v1"
+
+	// TokenPrefix makes tokens easy to recognize, e.g. for secret scanners
+	TokenPrefix = "kosh_"
+)
+
+// Credential is the JSON form of a credential, Secret is only set when a single credential is fetched
+type Credential struct {
+	Id         int       `json:"id"`
+	Label      string    `json:"label"`
+	User       string    `json:"user"`
+	Folder     string    `json:"folder,omitempty"`
+	Tags       []string  `json:"tags,omitempty"`
+	Secret     string    `json:"secret,omitempty"`
+	Score      float64   `json:"score,omitempty"`
+	CreatedAt  time.Time `json:"createdAt,omitzero"`
+	UpdatedAt  time.Time `json:"updatedAt,omitzero"`
+	AccessedAt time.Time `json:"accessedAt,omitzero"`
+
+	SecretChangedAt   time.Time `json:"secretChangedAt,omitzero"`
+	MetadataChangedAt time.Time `json:"metadataChangedAt,omitzero"`
+}
+
+// CredentialInput is the body of add and update requests, empty fields are left unchanged on update
+type CredentialInput struct {
+	Label  string `json:"label"`
+	User   string `json:"user"`
+	Secret string `json:"secret"`
+}
+
+// GenerateInput is the body of a generate request. Character groups default to enabled; with a
+// label and user the password is saved as a new credential.
+type GenerateInput struct {
+	Length  int            `json:"length"`
+	Upper   *bool          `json:"upper"`
+	Lower   *bool          `json:"lower"`
+	Digit   *bool          `json:"digit"`
+	Symbol  *bool          `json:"symbol"`
+	Require map[string]int `json:"require"`
+	Label   string         `json:"label"`
+	User    string         `json:"user"`
+}
+
+// GenerateOutput answers a generate request, Credential is set when the password was saved
+type GenerateOutput struct {
+	Password   string      `json:"password"`
+	Credential *Credential `json:"credential,omitempty"`
+}
+
+// Error is the body of every failed request
+type Error struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// NewToken creates a random client token and the hash that is stored in the vault
+func NewToken() (token, hash string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	token = TokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token, tokens carry enough entropy to not need a
+// slow password hash
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchLabel reports whether a label matches any of the patterns. Patterns are case-insensitive
+// globs where * matches any run of characters, "/" included, and ? a single character.
+func MatchLabel(patterns []string, label string) bool {
+	label = strings.ToLower(label)
+	for _, pattern := range patterns {
+		if matchGlob([]rune(strings.ToLower(pattern)), []rune(label)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches iteratively, backtracking to the last * on a mismatch
+func matchGlob(pattern, s []rune) bool {
+	pIdx, sIdx := 0, 0
+	starIdx, matchIdx := -1, 0
+	for sIdx < len(s) {
+		switch {
+		case pIdx < len(pattern) && (pattern[pIdx] == '?' || pattern[pIdx] == s[sIdx]):
+			pIdx++
+			sIdx++
+		case pIdx < len(pattern) && pattern[pIdx] == '*':
+			starIdx, matchIdx = pIdx, sIdx
+			pIdx++
+		case starIdx >= 0:
+			pIdx = starIdx + 1
+			matchIdx++
+			sIdx = matchIdx
+		default:
+			return false
+		}
+	}
+	for pIdx < len(pattern) && pattern[pIdx] == '*' {
+		pIdx++
+	}
+	return pIdx == len(pattern)
+}
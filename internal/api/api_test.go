@@ -0,0 +1,83 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchLabel(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		label    string
+		want     bool
+	}{
+		{"exact", []string{"github"}, "github", true},
+		{"case insensitive", []string{"GitHub"}, "github", true},
+		{"prefix glob", []string{"aws-*"}, "aws-prod", true},
+		{"star crosses slashes", []string{"ci/*"}, "ci/deploy/key", true},
+		{"star matches empty", []string{"aws*"}, "aws", true},
+		{"single character", []string{"db?"}, "db1", true},
+		{"single character needs one", []string{"db?"}, "db", false},
+		{"inner star", []string{"*-prod-*"}, "eu-prod-db", true},
+		{"no match", []string{"aws-*", "ci/*"}, "github", false},
+		{"match all", []string{"*"}, "anything", true},
+		{"no patterns", nil, "github", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchLabel(tt.patterns, tt.label); got != tt.want {
+				t.Errorf("MatchLabel(%q, %q) = %v, want %v", tt.patterns, tt.label, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(token, TokenPrefix) {
+		t.Errorf("token %q lacks prefix %q", token, TokenPrefix)
+	}
+	if hash != HashToken(token) {
+		t.Errorf("hash does not match HashToken of the token")
+	}
+
+	other, _, _ := NewToken()
+	if other == token {
+		t.Errorf("two tokens are equal")
+	}
+}
+
+func TestListen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.sock")
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("socket permissions = %o, want 600", perm)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Listen() left %d entries next to the socket, want only the socket", len(entries))
+	}
+	if _, err := Listen(path); err == nil {
+		t.Error("Listen() on a socket in use succeeded")
+	}
+
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("socket still exists after Close()")
+	}
+}
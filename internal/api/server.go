@@ -0,0 +1,592 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/core"
+	"git.plutolab.org/plutolab/kosh/internal/generator"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/peercred"
+	"git.plutolab.org/plutolab/kosh/internal/search"
+	"git.plutolab.org/plutolab/kosh/internal/storage"
+)
+
+// error codes of failed requests
+const (
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInvalid      = "invalid_request"
+	CodeLocked       = "locked"
+	CodeFailed       = "failed"
+)
+
+const maxBodySize = 1 << 20
+
+type right int
+
+const (
+	rightRead right = iota
+	rightWrite
+)
+
+// Server answers API requests with an unlocked vault private key
+type Server struct {
+	store storage.Store
+	vault *core.VaultService
+
+	// serializes requests, net/http handles them concurrently but the vault service keeps state between
+	// calls (the integrity report, the head of the audit chain) and reads update access counters
+	vaultMu sync.Mutex
+
+	mu  sync.RWMutex
+	key []byte
+
+	// refuse connections whose peer credentials cannot be read, see SkipPeerCheck
+	checkPeer bool
+}
+
+// call collects what the audit trail records about a request while it is handled
+type call struct {
+	peer         *peercred.Cred
+	client       *model.APIClient
+	credentialId int
+}
+
+type contextKey int
+
+const (
+	peerKey contextKey = iota
+	callKey
+)
+
+// NewServer creates an API server, the private key is wiped by Close
+func NewServer(store storage.Store, vault *core.VaultService, privateKey []byte) *Server {
+	return &Server{store: store, vault: vault, key: privateKey, checkPeer: true}
+}
+
+// SkipPeerCheck serves connections whose peer credentials cannot be read, e.g. on platforms without
+// SO_PEERCRED, relying on the permissions of the socket alone. Peers known to run as another user are
+// still refused. Only for when the user asks for it.
+func (s *Server) SkipPeerCheck() {
+	s.checkPeer = false
+}
+
+// Listen creates the API socket with owner-only permissions, replacing a socket left behind by a
+// server that is no longer running. The socket is created in a new 0700 directory and only moved to
+// path once it is 0600, so other users can never reach it, also outside ~/.kosh.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, errors.New("api server is already running on " + path)
+	}
+	os.Remove(path)
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".kosh")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	private := filepath.Join(dir, "s")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: private, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed at path on close, not where it was created
+	listener.SetUnlinkOnClose(false)
+	if err := os.Chmod(private, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	if err := os.Rename(private, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &socketListener{UnixListener: listener, path: path}, nil
+}
+
+// socketListener removes the socket file when closed
+type socketListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *socketListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// Serve answers requests on the listener until ctx is done, then wipes the key
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	httpServer := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			cred, err := peercred.Get(conn)
+			if err != nil {
+				logger.Debug("serve:unable to read peer credentials: %s", err.Error())
+				return ctx
+			}
+			return context.WithValue(ctx, peerKey, cred)
+		},
+	}
+
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+	defer s.Close()
+
+	if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Close wipes the vault private key, later requests fail with CodeLocked
+func (s *Server) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key != nil {
+		clear(s.key)
+		s.key = nil
+	}
+}
+
+// Handler returns the versioned API routes wrapped in the peer check and audit trail
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/credentials", s.authorize(rightRead, s.listCredentials))
+	mux.HandleFunc("GET /v1/search", s.authorize(rightRead, s.searchCredentials))
+	mux.HandleFunc("GET /v1/credentials/{id}", s.authorize(rightRead, s.getCredential))
+	mux.HandleFunc("POST /v1/credentials", s.authorize(rightWrite, s.addCredential))
+	mux.HandleFunc("PATCH /v1/credentials/{id}", s.authorize(rightWrite, s.updateCredential))
+	mux.HandleFunc("DELETE /v1/credentials/{id}", s.authorize(rightWrite, s.deleteCredential))
+	mux.HandleFunc("POST /v1/generate", s.authorize(rightRead, s.generate))
+	return s.audit(mux)
+}
+
+// audit records every request, including rejected ones, and refuses processes of other users and, unless
+// the peer check is skipped, processes that cannot be identified. Requests are handled one at a time.
+func (s *Server) audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.vaultMu.Lock()
+		defer s.vaultMu.Unlock()
+
+		c := &call{}
+		if cred, ok := r.Context().Value(peerKey).(*peercred.Cred); ok {
+			c.peer = cred
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		switch {
+		case c.peer == nil && s.checkPeer:
+			writeError(recorder, http.StatusForbidden, CodeForbidden, errors.New("unable to identify the peer process"))
+		case c.peer != nil && !c.peer.SameUser():
+			writeError(recorder, http.StatusForbidden, CodeForbidden, errors.New("peer is not the vault owner"))
+		default:
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), callKey, c)))
+		}
+
+		entry := &model.APIAuditEntry{
+			Method:       r.Method,
+			Path:         r.URL.Path,
+			Status:       recorder.status,
+			CredentialId: c.credentialId,
+			PID:          -1,
+			UID:          -1,
+		}
+		if c.client != nil {
+			entry.Client = c.client.Name
+		}
+		if c.peer != nil {
+			entry.PID, entry.UID = c.peer.PID, c.peer.UID
+		}
+		if err := s.store.AddAPIAuditEntry(entry); err != nil {
+			logger.Error("unable to write api audit entry: %s", err.Error())
+		}
+	})
+}
+
+// authorize checks the bearer token and the right the route needs
+func (s *Server) authorize(need right, handler func(w http.ResponseWriter, r *http.Request, c *call)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(callKey).(*call)
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, errors.New("missing bearer token"))
+			return
+		}
+		client, err := s.store.GetAPIClientByTokenHash(HashToken(token))
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, errors.New("unknown token"))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, CodeFailed, err)
+			return
+		}
+		c.client = client
+		s.store.TouchAPIClient(client.Id)
+
+		if (need == rightRead && !client.Read) || (need == rightWrite && !client.Write) {
+			writeError(w, http.StatusForbidden, CodeForbidden, errors.New("token lacks the right for this call"))
+			return
+		}
+
+		handler(w, r, c)
+	}
+}
+
+func (s *Server) listCredentials(w http.ResponseWriter, r *http.Request, c *call) {
+	query := r.URL.Query()
+
+	tags := []string{}
+	for _, name := range query["tag"] {
+		tag, ok := model.NormalizeTag(name)
+		if !ok {
+			writeError(w, http.StatusBadRequest, CodeInvalid, errors.New("invalid tag "+name))
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	summaries, err := s.store.SearchCredentialByLabelOrUser(query.Get("label"), query.Get("user"), tags, model.NormalizeFolder(query.Get("folder")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return
+	}
+
+	credentials := []Credential{}
+	for _, summary := range summaries {
+		if MatchLabel(c.client.Labels, summary.Label) {
+			credentials = append(credentials, fromSummary(&summary))
+		}
+	}
+	writeJSON(w, http.StatusOK, credentials)
+}
+
+func (s *Server) searchCredentials(w http.ResponseWriter, r *http.Request, c *call) {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 10
+	}
+
+	all, err := s.store.GetAllCredentials()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return
+	}
+
+	// rank only what the client may see, scores do not leak other credentials
+	scoped := make([]model.Credential, 0, len(all))
+	for _, credential := range all {
+		if MatchLabel(c.client.Labels, credential.Label) {
+			scoped = append(scoped, credential)
+		}
+	}
+
+	results := search.BestMatches(query.Get("q"), query.Get("user"), scoped, nil, time.Now())
+	credentials := []Credential{}
+	for _, result := range results[:min(len(results), limit)] {
+		credential := fromCredential(&result.Credential)
+		credential.Score = result.Score
+		credentials = append(credentials, credential)
+	}
+	writeJSON(w, http.StatusOK, credentials)
+}
+
+func (s *Server) getCredential(w http.ResponseWriter, r *http.Request, c *call) {
+	credential, ok := s.scopedCredential(w, r, c)
+	if !ok {
+		return
+	}
+
+	secret, err := s.decrypt(credential)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, CodeLocked, err)
+		return
+	}
+
+	s.store.RecordAccess(&model.AccessEvent{CredentialId: credential.Id, Via: model.AccessViaAPI, At: time.Now()})
+	s.vault.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+
+	out := fromCredential(credential)
+	out.Secret = secret
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) addCredential(w http.ResponseWriter, r *http.Request, c *call) {
+	var input CredentialInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+	if input.Label == "" || input.User == "" || input.Secret == "" {
+		writeError(w, http.StatusBadRequest, CodeInvalid, errors.New("label, user and secret are required"))
+		return
+	}
+
+	credential, ok := s.add(w, c, input.Label, input.User, []byte(input.Secret))
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusCreated, fromCredential(credential))
+}
+
+func (s *Server) updateCredential(w http.ResponseWriter, r *http.Request, c *call) {
+	credential, ok := s.scopedCredential(w, r, c)
+	if !ok {
+		return
+	}
+
+	var input CredentialInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	if input.Label != "" || input.User != "" {
+		label, user := cmp.Or(input.Label, credential.Label), cmp.Or(input.User, credential.User)
+		if !MatchLabel(c.client.Labels, label) {
+			writeError(w, http.StatusForbidden, CodeForbidden, errors.New("label is outside the token scope"))
+			return
+		}
+		if existing, _ := s.store.GetCredentialByLabelAndUser(label, user); existing != nil && existing.Id != credential.Id {
+			writeError(w, http.StatusConflict, CodeConflict, errors.New("credential already exists"))
+			return
+		}
+		if err := s.store.UpdateCredential(&model.Credential{Id: credential.Id, Label: input.Label, User: input.User}); err != nil {
+			writeError(w, http.StatusInternalServerError, CodeFailed, err)
+			return
+		}
+		s.vault.SealCredentials(credential.Id)
+	}
+
+	if input.Secret != "" {
+		if err := s.vault.UpdateCredentialSecret(credential.Id, []byte(input.Secret)); err != nil {
+			writeError(w, http.StatusInternalServerError, CodeFailed, err)
+			return
+		}
+	}
+
+	updated, err := s.store.GetCredentialById(credential.Id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return
+	}
+	s.vault.RecordEvent(model.AuditEventUpdate, updated.Id, updated.Label, updated.User)
+	writeJSON(w, http.StatusOK, fromCredential(updated))
+}
+
+func (s *Server) deleteCredential(w http.ResponseWriter, r *http.Request, c *call) {
+	credential, ok := s.scopedCredential(w, r, c)
+	if !ok {
+		return
+	}
+
+	// API deletes go to the trash, purging stays a deliberate CLI action
+	if err := s.store.TrashCredentialById(credential.Id); err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return
+	}
+	s.vault.SealCredentials(credential.Id)
+	s.vault.RecordEvent(model.AuditEventTrash, credential.Id, credential.Label, credential.User)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) generate(w http.ResponseWriter, r *http.Request, c *call) {
+	var input GenerateInput
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	length := input.Length
+	if length <= 0 {
+		length = 20
+	}
+	if length > 1024 {
+		writeError(w, http.StatusBadRequest, CodeInvalid, errors.New("length must be at most 1024"))
+		return
+	}
+
+	require := generator.RequireConfig{}
+	for group, count := range input.Require {
+		switch group {
+		case generator.LowerCharGroup, generator.UpperCharGroup, generator.DigitCharGroup, generator.SymbolCharGroup:
+		default:
+			writeError(w, http.StatusBadRequest, CodeInvalid, errors.New("unknown character group "+group))
+			return
+		}
+		if count < 0 || count > length {
+			writeError(w, http.StatusBadRequest, CodeInvalid, errors.New("invalid count for "+group))
+			return
+		}
+		require[generator.CharGroup(group)] = count
+	}
+
+	password, err := generator.Password(length, enabled(input.Upper), enabled(input.Lower), enabled(input.Digit), enabled(input.Symbol), require)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalid, err)
+		return
+	}
+
+	out := GenerateOutput{Password: string(password)}
+	if input.Label != "" || input.User != "" {
+		if !c.client.Write {
+			writeError(w, http.StatusForbidden, CodeForbidden, errors.New("saving needs a token with write right"))
+			return
+		}
+		credential, ok := s.add(w, c, input.Label, input.User, password)
+		if !ok {
+			return
+		}
+		saved := fromCredential(credential)
+		out.Credential = &saved
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// add saves a new credential, an existing label and user is a conflict rather than an overwrite
+func (s *Server) add(w http.ResponseWriter, c *call, label, user string, secret []byte) (*model.Credential, bool) {
+	if !MatchLabel(c.client.Labels, label) {
+		writeError(w, http.StatusForbidden, CodeForbidden, errors.New("label is outside the token scope"))
+		return nil, false
+	}
+	if existing, _ := s.store.GetCredentialByLabelAndUser(label, user); existing != nil {
+		writeError(w, http.StatusConflict, CodeConflict, errors.New("credential already exists"))
+		return nil, false
+	}
+
+	if err := s.vault.AddCredential(label, user, secret); errors.Is(err, constants.ErrCredentialInTrash) {
+		writeError(w, http.StatusConflict, CodeConflict, err)
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return nil, false
+	}
+	credential, err := s.store.GetCredentialByLabelAndUser(label, user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return nil, false
+	}
+	c.credentialId = credential.Id
+	s.vault.RecordEvent(model.AuditEventAdd, credential.Id, credential.Label, credential.User)
+	return credential, true
+}
+
+// scopedCredential loads the credential of the {id} path value. Credentials outside the token scope
+// are reported as not found, so a client cannot probe for them.
+func (s *Server) scopedCredential(w http.ResponseWriter, r *http.Request, c *call) (*model.Credential, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalid, errors.New("id must be an integer"))
+		return nil, false
+	}
+	c.credentialId = id
+
+	credential, err := s.store.GetCredentialById(id)
+	if err == sql.ErrNoRows || (err == nil && !MatchLabel(c.client.Labels, credential.Label)) {
+		writeError(w, http.StatusNotFound, CodeNotFound, errors.New("credential not found"))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeFailed, err)
+		return nil, false
+	}
+	return credential, true
+}
+
+func (s *Server) decrypt(credential *model.Credential) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.key == nil {
+		return "", errors.New("api server is locked")
+	}
+	return s.vault.DecryptCredentialWithKey(credential, s.key)
+}
+
+// statusRecorder remembers the status code written, for the audit trail
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalid, err)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, err error) {
+	writeJSON(w, status, Error{Code: code, Error: err.Error()})
+}
+
+func fromCredential(c *model.Credential) Credential {
+	return Credential{
+		Id:         c.Id,
+		Label:      c.Label,
+		User:       c.User,
+		Folder:     c.Folder,
+		Tags:       c.Tags,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		AccessedAt: c.AccessedAt,
+
+		SecretChangedAt:   c.SecretChangedAt,
+		MetadataChangedAt: c.MetadataChangedAt,
+	}
+}
+
+func fromSummary(c *model.CredentialSummary) Credential {
+	return Credential{
+		Id:         c.Id,
+		Label:      c.Label,
+		User:       c.User,
+		Folder:     c.Folder,
+		Tags:       c.Tags,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		AccessedAt: c.AccessedAt,
+
+		SecretChangedAt:   c.SecretChangedAt,
+		MetadataChangedAt: c.MetadataChangedAt,
+	}
+}
+
+// enabled defaults a character group flag to true when it is left out
+func enabled(flag *bool) bool {
+	return flag == nil || *flag
+}
@@ -0,0 +1,98 @@
+// Package auditlog chains the entries of the audit log. Every entry stores a MAC of its own fields
+// together with the hash of the previous entry, keyed with the integrity MAC of the vault, so changing,
+// inserting or removing an entry breaks every keyed hash after it and cannot be repaired without the
+// master password. Entries recorded while the vault is locked fall back to plain SHA-256; they are
+// covered once a keyed entry follows them. The integrity record anchors the length and last hash of
+// the log, so entries cut off the end are noticed as well.
+package auditlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/integrity"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// domain keeps audit MACs apart from the row and set MACs made with the same key
+const domain = "kosh audit chain v1\n"
+
+// entry is the canonical form that is hashed, its field order must never change. Unkeyed entries
+// leave out keyed, so they hash as entries recorded before the chain was keyed.
+type entry struct {
+	PrevHash     string `json:"prevHash"`
+	Event        string `json:"event"`
+	CredentialId int    `json:"credentialId"`
+	Label        string `json:"label"`
+	User         string `json:"user"`
+	Command      string `json:"command"`
+	PID          int    `json:"pid"`
+	At           string `json:"at"`
+	Keyed        bool   `json:"keyed,omitempty"`
+}
+
+// Hash returns the hex encoded chain hash of an event, using event.PrevHash as the link to the
+// previous entry. The first entry of the log has an empty PrevHash. Keyed events need the integrity
+// MAC, the others are hashed with SHA-256 and mac may be nil.
+func Hash(mac integrity.MACFunc, event *model.AuditEvent) (string, error) {
+	canonical, _ := json.Marshal(entry{
+		PrevHash:     event.PrevHash,
+		Event:        string(event.Event),
+		CredentialId: event.CredentialId,
+		Label:        event.Label,
+		User:         event.User,
+		Command:      event.Command,
+		PID:          event.PID,
+		At:           event.At.UTC().Format(time.RFC3339),
+		Keyed:        event.Keyed,
+	})
+
+	if !event.Keyed {
+		sum := sha256.Sum256(canonical)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	if mac == nil {
+		return "", errors.New("keyed audit entry needs the integrity mac")
+	}
+	sum, err := mac(append([]byte(domain), canonical...))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// Verify checks a complete log, oldest entry first, against the anchor of the integrity record: the
+// number of entries and the hash of the last one when it was sealed, zero and empty before the first
+// seal. It returns the ids of the entries that do not match their hash, do not link to the entry
+// before them or differ from the anchor, and how many anchored entries are missing from the end. An
+// empty result and zero missing mean the chain is intact.
+func Verify(mac integrity.MACFunc, events []model.AuditEvent, anchorLength int, anchorHead string) (broken []int, missing int, err error) {
+	broken = []int{}
+	prevHash := ""
+	for i := range events {
+		event := &events[i]
+		hash, err := Hash(mac, event)
+		if err != nil {
+			return nil, 0, err
+		}
+		if event.PrevHash != prevHash || hash != event.Hash {
+			broken = append(broken, event.Id)
+		}
+		prevHash = event.Hash
+	}
+
+	switch {
+	case anchorLength > len(events):
+		missing = anchorLength - len(events)
+	case anchorLength > 0 && events[anchorLength-1].Hash != anchorHead:
+		// rehashed in plain SHA-256 from here back, only the anchor still knows
+		if id := events[anchorLength-1].Id; !slices.Contains(broken, id) {
+			broken = append(broken, id)
+		}
+	}
+	return broken, missing, nil
+}
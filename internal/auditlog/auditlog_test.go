@@ -0,0 +1,183 @@
+package auditlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"testing"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/integrity"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+func testMAC(t *testing.T, privateKey string) integrity.MACFunc {
+	t.Helper()
+	key, err := integrity.Key([]byte(privateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return integrity.Keyed(key)
+}
+
+// rehash recomputes the links and hashes of every entry from the first one on, as someone rewriting
+// the log would
+func rehash(t *testing.T, mac integrity.MACFunc, events []model.AuditEvent) []model.AuditEvent {
+	t.Helper()
+	prevHash := ""
+	for i := range events {
+		events[i].PrevHash = prevHash
+		hash, err := Hash(mac, &events[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		events[i].Hash = hash
+		prevHash = hash
+	}
+	return events
+}
+
+func newChain(t *testing.T, n int) []model.AuditEvent {
+	t.Helper()
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	events := []model.AuditEvent{}
+	for i := range n {
+		events = append(events, model.AuditEvent{
+			Id:           i + 1,
+			Event:        model.AuditEventRead,
+			CredentialId: 7,
+			Label:        "github",
+			User:         "alice",
+			Command:      "kosh get",
+			PID:          4242,
+			At:           at.Add(time.Duration(i) * time.Minute),
+			Keyed:        true,
+		})
+	}
+	return rehash(t, testMAC(t, "vault private key"), events)
+}
+
+func TestVerify(t *testing.T) {
+	mac := testMAC(t, "vault private key")
+	tests := []struct {
+		name        string
+		tamper      func([]model.AuditEvent) []model.AuditEvent
+		want        []int
+		wantMissing int
+	}{
+		{"intact", func(e []model.AuditEvent) []model.AuditEvent { return e }, []int{}, 0},
+		{"changed field", func(e []model.AuditEvent) []model.AuditEvent {
+			e[1].Label = "gitlab"
+			return e
+		}, []int{2}, 0},
+		{"changed field rehashed without the key", func(e []model.AuditEvent) []model.AuditEvent {
+			e[1].Event = model.AuditEventAdd
+			e[1].Hash, _ = Hash(testMAC(t, "guessed key"), &e[1])
+			return e
+		}, []int{2, 3}, 0},
+		{"whole log rehashed unkeyed", func(e []model.AuditEvent) []model.AuditEvent {
+			e = slices.Delete(e, 1, 2)
+			for i := range e {
+				e[i].Keyed = false
+			}
+			return rehash(t, nil, e)
+		}, []int{}, 1},
+		{"changed and rehashed unkeyed", func(e []model.AuditEvent) []model.AuditEvent {
+			e[1].Label = "gitlab"
+			for i := range e {
+				e[i].Keyed = false
+			}
+			return rehash(t, nil, e)
+		}, []int{5}, 0},
+		{"removed entry", func(e []model.AuditEvent) []model.AuditEvent {
+			return slices.Delete(e, 1, 2)
+		}, []int{3}, 1},
+		{"removed first entry", func(e []model.AuditEvent) []model.AuditEvent {
+			return e[1:]
+		}, []int{2}, 1},
+		{"reordered", func(e []model.AuditEvent) []model.AuditEvent {
+			e[2], e[3] = e[3], e[2]
+			return e
+		}, []int{4, 3, 5}, 0},
+		{"removed tail", func(e []model.AuditEvent) []model.AuditEvent {
+			return e[:3]
+		}, []int{}, 2},
+		{"unkeyed entry after the anchor", func(e []model.AuditEvent) []model.AuditEvent {
+			locked := e[4]
+			locked.Id, locked.PrevHash, locked.Keyed = 6, e[4].Hash, false
+			locked.Hash, _ = Hash(nil, &locked)
+			return append(e, locked)
+		}, []int{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := newChain(t, 5)
+			head := events[4].Hash
+
+			got, missing, err := Verify(mac, tt.tamper(events), 5, head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) || missing != tt.wantMissing {
+				t.Errorf("Verify() = %v, %d missing, want %v, %d missing", got, missing, tt.want, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutAnchor(t *testing.T) {
+	mac := testMAC(t, "vault private key")
+	if got, missing, err := Verify(mac, nil, 0, ""); err != nil || len(got) != 0 || missing != 0 {
+		t.Errorf("Verify() of an empty log = %v, %d, %v", got, missing, err)
+	}
+	if got, missing, err := Verify(mac, newChain(t, 3), 0, ""); err != nil || len(got) != 0 || missing != 0 {
+		t.Errorf("Verify() before the first seal = %v, %d, %v", got, missing, err)
+	}
+}
+
+func TestVerifyOtherKey(t *testing.T) {
+	got, _, err := Verify(testMAC(t, "another vault"), newChain(t, 3), 3, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Verify() with another key = %v, want every entry", got)
+	}
+}
+
+func TestHashNeedsKey(t *testing.T) {
+	event := newChain(t, 1)[0]
+	if _, err := Hash(nil, &event); err == nil {
+		t.Error("Hash() of a keyed entry without a mac succeeded")
+	}
+}
+
+func TestHashUnkeyedAsBefore(t *testing.T) {
+	event := newChain(t, 1)[0]
+	event.Keyed = false
+	// the canonical form of entries recorded before the chain was keyed
+	canonical := `{"prevHash":"","event":"read","credentialId":7,"label":"github","user":"alice",` +
+		`"command":"kosh get","pid":4242,"at":"2026-01-02T03:04:05Z"}`
+	sum := sha256.Sum256([]byte(canonical))
+
+	got, err := Hash(nil, &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("Hash() of an unkeyed entry = %s, want %s", got, want)
+	}
+}
+
+func TestHashIgnoresTimeZone(t *testing.T) {
+	mac := testMAC(t, "vault private key")
+	event := newChain(t, 1)[0]
+	local := event
+	local.At = event.At.In(time.FixedZone("IST", 19800))
+	utcHash, _ := Hash(mac, &event)
+	localHash, _ := Hash(mac, &local)
+	if utcHash != localHash {
+		t.Error("Hash() differs for the same instant in another time zone")
+	}
+}
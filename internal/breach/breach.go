@@ -0,0 +1,140 @@
+// Package breach looks up passwords in a local copy of the Pwned Passwords list, the SHA-1 variant
+// ordered by hash (pwned-passwords-sha1-ordered.txt). Every line is "<SHA-1 hex>:<count>"; lookups
+// binary search the file in place, nothing is loaded into memory or sent over the network.
+package breach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// hashLength is the length of a hex encoded SHA-1 hash
+const hashLength = 40
+
+var ErrInvalidFile = errors.New("not a Pwned Passwords file ordered by hash")
+
+// DB is an open Pwned Passwords file
+type DB struct {
+	file *os.File
+	size int64
+}
+
+// Open opens a Pwned Passwords file and checks that its first line looks like one
+func Open(path string) (*DB, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	db := &DB{file: file, size: info.Size()}
+	_, line, err := db.lineAfter(0)
+	if err == nil && line != nil {
+		_, _, err = parseLine(line)
+	}
+	if err != nil || line == nil {
+		file.Close()
+		return nil, ErrInvalidFile
+	}
+	return db, nil
+}
+
+// Close closes the file
+func (db *DB) Close() error {
+	return db.file.Close()
+}
+
+// Count returns how often the password was seen in breaches, 0 when it is not in the list
+func (db *DB) Count(password []byte) (int, error) {
+	sum := sha1.Sum(password)
+	target := make([]byte, hashLength)
+	hex.Encode(target, sum[:])
+	target = bytes.ToUpper(target)
+
+	// the line holding the target, if any, starts in [low, high)
+	low, high := int64(0), db.size
+	for low < high {
+		middle := low + (high-low)/2
+		start, line, err := db.lineAfter(middle)
+		if err != nil {
+			return 0, err
+		}
+		if line == nil {
+			high = middle
+			continue
+		}
+
+		hash, count, err := parseLine(line)
+		if err != nil {
+			return 0, err
+		}
+		switch bytes.Compare(hash, target) {
+		case 0:
+			return count, nil
+		case -1:
+			low = start + int64(len(line))
+		default:
+			// no line starts in [middle, start), so the target starts before middle
+			high = middle
+		}
+	}
+	return 0, nil
+}
+
+// lineAfter returns the first line starting at or after offset, including its line break, and where
+// it starts. The line is nil past the last line.
+func (db *DB) lineAfter(offset int64) (int64, []byte, error) {
+	start := offset
+	if offset > 0 {
+		// a line starts at offset when the byte before it ends the previous one
+		start = offset - 1
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(db.file, start, db.size-start), 128)
+
+	if offset > 0 {
+		skipped, err := reader.ReadSlice('\n')
+		if err == io.EOF {
+			return 0, nil, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		start += int64(len(skipped))
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if err == io.EOF && len(line) == 0 {
+		return 0, nil, nil
+	}
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+	return start, bytes.Clone(line), nil
+}
+
+// parseLine splits "<hash>:<count>\r\n" into the upper case hash and the count
+func parseLine(line []byte) ([]byte, int, error) {
+	line = bytes.TrimRight(line, "\r\n")
+	hash, count, ok := bytes.Cut(line, []byte(":"))
+	if !ok || len(hash) != hashLength {
+		return nil, 0, fmt.Errorf("%w: unexpected line %q", ErrInvalidFile, line)
+	}
+
+	n, err := strconv.Atoi(string(bytes.TrimSpace(count)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: unexpected count in %q", ErrInvalidFile, line)
+	}
+	return bytes.ToUpper(hash), n, nil
+}
@@ -0,0 +1,95 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeDB writes a Pwned Passwords style file holding the passwords with count i+1
+func writeDB(t *testing.T, passwords []string, lineEnd string, trailing bool) string {
+	t.Helper()
+	lines := []string{}
+	for i, password := range passwords {
+		sum := sha1.Sum([]byte(password))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(sum[:])), i+1))
+	}
+	slices.Sort(lines)
+
+	content := strings.Join(lines, lineEnd)
+	if trailing {
+		content += lineEnd
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCount(t *testing.T) {
+	passwords := []string{}
+	for i := range 500 {
+		passwords = append(passwords, fmt.Sprintf("password%d", i))
+	}
+
+	for _, format := range []struct {
+		name     string
+		lineEnd  string
+		trailing bool
+	}{{"crlf", "\r\n", true}, {"lf", "\n", true}, {"no trailing line break", "\r\n", false}} {
+		t.Run(format.name, func(t *testing.T) {
+			db, err := Open(writeDB(t, passwords, format.lineEnd, format.trailing))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			for i, password := range passwords {
+				count, err := db.Count([]byte(password))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count != i+1 {
+					t.Fatalf("Count(%q) = %d, want %d", password, count, i+1)
+				}
+			}
+			for _, password := range []string{"", "correct horse battery staple", "password500", "Password1"} {
+				if count, err := db.Count([]byte(password)); err != nil || count != 0 {
+					t.Fatalf("Count(%q) = %d, %v, want 0", password, count, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCountSingleLine(t *testing.T) {
+	db, err := Open(writeDB(t, []string{"hunter2"}, "\r\n", false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if count, _ := db.Count([]byte("hunter2")); count != 1 {
+		t.Errorf("Count(hunter2) = %d, want 1", count)
+	}
+	if count, _ := db.Count([]byte("hunter3")); count != 0 {
+		t.Errorf("Count(hunter3) = %d, want 0", count)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passwords.txt")
+	if err := os.WriteFile(path, []byte("hunter2\npassword\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrInvalidFile) {
+		t.Fatalf("Open() error = %v, want ErrInvalidFile", err)
+	}
+}
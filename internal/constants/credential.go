@@ -1,5 +1,6 @@
 package constants
 
 const (
-	AccessCountResetThreshold = 2_000
+	// accesses count half as much towards the frequency of a credential after this many days
+	AccessFrequencyHalfLifeDays = 30
 )
@@ -14,12 +14,40 @@ var (
 	ErrLabelCannotBeCommand      = errors.New("credential label cannot be same as command")
 	ErrSecretDoesNotMatch        = errors.New("credential secret does not match")
 	ErrCredentialAlreadyExists   = errors.New("credential already exists")
+	ErrCredentialInTrash         = errors.New("credential exists in the trash, restore or purge it first")
 	ErrFailedToFetchCredential   = errors.New("unable to fetch credential/s")
 	ErrFailedToSaveCredential    = errors.New("unable to save credential")
 	ErrFailedToDeleteCredential  = errors.New("unable to delete credential")
 	ErrFailedToDecryptCredential = errors.New("unable to decrypt credential")
 	ErrFailedToReadInput         = errors.New("unable to read input")
 	ErrSearchCancelled           = errors.New("search cancelled")
+	ErrCredentialHasNoOTP        = errors.New("credential has no one-time password")
+	ErrFailedToGenerateOTP       = errors.New("unable to generate one-time password")
+	ErrCredentialAlreadyHasOTP   = errors.New("credential already has a one-time password")
+	ErrUnsupportedImportFormat   = errors.New("unsupported import format")
+	ErrInvalidFieldType          = errors.New("invalid field type")
+	ErrFieldNotFound             = errors.New("credential field not found")
+	ErrAttachmentNotFound        = errors.New("attachment not found")
+	ErrAttachmentAlreadyExists   = errors.New("attachment already exists")
+	ErrAttachmentTooLarge        = errors.New("attachment exceeds the maximum size")
+	ErrFailedToSaveAttachment    = errors.New("unable to save attachment")
+	ErrFailedToDecryptAttachment = errors.New("unable to decrypt attachment")
+	ErrInvalidSetting            = errors.New("invalid setting")
+	ErrVersionNotFound           = errors.New("credential version not found")
+	ErrCredentialNotInTrash      = errors.New("credential is not in trash")
+	ErrInvalidTag                = errors.New("invalid tag")
+	ErrOriginNotAllowed          = errors.New("origin is not allowed")
+	ErrAPIClientNotFound         = errors.New("api token not found")
+	ErrFailedToSaveAPIClient     = errors.New("unable to save api token")
+	ErrAuditLogTampered          = errors.New("audit log has been tampered with")
+	ErrAuditLogTruncated         = errors.New("entries were removed from the end of the audit log")
+	ErrVaultTampered             = errors.New("vault file was changed outside kosh")
+	ErrVaultNotUnlocked          = errors.New("vault is not unlocked")
+	ErrInvalidPolicyName         = errors.New("policy name cannot be empty or contain whitespace")
+	ErrPolicyAlreadyExists       = errors.New("policy already exists")
+	ErrPolicyNotFound            = errors.New("policy not found")
+	ErrFailedToSavePolicy        = errors.New("unable to save policy")
+	ErrNoRotationPending         = errors.New("no rotation pending")
 
 	ErrCredentialMatchNotFound = errors.New("credential match not found")
 	ErrCredentialNotFound      = errors.New("no credential found")
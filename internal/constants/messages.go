@@ -8,12 +8,45 @@ const (
 	MsgDeleteCredential    = "delete credential?"
 	MsgSavedCredential     = "saved credential in the vault successfully"
 	MsgDeletedCredential   = "permanently deleted credential successfully"
+	MsgTrashedCredential   = "moved credential to trash"
+	MsgRestoredCredential  = "restored credential from trash"
+	MsgPurgedTrash         = "permanently deleted credentials in trash"
 	MsgUpdatedCredential   = "updated credential successfully"
+	MsgSavedField          = "saved credential field successfully"
+	MsgDeletedField        = "deleted credential field successfully"
+	MsgOverwriteField      = "overwrite existing field?"
+	MsgSavedAttachment     = "saved attachment in the vault successfully"
+	MsgDeletedAttachment   = "deleted attachment successfully"
+	MsgWroteAttachment     = "wrote attachment to"
+	MsgSavedNote           = "saved note in the vault successfully"
+	MsgSavedSetting        = "saved setting successfully"
+	MsgRestoredVersion     = "restored previous secret successfully"
+	MsgAgentUnlocked       = "unlock agent is running"
+	MsgAgentLocked         = "unlock agent locked"
+	MsgAllowedOrigin       = "allowed origin for the browser extension"
+	MsgRevokedOrigin       = "revoked origin from the browser extension"
+	MsgServingAPI          = "serving api on"
+	MsgStoppedAPI          = "stopped api server"
+	MsgCreatedAPIToken     = "created api token"
+	MsgRevokedAPIToken     = "revoked api token"
+	MsgVaultIntact         = "vault file is unchanged since it was last sealed"
+	MsgAcceptedIntegrity   = "sealed the vault file as it is now"
+	MsgSecretBreached      = "this secret appears %d times in known breaches, better pick another one"
+	MsgSecretStrength      = "strength: %d/4 (%s), %s to crack offline"
+	MsgSavedPolicy         = "saved policy"
+	MsgDeletedPolicy       = "deleted policy"
+	MsgStartedRotation     = "copied the new secret to clipboard, the current secret stays in place until the rotation is committed"
+	MsgCommittedRotation   = "committed the new secret"
+	MsgAbortedRotation     = "discarded the new secret, the current secret stays in place"
 
 	MsgListCommandsWithHelp   = "list commands with `help` command"
 	MsgListCredentialWithList = "list credentials with `list` command"
 
 	MsgCopiedCredential     = "copied credential to clipboard"
+	MsgCopiedOTP            = "copied one-time password to clipboard"
+	MsgCopiedField          = "copied field value to clipboard"
+	MsgCopiedVersion        = "copied previous secret to clipboard"
+	MsgRemovedOTP           = "removed one-time password from credential"
 	MsgOperationIsPermanent = "operation is permanent"
 	MsgOperationAborted     = "operation aborted"
 )
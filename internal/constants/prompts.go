@@ -9,6 +9,11 @@ const (
 	MsgEnterCredentialUsername = "enter credential username: "
 	MsgEnterCredentialSecret   = "enter credential secret: "
 	MsgConfirmCredentialSecret = "confirm credential secret: "
+	MsgEnterCredentialOTPURI   = "enter otpauth:// uri (empty to remove): "
+	MsgEnterFieldValue         = "enter field value: "
+	MsgConfirmFieldValue       = "confirm field value: "
+	MsgEnterNoteLabel          = "enter note label: "
+	MsgEnterNoteBody           = "enter note, finish with a line containing only '.' or ctrl-d:"
 
 	MsgSelectCredentialFieldToUpdate = "select credential field to update: "
 
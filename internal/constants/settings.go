@@ -0,0 +1,29 @@
+package constants
+
+// Keys of the user settings stored in the vault, see `kosh config`
+const (
+	SettingAttachmentMaxSize  = "attachment.max_size"
+	SettingHistoryMaxVersions = "history.max_versions"
+	SettingHistoryMaxAgeDays  = "history.max_age_days"
+	SettingTrashRetentionDays = "trash.retention_days"
+	SettingAgentTimeout       = "agent.timeout_minutes"
+	SettingAuditMaxAgeDays    = "audit.max_age_days"
+	SettingAuditMinEntropy    = "audit.min_entropy_bits"
+	SettingBreachDBPath       = "breach.db_path"
+	SettingExpiryNoticeDays   = "expiry.notice_days"
+	SettingStatsRetentionDays = "stats.retention_days"
+)
+
+// DefaultSettings holds the value of every known setting that has not been set by the user
+var DefaultSettings = map[string]string{
+	SettingAttachmentMaxSize:  "25MB",
+	SettingHistoryMaxVersions: "20",
+	SettingHistoryMaxAgeDays:  "0",
+	SettingTrashRetentionDays: "30",
+	SettingAgentTimeout:       "15",
+	SettingAuditMaxAgeDays:    "365",
+	SettingAuditMinEntropy:    "40",
+	SettingBreachDBPath:       "",
+	SettingExpiryNoticeDays:   "14",
+	SettingStatsRetentionDays: "365",
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/crypto"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// AttachmentChunkSize is the plain text size of every attachment chunk except the last one
+const AttachmentChunkSize = 64 * 1024
+
+// AddAttachment encrypts everything read from r and stores it as a named attachment of a credential.
+// Content is streamed in AttachmentChunkSize chunks so the file is never fully held in memory. Reading
+// more than maxSize bytes aborts the upload without storing anything.
+func (s *VaultService) AddAttachment(credentialId int, name string, r io.Reader, maxSize int64) (*model.Attachment, error) {
+	vaultInfo, err := s.store.GetVaultInfo()
+	if err != nil {
+		return nil, constants.ErrFailedToFetchVaultInfo
+	}
+
+	// every attachment gets its own random file key, sealed for the vault like a credential secret
+	fileKey := crypto.GenerateKey()
+	sealedKey, keyNonce, keyEphemeral, err := sealSecret(vaultInfo.GetRawData().PublicKey, fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	attachmentData := model.AttachmentData{
+		CredentialId: credentialId,
+		Name:         name,
+		ChunkSize:    AttachmentChunkSize,
+		Key:          sealedKey,
+		KeyEphemeral: keyEphemeral,
+		KeyNonce:     keyNonce,
+	}
+
+	// read one chunk ahead so the final chunk can be marked as such
+	current := make([]byte, AttachmentChunkSize)
+	ahead := make([]byte, AttachmentChunkSize)
+	currentLen, err := io.ReadFull(r, current)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+
+	attachment := attachmentData.EncodeToString()
+	index := uint64(0)
+	done := false
+	next := func() ([]byte, []byte, error) {
+		if done {
+			return nil, nil, io.EOF
+		}
+
+		aheadLen, err := io.ReadFull(r, ahead)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, nil, err
+		}
+		last := aheadLen == 0
+
+		attachment.Size += int64(currentLen)
+		if attachment.Size > maxSize {
+			return nil, nil, constants.ErrAttachmentTooLarge
+		}
+
+		cipher, nonce, err := crypto.EncryptChunk(fileKey, current[:currentLen], chunkAdditionalData(index, last))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		index++
+		done = last
+		current, ahead, currentLen = ahead, current, aheadLen
+		return nonce, cipher, nil
+	}
+
+	if err := s.store.AddAttachment(attachment, next); err != nil {
+		if errors.Is(err, constants.ErrAttachmentTooLarge) {
+			return nil, err
+		}
+		logger.Debug("addAttachment:%s", err.Error())
+		return nil, constants.ErrFailedToSaveAttachment
+	}
+
+	return attachment, nil
+}
+
+// DecryptAttachment decrypts an attachment chunk by chunk and writes the plain content to w. It fails
+// if any chunk was modified, reordered or dropped, or if the stream was truncated; w may have received
+// partial content in that case.
+func (s *VaultService) DecryptAttachment(attachment *model.Attachment, password []byte, w io.Writer) error {
+	vaultPrivateKey, err := s.UnlockVault(password)
+	if err != nil {
+		return err
+	}
+
+	attachmentData := attachment.GetRawData()
+	fileKey, err := OpenSecret(vaultPrivateKey, attachmentData.KeyEphemeral, attachmentData.Key, attachmentData.KeyNonce)
+	if err != nil {
+		return constants.ErrFailedToDecryptAttachment
+	}
+
+	expected := 0
+	finished := false
+	err = s.store.ReadAttachmentChunks(attachment.Id, func(index int, nonce, data []byte) error {
+		if index != expected || finished {
+			return constants.ErrFailedToDecryptAttachment
+		}
+
+		// the last flag is not stored, try the more common case first
+		chunk, err := crypto.DecryptChunk(fileKey, data, nonce, chunkAdditionalData(uint64(index), false))
+		if err != nil {
+			chunk, err = crypto.DecryptChunk(fileKey, data, nonce, chunkAdditionalData(uint64(index), true))
+			if err != nil {
+				return constants.ErrFailedToDecryptAttachment
+			}
+			finished = true
+		}
+
+		expected++
+		_, err = w.Write(chunk)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !finished {
+		logger.Debug("decryptAttachment:stream ended without final chunk")
+		return constants.ErrFailedToDecryptAttachment
+	}
+	return nil
+}
+
+// chunkAdditionalData encodes the chunk position and whether it is the final chunk of the stream
+func chunkAdditionalData(index uint64, last bool) []byte {
+	ad := make([]byte, 9)
+	binary.BigEndian.PutUint64(ad, index)
+	if last {
+		ad[8] = 1
+	}
+	return ad
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/auditlog"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// SetAuditCommand sets the command recorded with audit log events, e.g. "kosh get"
+func (s *VaultService) SetAuditCommand(command string) {
+	s.command = command
+}
+
+// RecordEvent appends an event for a credential to the audit log, with a snapshot of its label and
+// user. Once the vault is unlocked the entry is keyed with the integrity MAC and anchored in the
+// integrity record. Failures are only logged, a full disk or a locked database must not fail the
+// operation that already happened.
+func (s *VaultService) RecordEvent(event model.AuditEventType, id int, label, user string) {
+	s.RecordEvents([]model.AuditEvent{{Event: event, CredentialId: id, Label: label, User: user}})
+}
+
+// RecordEvents appends events of which only the type, credential id, label and user are set, as
+// RecordEvent does, for commands that touch many credentials at once. The log is anchored once, after
+// the last of them.
+func (s *VaultService) RecordEvents(events []model.AuditEvent) {
+	anchor := false
+	for i := range events {
+		entry := &events[i]
+		entry.Command = s.command
+		entry.PID = os.Getpid()
+		entry.At = time.Now()
+		entry.Keyed = s.mac != nil
+
+		err := s.store.AppendAuditEvent(entry, func(event *model.AuditEvent) (string, error) {
+			return auditlog.Hash(s.mac, event)
+		})
+		if err != nil {
+			logger.Debug("recordEvents:unable to record %s of credential %d: %s", entry.Event, entry.CredentialId, err.Error())
+			continue
+		}
+		anchor = anchor || entry.Keyed
+	}
+	if anchor {
+		s.anchorAuditLog()
+	}
+}
+
+// RecordAdded records an add, or an update when the credential already existed, of the credential
+// saved under label and user
+func (s *VaultService) RecordAdded(label, user string, existed bool) {
+	credential, err := s.store.GetCredentialByLabelAndUser(label, user)
+	if err != nil {
+		logger.Debug("recordAdded:unable to fetch saved credential: %s", err.Error())
+		return
+	}
+
+	event := model.AuditEventAdd
+	if existed {
+		event = model.AuditEventUpdate
+	}
+	s.RecordEvent(event, credential.Id, credential.Label, credential.User)
+}
+
+// VerifyAuditLog loads the whole audit log and checks its chain with the integrity MAC and against the
+// anchor of the integrity record. Returns the log with the ids of entries that fail the check and the
+// number of anchored entries missing from its end.
+func (s *VaultService) VerifyAuditLog() ([]model.AuditEvent, []int, int, error) {
+	if s.mac == nil {
+		return nil, nil, 0, constants.ErrVaultNotUnlocked
+	}
+
+	events, err := s.store.GetAuditEvents()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	anchor, err := s.store.GetIntegrityRecord()
+	if errors.Is(err, sql.ErrNoRows) {
+		anchor = &model.IntegrityRecord{}
+	} else if err != nil {
+		return nil, nil, 0, err
+	}
+
+	broken, missing, err := auditlog.Verify(s.mac, events, anchor.AuditLength, anchor.AuditHead)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return events, broken, missing, nil
+}
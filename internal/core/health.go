@@ -0,0 +1,86 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"git.plutolab.org/plutolab/kosh/internal/breach"
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/strength"
+)
+
+// OpenBreachDB opens the Pwned Passwords file of the breach.db_path setting, nil when it is not set
+func (s *VaultService) OpenBreachDB() (*breach.DB, error) {
+	path := s.GetSetting(constants.SettingBreachDBPath)
+	if path == "" {
+		return nil, nil
+	}
+	return breach.Open(path)
+}
+
+// AuditCredentials unlocks the vault once and decrypts every credential to rate its secret with the
+// strength estimator. Each secret is replaced by an HMAC under a key that only lives for this call, so
+// reused secrets can be matched without keeping any plain text, and wiped right after. With a breach
+// list, secrets are also looked up in it.
+func (s *VaultService) AuditCredentials(password []byte, breaches *breach.DB) ([]model.CredentialHealth, error) {
+	vaultPrivateKey, err := s.UnlockVault(password)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(vaultPrivateKey)
+
+	credentials, err := s.store.GetAllCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	hashKey := make([]byte, 32)
+	if _, err := rand.Read(hashKey); err != nil {
+		return nil, err
+	}
+	defer clear(hashKey)
+
+	result := make([]model.CredentialHealth, 0, len(credentials))
+	for i := range credentials {
+		credential := &credentials[i]
+		credData := credential.GetRawData()
+		secret, err := OpenSecret(vaultPrivateKey, credData.Ephemeral, credData.Secret, credData.Nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		breached := 0
+		if breaches != nil && len(secret) > 0 {
+			if breached, err = breaches.Count(secret); err != nil {
+				clear(secret)
+				return nil, err
+			}
+		}
+
+		// label and user are the first words an attacker tries for an account
+		estimate := strength.Estimate(secret, credential.Label, credential.User)
+
+		mac := hmac.New(sha256.New, hashKey)
+		mac.Write(secret)
+		result = append(result, model.CredentialHealth{
+			Id:              credential.Id,
+			Label:           credential.Label,
+			User:            credential.User,
+			Note:            credential.Kind == model.CredentialKindNote,
+			HasSecret:       len(secret) > 0,
+			SecretHash:      hex.EncodeToString(mac.Sum(nil)),
+			Entropy:         estimate.Bits(),
+			Score:           estimate.Score,
+			CrackSeconds:    estimate.CrackSeconds,
+			Breached:        breached,
+			CreatedAt:       credential.CreatedAt,
+			SecretChangedAt: credential.SecretChangedAt,
+			AccessedAt:      credential.AccessedAt,
+		})
+		clear(secret)
+	}
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// DecryptCredentialVersion decrypts a previous secret of a credential
+func (s *VaultService) DecryptCredentialVersion(version *model.CredentialVersion, password []byte) (string, error) {
+	vaultPrivateKey, err := s.UnlockVault(password)
+	if err != nil {
+		return "", err
+	}
+
+	versionData := version.GetRawData()
+	plainText, err := OpenSecret(vaultPrivateKey, versionData.Ephemeral, versionData.Secret, versionData.Nonce)
+	if err != nil {
+		return "", constants.ErrFailedToDecryptCredential
+	}
+
+	return string(plainText), nil
+}
+
+// RestoreCredentialVersion makes a previous secret the current secret of its credential. Versions
+// are sealed for the vault key like any secret, so the ciphertext is copied as is; the secret being
+// replaced is archived as a new version, which makes a restore undoable.
+func (s *VaultService) RestoreCredentialVersion(version *model.CredentialVersion) error {
+	restored := model.Credential{
+		Id:        version.CredentialId,
+		Secret:    version.Secret,
+		Ephemeral: version.Ephemeral,
+		Nonce:     version.Nonce,
+	}
+	if err := s.store.UpdateCredential(&restored); err != nil {
+		return constants.ErrFailedToSaveCredential
+	}
+	s.SealCredentials(version.CredentialId)
+
+	s.pruneHistory()
+	return nil
+}
+
+// pruneHistory applies the history.max_versions and history.max_age_days settings. Failures are only
+// logged, an oversized history must never prevent a secret from being saved.
+func (s *VaultService) pruneHistory() {
+	var before time.Time
+	if days := s.GetIntSetting(constants.SettingHistoryMaxAgeDays); days > 0 {
+		before = time.Now().AddDate(0, 0, -days)
+	}
+
+	if err := s.store.PruneCredentialHistory(s.GetIntSetting(constants.SettingHistoryMaxVersions), before); err != nil {
+		logger.Debug("pruneHistory:unable to prune credential history: %s", err.Error())
+	}
+}
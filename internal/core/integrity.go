@@ -0,0 +1,266 @@
+package core
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"errors"
+	"slices"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/integrity"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// SetIntegrityReporter sets the function called when the vault file fails the integrity check on
+// unlock, e.g. to warn the user
+func (s *VaultService) SetIntegrityReporter(reporter func(*model.IntegrityReport)) {
+	s.integrityReporter = reporter
+}
+
+// SetIntegrityMAC sets the integrity MAC for callers that never see the vault private key, like the
+// native messaging host using the unlock agent, and checks the vault file with it
+func (s *VaultService) SetIntegrityMAC(mac integrity.MACFunc) {
+	s.mac = mac
+	s.report = nil
+	if _, err := s.CheckIntegrity(); err != nil {
+		logger.Debug("setIntegrityMAC:unable to check vault integrity: %s", err.Error())
+	}
+}
+
+// IntegrityReport returns the result of the integrity check done on unlock, nil while the vault was
+// not unlocked or the check failed
+func (s *VaultService) IntegrityReport() *model.IntegrityReport {
+	return s.report
+}
+
+// unlocked derives the integrity MAC the first time the vault private key is known
+func (s *VaultService) unlocked(vaultPrivateKey []byte) {
+	if s.mac != nil {
+		return
+	}
+
+	key, err := integrity.Key(vaultPrivateKey)
+	if err != nil {
+		logger.Debug("unlocked:unable to derive integrity key: %s", err.Error())
+		return
+	}
+	s.SetIntegrityMAC(integrity.Keyed(key))
+}
+
+// CheckIntegrity verifies the stored credential MACs and their counter, then compares every credential
+// row against them. The first check of a vault without an integrity record seals the current rows as
+// the baseline.
+func (s *VaultService) CheckIntegrity() (*model.IntegrityReport, error) {
+	if s.mac == nil {
+		return nil, constants.ErrVaultNotUnlocked
+	}
+
+	known, err := s.store.GetKnownIntegrityCounter()
+	if err != nil {
+		logger.Debug("checkIntegrity:unable to read known counter: %s", err.Error())
+	}
+
+	record, err := s.store.GetIntegrityRecord()
+	if errors.Is(err, sql.ErrNoRows) {
+		if known == 0 {
+			return s.AcceptIntegrity()
+		}
+		// the record was sealed on this machine before, it did not just disappear
+		record = &model.IntegrityRecord{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.store.GetCredentialMACs()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.IntegrityReport{
+		Counter:      record.Counter,
+		KnownCounter: known,
+		RolledBack:   record.Counter < known,
+	}
+
+	expected, err := integrity.SetMAC(s.mac, record, stored)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordInvalid = !hmac.Equal([]byte(expected), []byte(record.MAC))
+
+	credentials, err := s.store.GetAllCredentials()
+	if err != nil {
+		return nil, err
+	}
+	report.Added, report.Modified, report.Removed, err = integrity.Compare(s.mac, credentials, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	s.report = report
+	if !report.OK() {
+		if s.integrityReporter != nil {
+			s.integrityReporter(report)
+		}
+		return report, nil
+	}
+
+	// a vault synced from another machine carries a higher counter, remember it
+	if record.Counter > known {
+		if err := s.store.SetKnownIntegrityCounter(record.Counter); err != nil {
+			logger.Debug("checkIntegrity:unable to save known counter: %s", err.Error())
+		}
+	}
+	return report, nil
+}
+
+// AcceptIntegrity seals every credential row as it is now, accepting whatever changed since the last
+// seal
+func (s *VaultService) AcceptIntegrity() (*model.IntegrityReport, error) {
+	if s.mac == nil {
+		return nil, constants.ErrVaultNotUnlocked
+	}
+
+	credentials, err := s.store.GetAllCredentials()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.store.GetCredentialMACs()
+	if err != nil {
+		return nil, err
+	}
+
+	set := []model.CredentialMAC{}
+	current := map[int]bool{}
+	for i := range credentials {
+		rowMAC, err := integrity.RowMAC(s.mac, &credentials[i])
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, model.CredentialMAC{CredentialId: credentials[i].Id, Label: credentials[i].Label, User: credentials[i].User, MAC: rowMAC})
+		current[credentials[i].Id] = true
+	}
+
+	remove := []int{}
+	for _, sealed := range stored {
+		if !current[sealed.CredentialId] {
+			remove = append(remove, sealed.CredentialId)
+		}
+	}
+
+	counter, err := s.save(set, set, remove)
+	if err != nil {
+		return nil, err
+	}
+
+	s.report = &model.IntegrityReport{Counter: counter, KnownCounter: counter}
+	return s.report, nil
+}
+
+// SealCredentials updates the stored MACs of credentials after kosh changed them, removed or trashed
+// credentials lose theirs. Nothing is sealed while the vault is locked or failed its integrity check,
+// so changes made outside kosh keep being reported until accepted. Failures are only logged, the
+// change itself already happened and shows up on the next check.
+func (s *VaultService) SealCredentials(ids ...int) {
+	if s.mac == nil {
+		logger.Debug("sealCredentials:vault not unlocked, credentials %v left unsealed", ids)
+		return
+	}
+	if s.report == nil || !s.report.OK() {
+		logger.Debug("sealCredentials:vault failed its integrity check, credentials %v left unsealed", ids)
+		return
+	}
+
+	stored, err := s.store.GetCredentialMACs()
+	if err != nil {
+		logger.Debug("sealCredentials:unable to fetch credential macs: %s", err.Error())
+		return
+	}
+
+	set := []model.CredentialMAC{}
+	remove := []int{}
+	for _, id := range ids {
+		credential, err := s.store.GetCredentialById(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			remove = append(remove, id)
+			continue
+		}
+		if err != nil {
+			logger.Debug("sealCredentials:unable to fetch credential %d: %s", id, err.Error())
+			return
+		}
+
+		rowMAC, err := integrity.RowMAC(s.mac, credential)
+		if err != nil {
+			logger.Debug("sealCredentials:unable to compute mac of credential %d: %s", id, err.Error())
+			return
+		}
+		set = append(set, model.CredentialMAC{CredentialId: id, Label: credential.Label, User: credential.User, MAC: rowMAC})
+	}
+
+	// the set MAC covers every row, the stored ones with this change applied
+	sealed := []model.CredentialMAC{}
+	for _, mac := range stored {
+		if !slices.Contains(ids, mac.CredentialId) {
+			sealed = append(sealed, mac)
+		}
+	}
+	sealed = append(sealed, set...)
+
+	counter, err := s.save(sealed, set, remove)
+	if err != nil {
+		logger.Debug("sealCredentials:unable to seal credentials %v: %s", ids, err.Error())
+		return
+	}
+	s.report.Counter, s.report.KnownCounter = counter, counter
+}
+
+// anchorAuditLog moves the audit log anchor of the integrity record to the current end of the log.
+// Like SealCredentials it is skipped while the vault is locked or failed its integrity check, and
+// failures are only logged.
+func (s *VaultService) anchorAuditLog() {
+	if s.mac == nil || s.report == nil || !s.report.OK() {
+		return
+	}
+
+	stored, err := s.store.GetCredentialMACs()
+	if err != nil {
+		logger.Debug("anchorAuditLog:unable to fetch credential macs: %s", err.Error())
+		return
+	}
+	counter, err := s.save(stored, nil, nil)
+	if err != nil {
+		logger.Debug("anchorAuditLog:unable to seal audit log: %s", err.Error())
+		return
+	}
+	s.report.Counter, s.report.KnownCounter = counter, counter
+}
+
+// save seals the rows in sealed and the current end of the audit log under the next counter, storing
+// the changed MACs in set and dropping the ones in remove. Returns the new counter.
+func (s *VaultService) save(sealed, set []model.CredentialMAC, remove []int) (int, error) {
+	counter, err := s.store.GetKnownIntegrityCounter()
+	if err != nil {
+		logger.Debug("save:unable to read known counter: %s", err.Error())
+	}
+	if record, err := s.store.GetIntegrityRecord(); err == nil {
+		counter = max(counter, record.Counter)
+	}
+	counter++
+
+	record := &model.IntegrityRecord{Counter: counter}
+	record.AuditLength, record.AuditHead, err = s.store.GetAuditHead()
+	if err != nil {
+		return 0, err
+	}
+	record.MAC, err = integrity.SetMAC(s.mac, record, sealed)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.store.SaveIntegrity(record, set, remove); err != nil {
+		return 0, err
+	}
+	return counter, nil
+}
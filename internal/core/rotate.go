@@ -0,0 +1,70 @@
+package core
+
+import (
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/encoding"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// StartRotation seals a new secret for a credential and keeps it as pending, next to the current
+// secret which stays in use until CommitRotation. A pending secret already there is replaced.
+func (s *VaultService) StartRotation(id int, newSecret []byte) error {
+	vaultInfo, err := s.store.GetVaultInfo()
+	if err != nil {
+		return constants.ErrFailedToFetchVaultInfo
+	}
+
+	cipher, nonce, ephemeralPublicKey, err := sealSecret(vaultInfo.GetRawData().PublicKey, newSecret)
+	if err != nil {
+		return err
+	}
+
+	pending := model.PendingSecret{
+		CredentialId: id,
+		Secret:       encoding.EncodeToBase64String(cipher),
+		Ephemeral:    encoding.EncodeToBase64String(ephemeralPublicKey),
+		Nonce:        encoding.EncodeToBase64String(nonce),
+	}
+	if err := s.store.SetPendingSecret(&pending); err != nil {
+		return constants.ErrFailedToSaveCredential
+	}
+	return nil
+}
+
+// DecryptPendingSecret decrypts the pending secret of a rotation
+func (s *VaultService) DecryptPendingSecret(pending *model.PendingSecret, password []byte) (string, error) {
+	vaultPrivateKey, err := s.UnlockVault(password)
+	if err != nil {
+		return "", err
+	}
+
+	pendingData := pending.GetRawData()
+	plainText, err := OpenSecret(vaultPrivateKey, pendingData.Ephemeral, pendingData.Secret, pendingData.Nonce)
+	if err != nil {
+		return "", constants.ErrFailedToDecryptCredential
+	}
+
+	return string(plainText), nil
+}
+
+// CommitRotation makes the pending secret the current secret of its credential. Like a restore the
+// ciphertext is copied as is, and the secret being replaced is archived as a version.
+func (s *VaultService) CommitRotation(pending *model.PendingSecret) error {
+	rotated := model.Credential{
+		Id:        pending.CredentialId,
+		Secret:    pending.Secret,
+		Ephemeral: pending.Ephemeral,
+		Nonce:     pending.Nonce,
+	}
+	if err := s.store.UpdateCredential(&rotated); err != nil {
+		return constants.ErrFailedToSaveCredential
+	}
+	s.SealCredentials(pending.CredentialId)
+
+	if err := s.store.DeletePendingSecret(pending.CredentialId); err != nil {
+		return constants.ErrFailedToSaveCredential
+	}
+
+	s.pruneHistory()
+	return nil
+}
@@ -0,0 +1,31 @@
+package core
+
+import (
+	"database/sql"
+	"strconv"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+)
+
+// GetSetting returns the stored value of a setting, or its default when it has not been set
+func (s *VaultService) GetSetting(key string) string {
+	value, err := s.store.GetSetting(key)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Debug("getSetting:falling back to default for %s", key)
+		}
+		return constants.DefaultSettings[key]
+	}
+	return value
+}
+
+// GetIntSetting returns the value of a numeric setting, or its default when the stored value is invalid
+func (s *VaultService) GetIntSetting(key string) int {
+	value, err := strconv.Atoi(s.GetSetting(key))
+	if err != nil || value < 0 {
+		logger.Debug("getIntSetting:invalid stored value for %s, using default", key)
+		value, _ = strconv.Atoi(constants.DefaultSettings[key])
+	}
+	return value
+}
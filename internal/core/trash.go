@@ -0,0 +1,42 @@
+package core
+
+import (
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// TrashRetention returns how long credentials stay in the trash before they are purged, zero when
+// they are kept until purged by hand
+func (s *VaultService) TrashRetention() time.Duration {
+	return time.Duration(s.GetIntSetting(constants.SettingTrashRetentionDays)) * 24 * time.Hour
+}
+
+// PurgeExpiredTrash permanently deletes the credentials that have been in the trash for longer than
+// the trash.retention_days setting. Returns the number of purged credentials.
+func (s *VaultService) PurgeExpiredTrash() (int, error) {
+	retention := s.TrashRetention()
+	if retention == 0 {
+		return 0, nil
+	}
+	before := time.Now().Add(-retention)
+
+	// snapshot what is about to go for the audit log
+	trashed, err := s.store.GetTrashedCredentials()
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := s.store.PurgeTrashedCredentials(before)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, credential := range trashed {
+		if credential.DeletedAt.Before(before) {
+			s.RecordEvent(model.AuditEventDelete, credential.Id, credential.Label, credential.User)
+		}
+	}
+	return purged, nil
+}
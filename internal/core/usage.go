@@ -0,0 +1,18 @@
+package core
+
+import (
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+)
+
+// PruneAccessEvents deletes the access events and query selections older than the stats.retention_days
+// setting, which also drops them from the frequency of their credentials. Returns the number of deleted
+// events.
+func (s *VaultService) PruneAccessEvents() (int, error) {
+	days := s.GetIntSetting(constants.SettingStatsRetentionDays)
+	if days == 0 {
+		return 0, nil
+	}
+	return s.store.PruneAccessEvents(time.Now().AddDate(0, 0, -days))
+}
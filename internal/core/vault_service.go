@@ -2,22 +2,35 @@ package core
 
 import (
 	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
 	"git.plutolab.org/plutolab/kosh/internal/crypto"
+	"git.plutolab.org/plutolab/kosh/internal/integrity"
 	"git.plutolab.org/plutolab/kosh/internal/logger"
 	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/otp"
 	"git.plutolab.org/plutolab/kosh/internal/storage"
 	"golang.org/x/crypto/curve25519"
 )
 
 type VaultService struct {
 	store storage.Store
+
+	// recorded with every audit log event, see SetAuditCommand
+	command string
+
+	// integrity MAC, known once the vault was unlocked, see CheckIntegrity
+	mac               integrity.MACFunc
+	report            *model.IntegrityReport
+	integrityReporter func(*model.IntegrityReport)
 }
 
 // NewVaultService creates a new service instance
 func NewVaultService(store storage.Store) *VaultService {
-	return &VaultService{store}
+	return &VaultService{store: store, command: filepath.Base(os.Args[0])}
 }
 
 // verifyMasterPassword checks if the provided master password can unlock the vault.
@@ -30,29 +43,31 @@ func (s *VaultService) VerifyMasterPassword(password []byte) error {
 	vaultData := vault.GetRawData()
 
 	unlockKey := crypto.GenerateSymmetricKey(password, vaultData.Salt)
-	if _, err := crypto.DecryptSecret(unlockKey, vaultData.Secret, vaultData.Nonce); err != nil {
+	vaultPrivateKey, err := crypto.DecryptSecret(unlockKey, vaultData.Secret, vaultData.Nonce)
+	if err != nil {
 		return constants.ErrIncorrectMasterPassword
 	}
 
+	s.unlocked(vaultPrivateKey)
 	return nil
 }
 
 func (s *VaultService) AddCredential(label, user string, secret []byte) error {
+	return s.addCredential(label, user, model.CredentialKindLogin, secret)
+}
+
+// AddNote saves a secure note, a credential without a user whose secret is the text of the note
+func (s *VaultService) AddNote(label string, body []byte) error {
+	return s.addCredential(label, "", model.CredentialKindNote, body)
+}
+
+func (s *VaultService) addCredential(label, user string, kind model.CredentialKind, secret []byte) error {
 	vaultInfo, err := s.store.GetVaultInfo()
 	if err != nil {
 		return err
 	}
 
-	vaultData := vaultInfo.GetRawData()
-	ephemeralPrivateKey, ephemeralPublicKey := crypto.GenerateAsymmetricKeyPair()
-
-	// generate symmetric shared secret
-	encryptionKey, _ := curve25519.X25519(ephemeralPrivateKey, vaultData.PublicKey)
-
-	// hash to get 32 bit consistent key for encryption
-	key := sha256.Sum256(encryptionKey)
-
-	cipher, nonce, err := crypto.EncryptSecret(key[:], secret)
+	cipher, nonce, ephemeralPublicKey, err := sealSecret(vaultInfo.GetRawData().PublicKey, secret)
 	if err != nil {
 		return err
 	}
@@ -60,6 +75,7 @@ func (s *VaultService) AddCredential(label, user string, secret []byte) error {
 	credential := model.CredentialData{
 		Label:     label,
 		User:      user,
+		Kind:      kind,
 		Nonce:     nonce,
 		Secret:    cipher,
 		Ephemeral: ephemeralPublicKey,
@@ -67,74 +83,239 @@ func (s *VaultService) AddCredential(label, user string, secret []byte) error {
 
 	// save credential
 	err = s.store.AddCredential(credential.EncodeToString())
+	if errors.Is(err, constants.ErrCredentialInTrash) {
+		return err
+	}
 	if err != nil {
 		return constants.ErrFailedToSaveCredential
 	}
 
+	if saved, err := s.store.GetCredentialByLabelAndUser(label, user); err == nil {
+		s.SealCredentials(saved.Id)
+	}
+
+	// overwriting an existing credential archives its previous secret
+	s.pruneHistory()
 	return nil
 }
 
 func (s *VaultService) DecryptCredential(credential *model.Credential, password []byte) (string, error) {
-	vaultInfo, err := s.store.GetVaultInfo()
+	vaultPrivateKey, err := s.UnlockVault(password)
 	if err != nil {
-		logger.Debug("decryptCredential:failed to get vault info")
 		return "", err
 	}
-	vaultData := vaultInfo.GetRawData()
 
-	// Derive unlock key
-	unlockKey := crypto.GenerateSymmetricKey(password, vaultData.Salt)
+	return s.DecryptCredentialWithKey(credential, vaultPrivateKey)
+}
 
-	// Decrypt vault private key
-	vaultPrivateKey, err := crypto.DecryptSecret(unlockKey, vaultData.Secret, vaultData.Nonce)
+// DecryptCredentialWithKey decrypts a credential secret with an already unlocked vault private key,
+// for long running callers like `kosh serve` that unlock the vault once.
+func (s *VaultService) DecryptCredentialWithKey(credential *model.Credential, vaultPrivateKey []byte) (string, error) {
+	credData := credential.GetRawData()
+	plainText, err := OpenSecret(vaultPrivateKey, credData.Ephemeral, credData.Secret, credData.Nonce)
 	if err != nil {
-		logger.Debug("decryptCredential:failed to get private key from vault")
 		return "", constants.ErrFailedToDecryptCredential
 	}
 
-	// Generate shared secret
-	credData := credential.GetRawData()
-	decryptionKey, _ := curve25519.X25519(vaultPrivateKey, credData.Ephemeral)
+	return string(plainText), nil
+}
 
-	// Hash to get 32-bit consistent key
-	key := sha256.Sum256(decryptionKey)
+// UpdateCredentialSecret encrypts a new secret for an existing credential and saves it.
+func (s *VaultService) UpdateCredentialSecret(id int, newSecret []byte) error {
+	vaultInfo, err := s.store.GetVaultInfo()
+	if err != nil {
+		return constants.ErrFailedToFetchVaultInfo
+	}
 
-	plainText, err := crypto.DecryptSecret(key[:], credData.Secret, credData.Nonce)
+	cipher, nonce, ephemeralPublicKey, err := sealSecret(vaultInfo.GetRawData().PublicKey, newSecret)
 	if err != nil {
-		return "", constants.ErrFailedToDecryptCredential
+		return err
 	}
 
-	return string(plainText), nil
+	// Create a credential with ONLY the fields that need updating
+	updatedCredential := model.CredentialData{
+		Id:        id,
+		Nonce:     nonce,
+		Secret:    cipher,
+		Ephemeral: ephemeralPublicKey,
+	}
+
+	if err := s.store.UpdateCredential(updatedCredential.EncodeToString()); err != nil {
+		return err
+	}
+	s.SealCredentials(id)
+
+	s.pruneHistory()
+	return nil
 }
 
-// UpdateCredentialSecret encrypts a new secret for an existing credential and saves it.
-func (s *VaultService) UpdateCredentialSecret(id int, newSecret []byte) error {
+// SetCredentialOTP validates an otpauth:// URI, encrypts it the same way as the credential secret and
+// attaches it to the credential. An empty URI removes the one-time password from the credential.
+func (s *VaultService) SetCredentialOTP(id int, uri string) error {
+	if uri == "" {
+		if err := s.store.SetCredentialOTP(&model.Credential{Id: id}); err != nil {
+			return err
+		}
+		s.SealCredentials(id)
+		return nil
+	}
+
+	if _, err := otp.ParseURI(uri); err != nil {
+		return err
+	}
+
 	vaultInfo, err := s.store.GetVaultInfo()
 	if err != nil {
 		return constants.ErrFailedToFetchVaultInfo
 	}
+
+	cipher, nonce, ephemeralPublicKey, err := sealSecret(vaultInfo.GetRawData().PublicKey, []byte(uri))
+	if err != nil {
+		return err
+	}
+
+	credential := model.CredentialData{
+		Id:           id,
+		Otp:          cipher,
+		OtpNonce:     nonce,
+		OtpEphemeral: ephemeralPublicKey,
+	}
+
+	if err := s.store.SetCredentialOTP(credential.EncodeToString()); err != nil {
+		return err
+	}
+	s.SealCredentials(id)
+	return nil
+}
+
+// DecryptCredentialOTP decrypts and parses the one-time password key attached to a credential.
+func (s *VaultService) DecryptCredentialOTP(credential *model.Credential, password []byte) (*otp.Key, error) {
+	if !credential.HasOTP() {
+		return nil, constants.ErrCredentialHasNoOTP
+	}
+
+	vaultPrivateKey, err := s.UnlockVault(password)
+	if err != nil {
+		return nil, err
+	}
+
+	credData := credential.GetRawData()
+	uri, err := OpenSecret(vaultPrivateKey, credData.OtpEphemeral, credData.Otp, credData.OtpNonce)
+	if err != nil {
+		return nil, constants.ErrFailedToDecryptCredential
+	}
+
+	return otp.ParseURI(string(uri))
+}
+
+// SetCredentialField adds or replaces a named field on a credential. Values of sensitive field types
+// are sealed with their own ephemeral key pair and nonce, same as the credential secret.
+func (s *VaultService) SetCredentialField(credentialId int, name string, fieldType model.FieldType, value []byte) error {
+	if !fieldType.IsValid() {
+		return constants.ErrInvalidFieldType
+	}
+
+	if fieldType == model.FieldTypeOTP {
+		if _, err := otp.ParseURI(string(value)); err != nil {
+			return err
+		}
+	}
+
+	field := model.CredentialFieldData{
+		CredentialId: credentialId,
+		Name:         name,
+		Type:         fieldType,
+		Value:        value,
+	}
+
+	if fieldType.IsSensitive() {
+		vaultInfo, err := s.store.GetVaultInfo()
+		if err != nil {
+			return constants.ErrFailedToFetchVaultInfo
+		}
+
+		field.Value, field.Nonce, field.Ephemeral, err = sealSecret(vaultInfo.GetRawData().PublicKey, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.store.SetCredentialField(field.EncodeToString()); err != nil {
+		return constants.ErrFailedToSaveCredential
+	}
+	return nil
+}
+
+// DecryptCredentialField returns the plain value of a credential field. The master password is only
+// used for sensitive field types.
+func (s *VaultService) DecryptCredentialField(field *model.CredentialField, password []byte) ([]byte, error) {
+	fieldData := field.GetRawData()
+	if !field.Type.IsSensitive() {
+		return fieldData.Value, nil
+	}
+
+	vaultPrivateKey, err := s.UnlockVault(password)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := OpenSecret(vaultPrivateKey, fieldData.Ephemeral, fieldData.Value, fieldData.Nonce)
+	if err != nil {
+		return nil, constants.ErrFailedToDecryptCredential
+	}
+	return value, nil
+}
+
+// UnlockVault derives the unlock key from the master password and returns the decrypted vault private key.
+// Callers holding on to the key, like the unlock agent, must wipe it when done.
+func (s *VaultService) UnlockVault(password []byte) ([]byte, error) {
+	vaultInfo, err := s.store.GetVaultInfo()
+	if err != nil {
+		logger.Debug("unlockVault:failed to get vault info")
+		return nil, err
+	}
 	vaultData := vaultInfo.GetRawData()
 
+	// Derive unlock key
+	unlockKey := crypto.GenerateSymmetricKey(password, vaultData.Salt)
+
+	// Decrypt vault private key
+	vaultPrivateKey, err := crypto.DecryptSecret(unlockKey, vaultData.Secret, vaultData.Nonce)
+	if err != nil {
+		logger.Debug("unlockVault:failed to get private key from vault")
+		return nil, constants.ErrFailedToDecryptCredential
+	}
+
+	s.unlocked(vaultPrivateKey)
+	return vaultPrivateKey, nil
+}
+
+// sealSecret encrypts a secret for the vault public key using a fresh ephemeral key pair. Only the
+// holder of the vault private key can derive the same shared key and decrypt it.
+func sealSecret(vaultPublicKey, secret []byte) (cipher, nonce, ephemeralPublicKey []byte, err error) {
 	ephemeralPrivateKey, ephemeralPublicKey := crypto.GenerateAsymmetricKeyPair()
 
 	// generate symmetric shared secret
-	encryptionKey, _ := curve25519.X25519(ephemeralPrivateKey, vaultData.PublicKey)
+	encryptionKey, _ := curve25519.X25519(ephemeralPrivateKey, vaultPublicKey)
 
 	// hash to get 32 bit consistent key for encryption
 	key := sha256.Sum256(encryptionKey)
 
-	cipher, nonce, err := crypto.EncryptSecret(key[:], newSecret)
+	cipher, nonce, err = crypto.EncryptSecret(key[:], secret)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
-	// Create a credential with ONLY the fields that need updating
-	updatedCredential := model.CredentialData{
-		Id:        id,
-		Nonce:     nonce,
-		Secret:    cipher,
-		Ephemeral: ephemeralPublicKey,
-	}
+	return cipher, nonce, ephemeralPublicKey, nil
+}
+
+// OpenSecret decrypts a secret sealed with sealSecret using the vault private key
+func OpenSecret(vaultPrivateKey, ephemeralPublicKey, cipher, nonce []byte) ([]byte, error) {
+	// Generate shared secret
+	decryptionKey, _ := curve25519.X25519(vaultPrivateKey, ephemeralPublicKey)
+
+	// Hash to get 32-bit consistent key
+	key := sha256.Sum256(decryptionKey)
 
-	return s.store.UpdateCredential(updatedCredential.EncodeToString())
+	return crypto.DecryptSecret(key[:], cipher, nonce)
 }
@@ -75,3 +75,45 @@ func DecryptSecret(key, cipher, nonce []byte) ([]byte, error) {
 
 	return secret, nil
 }
+
+// GenerateKey returns a random 32 byte symmetric key
+func GenerateKey() []byte {
+	key := make([]byte, keyLength)
+	_, _ = rand.Read(key)
+	return key
+}
+
+// EncryptChunk encrypts one chunk of a stream with a fresh nonce. The additional data binds the chunk
+// to its position in the stream so chunks cannot be reordered, dropped or truncated unnoticed.
+func EncryptChunk(key, chunk, additionalData []byte) (cipher, nonce []byte, err error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		logger.Debug("encryptChunk:failed to generate chunk AEAD: %s", err.Error())
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+
+	cipher = aead.Seal(nil, nonce, chunk, additionalData)
+	return cipher, nonce, nil
+}
+
+// DecryptChunk decrypts a chunk encrypted with EncryptChunk using the same additional data
+func DecryptChunk(key, cipher, nonce, additionalData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("incorrect nonce len")
+	}
+
+	chunk, err := aead.Open(nil, nonce, cipher, additionalData)
+	if err != nil {
+		logger.Debug("unable to decrypt chunk: %s", err.Error())
+		return nil, err
+	}
+	return chunk, nil
+}
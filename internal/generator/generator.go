@@ -0,0 +1,281 @@
+// Package generator creates random passwords from character groups, reading all randomness from
+// crypto/rand.
+package generator
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+type CharGroup string
+type RequireConfig map[CharGroup]int
+
+const (
+	LowerCharGroup  = "lower"
+	UpperCharGroup  = "upper"
+	DigitCharGroup  = "digit"
+	SymbolCharGroup = "symbol"
+
+	// lower or upper, only for Constraints.StartWith
+	LetterCharGroup = "letter"
+)
+
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!@#$%^&*()-_=+[]{}<>?/|"
+
+	// characters that look alike in many fonts
+	ambiguousChars = "Il1|O0o"
+)
+
+// maxAttempts bounds the candidates drawn for Constraints before giving up
+const maxAttempts = 100000
+
+// ErrConstraintsTooStrict is returned when no or hardly any password satisfies the constraints
+var ErrConstraintsTooStrict = errors.New("no password satisfies the constraints")
+
+// Constraints are site rules the character groups cannot express. Candidates breaking them are thrown
+// away and drawn again, so every password that satisfies them stays equally likely.
+type Constraints struct {
+	// leave out characters that look alike, see ambiguousChars
+	ExcludeAmbiguous bool
+
+	// replaces the default symbol set when not empty
+	Symbols string
+
+	// no character may appear twice
+	NoRepeat bool
+
+	// group of the first character, one of the CharGroup constants or LetterCharGroup
+	StartWith CharGroup
+}
+
+// chars returns the characters of a group under the constraints
+func (c Constraints) chars(group CharGroup) string {
+	var chars string
+	switch group {
+	case LowerCharGroup:
+		chars = lowerChars
+	case UpperCharGroup:
+		chars = upperChars
+	case DigitCharGroup:
+		chars = digitChars
+	case SymbolCharGroup:
+		chars = symbolChars
+		if c.Symbols != "" {
+			chars = c.Symbols
+		}
+	case LetterCharGroup:
+		chars = lowerChars + upperChars
+	}
+	return c.filter(chars)
+}
+
+// filter drops repeated characters, and ambiguous ones when asked to
+func (c Constraints) filter(chars string) string {
+	var kept strings.Builder
+	for _, r := range chars {
+		if (c.ExcludeAmbiguous && strings.ContainsRune(ambiguousChars, r)) || strings.ContainsRune(kept.String(), r) {
+			continue
+		}
+		kept.WriteRune(r)
+	}
+	return kept.String()
+}
+
+// validate checks the symbol set and the group to start with
+func (c Constraints) validate() error {
+	for _, r := range c.Symbols {
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("symbols must be printable ASCII characters, got %q", r)
+		}
+	}
+	switch c.StartWith {
+	case "", LowerCharGroup, UpperCharGroup, DigitCharGroup, SymbolCharGroup, LetterCharGroup:
+		return nil
+	}
+	return fmt.Errorf("unknown group %q to start with", c.StartWith)
+}
+
+// accepts tells whether a candidate satisfies StartWith and NoRepeat, fixed marks positions that
+// are not generated and may repeat
+func (c Constraints) accepts(password []rune, fixed []bool) bool {
+	if c.StartWith != "" && len(password) > 0 && (fixed == nil || !fixed[0]) {
+		if !strings.ContainsRune(c.chars(c.StartWith), password[0]) {
+			return false
+		}
+	}
+	if c.NoRepeat {
+		seen := map[rune]bool{}
+		for i, r := range password {
+			if fixed != nil && fixed[i] {
+				continue
+			}
+			if seen[r] {
+				return false
+			}
+			seen[r] = true
+		}
+	}
+	return true
+}
+
+// Password generates a password of length characters drawn from the enabled groups, with at least
+// as many characters of each group as require asks for
+func Password(length int, upper, lower, digit, symbol bool, require RequireConfig) ([]byte, error) {
+	return ConstrainedPassword(length, upper, lower, digit, symbol, require, Constraints{})
+}
+
+// ConstrainedPassword is Password under constraints
+func ConstrainedPassword(length int, upper, lower, digit, symbol bool, require RequireConfig, constraints Constraints) ([]byte, error) {
+	if err := constraints.validate(); err != nil {
+		return nil, err
+	}
+
+	var groups []CharGroup
+	var pool string
+	for _, group := range []struct {
+		name    CharGroup
+		enabled bool
+	}{{LowerCharGroup, lower}, {UpperCharGroup, upper}, {DigitCharGroup, digit}, {SymbolCharGroup, symbol}} {
+		if !group.enabled {
+			continue
+		}
+		chars := constraints.chars(group.name)
+		if chars == "" {
+			return nil, fmt.Errorf("%w: no %s characters left", ErrConstraintsTooStrict, group.name)
+		}
+		groups = append(groups, group.name)
+		pool += chars
+	}
+	pool = constraints.filter(pool)
+
+	if constraints.StartWith != "" && !strings.ContainsAny(pool, constraints.chars(constraints.StartWith)) {
+		return nil, fmt.Errorf("%w: no %s character enabled to start with", ErrConstraintsTooStrict, constraints.StartWith)
+	}
+	if constraints.NoRepeat {
+		if length > len(pool) {
+			return nil, fmt.Errorf("%w: %d characters cannot be all different out of %d", ErrConstraintsTooStrict, length, len(pool))
+		}
+		for _, group := range groups {
+			if chars := constraints.chars(group); require[group] > len(chars) {
+				return nil, fmt.Errorf("%w: %d %s characters cannot be all different out of %d", ErrConstraintsTooStrict, require[group], group, len(chars))
+			}
+		}
+	}
+
+	// the required characters of each enabled group, the rest from the pool
+	var sets [][]rune
+	for _, group := range groups {
+		for range require[group] {
+			sets = append(sets, []rune(constraints.chars(group)))
+		}
+	}
+	for len(sets) < length {
+		sets = append(sets, []rune(pool))
+	}
+
+	for range maxAttempts {
+		password, err := draw(sets, constraints.NoRepeat)
+		if err != nil {
+			return nil, err
+		}
+		if password == nil {
+			continue
+		}
+
+		// shuffle everything
+		for i := len(password) - 1; i > 0; i-- {
+			j, err := RandomInt(i + 1)
+			if err != nil {
+				return nil, err
+			}
+			password[i], password[j] = password[j], password[i]
+		}
+
+		if constraints.accepts(password, nil) {
+			return []byte(string(password)), nil
+		}
+		clear(password)
+	}
+	return nil, ErrConstraintsTooStrict
+}
+
+// draw picks one character of every set, nil when the candidate is thrown away. With distinct no
+// two picks are equal, and every such outcome stays as likely as with independent picks: each pick
+// comes from the characters not used yet, and is kept with probability available / bound, bound
+// being the most characters any outcome can leave for it. Sets of the same characters are never
+// thrown away, so --no-repeat works up to the size of the set.
+func draw(sets [][]rune, distinct bool) ([]rune, error) {
+	picks := make([]rune, len(sets))
+	for i, set := range sets {
+		if !distinct {
+			n, err := RandomInt(len(set))
+			if err != nil {
+				return nil, err
+			}
+			picks[i] = set[n]
+			continue
+		}
+
+		// earlier picks from a subset of this set always use up one of its characters
+		bound := len(set)
+		for _, earlier := range sets[:i] {
+			if isSubset(earlier, set) {
+				bound--
+			}
+		}
+		available := slices.DeleteFunc(slices.Clone(set), func(r rune) bool { return slices.Contains(picks[:i], r) })
+		if len(available) == 0 {
+			return nil, nil
+		}
+		if keep, err := RandomInt(bound); err != nil {
+			return nil, err
+		} else if keep >= len(available) {
+			return nil, nil
+		}
+
+		n, err := RandomInt(len(available))
+		if err != nil {
+			return nil, err
+		}
+		picks[i] = available[n]
+	}
+	return picks, nil
+}
+
+func isSubset(a, b []rune) bool {
+	for _, r := range a {
+		if !slices.Contains(b, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// RandomInt returns a uniform random number in [0, max)
+func RandomInt(max int) (int, error) {
+	if max <= 0 {
+		return 0, fmt.Errorf("max must be greater than 0")
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		// extremely rare: crypto/rand failure (system entropy issue)
+		return 0, err
+	}
+	return int(n.Int64()), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := RandomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
@@ -0,0 +1,72 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRandomInt(t *testing.T) {
+
+	t.Run("normal range", func(t *testing.T) {
+		n, err := RandomInt(10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n < 0 || n >= 10 {
+			t.Errorf("RandomInt(10) = %d, want between 0-9", n)
+		}
+	})
+
+	t.Run("edge case zero or negative", func(t *testing.T) {
+		_, err := RandomInt(0)
+		if err == nil {
+			t.Errorf("expected error for max=0, got nil")
+		}
+
+		_, err = RandomInt(-5)
+		if err == nil {
+			t.Errorf("expected error for max=-5, got nil")
+		}
+	})
+
+	t.Run("edge case max=1", func(t *testing.T) {
+		n, err := RandomInt(1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if n != 0 {
+			t.Errorf("RandomInt(1)=%d, want 0", n)
+		}
+	})
+}
+
+func TestRandomChar(t *testing.T) {
+	t.Run("normal string", func(t *testing.T) {
+		c, err := randomChar("abc")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !strings.Contains("abc", string(c)) {
+			t.Errorf("randomChar(abc)=%s, want a, b or c", string(c))
+		}
+	})
+
+	t.Run("edge case empty string", func(t *testing.T) {
+		_, err := randomChar("")
+		if err == nil {
+			t.Errorf("expected an error, but got nil")
+		}
+	})
+
+	t.Run("edge case single character", func(t *testing.T) {
+		c, err := randomChar("a")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(c) != "a" {
+			t.Errorf("randomChar(a)=%s, want a", string(c))
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// effLargeWordlist is the EFF large wordlist for five dice, lines of "<rolls>\t<word>"
+// (https://www.eff.org/dice, CC BY 3.0)
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+// ErrWordlistTooShort is returned for wordlists with fewer than two distinct words
+var ErrWordlistTooShort = errors.New("wordlist needs at least two distinct words")
+
+// Wordlist is a list of distinct words to draw passphrases from
+type Wordlist []string
+
+var loadEFFLarge = sync.OnceValue(func() Wordlist {
+	words, err := ReadWordlist(strings.NewReader(effLargeWordlist))
+	if err != nil {
+		// the list is compiled in, a broken one is a build error
+		panic(err)
+	}
+	return words
+})
+
+// EFFLargeWordlist returns the embedded EFF large wordlist of 7776 words
+func EFFLargeWordlist() Wordlist {
+	return loadEFFLarge()
+}
+
+// ReadWordlist reads one word per line. Diceware lists with the dice rolls in front of each word are
+// accepted too; blank lines, lines starting with # and repeated words are skipped.
+func ReadWordlist(r io.Reader) (Wordlist, error) {
+	var words Wordlist
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		word := fields[len(fields)-1]
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(words) < 2 {
+		return nil, ErrWordlistTooShort
+	}
+	return words, nil
+}
+
+// LoadWordlist reads a wordlist file, see ReadWordlist
+func LoadWordlist(path string) (Wordlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadWordlist(file)
+}
+
+// PassphraseOptions shape a passphrase
+type PassphraseOptions struct {
+	Words     int
+	Separator string
+
+	// upper case the first letter of every word
+	Capitalize bool
+
+	// append a random digit to a random word
+	Digit bool
+}
+
+// Passphrase draws words uniformly from the wordlist and joins them
+func Passphrase(wordlist Wordlist, options PassphraseOptions) ([]byte, error) {
+	if options.Words <= 0 {
+		return nil, errors.New("passphrase needs at least one word")
+	}
+	if len(wordlist) < 2 {
+		return nil, ErrWordlistTooShort
+	}
+
+	words := make([]string, options.Words)
+	for i := range words {
+		n, err := RandomInt(len(wordlist))
+		if err != nil {
+			return nil, err
+		}
+		words[i] = wordlist[n]
+		if options.Capitalize {
+			first, size := utf8.DecodeRuneInString(words[i])
+			words[i] = string(unicode.ToUpper(first)) + words[i][size:]
+		}
+	}
+
+	if options.Digit {
+		i, err := RandomInt(len(words))
+		if err != nil {
+			return nil, err
+		}
+		digit, err := randomChar("0123456789")
+		if err != nil {
+			return nil, err
+		}
+		words[i] += string(digit)
+	}
+
+	return []byte(strings.Join(words, options.Separator)), nil
+}
+
+// PassphraseEntropy is the entropy in bits of a passphrase from a wordlist of the given size, for an
+// attacker who knows the list and the options. Capitalizing every word adds nothing, the digit adds
+// its value and the word it follows.
+func PassphraseEntropy(wordlistSize int, options PassphraseOptions) float64 {
+	if wordlistSize < 2 || options.Words <= 0 {
+		return 0
+	}
+	bits := float64(options.Words) * math.Log2(float64(wordlistSize))
+	if options.Digit {
+		bits += math.Log2(10) + math.Log2(float64(options.Words))
+	}
+	return bits
+}
@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestEFFLargeWordlist(t *testing.T) {
+	words := EFFLargeWordlist()
+	if len(words) != 7776 {
+		t.Fatalf("len = %d, want 7776", len(words))
+	}
+	if words[0] != "abacus" || words[len(words)-1] != "zoom" {
+		t.Errorf("first and last words = %q, %q", words[0], words[len(words)-1])
+	}
+}
+
+func TestReadWordlist(t *testing.T) {
+	t.Run("plain and diceware lines", func(t *testing.T) {
+		words, err := ReadWordlist(strings.NewReader("# my list\napple\n\n11112\tbanana\napple\n  cherry  \n"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(words, Wordlist{"apple", "banana", "cherry"}) {
+			t.Errorf("words = %v", words)
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		_, err := ReadWordlist(strings.NewReader("apple\napple\n"))
+		if !errors.Is(err, ErrWordlistTooShort) {
+			t.Errorf("err = %v, want ErrWordlistTooShort", err)
+		}
+	})
+}
+
+func TestPassphrase(t *testing.T) {
+	wordlist := Wordlist{"apple", "banana", "cherry"}
+
+	t.Run("words and separator", func(t *testing.T) {
+		passphrase, err := Passphrase(wordlist, PassphraseOptions{Words: 4, Separator: "."})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		words := strings.Split(string(passphrase), ".")
+		if len(words) != 4 {
+			t.Fatalf("passphrase %q has %d words, want 4", passphrase, len(words))
+		}
+		for _, word := range words {
+			if !slices.Contains(wordlist, word) {
+				t.Errorf("word %q not in the wordlist", word)
+			}
+		}
+	})
+
+	t.Run("capitalize and digit", func(t *testing.T) {
+		passphrase, err := Passphrase(wordlist, PassphraseOptions{Words: 3, Separator: " ", Capitalize: true, Digit: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, word := range strings.Fields(string(passphrase)) {
+			if !unicode.IsUpper(rune(word[0])) {
+				t.Errorf("word %q is not capitalized", word)
+			}
+		}
+		if first := strings.IndexFunc(string(passphrase), unicode.IsDigit); first < 0 || strings.LastIndexFunc(string(passphrase), unicode.IsDigit) != first {
+			t.Errorf("passphrase %q should have exactly one digit", passphrase)
+		}
+	})
+
+	t.Run("no words", func(t *testing.T) {
+		if _, err := Passphrase(wordlist, PassphraseOptions{}); err == nil {
+			t.Errorf("expected an error, but got nil")
+		}
+	})
+}
+
+func TestPassphraseEntropy(t *testing.T) {
+	tests := []struct {
+		size    int
+		options PassphraseOptions
+		want    float64
+	}{
+		{7776, PassphraseOptions{Words: 6}, 6 * math.Log2(7776)},
+		{7776, PassphraseOptions{Words: 6, Capitalize: true}, 6 * math.Log2(7776)},
+		{7776, PassphraseOptions{Words: 6, Digit: true}, 6*math.Log2(7776) + math.Log2(10) + math.Log2(6)},
+		{1, PassphraseOptions{Words: 6}, 0},
+	}
+	for _, tt := range tests {
+		if got := PassphraseEntropy(tt.size, tt.options); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("PassphraseEntropy(%d, %+v) = %f, want %f", tt.size, tt.options, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	lowerVowels     = "aeiou"
+	lowerConsonants = "bcdfghjklmnpqrstvwxyz"
+
+	// patterns longer than this are rejected, as are repeat counts above it
+	maxPatternLength = 256
+)
+
+// patternClasses are the placeholders of a pattern and the characters they stand for
+var patternClasses = map[rune]func(c Constraints) string{
+	'c': func(c Constraints) string { return c.filter(lowerConsonants) },
+	'C': func(c Constraints) string { return c.filter(strings.ToUpper(lowerConsonants)) },
+	'v': func(c Constraints) string { return c.filter(lowerVowels) },
+	'V': func(c Constraints) string { return c.filter(strings.ToUpper(lowerVowels)) },
+	'a': func(c Constraints) string { return c.chars(LowerCharGroup) },
+	'A': func(c Constraints) string { return c.chars(UpperCharGroup) },
+	'L': func(c Constraints) string { return c.chars(LetterCharGroup) },
+	'9': func(c Constraints) string { return c.chars(DigitCharGroup) },
+	'@': func(c Constraints) string { return c.chars(SymbolCharGroup) },
+	'#': func(c Constraints) string { return c.filter(c.chars(LetterCharGroup) + digitChars) },
+	'*': func(c Constraints) string {
+		return c.filter(lowerChars + upperChars + digitChars + c.chars(SymbolCharGroup))
+	},
+}
+
+// patternElement is one position of a pattern: a placeholder, a [set] or a literal character
+type patternElement struct {
+	class   rune
+	set     string
+	literal rune
+}
+
+// chars returns the characters the element draws from, nil for literals
+func (e patternElement) chars(c Constraints) []rune {
+	switch {
+	case e.class != 0:
+		return []rune(patternClasses[e.class](c))
+	case e.set != "":
+		return []rune(c.filter(e.set))
+	}
+	return nil
+}
+
+// Pattern is a parsed password template, see ParsePattern
+type Pattern struct {
+	elements []patternElement
+}
+
+// ParsePattern parses a template like "Cvcc-9999-@@". Every character is one position:
+//
+//	c C  lower / upper consonant     a A  lower / upper letter     9  digit
+//	v V  lower / upper vowel         L    any letter               @  symbol
+//	#    letter or digit             *    any character            [..]  one of a set, with ranges
+//
+// "{n}" repeats the position before it n times, "\" makes the next character literal, and all other
+// characters are literal.
+func ParsePattern(text string) (*Pattern, error) {
+	pattern := &Pattern{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("pattern ends with an escape")
+			}
+			i++
+			pattern.elements = append(pattern.elements, patternElement{literal: runes[i]})
+
+		case r == '[':
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated set at %d", i+1)
+			}
+			set, err := parseSet(runes[i+1 : end])
+			if err != nil {
+				return nil, err
+			}
+			pattern.elements = append(pattern.elements, patternElement{set: set})
+			i = end
+
+		case r == '{':
+			end := slices.Index(runes[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated repeat at %d", i+1)
+			}
+			count, err := strconv.Atoi(string(runes[i+1 : i+end]))
+			if err != nil || count < 1 || count > maxPatternLength {
+				return nil, fmt.Errorf("invalid repeat %q", string(runes[i:i+end+1]))
+			}
+			if len(pattern.elements) == 0 {
+				return nil, fmt.Errorf("repeat at %d follows nothing", i+1)
+			}
+			last := pattern.elements[len(pattern.elements)-1]
+			for range count - 1 {
+				pattern.elements = append(pattern.elements, last)
+			}
+			i += end
+
+		case patternClasses[r] != nil:
+			pattern.elements = append(pattern.elements, patternElement{class: r})
+
+		default:
+			pattern.elements = append(pattern.elements, patternElement{literal: r})
+		}
+
+		if len(pattern.elements) > maxPatternLength {
+			return nil, fmt.Errorf("pattern is longer than %d characters", maxPatternLength)
+		}
+	}
+
+	if len(pattern.elements) == 0 {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+	return pattern, nil
+}
+
+// parseSet expands the inside of a [set], "a-f" is a range and "\" escapes the next character
+func parseSet(runes []rune) (string, error) {
+	var set strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' {
+			i++
+			set.WriteRune(runes[i])
+			continue
+		}
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			end := runes[i+2]
+			if end < r {
+				return "", fmt.Errorf("invalid range %c-%c", r, end)
+			}
+			for c := r; c <= end; c++ {
+				set.WriteRune(c)
+			}
+			i += 2
+			continue
+		}
+		set.WriteRune(r)
+	}
+	if set.Len() == 0 {
+		return "", fmt.Errorf("empty set")
+	}
+	return set.String(), nil
+}
+
+// resolve returns the characters of every position under the constraints, a single rune for
+// literals, and which positions are literal. StartWith narrows the first position.
+func (p *Pattern) resolve(c Constraints) ([][]rune, []bool, error) {
+	if err := c.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	positions := make([][]rune, len(p.elements))
+	literal := make([]bool, len(p.elements))
+	for i, element := range p.elements {
+		if element.literal != 0 {
+			positions[i] = []rune{element.literal}
+			literal[i] = true
+			continue
+		}
+		positions[i] = element.chars(c)
+		if len(positions[i]) == 0 {
+			return nil, nil, fmt.Errorf("%w: position %d has no characters left", ErrConstraintsTooStrict, i+1)
+		}
+	}
+
+	if c.StartWith != "" {
+		allowed := c.chars(c.StartWith)
+		positions[0] = slices.DeleteFunc(slices.Clone(positions[0]), func(r rune) bool { return !strings.ContainsRune(allowed, r) })
+		if len(positions[0]) == 0 {
+			return nil, nil, fmt.Errorf("%w: the pattern cannot start with a %s character", ErrConstraintsTooStrict, c.StartWith)
+		}
+	}
+
+	if c.NoRepeat {
+		generated := map[rune]bool{}
+		count := 0
+		for i, chars := range positions {
+			if literal[i] {
+				continue
+			}
+			count++
+			for _, r := range chars {
+				generated[r] = true
+			}
+		}
+		if count > len(generated) {
+			return nil, nil, fmt.Errorf("%w: %d characters cannot be all different out of %d", ErrConstraintsTooStrict, count, len(generated))
+		}
+	}
+	return positions, literal, nil
+}
+
+// Generate draws every position uniformly, without repeating a character under NoRepeat. Literal
+// characters may repeat.
+func (p *Pattern) Generate(c Constraints) ([]byte, error) {
+	positions, literal, err := p.resolve(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets [][]rune
+	for i, chars := range positions {
+		if !literal[i] {
+			sets = append(sets, chars)
+		}
+	}
+
+	for range maxAttempts {
+		picks, err := draw(sets, c.NoRepeat)
+		if err != nil {
+			return nil, err
+		}
+		if picks == nil {
+			continue
+		}
+
+		candidate := make([]rune, 0, len(positions))
+		for i, chars := range positions {
+			if literal[i] {
+				candidate = append(candidate, chars[0])
+				continue
+			}
+			candidate = append(candidate, picks[0])
+			picks = picks[1:]
+		}
+		if c.accepts(candidate, literal) {
+			return []byte(string(candidate)), nil
+		}
+	}
+	return nil, ErrConstraintsTooStrict
+}
+
+// Entropy estimates the bits of a password from the pattern, for an attacker who knows the pattern.
+// Under NoRepeat every earlier position sharing characters with a position is taken to have used one
+// of them, which slightly underestimates.
+func (p *Pattern) Entropy(c Constraints) (float64, error) {
+	positions, literal, err := p.resolve(c)
+	if err != nil {
+		return 0, err
+	}
+
+	bits := 0.0
+	for i, chars := range positions {
+		if literal[i] {
+			continue
+		}
+		size := len(chars)
+		if c.NoRepeat {
+			for j := range i {
+				if !literal[j] && slices.ContainsFunc(positions[j], func(r rune) bool { return slices.Contains(chars, r) }) {
+					size--
+				}
+			}
+		}
+		bits += math.Log2(float64(max(size, 1)))
+	}
+	return bits, nil
+}
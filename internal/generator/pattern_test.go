@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	valid := map[string]int{
+		"Cvcc-9999-@@":  12,
+		"9{4}":          4,
+		"[a-f0-9]{16}":  16,
+		`\9\@-[!#$]`:    4,
+		"[\\]a]L#*":     4,
+		"x{3}y":         4,
+		"[a-c][-_][x-]": 3,
+	}
+	for text, length := range valid {
+		pattern, err := ParsePattern(text)
+		if err != nil {
+			t.Errorf("ParsePattern(%q) unexpected error: %v", text, err)
+			continue
+		}
+		if len(pattern.elements) != length {
+			t.Errorf("ParsePattern(%q) has %d positions, want %d", text, len(pattern.elements), length)
+		}
+	}
+
+	for _, text := range []string{"", "[abc", "[]", "9{", "9{0}", "9{x}", "{3}", `ab\`, "[z-a]", "9{300}"} {
+		if _, err := ParsePattern(text); err == nil {
+			t.Errorf("ParsePattern(%q) expected an error, got nil", text)
+		}
+	}
+}
+
+func TestPatternGenerate(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		constraints Constraints
+		want        string
+	}{
+		{"Cvcc-9999-@@", Constraints{}, `^[B-DF-HJ-NP-TV-Z][aeiou][b-df-hj-np-tv-z]{2}-[0-9]{4}-[!@#$%^&*()\-_=+\[\]{}<>?/|]{2}$`},
+		{"@@@@", Constraints{Symbols: "!#$"}, `^[!#$]{4}$`},
+		{"#{40}", Constraints{ExcludeAmbiguous: true}, `^[^Il1|O0o]{40}$`},
+		{"*{8}", Constraints{StartWith: LetterCharGroup}, `^[a-zA-Z]`},
+		{"[x-z]\\[", Constraints{}, `^[x-z]\[$`},
+	}
+	for _, tt := range tests {
+		pattern, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q) unexpected error: %v", tt.pattern, err)
+		}
+		for range 20 {
+			password, err := pattern.Generate(tt.constraints)
+			if err != nil {
+				t.Fatalf("Generate(%q) unexpected error: %v", tt.pattern, err)
+			}
+			if !regexp.MustCompile(tt.want).Match(password) {
+				t.Errorf("Generate(%q) = %q, want %s", tt.pattern, password, tt.want)
+			}
+		}
+	}
+}
+
+func TestPatternNoRepeat(t *testing.T) {
+	pattern, _ := ParsePattern("[a-f0-9]{16}--")
+	for range 20 {
+		password, err := pattern.Generate(Constraints{NoRepeat: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, r := range "abcdef0123456789" {
+			if strings.Count(string(password), string(r)) != 1 {
+				t.Fatalf("password %q does not use %c exactly once", password, r)
+			}
+		}
+	}
+
+	pattern, _ = ParsePattern("[abc]{4}")
+	if _, err := pattern.Generate(Constraints{NoRepeat: true}); !errors.Is(err, ErrConstraintsTooStrict) {
+		t.Errorf("err = %v, want ErrConstraintsTooStrict", err)
+	}
+
+	pattern, _ = ParsePattern("99")
+	if _, err := pattern.Generate(Constraints{StartWith: LetterCharGroup}); !errors.Is(err, ErrConstraintsTooStrict) {
+		t.Errorf("err = %v, want ErrConstraintsTooStrict", err)
+	}
+}
+
+// TestDrawUniform checks that distinct picks from overlapping sets are not skewed towards outcomes
+// that leave fewer choices, as plain drawing without replacement would be
+func TestDrawUniform(t *testing.T) {
+	sets := [][]rune{[]rune("abc"), []rune("ab")}
+	counts := map[string]int{}
+	drawn := 0
+	for drawn < 40000 {
+		picks, err := draw(sets, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if picks != nil {
+			counts[string(picks)]++
+			drawn++
+		}
+	}
+
+	for _, outcome := range []string{"ab", "ba", "ca", "cb"} {
+		if share := float64(counts[outcome]) / float64(drawn); math.Abs(share-0.25) > 0.02 {
+			t.Errorf("outcome %q drawn %.3f of the time, want 0.25", outcome, share)
+		}
+	}
+}
+
+func TestPatternEntropy(t *testing.T) {
+	tests := []struct {
+		pattern     string
+		constraints Constraints
+		want        float64
+	}{
+		{"9999", Constraints{}, 4 * math.Log2(10)},
+		{"a-9", Constraints{}, math.Log2(26) + math.Log2(10)},
+		{"9999", Constraints{NoRepeat: true}, math.Log2(10 * 9 * 8 * 7)},
+		{"@@", Constraints{Symbols: "!#$"}, 2 * math.Log2(3)},
+		{"L", Constraints{ExcludeAmbiguous: true}, math.Log2(48)},
+	}
+	for _, tt := range tests {
+		pattern, err := ParsePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("ParsePattern(%q) unexpected error: %v", tt.pattern, err)
+		}
+		got, err := pattern.Entropy(tt.constraints)
+		if err != nil {
+			t.Fatalf("Entropy(%q) unexpected error: %v", tt.pattern, err)
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Entropy(%q, %+v) = %f, want %f", tt.pattern, tt.constraints, got, tt.want)
+		}
+	}
+}
+
+func TestConstrainedPassword(t *testing.T) {
+	constraints := Constraints{Symbols: "!#$", NoRepeat: true, StartWith: LetterCharGroup, ExcludeAmbiguous: true}
+	for range 20 {
+		password, err := ConstrainedPassword(8, true, true, true, true, RequireConfig{SymbolCharGroup: 1}, constraints)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9!#$]{7}$`).Match(password) || strings.ContainsAny(string(password), ambiguousChars) {
+			t.Errorf("password %q breaks the constraints", password)
+		}
+		if !strings.ContainsAny(string(password), "!#$") {
+			t.Errorf("password %q misses the required symbol", password)
+		}
+		for _, r := range string(password) {
+			if strings.Count(string(password), string(r)) > 1 {
+				t.Errorf("password %q repeats %c", password, r)
+			}
+		}
+	}
+
+	if _, err := ConstrainedPassword(11, false, false, true, false, nil, Constraints{NoRepeat: true}); !errors.Is(err, ErrConstraintsTooStrict) {
+		t.Errorf("err = %v, want ErrConstraintsTooStrict", err)
+	}
+	if _, err := ConstrainedPassword(8, true, true, true, true, nil, Constraints{StartWith: "vowel"}); err == nil {
+		t.Errorf("expected an error for an unknown group, got nil")
+	}
+}
@@ -0,0 +1,84 @@
+// Package health finds weak, reused, old and unused credentials for `kosh audit`. It works on
+// model.CredentialHealth, so plain secrets never leave the loop that decrypts them.
+package health
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// Options are the thresholds of an audit, zero values disable the check
+type Options struct {
+	// secrets taking fewer than 2^MinEntropy guesses by the strength estimate are weak
+	MinEntropy float64
+
+	// secrets not changed for longer are old
+	MaxAge time.Duration
+
+	Now time.Time
+}
+
+// Check returns the credentials with issues. Notes are only checked for reuse, they have no user and
+// no password policy; credentials without a secret, e.g. imported one-time passwords, are not weak.
+func Check(credentials []model.CredentialHealth, options Options) *model.HealthReport {
+	report := &model.HealthReport{Checked: len(credentials), Findings: []model.CredentialHealth{}, Reused: [][]int{}}
+
+	byHash := map[string][]int{}
+	for _, credential := range credentials {
+		if credential.HasSecret {
+			byHash[credential.SecretHash] = append(byHash[credential.SecretHash], credential.Id)
+		}
+	}
+	for _, ids := range byHash {
+		if len(ids) > 1 {
+			slices.Sort(ids)
+			report.Reused = append(report.Reused, ids)
+		}
+	}
+	slices.SortFunc(report.Reused, func(a, b []int) int { return cmp.Compare(a[0], b[0]) })
+
+	for _, credential := range credentials {
+		credential.Issues = nil
+		credential.ReusedWith = nil
+
+		if credential.HasSecret {
+			for _, id := range byHash[credential.SecretHash] {
+				if id != credential.Id {
+					credential.ReusedWith = append(credential.ReusedWith, id)
+				}
+			}
+		}
+		if len(credential.ReusedWith) > 0 {
+			credential.Issues = append(credential.Issues, model.HealthIssueReused)
+		}
+
+		if !credential.Note {
+			if credential.HasSecret && options.MinEntropy > 0 && credential.Entropy < options.MinEntropy {
+				credential.Issues = append(credential.Issues, model.HealthIssueWeak)
+			}
+			if credential.HasSecret && options.MaxAge > 0 && options.Now.Sub(credential.SecretChangedAt) > options.MaxAge {
+				credential.Issues = append(credential.Issues, model.HealthIssueOld)
+			}
+			if credential.Breached > 0 {
+				credential.Issues = append(credential.Issues, model.HealthIssueBreached)
+			}
+			if credential.User == "" {
+				credential.Issues = append(credential.Issues, model.HealthIssueMissingUser)
+			}
+		}
+
+		// accessed_at starts out as created_at and only moves on reads
+		if !credential.AccessedAt.After(credential.CreatedAt) {
+			credential.Issues = append(credential.Issues, model.HealthIssueNeverAccessed)
+		}
+
+		if len(credential.Issues) > 0 {
+			report.Findings = append(report.Findings, credential)
+		}
+	}
+	slices.SortFunc(report.Findings, func(a, b model.CredentialHealth) int { return cmp.Compare(a.Id, b.Id) })
+	return report
+}
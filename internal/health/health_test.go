@@ -0,0 +1,58 @@
+package health
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := now.AddDate(-2, 0, 0)
+	accessed := now.AddDate(0, 0, -1)
+
+	credentials := []model.CredentialHealth{
+		{Id: 1, Label: "github", User: "alice", HasSecret: true, SecretHash: "a", Entropy: 120, CreatedAt: created, SecretChangedAt: now, AccessedAt: accessed},
+		{Id: 2, Label: "gitlab", User: "alice", HasSecret: true, SecretHash: "a", Entropy: 120, CreatedAt: created, SecretChangedAt: now, AccessedAt: accessed},
+		{Id: 3, Label: "bank", User: "alice", HasSecret: true, SecretHash: "b", Entropy: 30, CreatedAt: created, SecretChangedAt: created, AccessedAt: created},
+		{Id: 4, Label: "wifi", User: "", HasSecret: true, SecretHash: "c", Entropy: 90, CreatedAt: created, SecretChangedAt: now, AccessedAt: accessed},
+		{Id: 5, Label: "recovery codes", User: "", Note: true, HasSecret: true, SecretHash: "a", Entropy: 20, CreatedAt: created, SecretChangedAt: created, AccessedAt: accessed},
+		{Id: 6, Label: "totp only", User: "bob", HasSecret: false, SecretHash: "e", CreatedAt: created, SecretChangedAt: created, AccessedAt: accessed},
+		{Id: 7, Label: "totp too", User: "carol", HasSecret: false, SecretHash: "e", CreatedAt: created, SecretChangedAt: created, AccessedAt: accessed},
+	}
+
+	report := Check(credentials, Options{MinEntropy: 60, MaxAge: 365 * 24 * time.Hour, Now: now})
+
+	if report.Checked != len(credentials) {
+		t.Errorf("Checked = %d, want %d", report.Checked, len(credentials))
+	}
+	if len(report.Reused) != 1 || !slices.Equal(report.Reused[0], []int{1, 2, 5}) {
+		t.Errorf("Reused = %v, want [[1 2 5]]", report.Reused)
+	}
+
+	want := map[int][]model.HealthIssue{
+		1: {model.HealthIssueReused},
+		2: {model.HealthIssueReused},
+		3: {model.HealthIssueWeak, model.HealthIssueOld, model.HealthIssueNeverAccessed},
+		4: {model.HealthIssueMissingUser},
+		5: {model.HealthIssueReused},
+	}
+	if len(report.Findings) != len(want) {
+		t.Fatalf("Findings = %+v, want %d", report.Findings, len(want))
+	}
+	for _, finding := range report.Findings {
+		if !slices.Equal(finding.Issues, want[finding.Id]) {
+			t.Errorf("issues of #%d = %v, want %v", finding.Id, finding.Issues, want[finding.Id])
+		}
+	}
+	if got := report.Findings[0].ReusedWith; !slices.Equal(got, []int{2, 5}) {
+		t.Errorf("ReusedWith of #1 = %v, want [2 5]", got)
+	}
+
+	disabled := Check(credentials[2:3], Options{Now: now})
+	if got := disabled.Findings[0].Issues; !slices.Equal(got, []model.HealthIssue{model.HealthIssueNeverAccessed}) {
+		t.Errorf("issues with disabled thresholds = %v, want only never-accessed", got)
+	}
+}
@@ -0,0 +1,135 @@
+// Package integrity detects changes made to the vault file outside kosh. Every credential row gets a
+// MAC keyed from the vault private key, and the set of row MACs is sealed together with a counter
+// that grows with every sealed change. Without the master password rows cannot be added, removed or
+// altered without breaking a MAC, and an older copy of the vault shows up as a counter lower than
+// the last one seen.
+package integrity
+
+import (
+	"cmp"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strconv"
+
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// MACFunc computes a MAC over data, either locally with Keyed or remotely by the unlock agent
+type MACFunc func(data []byte) ([]byte, error)
+
+// Key derives the MAC key from the vault private key, so it is only known with the master password
+func Key(vaultPrivateKey []byte) ([]byte, error) {
+	return hkdf.Key(sha256.New, vaultPrivateKey, nil, "kosh vault integrity v1", 32)
+}
+
+// Keyed returns an HMAC-SHA256 MACFunc for a key from Key
+func Keyed(key []byte) MACFunc {
+	return func(data []byte) ([]byte, error) {
+		h := hmac.New(sha256.New, key)
+		h.Write(data)
+		return h.Sum(nil), nil
+	}
+}
+
+// row is the canonical form of the columns covered by a row MAC, its field order must never change.
+// Access counts, times, folders and tags are left out, they change without the master password.
+type row struct {
+	Id           int    `json:"id"`
+	Label        string `json:"label"`
+	User         string `json:"user"`
+	Secret       string `json:"secret"`
+	Ephemeral    string `json:"ephemeral"`
+	Nonce        string `json:"nonce"`
+	Otp          string `json:"otp"`
+	OtpEphemeral string `json:"otpEphemeral"`
+	OtpNonce     string `json:"otpNonce"`
+}
+
+// RowMAC returns the hex encoded MAC of a credential row
+func RowMAC(mac MACFunc, credential *model.Credential) (string, error) {
+	canonical, _ := json.Marshal(row{
+		Id:           credential.Id,
+		Label:        credential.Label,
+		User:         credential.User,
+		Secret:       credential.Secret,
+		Ephemeral:    credential.Ephemeral,
+		Nonce:        credential.Nonce,
+		Otp:          credential.Otp,
+		OtpEphemeral: credential.OtpEphemeral,
+		OtpNonce:     credential.OtpNonce,
+	})
+	sum, err := mac(canonical)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// sealedRow is the canonical form of a stored row MAC inside the set MAC
+type sealedRow struct {
+	Id    int    `json:"id"`
+	Label string `json:"label"`
+	User  string `json:"user"`
+	MAC   string `json:"mac"`
+}
+
+// SetMAC returns the hex encoded MAC sealing the counter and audit log anchor of a record together
+// with every stored row MAC. An empty anchor is left out, so records sealed before the audit log was
+// anchored still match.
+func SetMAC(mac MACFunc, record *model.IntegrityRecord, macs []model.CredentialMAC) (string, error) {
+	rows := make([]sealedRow, 0, len(macs))
+	for _, sealed := range macs {
+		rows = append(rows, sealedRow{sealed.CredentialId, sealed.Label, sealed.User, sealed.MAC})
+	}
+	slices.SortFunc(rows, func(a, b sealedRow) int { return cmp.Compare(a.Id, b.Id) })
+
+	canonical, _ := json.Marshal(struct {
+		Counter     string      `json:"counter"`
+		Rows        []sealedRow `json:"rows"`
+		AuditLength int         `json:"auditLength,omitempty"`
+		AuditHead   string      `json:"auditHead,omitempty"`
+	}{strconv.Itoa(record.Counter), rows, record.AuditLength, record.AuditHead})
+	sum, err := mac(canonical)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// Compare matches the current credential rows against the stored row MACs and reports rows that
+// were added, altered or removed since they were sealed
+func Compare(mac MACFunc, credentials []model.Credential, stored []model.CredentialMAC) (added, modified, removed []model.CredentialMAC, err error) {
+	storedById := map[int]model.CredentialMAC{}
+	for _, sealed := range stored {
+		storedById[sealed.CredentialId] = sealed
+	}
+
+	for i := range credentials {
+		credential := &credentials[i]
+		current, err := RowMAC(mac, credential)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		sealed, ok := storedById[credential.Id]
+		delete(storedById, credential.Id)
+		entry := model.CredentialMAC{CredentialId: credential.Id, Label: credential.Label, User: credential.User, MAC: current}
+		switch {
+		case !ok:
+			added = append(added, entry)
+		case !hmac.Equal([]byte(sealed.MAC), []byte(current)):
+			modified = append(modified, entry)
+		}
+	}
+
+	for _, sealed := range stored {
+		if _, ok := storedById[sealed.CredentialId]; ok {
+			removed = append(removed, sealed)
+		}
+	}
+	return added, modified, removed, nil
+}
@@ -0,0 +1,171 @@
+package integrity
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+func testMAC(t *testing.T, privateKey string) MACFunc {
+	t.Helper()
+	key, err := Key([]byte(privateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Keyed(key)
+}
+
+func testCredentials() []model.Credential {
+	return []model.Credential{
+		{Id: 1, Label: "github", User: "alice", Secret: "c2VjcmV0", Ephemeral: "ZXBo", Nonce: "bm9uY2U="},
+		{Id: 2, Label: "vpn", User: "bob", Secret: "b3RoZXI=", Ephemeral: "ZXBo", Nonce: "bm9uY2U="},
+		{Id: 3, Label: "bank", User: "carol", Secret: "bW9uZXk=", Ephemeral: "ZXBo", Nonce: "bm9uY2U="},
+	}
+}
+
+func seal(t *testing.T, mac MACFunc, credentials []model.Credential) []model.CredentialMAC {
+	t.Helper()
+	sealed := []model.CredentialMAC{}
+	for i := range credentials {
+		rowMAC, err := RowMAC(mac, &credentials[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed = append(sealed, model.CredentialMAC{CredentialId: credentials[i].Id, Label: credentials[i].Label, User: credentials[i].User, MAC: rowMAC})
+	}
+	return sealed
+}
+
+func ids(macs []model.CredentialMAC) []int {
+	result := []int{}
+	for _, mac := range macs {
+		result = append(result, mac.CredentialId)
+	}
+	return result
+}
+
+func TestCompare(t *testing.T) {
+	mac := testMAC(t, "vault private key")
+
+	tests := []struct {
+		name                     string
+		change                   func([]model.Credential) []model.Credential
+		added, modified, removed []int
+	}{
+		{"unchanged", func(c []model.Credential) []model.Credential { return c }, []int{}, []int{}, []int{}},
+		{"secret replaced", func(c []model.Credential) []model.Credential {
+			c[1].Secret = "cm9sbGVkIGJhY2s="
+			return c
+		}, []int{}, []int{2}, []int{}},
+		{"otp removed", func(c []model.Credential) []model.Credential {
+			c[0].Otp = "c2VlZA=="
+			return c
+		}, []int{}, []int{1}, []int{}},
+		{"rows swapped ids", func(c []model.Credential) []model.Credential {
+			c[0].Id, c[1].Id = c[1].Id, c[0].Id
+			return c
+		}, []int{}, []int{2, 1}, []int{}},
+		{"row added", func(c []model.Credential) []model.Credential {
+			return append(c, model.Credential{Id: 4, Label: "evil", User: "mallory"})
+		}, []int{4}, []int{}, []int{}},
+		{"row removed", func(c []model.Credential) []model.Credential {
+			return c[:2]
+		}, []int{}, []int{}, []int{3}},
+		{"access count and folder are not covered", func(c []model.Credential) []model.Credential {
+			c[0].AccessCount = 42
+			c[0].Folder = "work"
+			c[0].Tags = []string{"ssh"}
+			return c
+		}, []int{}, []int{}, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := seal(t, mac, testCredentials())
+			added, modified, removed, err := Compare(mac, tt.change(testCredentials()), stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, check := range []struct {
+				kind      string
+				got, want []int
+			}{{"added", ids(added), tt.added}, {"modified", ids(modified), tt.modified}, {"removed", ids(removed), tt.removed}} {
+				if len(check.got) != len(check.want) {
+					t.Fatalf("%s = %v, want %v", check.kind, check.got, check.want)
+				}
+				for i := range check.got {
+					if check.got[i] != check.want[i] {
+						t.Fatalf("%s = %v, want %v", check.kind, check.got, check.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompareOtherKey(t *testing.T) {
+	stored := seal(t, testMAC(t, "vault private key"), testCredentials())
+	_, modified, _, err := Compare(testMAC(t, "another vault"), testCredentials(), stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modified) != 3 {
+		t.Errorf("modified = %v, want every row", ids(modified))
+	}
+}
+
+func TestSetMAC(t *testing.T) {
+	mac := testMAC(t, "vault private key")
+	stored := seal(t, mac, testCredentials())
+
+	record := &model.IntegrityRecord{Counter: 7, AuditLength: 3, AuditHead: "c0ffee"}
+	want, err := SetMAC(mac, record, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reversed := []model.CredentialMAC{stored[2], stored[1], stored[0]}
+	if got, _ := SetMAC(mac, record, reversed); got != want {
+		t.Error("SetMAC() depends on the order of rows")
+	}
+	if got, _ := SetMAC(mac, &model.IntegrityRecord{Counter: 6, AuditLength: 3, AuditHead: "c0ffee"}, stored); got == want {
+		t.Error("SetMAC() does not cover the counter")
+	}
+	if got, _ := SetMAC(mac, &model.IntegrityRecord{Counter: 7, AuditLength: 2, AuditHead: "c0ffee"}, stored); got == want {
+		t.Error("SetMAC() does not cover the audit log length")
+	}
+	if got, _ := SetMAC(mac, &model.IntegrityRecord{Counter: 7, AuditLength: 3, AuditHead: "decade"}, stored); got == want {
+		t.Error("SetMAC() does not cover the audit log head")
+	}
+	if got, _ := SetMAC(mac, record, stored[:2]); got == want {
+		t.Error("SetMAC() does not cover every row")
+	}
+
+	renamed := seal(t, mac, testCredentials())
+	renamed[0].Label = "gitlab"
+	if got, _ := SetMAC(mac, record, renamed); got == want {
+		t.Error("SetMAC() does not cover the label snapshot")
+	}
+}
+
+func TestSetMACWithoutAnchor(t *testing.T) {
+	mac := testMAC(t, "vault private key")
+	stored := seal(t, mac, testCredentials())
+
+	// the form sealed before the audit log was anchored
+	rows := []sealedRow{}
+	for _, sealed := range stored {
+		rows = append(rows, sealedRow{sealed.CredentialId, sealed.Label, sealed.User, sealed.MAC})
+	}
+	canonical, _ := json.Marshal(struct {
+		Counter string      `json:"counter"`
+		Rows    []sealedRow `json:"rows"`
+	}{"7", rows})
+	sum, _ := mac(canonical)
+
+	if got, _ := SetMAC(mac, &model.IntegrityRecord{Counter: 7}, stored); got != hex.EncodeToString(sum) {
+		t.Error("SetMAC() without an anchor does not match records sealed before anchoring")
+	}
+}
@@ -96,3 +96,15 @@ func Muted(format string, args ...any) {
 	message := fmt.Sprintf(format, args...)
 	fmt.Fprintf(out, "%s[•] %s%s\n", ColorGray, message, ColorReset)
 }
+
+// Redirect sends all logger output to w and returns a function that restores the previous writers.
+// Use it when stdout carries a protocol, e.g. the browser native messaging host:
+//
+//	defer logger.Redirect(os.Stderr)()
+func Redirect(w io.Writer) func() {
+	prevOut, prevErr := out, errOut
+	out, errOut = w, w
+	return func() {
+		out, errOut = prevOut, prevErr
+	}
+}
@@ -0,0 +1,100 @@
+package model
+
+import "time"
+
+// AccessVia is the way a credential was accessed
+type AccessVia string
+
+const (
+	AccessViaSearch     AccessVia = "search"
+	AccessViaMatch      AccessVia = "match"
+	AccessViaGet        AccessVia = "get"
+	AccessViaOTP        AccessVia = "otp"
+	AccessViaField      AccessVia = "field"
+	AccessViaNote       AccessVia = "note"
+	AccessViaAttachment AccessVia = "attachment"
+	AccessViaBrowser    AccessVia = "browser"
+	AccessViaAPI        AccessVia = "api"
+	AccessViaSDK        AccessVia = "sdk"
+
+	// one event per credential carrying its access count from before events were recorded
+	AccessViaMigrated AccessVia = "migrated"
+)
+
+// Weight is how much an access counts towards the frequency of a credential. Picking a search or
+// URL match counts less than asking for a credential by name or through a client.
+func (v AccessVia) Weight() float64 {
+	if v == AccessViaSearch || v == AccessViaMatch {
+		return 1
+	}
+	return 2
+}
+
+// AccessEvent is a read of a credential's secret or other data
+type AccessEvent struct {
+	Id           int
+	CredentialId int
+	Via          AccessVia
+
+	// hash of the search query the credential was found with, empty without a query
+	QueryHash string
+
+	// where the access came from, empty outside the command line
+	Context AccessContext
+
+	Weight float64
+	At     time.Time
+}
+
+// AccessContext is where a command runs: its working directory and the origin remote of the git
+// repository it is in
+type AccessContext struct {
+	Dir       string
+	GitRemote string // normalized, e.g. github.com/acme/api, empty outside a repository
+}
+
+// ContextFrequency is the frequency of a credential counting only accesses from the same context
+type ContextFrequency struct {
+	Dir       float64 // accesses from the same working directory
+	GitRemote float64 // accesses from any checkout of the same repository
+}
+
+// SelectionFrequency is how often a credential was picked from the results of a search query,
+// decayed like the frequency of a credential
+type SelectionFrequency struct {
+	Exact  float64 // picks for this very query
+	Prefix float64 // picks for longer queries starting with it
+}
+
+// QuerySelections holds the selection frequency of every credential picked for a query, by
+// credential id
+type QuerySelections map[int]SelectionFrequency
+
+// CredentialUsage is how much a credential is used, for `kosh stats`
+type CredentialUsage struct {
+	Id    int
+	Label string
+	User  string
+
+	// accesses ever, and accesses since the start of the period asked for
+	AccessCount int
+	Accesses    int
+
+	// sum of access weights halved every constants.AccessFrequencyHalfLifeDays days
+	Frequency float64
+
+	CreatedAt  time.Time
+	AccessedAt time.Time
+}
+
+// NeverUsed tells whether the credential was never accessed since it was added
+func (u *CredentialUsage) NeverUsed() bool {
+	return u.AccessCount == 0 && !u.AccessedAt.After(u.CreatedAt)
+}
+
+// AccessCount is the number of accesses of one kind on one day
+type AccessCount struct {
+	Day   time.Time
+	Via   AccessVia
+	Count int
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// APIClient is a client of the local JSON API (`kosh serve`). Only the SHA-256 hash of its token is
+// stored; Labels are glob patterns restricting the credentials the client may see or change.
+type APIClient struct {
+	Id        int
+	Name      string
+	TokenHash string
+	Labels    []string
+	Read      bool
+	Write     bool
+
+	CreatedAt time.Time
+	// zero until the token is used the first time
+	LastUsedAt time.Time
+}
+
+// APIAuditEntry records a single call to the local JSON API, rejected calls included
+type APIAuditEntry struct {
+	Id     int
+	Client string // empty when the call was not authenticated
+	Method string
+	Path   string
+	Status int
+	PID    int
+	UID    int
+
+	// zero when the call did not concern a single credential
+	CredentialId int
+
+	At time.Time
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/encoding"
+)
+
+// Attachment is an encrypted file stored alongside a credential. The file content is encrypted in
+// chunks with a random per-attachment file key, which is in turn sealed for the vault public key.
+type Attachment struct {
+	Id           int
+	CredentialId int
+	Name         string
+	Size         int64
+	ChunkSize    int
+
+	// crypto data of the sealed file key
+	Key          string
+	KeyEphemeral string
+	KeyNonce     string
+
+	CreatedAt time.Time
+}
+
+func (a *Attachment) GetRawData() *AttachmentData {
+	return &AttachmentData{
+		Id:           a.Id,
+		CredentialId: a.CredentialId,
+		Name:         a.Name,
+		Size:         a.Size,
+		ChunkSize:    a.ChunkSize,
+		Key:          encoding.DecodeBase64String(a.Key),
+		KeyEphemeral: encoding.DecodeBase64String(a.KeyEphemeral),
+		KeyNonce:     encoding.DecodeBase64String(a.KeyNonce),
+	}
+}
+
+type AttachmentData struct {
+	Id           int
+	CredentialId int
+	Name         string
+	Size         int64
+	ChunkSize    int
+	Key          []byte
+	KeyEphemeral []byte
+	KeyNonce     []byte
+}
+
+func (a *AttachmentData) EncodeToString() *Attachment {
+	return &Attachment{
+		Id:           a.Id,
+		CredentialId: a.CredentialId,
+		Name:         a.Name,
+		Size:         a.Size,
+		ChunkSize:    a.ChunkSize,
+		Key:          encoding.EncodeToBase64String(a.Key),
+		KeyEphemeral: encoding.EncodeToBase64String(a.KeyEphemeral),
+		KeyNonce:     encoding.EncodeToBase64String(a.KeyNonce),
+	}
+}
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// AuditEventType is the kind of vault operation recorded in the audit log
+type AuditEventType string
+
+const (
+	AuditEventAdd     AuditEventType = "add"
+	AuditEventUpdate  AuditEventType = "update"
+	AuditEventRead    AuditEventType = "read"
+	AuditEventTrash   AuditEventType = "trash"
+	AuditEventRestore AuditEventType = "restore"
+	AuditEventDelete  AuditEventType = "delete"
+
+	// AuditEventConfig is a change to the whole vault, a setting, tag max age or policy. It has no
+	// credential, its label names what changed.
+	AuditEventConfig AuditEventType = "config"
+)
+
+// AuditEvent is an entry of the append-only audit log. Label and User are a snapshot taken when the
+// event was recorded, so entries stay readable after a credential is renamed or deleted. Hash covers
+// every other field and PrevHash, chaining each entry to the one before it. Keyed entries were hashed
+// with the integrity MAC, the others were recorded while the vault was locked.
+type AuditEvent struct {
+	Id           int
+	Event        AuditEventType
+	CredentialId int
+	Label        string
+	User         string
+	Command      string
+	PID          int
+	At           time.Time
+
+	PrevHash string
+	Hash     string
+	Keyed    bool
+}
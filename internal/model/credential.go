@@ -6,40 +6,84 @@ import (
 	"git.plutolab.org/plutolab/kosh/internal/encoding"
 )
 
+// CredentialKind tells what a credential holds
+type CredentialKind string
+
+const (
+	CredentialKindLogin CredentialKind = "login" // a secret for a user
+	CredentialKindNote  CredentialKind = "note"  // a secure note, free text without a user
+)
+
 type Credential struct {
 	Id          int
 	Label       string
 	User        string
+	Kind        CredentialKind
 	AccessCount int
 
+	// decayed weight of recent accesses, see model.AccessVia.Weight
+	Frequency float64
+
+	// decayed weight of recent accesses from where the command runs, only loaded for search
+	ContextFrequency ContextFrequency
+
+	// organization, folder is a "/" separated path, empty for the root
+	Folder string
+	Tags   []string
+
+	// saved web URLs and app ids, only loaded where needed
+	URLs []string
+
 	// crypto data
 	Secret    string
 	Ephemeral string
 	Nonce     string
 
-	// timestamps
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
-	AccessedAt time.Time
+	// encrypted otpauth:// seed, empty when the credential has no one-time password
+	Otp          string
+	OtpEphemeral string
+	OtpNonce     string
+
+	// timestamps, UpdatedAt is the later of SecretChangedAt and MetadataChangedAt
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	SecretChangedAt   time.Time
+	MetadataChangedAt time.Time
+	AccessedAt        time.Time
 }
 
 func (c *Credential) GetRawData() *CredentialData {
 	return &CredentialData{
 		Label:     c.Label,
 		User:      c.User,
+		Kind:      c.Kind,
 		Secret:    encoding.DecodeBase64String(c.Secret),
 		Ephemeral: encoding.DecodeBase64String(c.Ephemeral),
 		Nonce:     encoding.DecodeBase64String(c.Nonce),
+
+		Otp:          encoding.DecodeBase64String(c.Otp),
+		OtpEphemeral: encoding.DecodeBase64String(c.OtpEphemeral),
+		OtpNonce:     encoding.DecodeBase64String(c.OtpNonce),
 	}
 }
 
+// HasOTP reports whether the credential carries an encrypted one-time password seed
+func (c *Credential) HasOTP() bool {
+	return c.Otp != ""
+}
+
 type CredentialData struct {
 	Id        int
 	Label     string
 	User      string
+	Kind      CredentialKind
 	Secret    []byte
 	Ephemeral []byte
 	Nonce     []byte
+
+	Otp          []byte
+	OtpEphemeral []byte
+	OtpNonce     []byte
 }
 
 func (c *CredentialData) EncodeToString() *Credential {
@@ -47,9 +91,14 @@ func (c *CredentialData) EncodeToString() *Credential {
 		Id:        c.Id,
 		Label:     c.Label,
 		User:      c.User,
+		Kind:      c.Kind,
 		Secret:    encoding.EncodeToBase64String(c.Secret),
 		Ephemeral: encoding.EncodeToBase64String(c.Ephemeral),
 		Nonce:     encoding.EncodeToBase64String(c.Nonce),
+
+		Otp:          encoding.EncodeToBase64String(c.Otp),
+		OtpEphemeral: encoding.EncodeToBase64String(c.OtpEphemeral),
+		OtpNonce:     encoding.EncodeToBase64String(c.OtpNonce),
 	}
 }
 
@@ -57,8 +106,19 @@ type CredentialSummary struct {
 	Id          int
 	Label       string
 	User        string
+	Kind        CredentialKind
 	AccessCount int
+	Folder      string
+	Tags        []string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	AccessedAt  time.Time
+
+	// when the secret last changed, and when label, user, folder, tags, fields, URLs or the
+	// one-time password seed last changed. Reads move neither.
+	SecretChangedAt   time.Time
+	MetadataChangedAt time.Time
+
+	// zero unless the credential is in the trash
+	DeletedAt time.Time
 }
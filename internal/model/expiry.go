@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// MaxAge is a rule that the secret of a credential, or of every credential with a tag, is changed at
+// least every Days days. Exactly one of CredentialId and Tag is set.
+type MaxAge struct {
+	CredentialId int
+	Label        string
+	User         string
+
+	Tag string
+
+	Days int
+}
+
+// CredentialExpiry is the max age that applies to a credential and when its secret last changed. A
+// max age set on the credential wins over its tags, otherwise the shortest of its tags applies.
+type CredentialExpiry struct {
+	Id    int
+	Label string
+	User  string
+
+	SecretChangedAt time.Time
+	MaxAgeDays      int
+
+	// tag the max age comes from, empty when it is set on the credential
+	Tag string
+}
+
+// DueAt is when the secret has to be changed
+func (e *CredentialExpiry) DueAt() time.Time {
+	return e.SecretChangedAt.AddDate(0, 0, e.MaxAgeDays)
+}
+
+// DaysLeft is the number of whole days until DueAt, negative once overdue by a day or more
+func (e *CredentialExpiry) DaysLeft(now time.Time) int {
+	return int(e.DueAt().Sub(now).Hours() / 24)
+}
+
+// Overdue tells whether the secret is older than its max age
+func (e *CredentialExpiry) Overdue(now time.Time) bool {
+	return now.After(e.DueAt())
+}
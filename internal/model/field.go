@@ -0,0 +1,91 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/encoding"
+)
+
+type FieldType string
+
+const (
+	FieldTypeText   FieldType = "text"
+	FieldTypeSecret FieldType = "secret"
+	FieldTypeURL    FieldType = "url"
+	FieldTypeOTP    FieldType = "otp"
+	FieldTypeNote   FieldType = "note"
+)
+
+var FieldTypes = []FieldType{FieldTypeText, FieldTypeSecret, FieldTypeURL, FieldTypeOTP, FieldTypeNote}
+
+// IsValid reports whether t is one of the known field types
+func (t FieldType) IsValid() bool {
+	return slices.Contains(FieldTypes, t)
+}
+
+// IsSensitive reports whether values of this type are encrypted at rest. Text and url fields are
+// plain metadata, same as the credential label and user.
+func (t FieldType) IsSensitive() bool {
+	return t == FieldTypeSecret || t == FieldTypeOTP || t == FieldTypeNote
+}
+
+// CredentialField is an additional named value attached to a credential, e.g. recovery codes,
+// security answers or an API key id.
+type CredentialField struct {
+	Id           int
+	CredentialId int
+	Name         string
+	Type         FieldType
+
+	// crypto data, ephemeral and nonce are empty for non-sensitive fields
+	Value     string
+	Ephemeral string
+	Nonce     string
+
+	// timestamps
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (f *CredentialField) GetRawData() *CredentialFieldData {
+	data := &CredentialFieldData{
+		Id:           f.Id,
+		CredentialId: f.CredentialId,
+		Name:         f.Name,
+		Type:         f.Type,
+		Value:        []byte(f.Value),
+	}
+	if f.Type.IsSensitive() {
+		data.Value = encoding.DecodeBase64String(f.Value)
+		data.Ephemeral = encoding.DecodeBase64String(f.Ephemeral)
+		data.Nonce = encoding.DecodeBase64String(f.Nonce)
+	}
+	return data
+}
+
+type CredentialFieldData struct {
+	Id           int
+	CredentialId int
+	Name         string
+	Type         FieldType
+	Value        []byte
+	Ephemeral    []byte
+	Nonce        []byte
+}
+
+func (f *CredentialFieldData) EncodeToString() *CredentialField {
+	field := &CredentialField{
+		Id:           f.Id,
+		CredentialId: f.CredentialId,
+		Name:         f.Name,
+		Type:         f.Type,
+		Value:        string(f.Value),
+	}
+	if f.Type.IsSensitive() {
+		field.Value = encoding.EncodeToBase64String(f.Value)
+		field.Ephemeral = encoding.EncodeToBase64String(f.Ephemeral)
+		field.Nonce = encoding.EncodeToBase64String(f.Nonce)
+	}
+	return field
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// HealthIssue is a problem `kosh audit` finds with a credential
+type HealthIssue string
+
+const (
+	HealthIssueReused        HealthIssue = "reused"
+	HealthIssueWeak          HealthIssue = "weak"
+	HealthIssueOld           HealthIssue = "old"
+	HealthIssueNeverAccessed HealthIssue = "never-accessed"
+	HealthIssueMissingUser   HealthIssue = "missing-user"
+	HealthIssueBreached      HealthIssue = "breached"
+)
+
+// CredentialHealth is what the audit knows about a credential. The secret is reduced to a keyed hash,
+// only comparable within one audit, and a strength estimate right after it is decrypted.
+type CredentialHealth struct {
+	Id         int
+	Label      string
+	User       string
+	Note       bool
+	HasSecret  bool
+	SecretHash string
+
+	// log2 of the guesses the strength estimate needs, its 0 to 4 score and offline crack time
+	Entropy      float64
+	Score        int
+	CrackSeconds float64
+
+	// times the secret was seen in breaches, only known with a breach list
+	Breached int
+
+	CreatedAt       time.Time
+	SecretChangedAt time.Time
+	AccessedAt      time.Time
+
+	Issues []HealthIssue
+
+	// ids of the other credentials sharing the secret
+	ReusedWith []int
+}
+
+// HealthReport is the result of `kosh audit`
+type HealthReport struct {
+	Checked int
+
+	// credentials with at least one issue, by id
+	Findings []CredentialHealth
+
+	// groups of credential ids sharing a secret
+	Reused [][]int
+}
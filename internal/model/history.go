@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/encoding"
+)
+
+// CredentialVersion is a previous secret of a credential, archived when the secret was replaced
+type CredentialVersion struct {
+	CredentialId int
+	Version      int
+
+	// crypto data
+	Secret    string
+	Ephemeral string
+	Nonce     string
+
+	ReplacedAt time.Time
+}
+
+func (v *CredentialVersion) GetRawData() *CredentialData {
+	return &CredentialData{
+		Id:        v.CredentialId,
+		Secret:    encoding.DecodeBase64String(v.Secret),
+		Ephemeral: encoding.DecodeBase64String(v.Ephemeral),
+		Nonce:     encoding.DecodeBase64String(v.Nonce),
+	}
+}
+
+// PendingSecret is a new secret generated by `kosh rotate`, kept next to the current secret until the
+// rotation is committed or aborted
+type PendingSecret struct {
+	CredentialId int
+
+	// crypto data
+	Secret    string
+	Ephemeral string
+	Nonce     string
+
+	CreatedAt time.Time
+}
+
+func (p *PendingSecret) GetRawData() *CredentialData {
+	return &CredentialData{
+		Id:        p.CredentialId,
+		Secret:    encoding.DecodeBase64String(p.Secret),
+		Ephemeral: encoding.DecodeBase64String(p.Ephemeral),
+		Nonce:     encoding.DecodeBase64String(p.Nonce),
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import "time"
+
+// IntegrityRecord seals the stored credential MACs. Counter grows with every sealed change, a vault
+// file whose counter is lower than the last one seen is an older copy.
+type IntegrityRecord struct {
+	Counter  int
+	MAC      string
+	SealedAt time.Time
+
+	// the audit log when it was sealed, its number of entries and the hash of the last one, so entries
+	// cut off the end are noticed
+	AuditLength int
+	AuditHead   string
+}
+
+// CredentialMAC is the sealed MAC of a credential row, with the label and user it had when sealed so
+// removed rows can still be named
+type CredentialMAC struct {
+	CredentialId int
+	Label        string
+	User         string
+	MAC          string
+}
+
+// IntegrityReport is the result of checking the vault file against its integrity record
+type IntegrityReport struct {
+	// the record itself does not match its MAC, the stored row MACs cannot be trusted
+	RecordInvalid bool
+
+	// Counter of the vault file is lower than KnownCounter, the last counter seen on this machine
+	RolledBack   bool
+	Counter      int
+	KnownCounter int
+
+	Added    []CredentialMAC
+	Modified []CredentialMAC
+	Removed  []CredentialMAC
+}
+
+// OK reports whether the vault file is unchanged since it was last sealed
+func (r *IntegrityReport) OK() bool {
+	return !r.RecordInvalid && !r.RolledBack && len(r.Added) == 0 && len(r.Modified) == 0 && len(r.Removed) == 0
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Policy is a named set of `kosh generate` options, kept in the vault so a system's password rules
+// are typed once. A credential may be linked to a policy, and is then regenerated with it.
+type Policy struct {
+	Id   int
+	Name string
+
+	// character groups, used unless Pattern or Passphrase is set
+	Length  int
+	Upper   bool
+	Lower   bool
+	Digit   bool // with Passphrase: append a digit to one word
+	Symbol  bool
+	Require string // minimum count per group, in --require syntax: "upper=2,digit=3"
+
+	// site rules, for character groups and patterns
+	ExcludeAmbiguous bool
+	Symbols          string
+	NoRepeat         bool
+	StartWith        string
+
+	// template like "Cvcc-9999-@@"
+	Pattern string
+
+	// diceware passphrase, an empty Wordlist is the EFF large wordlist
+	Passphrase bool
+	Words      int
+	Separator  string
+	Capitalize bool
+	Wordlist   string
+
+	CreatedAt time.Time
+
+	// number of credentials linked to the policy, only set by GetPolicies
+	Credentials int
+}
+
+// ValidPolicyName tells whether name can name a policy: not empty and without whitespace
+func ValidPolicyName(name string) bool {
+	return name != "" && !strings.ContainsFunc(name, unicode.IsSpace)
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tag is a tag name with the number of credentials carrying it
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// NormalizeTag lower-cases and trims a tag name, returns false if the name is empty or contains
+// whitespace or commas
+func NormalizeTag(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(name), "#")))
+	if name == "" || strings.ContainsFunc(name, func(r rune) bool { return unicode.IsSpace(r) || r == ',' }) {
+		return "", false
+	}
+	return name, true
+}
+
+// NormalizeFolder cleans up a folder path: segments are trimmed, empty segments are dropped and
+// joined with a single "/", so " /clients//acme/ " becomes "clients/acme"
+func NormalizeFolder(path string) string {
+	segments := []string{}
+	for segment := range strings.SplitSeq(path, "/") {
+		if segment = strings.TrimSpace(segment); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
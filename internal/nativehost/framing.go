@@ -0,0 +1,54 @@
+// Package nativehost implements the browser side protocol of kosh: Chrome and Firefox native
+// messaging. The browser starts the host and exchanges JSON messages over its stdin and stdout, each
+// prefixed with its length as a 32-bit unsigned integer in native byte order.
+package nativehost
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxMessageSize is the largest message sent to the browser, Chrome drops the host for anything
+// bigger. Messages from the browser are held to the same limit.
+const MaxMessageSize = 1 << 20
+
+var ErrMessageTooLarge = errors.New("native message too large")
+
+// ReadMessage reads one length-prefixed JSON message into v. It returns io.EOF when the browser
+// closed the pipe between messages.
+func ReadMessage(r io.Reader, v any) error {
+	var length uint32
+	if err := binary.Read(r, binary.NativeEndian, &length); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("reading message length: %w", err)
+		}
+		return err
+	}
+	if length > MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return fmt.Errorf("reading message body: %w", err)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// WriteMessage writes v as one length-prefixed JSON message
+func WriteMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(body) > MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	message := binary.NativeEndian.AppendUint32(make([]byte, 0, 4+len(body)), uint32(len(body)))
+	_, err = w.Write(append(message, body...))
+	return err
+}
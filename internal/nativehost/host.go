@@ -0,0 +1,185 @@
+package nativehost
+
+import (
+	"errors"
+	"io"
+
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
+)
+
+// request types
+const (
+	TypeStatus   = "status"
+	TypeLookup   = "lookup"
+	TypeFill     = "fill"
+	TypeSave     = "save"
+	TypeGenerate = "generate"
+)
+
+// machine readable error codes, the extension decides what to show from these
+const (
+	CodeLocked           = "locked"
+	CodeOriginNotAllowed = "origin_not_allowed"
+	CodeNotFound         = "not_found"
+	CodeInvalidRequest   = "invalid_request"
+	CodeFailed           = "failed"
+)
+
+var (
+	ErrNotFound       = errors.New("no matching credential")
+	ErrInvalidRequest = errors.New("invalid request")
+)
+
+// Request is a message from the extension. URL is the page (or androidapp:// id) the request is
+// made for, its origin must be on the allowlist.
+type Request struct {
+	Id           string `json:"id,omitempty"`
+	Type         string `json:"type"`
+	URL          string `json:"url,omitempty"`
+	CredentialId int    `json:"credentialId,omitempty"`
+	Label        string `json:"label,omitempty"`
+	User         string `json:"user,omitempty"`
+	Secret       string `json:"secret,omitempty"`
+	Length       int    `json:"length,omitempty"`
+}
+
+// Response answers a Request, Id is copied from the request
+type Response struct {
+	Id       string  `json:"id,omitempty"`
+	Ok       bool    `json:"ok"`
+	Code     string  `json:"code,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	Unlocked bool    `json:"unlocked,omitempty"`
+	Origin   string  `json:"origin,omitempty"`
+	Allowed  bool    `json:"allowed,omitempty"`
+	Logins   []Login `json:"logins,omitempty"`
+	Login    *Login  `json:"login,omitempty"`
+	Password string  `json:"password,omitempty"`
+}
+
+// Login describes a credential, Secret and OTP are only set in answer to a fill
+type Login struct {
+	Id     int     `json:"id"`
+	Label  string  `json:"label"`
+	User   string  `json:"user"`
+	Score  float64 `json:"score,omitempty"`
+	Secret string  `json:"secret,omitempty"`
+	OTP    string  `json:"otp,omitempty"`
+}
+
+// Backend does the actual vault work, the Host only checks requests and gates them
+type Backend interface {
+	// Unlocked reports whether the unlock agent holds the vault key
+	Unlocked() bool
+	// OriginAllowed reports whether the user allowed the extension to act on an origin
+	OriginAllowed(origin string) bool
+
+	Lookup(visited *urlmatch.Target) ([]Login, error)
+	Fill(credentialId int, visited *urlmatch.Target) (*Login, error)
+	Save(visited *urlmatch.Target, label, user, secret string) (*Login, error)
+	Generate(length int) (string, error)
+}
+
+// Host answers native messaging requests
+type Host struct {
+	backend Backend
+}
+
+func NewHost(backend Backend) *Host {
+	return &Host{backend}
+}
+
+// Serve answers requests read from r on w until r is closed
+func (h *Host) Serve(r io.Reader, w io.Writer) error {
+	for {
+		var req Request
+		err := ReadMessage(r, &req)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// the stream is out of sync, nothing sensible can be read after this
+			WriteMessage(w, failure(&req, CodeInvalidRequest, err))
+			return err
+		}
+
+		if err := WriteMessage(w, h.Handle(&req)); err != nil {
+			return err
+		}
+	}
+}
+
+// Handle answers a single request. Everything but status needs the unlock agent, everything bound
+// to a page needs its origin on the allowlist.
+func (h *Host) Handle(req *Request) *Response {
+	unlocked := h.backend.Unlocked()
+
+	var visited *urlmatch.Target
+	var origin string
+	if req.URL != "" {
+		var err error
+		if visited, err = urlmatch.Parse(req.URL); err != nil {
+			return failure(req, CodeInvalidRequest, err)
+		}
+		origin = visited.String()
+	}
+
+	if req.Type == TypeStatus {
+		res := success(req)
+		res.Unlocked = unlocked
+		res.Origin = origin
+		res.Allowed = origin != "" && h.backend.OriginAllowed(origin)
+		return res
+	}
+
+	if !unlocked {
+		return failure(req, CodeLocked, errors.New("vault is locked, run `kosh agent`"))
+	}
+
+	switch req.Type {
+	case TypeLookup, TypeFill, TypeSave:
+		if visited == nil {
+			return failure(req, CodeInvalidRequest, errors.New("url is required"))
+		}
+		if !h.backend.OriginAllowed(origin) {
+			return failure(req, CodeOriginNotAllowed, errors.New("origin not allowed, run `kosh native-host allow "+origin+"`"))
+		}
+	case TypeGenerate:
+	default:
+		return failure(req, CodeInvalidRequest, errors.New("unknown request type "+req.Type))
+	}
+
+	res := success(req)
+	var err error
+	switch req.Type {
+	case TypeLookup:
+		res.Logins, err = h.backend.Lookup(visited)
+	case TypeFill:
+		res.Login, err = h.backend.Fill(req.CredentialId, visited)
+	case TypeSave:
+		if req.Secret == "" {
+			return failure(req, CodeInvalidRequest, errors.New("secret is required"))
+		}
+		res.Login, err = h.backend.Save(visited, req.Label, req.User, req.Secret)
+	case TypeGenerate:
+		res.Password, err = h.backend.Generate(req.Length)
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return failure(req, CodeNotFound, err)
+	case errors.Is(err, ErrInvalidRequest):
+		return failure(req, CodeInvalidRequest, err)
+	case err != nil:
+		return failure(req, CodeFailed, err)
+	}
+	return res
+}
+
+func success(req *Request) *Response {
+	return &Response{Id: req.Id, Ok: true}
+}
+
+func failure(req *Request, code string, err error) *Response {
+	return &Response{Id: req.Id, Code: code, Error: err.Error()}
+}
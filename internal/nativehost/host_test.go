@@ -0,0 +1,182 @@
+package nativehost
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
+)
+
+type fakeBackend struct {
+	unlocked bool
+	allowed  map[string]bool
+	saved    []Login
+}
+
+func (f *fakeBackend) Unlocked() bool                   { return f.unlocked }
+func (f *fakeBackend) OriginAllowed(origin string) bool { return f.allowed[origin] }
+
+func (f *fakeBackend) Lookup(visited *urlmatch.Target) ([]Login, error) {
+	return []Login{{Id: 1, Label: visited.Host, User: "alice", Score: 1}}, nil
+}
+
+func (f *fakeBackend) Fill(credentialId int, visited *urlmatch.Target) (*Login, error) {
+	if credentialId != 1 {
+		return nil, ErrNotFound
+	}
+	return &Login{Id: 1, Label: visited.Host, User: "alice", Secret: "hunter2"}, nil
+}
+
+func (f *fakeBackend) Save(visited *urlmatch.Target, label, user, secret string) (*Login, error) {
+	login := Login{Id: len(f.saved) + 2, Label: label, User: user}
+	f.saved = append(f.saved, login)
+	return &login, nil
+}
+
+func (f *fakeBackend) Generate(length int) (string, error) {
+	return string(bytes.Repeat([]byte("x"), length)), nil
+}
+
+// harness plays the browser, it talks to a host running on the other end of a pair of pipes
+type harness struct {
+	t      *testing.T
+	toHost *io.PipeWriter
+	output *io.PipeReader
+	done   chan error
+}
+
+func newHarness(t *testing.T, backend Backend) *harness {
+	inputReader, inputWriter := io.Pipe()
+	outputReader, outputWriter := io.Pipe()
+
+	h := &harness{t, inputWriter, outputReader, make(chan error, 1)}
+	go func() {
+		err := NewHost(backend).Serve(inputReader, outputWriter)
+		outputWriter.Close()
+		h.done <- err
+	}()
+	return h
+}
+
+func (h *harness) send(req Request) Response {
+	h.t.Helper()
+	if err := WriteMessage(h.toHost, req); err != nil {
+		h.t.Fatalf("WriteMessage: %v", err)
+	}
+	var res Response
+	if err := ReadMessage(h.output, &res); err != nil {
+		h.t.Fatalf("ReadMessage: %v", err)
+	}
+	if res.Id != req.Id {
+		h.t.Fatalf("response id = %q, want %q", res.Id, req.Id)
+	}
+	return res
+}
+
+func (h *harness) close() error {
+	h.toHost.Close()
+	return <-h.done
+}
+
+func TestHost_Gating(t *testing.T) {
+	backend := &fakeBackend{allowed: map[string]bool{"https://github.com": true}}
+	h := newHarness(t, backend)
+
+	res := h.send(Request{Id: "1", Type: TypeStatus, URL: "https://github.com/login"})
+	if !res.Ok || res.Unlocked || !res.Allowed || res.Origin != "https://github.com" {
+		t.Errorf("status while locked = %+v", res)
+	}
+
+	res = h.send(Request{Id: "2", Type: TypeLookup, URL: "https://github.com/login"})
+	if res.Ok || res.Code != CodeLocked {
+		t.Errorf("lookup while locked = %+v, want code %s", res, CodeLocked)
+	}
+
+	backend.unlocked = true
+
+	res = h.send(Request{Id: "3", Type: TypeLookup, URL: "https://evil.example"})
+	if res.Ok || res.Code != CodeOriginNotAllowed {
+		t.Errorf("lookup on other origin = %+v, want code %s", res, CodeOriginNotAllowed)
+	}
+
+	res = h.send(Request{Id: "4", Type: TypeLookup, URL: "https://github.com/login"})
+	if !res.Ok || len(res.Logins) != 1 || res.Logins[0].Secret != "" {
+		t.Errorf("lookup = %+v", res)
+	}
+
+	res = h.send(Request{Id: "5", Type: TypeFill})
+	if res.Ok || res.Code != CodeInvalidRequest {
+		t.Errorf("fill without url = %+v, want code %s", res, CodeInvalidRequest)
+	}
+
+	if err := h.close(); err != nil {
+		t.Errorf("Serve returned %v after the browser closed stdin", err)
+	}
+}
+
+func TestHost_Requests(t *testing.T) {
+	backend := &fakeBackend{unlocked: true, allowed: map[string]bool{"https://github.com": true}}
+	h := newHarness(t, backend)
+	defer h.close()
+
+	res := h.send(Request{Id: "fill", Type: TypeFill, URL: "https://github.com", CredentialId: 1})
+	if !res.Ok || res.Login == nil || res.Login.Secret != "hunter2" {
+		t.Errorf("fill = %+v", res)
+	}
+
+	res = h.send(Request{Id: "missing", Type: TypeFill, URL: "https://github.com", CredentialId: 9})
+	if res.Ok || res.Code != CodeNotFound {
+		t.Errorf("fill unknown credential = %+v, want code %s", res, CodeNotFound)
+	}
+
+	res = h.send(Request{Id: "save", Type: TypeSave, URL: "https://github.com", Label: "github", User: "bob"})
+	if res.Ok || res.Code != CodeInvalidRequest {
+		t.Errorf("save without secret = %+v, want code %s", res, CodeInvalidRequest)
+	}
+
+	res = h.send(Request{Id: "save", Type: TypeSave, URL: "https://github.com", Label: "github", User: "bob", Secret: "s3cret"})
+	if !res.Ok || res.Login == nil || len(backend.saved) != 1 || backend.saved[0].User != "bob" {
+		t.Errorf("save = %+v, saved %+v", res, backend.saved)
+	}
+
+	// generate is not bound to a page, so it needs no origin
+	res = h.send(Request{Id: "gen", Type: TypeGenerate, Length: 12})
+	if !res.Ok || len(res.Password) != 12 {
+		t.Errorf("generate = %+v", res)
+	}
+
+	res = h.send(Request{Id: "bad", Type: "export"})
+	if res.Ok || res.Code != CodeInvalidRequest {
+		t.Errorf("unknown type = %+v, want code %s", res, CodeInvalidRequest)
+	}
+}
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, Request{Type: TypeStatus}); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	if got, want := buf.Len(), 4+len(`{"type":"status"}`); got != want {
+		t.Errorf("framed length = %d, want %d", got, want)
+	}
+
+	var req Request
+	if err := ReadMessage(&buf, &req); err != nil || req.Type != TypeStatus {
+		t.Errorf("ReadMessage = %+v, %v", req, err)
+	}
+	if err := ReadMessage(&buf, &req); err != io.EOF {
+		t.Errorf("ReadMessage on empty stream = %v, want io.EOF", err)
+	}
+
+	oversized := []byte{0xff, 0xff, 0xff, 0xff}
+	if err := ReadMessage(bytes.NewReader(oversized), &req); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("ReadMessage oversized = %v, want %v", err, ErrMessageTooLarge)
+	}
+
+	truncated := []byte{10, 0, 0, 0, '{'}
+	if err := ReadMessage(bytes.NewReader(truncated), &req); err == nil || err == io.EOF {
+		t.Errorf("ReadMessage truncated = %v, want an error", err)
+	}
+}
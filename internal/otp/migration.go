@@ -0,0 +1,200 @@
+package otp
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var ErrInvalidMigrationPayload = errors.New("invalid otpauth-migration payload")
+
+// protobuf wire types used by the migration payload
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+// Enum values of the Google Authenticator MigrationPayload.OtpParameters message
+var (
+	migrationAlgorithms = map[uint64]string{1: AlgorithmSHA1, 2: AlgorithmSHA256, 3: AlgorithmSHA512}
+	migrationDigits     = map[uint64]int{1: 6, 2: 8}
+	migrationTypes      = map[uint64]string{1: TypeHOTP, 2: TypeTOTP}
+)
+
+// ParseMigrationURI decodes an otpauth-migration://offline?data=... URI as exported by Google
+// Authenticator into the keys it carries. The data parameter is a base64 encoded MigrationPayload
+// protobuf message, decoded here without any protobuf dependency:
+//
+//	message MigrationPayload {
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2; int32 batch_size = 3; int32 batch_index = 4; int32 batch_id = 5;
+//	}
+//	message OtpParameters {
+//	  bytes secret = 1; string name = 2; string issuer = 3;
+//	  Algorithm algorithm = 4; DigitCount digits = 5; OtpType type = 6; int64 counter = 7;
+//	}
+func ParseMigrationURI(uri string) ([]*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || u.Scheme != "otpauth-migration" {
+		return nil, ErrInvalidMigrationPayload
+	}
+
+	data, err := queryParam(u.RawQuery, "data")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationPayload, err.Error())
+	}
+	if data == "" {
+		return nil, fmt.Errorf("%w: missing data", ErrInvalidMigrationPayload)
+	}
+
+	// exporters are inconsistent about padding and the url-safe alphabet
+	data = strings.TrimRight(data, "=")
+	data = strings.NewReplacer("-", "+", "_", "/").Replace(data)
+	payload, err := base64.RawStdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationPayload, err.Error())
+	}
+
+	return decodeMigrationPayload(payload)
+}
+
+// queryParam returns the first value of a query parameter. Only %XX escapes are decoded: exporters
+// put base64 in the query unescaped, and a + in it is part of the data, not a space.
+func queryParam(rawQuery, name string) (string, error) {
+	for _, param := range strings.Split(rawQuery, "&") {
+		if value, ok := strings.CutPrefix(param, name+"="); ok {
+			return url.PathUnescape(value)
+		}
+	}
+	return "", nil
+}
+
+func decodeMigrationPayload(payload []byte) ([]*Key, error) {
+	var keys []*Key
+	err := walkMessage(payload, func(field int, wireType int, value uint64, data []byte) error {
+		if field != 1 || wireType != wireLen {
+			return nil // version and batch information are not needed
+		}
+		key, err := decodeOtpParameters(data)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func decodeOtpParameters(message []byte) (*Key, error) {
+	key := &Key{
+		Type:      TypeTOTP,
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod, // the migration format has no period, authenticator always uses 30s
+	}
+
+	var name string
+	err := walkMessage(message, func(field int, wireType int, value uint64, data []byte) error {
+		switch field {
+		case 1:
+			key.Secret = append([]byte(nil), data...)
+		case 2:
+			name = string(data)
+		case 3:
+			key.Issuer = string(data)
+		case 4:
+			if algorithm, ok := migrationAlgorithms[value]; ok {
+				key.Algorithm = algorithm
+			} else if value != 0 {
+				return ErrInvalidAlgorithm
+			}
+		case 5:
+			if digits, ok := migrationDigits[value]; ok {
+				key.Digits = digits
+			}
+		case 6:
+			if otpType, ok := migrationTypes[value]; ok {
+				key.Type = otpType
+			}
+		case 7:
+			key.Counter = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// name is "account" or "issuer:account", same as the otpauth:// label
+	if issuer, account, found := strings.Cut(name, ":"); found {
+		if key.Issuer == "" {
+			key.Issuer = strings.TrimSpace(issuer)
+		}
+		name = account
+	}
+	key.Account = strings.TrimSpace(name)
+
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// walkMessage iterates over the fields of a protobuf message. Varint fields are passed in value,
+// length-delimited fields in data.
+func walkMessage(message []byte, fn func(field int, wireType int, value uint64, data []byte) error) error {
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			return fmt.Errorf("%w: malformed tag", ErrInvalidMigrationPayload)
+		}
+		message = message[n:]
+
+		field, wireType := int(tag>>3), int(tag&0x7)
+		var value uint64
+		var data []byte
+
+		switch wireType {
+		case wireVarint:
+			value, n = binary.Uvarint(message)
+			if n <= 0 {
+				return fmt.Errorf("%w: malformed varint", ErrInvalidMigrationPayload)
+			}
+			message = message[n:]
+		case wireLen:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return fmt.Errorf("%w: malformed length", ErrInvalidMigrationPayload)
+			}
+			data = message[n : n+int(length)]
+			message = message[n+int(length):]
+		case wireI64:
+			if len(message) < 8 {
+				return fmt.Errorf("%w: truncated field", ErrInvalidMigrationPayload)
+			}
+			value = binary.LittleEndian.Uint64(message)
+			message = message[8:]
+		case wireI32:
+			if len(message) < 4 {
+				return fmt.Errorf("%w: truncated field", ErrInvalidMigrationPayload)
+			}
+			value = uint64(binary.LittleEndian.Uint32(message))
+			message = message[4:]
+		default:
+			return fmt.Errorf("%w: unsupported wire type %d", ErrInvalidMigrationPayload, wireType)
+		}
+
+		if err := fn(field, wireType, value, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
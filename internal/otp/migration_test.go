@@ -0,0 +1,69 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+)
+
+// two accounts: a TOTP GitHub key and an 8 digit SHA256 HOTP key without issuer
+const migrationFixture = "otpauth-migration://offline?data=CjIKFDEyMzQ1Njc4OTAxMjM0NTY3ODkwEgxHaXRIdWI6YWxpY2UaBkdpdEh1YiABKAEwAgolCgphYmNkZWZnaGlqEg9ib2JAZXhhbXBsZS5jb20gAigCMAE4BxABGAEgACi5YA%3D%3D"
+
+func TestParseMigrationURI(t *testing.T) {
+	keys, err := ParseMigrationURI(migrationFixture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys, want 2", len(keys))
+	}
+
+	totp := keys[0]
+	if totp.Type != TypeTOTP || totp.Issuer != "GitHub" || totp.Account != "alice" {
+		t.Errorf("unexpected totp key %+v", totp)
+	}
+	if string(totp.Secret) != "12345678901234567890" || totp.Algorithm != AlgorithmSHA1 || totp.Digits != 6 || totp.Period != DefaultPeriod {
+		t.Errorf("unexpected totp parameters %+v", totp)
+	}
+
+	hotp := keys[1]
+	if hotp.Type != TypeHOTP || hotp.Issuer != "" || hotp.Account != "bob@example.com" {
+		t.Errorf("unexpected hotp key %+v", hotp)
+	}
+	if string(hotp.Secret) != "abcdefghij" || hotp.Algorithm != AlgorithmSHA256 || hotp.Digits != 8 || hotp.Counter != 7 {
+		t.Errorf("unexpected hotp parameters %+v", hotp)
+	}
+}
+
+func TestParseMigrationURI_RawPlus(t *testing.T) {
+	// the base64 of the secret is ++++, left unescaped as some exporters do
+	keys, err := ParseMigrationURI("otpauth-migration://offline?data=ChQKBQAA++++EgVhbGljZSABKAEwAg==")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("got %d keys, want 1", len(keys))
+	}
+	if string(keys[0].Secret) != "\x00\x00\xfb\xef\xbe" || keys[0].Account != "alice" {
+		t.Errorf("unexpected key %+v", keys[0])
+	}
+}
+
+func TestParseMigrationURI_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{"wrong scheme", "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP"},
+		{"missing data", "otpauth-migration://offline"},
+		{"not base64", "otpauth-migration://offline?data=%%%"},
+		{"truncated message", "otpauth-migration://offline?data=CjIKFDEy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMigrationURI(tt.uri); !errors.Is(err, ErrInvalidMigrationPayload) {
+				t.Errorf("ParseMigrationURI(%s) error = %v, want %v", tt.uri, err, ErrInvalidMigrationPayload)
+			}
+		})
+	}
+}
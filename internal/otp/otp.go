@@ -0,0 +1,239 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+var (
+	ErrInvalidURI       = errors.New("invalid otpauth uri")
+	ErrInvalidSecret    = errors.New("invalid otp secret")
+	ErrInvalidAlgorithm = errors.New("unsupported otp algorithm")
+	ErrInvalidDigits    = errors.New("unsupported otp digits")
+	ErrInvalidPeriod    = errors.New("invalid otp period")
+)
+
+// Key holds everything needed to generate one-time passwords, as described by an otpauth:// URI
+// (https://github.com/google/google-authenticator/wiki/Key-Uri-Format).
+type Key struct {
+	Type      string
+	Issuer    string
+	Account   string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int    // seconds, TOTP only
+	Counter   uint64 // HOTP only
+}
+
+// ParseURI parses an otpauth:// URI into a Key, filling in the defaults for missing parameters.
+func ParseURI(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || u.Scheme != "otpauth" {
+		return nil, ErrInvalidURI
+	}
+
+	key := &Key{
+		Type:      strings.ToLower(u.Host),
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidURI, u.Host)
+	}
+
+	// label is either "account" or "issuer:account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, found := strings.Cut(label, ":"); found {
+		key.Issuer = strings.TrimSpace(issuer)
+		key.Account = strings.TrimSpace(account)
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+
+	params := u.Query()
+	if issuer := params.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+
+	key.Secret, err = DecodeSecret(params.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithm := params.Get("algorithm"); algorithm != "" {
+		key.Algorithm = strings.ToUpper(algorithm)
+	}
+
+	if digits := params.Get("digits"); digits != "" {
+		if key.Digits, err = strconv.Atoi(digits); err != nil {
+			return nil, ErrInvalidDigits
+		}
+	}
+
+	if period := params.Get("period"); period != "" {
+		if key.Period, err = strconv.Atoi(period); err != nil {
+			return nil, ErrInvalidPeriod
+		}
+	}
+
+	if counter := params.Get("counter"); counter != "" {
+		if key.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid counter", ErrInvalidURI)
+		}
+	}
+
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Validate checks that the key can be used to generate codes
+func (k *Key) Validate() error {
+	if len(k.Secret) == 0 {
+		return ErrInvalidSecret
+	}
+	if _, err := hashFunc(k.Algorithm); err != nil {
+		return err
+	}
+	if k.Digits < 6 || k.Digits > 8 {
+		return ErrInvalidDigits
+	}
+	if k.Type == TypeTOTP && k.Period <= 0 {
+		return ErrInvalidPeriod
+	}
+	return nil
+}
+
+// URI serializes the key back into an otpauth:// URI
+func (k *Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	params := url.Values{}
+	params.Set("secret", EncodeSecret(k.Secret))
+	if k.Issuer != "" {
+		params.Set("issuer", k.Issuer)
+	}
+	params.Set("algorithm", k.Algorithm)
+	params.Set("digits", strconv.Itoa(k.Digits))
+	if k.Type == TypeHOTP {
+		params.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		params.Set("period", strconv.Itoa(k.Period))
+	}
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     k.Type,
+		Path:     "/" + label,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// Code returns the one-time password for the key at time t. For HOTP keys the current counter is
+// used and t is ignored; callers are responsible for advancing and persisting the counter.
+func (k *Key) Code(t time.Time) (string, error) {
+	counter := k.Counter
+	if k.Type == TypeTOTP {
+		counter = uint64(t.Unix()) / uint64(k.Period)
+	}
+	return HOTP(k.Secret, counter, k.Digits, k.Algorithm)
+}
+
+// Remaining returns the time left before the TOTP code generated at t expires. It is zero for HOTP keys.
+func (k *Key) Remaining(t time.Time) time.Duration {
+	if k.Type != TypeTOTP {
+		return 0
+	}
+	period := int64(k.Period)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// HOTP computes an RFC 4226 one-time password for the given counter
+func HOTP(secret []byte, counter uint64, digits int, algorithm string) (string, error) {
+	h, err := hashFunc(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+
+	mac := hmac.New(h, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for range digits {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%modulo), nil
+}
+
+// DecodeSecret decodes a base32 secret as found in otpauth:// URIs. Padding, spaces and casing are
+// ignored since authenticator apps are lenient about them.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return nil, ErrInvalidSecret
+	}
+
+	data, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return data, nil
+}
+
+// EncodeSecret encodes raw secret bytes as unpadded base32
+func EncodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, ErrInvalidAlgorithm
+}
@@ -0,0 +1,157 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHOTP_RFC4226(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	for counter, code := range want {
+		got, err := HOTP(secret, uint64(counter), 6, AlgorithmSHA1)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != code {
+			t.Errorf("HOTP(counter=%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTP_RFC6238(t *testing.T) {
+	secrets := map[string][]byte{
+		AlgorithmSHA1:   []byte("12345678901234567890"),
+		AlgorithmSHA256: []byte("12345678901234567890123456789012"),
+		AlgorithmSHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, AlgorithmSHA1, "94287082"},
+		{59, AlgorithmSHA256, "46119246"},
+		{59, AlgorithmSHA512, "90693936"},
+		{1111111109, AlgorithmSHA1, "07081804"},
+		{1111111109, AlgorithmSHA256, "68084774"},
+		{1111111109, AlgorithmSHA512, "25091201"},
+		{1234567890, AlgorithmSHA1, "89005924"},
+		{1234567890, AlgorithmSHA256, "91819424"},
+		{1234567890, AlgorithmSHA512, "93441116"},
+		{20000000000, AlgorithmSHA1, "65353130"},
+		{20000000000, AlgorithmSHA256, "77737706"},
+		{20000000000, AlgorithmSHA512, "47863826"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			key := &Key{
+				Type:      TypeTOTP,
+				Secret:    secrets[tt.algorithm],
+				Algorithm: tt.algorithm,
+				Digits:    8,
+				Period:    30,
+			}
+			got, err := key.Code(time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TOTP(%d, %s) = %s, want %s", tt.unix, tt.algorithm, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	t.Run("defaults are applied", func(t *testing.T) {
+		key, err := ParseURI("otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if key.Type != TypeTOTP || key.Issuer != "Example" || key.Account != "alice@example.com" {
+			t.Errorf("unexpected key %+v", key)
+		}
+		if key.Algorithm != AlgorithmSHA1 || key.Digits != DefaultDigits || key.Period != DefaultPeriod {
+			t.Errorf("defaults not applied, got %+v", key)
+		}
+	})
+
+	t.Run("custom parameters", func(t *testing.T) {
+		key, err := ParseURI("otpauth://totp/alice?secret=jbswy3dpehpk3pxp&algorithm=sha256&digits=8&period=60")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if key.Algorithm != AlgorithmSHA256 || key.Digits != 8 || key.Period != 60 || key.Issuer != "" {
+			t.Errorf("unexpected key %+v", key)
+		}
+	})
+
+	t.Run("hotp counter", func(t *testing.T) {
+		key, err := ParseURI("otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP&counter=42")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if key.Type != TypeHOTP || key.Counter != 42 {
+			t.Errorf("unexpected key %+v", key)
+		}
+	})
+
+	t.Run("round trip through URI", func(t *testing.T) {
+		key, err := ParseURI("otpauth://totp/My%20Bank:alice?secret=JBSWY3DPEHPK3PXP&digits=8")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		again, err := ParseURI(key.URI())
+		if err != nil {
+			t.Fatalf("unexpected error re-parsing %s: %v", key.URI(), err)
+		}
+		if again.Issuer != "My Bank" || again.Account != "alice" || again.Digits != 8 || string(again.Secret) != string(key.Secret) {
+			t.Errorf("round trip mismatch, got %+v want %+v", again, key)
+		}
+	})
+
+	invalid := []struct {
+		name string
+		uri  string
+		want error
+	}{
+		{"wrong scheme", "https://totp/alice?secret=JBSWY3DPEHPK3PXP", ErrInvalidURI},
+		{"unknown type", "otpauth://motp/alice?secret=JBSWY3DPEHPK3PXP", ErrInvalidURI},
+		{"missing secret", "otpauth://totp/alice", ErrInvalidSecret},
+		{"bad secret", "otpauth://totp/alice?secret=not-base32!", ErrInvalidSecret},
+		{"bad algorithm", "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5", ErrInvalidAlgorithm},
+		{"bad digits", "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4", ErrInvalidDigits},
+		{"bad period", "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0", ErrInvalidPeriod},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseURI(tt.uri)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ParseURI(%s) error = %v, want %v", tt.uri, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	key := &Key{Type: TypeTOTP, Period: 30}
+	if got := key.Remaining(time.Unix(59, 0)); got != time.Second {
+		t.Errorf("remaining at 59s = %s, want 1s", got)
+	}
+	if got := key.Remaining(time.Unix(60, 0)); got != 30*time.Second {
+		t.Errorf("remaining at 60s = %s, want 30s", got)
+	}
+
+	hotp := &Key{Type: TypeHOTP}
+	if got := hotp.Remaining(time.Unix(59, 0)); got != 0 {
+		t.Errorf("hotp remaining = %s, want 0", got)
+	}
+}
@@ -0,0 +1,31 @@
+// Package peercred identifies the process on the other end of a Unix socket connection, so local
+// servers (the unlock agent, the API server) can refuse connections from other users.
+package peercred
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+var ErrUnsupported = errors.New("peer credentials are not supported on this connection")
+
+// Cred identifies the peer process of a Unix socket connection
+type Cred struct {
+	PID int
+	UID int
+}
+
+// SameUser reports whether the peer runs as the same user as the current process
+func (c *Cred) SameUser() bool {
+	return c.UID == os.Getuid()
+}
+
+// Get returns the credentials of the peer of a Unix socket connection
+func Get(conn net.Conn) (*Cred, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return get(unixConn)
+}
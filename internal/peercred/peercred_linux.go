@@ -0,0 +1,32 @@
+//go:build linux
+
+package peercred
+
+import (
+	"net"
+	"syscall"
+)
+
+// Supported tells whether Get can identify peers on this platform
+const Supported = true
+
+// get reads SO_PEERCRED, the credentials the kernel recorded when the peer connected
+func get(conn *net.UnixConn) (*Cred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+
+	return &Cred{PID: int(ucred.Pid), UID: int(ucred.Uid)}, nil
+}
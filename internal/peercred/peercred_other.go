@@ -0,0 +1,14 @@
+//go:build !linux
+
+package peercred
+
+import "net"
+
+// Supported tells whether Get can identify peers on this platform
+const Supported = false
+
+// get is not implemented outside Linux. Servers either refuse every connection or, when told to, fall
+// back to the permissions of the socket file, which is created 0600 inside the 0700 ~/.kosh directory.
+func get(conn *net.UnixConn) (*Cred, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,54 @@
+package search
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// QueryHash identifies a search query in the access history without keeping its text, empty for an
+// empty query or without a key. Queries are compared ignoring case and surrounding space. The hash is
+// an HMAC with the per-vault query key, kept outside the vault file, so stray input like a password
+// typed at the wrong prompt stays out of the vault and short queries and prefixes cannot be guessed
+// from a copy of it.
+func QueryHash(key []byte, label, user string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	user = strings.ToLower(strings.TrimSpace(user))
+	if len(key) == 0 || (label == "" && user == "") {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label + "\x00" + user))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// maxPrefixLength caps the prefixes a pick is remembered for, longer queries are not typed a
+// character at a time to find anything
+const maxPrefixLength = 16
+
+// QueryPrefixHashes returns the QueryHash of every prefix of the label query, up to maxPrefixLength
+// characters, with the whole user query, shortest first, and the hash of the whole query last. A pick
+// is remembered for each of them, and a later query starting the same way finds it. Nil without a key.
+func QueryPrefixHashes(key []byte, label, user string) []string {
+	if len(key) == 0 {
+		return nil
+	}
+	runes := []rune(strings.ToLower(strings.TrimSpace(label)))
+	if len(runes) == 0 {
+		if hash := QueryHash(key, "", user); hash != "" {
+			return []string{hash}
+		}
+		return nil
+	}
+
+	hashes := make([]string, 0, min(len(runes), maxPrefixLength)+1)
+	for i := 1; i <= min(len(runes), maxPrefixLength); i++ {
+		hashes = append(hashes, QueryHash(key, string(runes[:i]), user))
+	}
+	if len(runes) > maxPrefixLength {
+		hashes = append(hashes, QueryHash(key, string(runes), user))
+	}
+	return hashes
+}
@@ -15,8 +15,23 @@ const (
 	// feature weights
 	LABEL_WEIGHT     = 0.60
 	USER_WEIGHT      = 0.20
+	TAG_WEIGHT       = 0.40
+	FOLDER_WEIGHT    = 0.30
 	RECENCY_WEIGHT   = 0.12
 	FREQUENCY_WEIGHT = 0.05
+	CONTEXT_WEIGHT   = 0.75
+	SELECTION_WEIGHT = 0.60
+
+	// context scoring, accesses from the same working directory count fully, from another directory of
+	// the same git repository a little less
+	DIR_CONTEXT_SHARE    = 1.0
+	REMOTE_CONTEXT_SHARE = 0.8
+	CONTEXT_HALF_SCORE   = 1.0 // context frequency that scores half of the context weight
+
+	// selection scoring, picks for the same query count fully, for longer queries starting with it a quarter
+	EXACT_SELECTION_SHARE  = 1.0
+	PREFIX_SELECTION_SHARE = 0.25
+	SELECTION_HALF_SCORE   = 1.0 // selection frequency that scores half of the selection weight
 
 	// string scoring
 	PREFIX_BOOST = 0.8
@@ -34,16 +49,24 @@ type SearchResult struct {
 }
 
 func (s SearchResult) Display() string {
-	return fmt.Sprintf("%s (%s) [%.3f]", s.Credential.Label, s.Credential.User, s.Score)
+	var organization strings.Builder
+	if s.Credential.Folder != "" {
+		fmt.Fprintf(&organization, " %s/", s.Credential.Folder)
+	}
+	for _, tag := range s.Credential.Tags {
+		fmt.Fprintf(&organization, " #%s", tag)
+	}
+	return fmt.Sprintf("%s (%s)%s [%.3f]", s.Credential.Label, s.Credential.User, organization.String(), s.Score)
 }
 
-// BestMatches is a wrapper around the main search function
-func BestMatches(queryLabel, queryUser string, credentials []model.Credential, now time.Time) []SearchResult {
-	res := search(queryLabel, queryUser, credentials, MIN_SCORE_THRESHOLD, now)
+// BestMatches is a wrapper around the main search function. The picks made before for this query, or for
+// queries starting like it, boost the credentials picked; selections may be nil.
+func BestMatches(queryLabel, queryUser string, credentials []model.Credential, selections model.QuerySelections, now time.Time) []SearchResult {
+	res := search(queryLabel, queryUser, credentials, selections, MIN_SCORE_THRESHOLD, now)
 	return res
 }
 
-func search(queryLabel, queryUser string, credentials []model.Credential, threshold float64, now time.Time) []SearchResult {
+func search(queryLabel, queryUser string, credentials []model.Credential, selections model.QuerySelections, threshold float64, now time.Time) []SearchResult {
 	timeSearchStart := time.Now()
 	results := make([]SearchResult, 0, len(credentials))
 
@@ -54,7 +77,11 @@ func search(queryLabel, queryUser string, credentials []model.Credential, thresh
 			queryUser,
 			c.Label,
 			c.User,
-			c.AccessCount,
+			c.Tags,
+			c.Folder,
+			c.Frequency,
+			c.ContextFrequency,
+			selections[c.Id],
 			c.AccessedAt,
 			now,
 		)
@@ -65,21 +92,7 @@ func search(queryLabel, queryUser string, credentials []model.Credential, thresh
 		}
 	}
 
-	// sort records based on score, with access count and label as tie-breakers
-	sort.Slice(results, func(i, j int) bool {
-		prev := results[i]
-		curr := results[j]
-
-		if prev.Score == curr.Score {
-			// same score tie-breaker
-			if prev.Credential.AccessCount == curr.Credential.AccessCount {
-				// same access count tie-breaker
-				return prev.Credential.Label < curr.Credential.Label
-			}
-			return prev.Credential.AccessCount > curr.Credential.AccessCount
-		}
-		return prev.Score > curr.Score
-	})
+	sortResults(results)
 
 	timeSearchElapsed := time.Since(timeSearchStart)
 	logger.Debug("time for search %s", timeSearchElapsed.String())
@@ -88,26 +101,58 @@ func search(queryLabel, queryUser string, credentials []model.Credential, thresh
 }
 
 // ScoreQuery provides the overall score of an individual credential query based on following - label and/or user
-// string match, last used date-time, and frequency of usage.
-func ScoreQuery(queryLabel, queryUser, label, user string, count int, last time.Time, now time.Time) float64 {
+// string match, last used date-time, frequency of usage and of usage from the same place. The label query also
+// matches tags and folder names, at a lower weight; the best of label, tag and folder match counts. Picks for the
+// same query add to the string match, a credential picked often enough for it ranks like an exact label match.
+func ScoreQuery(queryLabel, queryUser, label, user string, tags []string, folder string, frequency float64, context model.ContextFrequency, selection model.SelectionFrequency, last time.Time, now time.Time) float64 {
 	labelScore := 0.0
 	userScore := 0.0
 
-	freqScore := frequencyScore(count) * FREQUENCY_WEIGHT
+	freqScore := frequencyScore(frequency) * FREQUENCY_WEIGHT
 	recScore := recencyScore(last, now) * RECENCY_WEIGHT
+	ctxScore := contextScore(context) * CONTEXT_WEIGHT
+	selScore := selectionScore(selection) * SELECTION_WEIGHT
 
 	if queryLabel != "" {
 		labelScore = stringScore(queryLabel, label) * LABEL_WEIGHT
+		labelScore = max(labelScore, bestStringScore(queryLabel, tags)*TAG_WEIGHT)
+		if folder != "" {
+			labelScore = max(labelScore, bestStringScore(queryLabel, strings.Split(folder, "/"))*FOLDER_WEIGHT)
+		}
 	}
 
 	if queryUser != "" {
 		userScore = stringScore(queryUser, user) * USER_WEIGHT
 	}
 
-	score := (labelScore + userScore) * (1 + recScore + freqScore)
+	match := labelScore + userScore
+	if match > 0 {
+		// the history only reorders credentials the query matches
+		match += selScore
+	}
+
+	score := match * (1 + recScore + freqScore + ctxScore)
 	return score
 }
 
+// sortResults sorts records based on score, with frequency and label as tie-breakers
+func sortResults(results []SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		prev := results[i]
+		curr := results[j]
+
+		if prev.Score == curr.Score {
+			// same score tie-breaker
+			if prev.Credential.Frequency == curr.Credential.Frequency {
+				// same frequency tie-breaker
+				return prev.Credential.Label < curr.Credential.Label
+			}
+			return prev.Credential.Frequency > curr.Credential.Frequency
+		}
+		return prev.Score > curr.Score
+	})
+}
+
 // stringScore provides a score for query and target match based on levenshtein distance (normalized) with bias
 // towards prefix and substring matching. In case of an exact match a MAX_STRING_SCORE is returned.
 func stringScore(query, target string) float64 {
@@ -136,6 +181,15 @@ func stringScore(query, target string) float64 {
 	return simScore
 }
 
+// bestStringScore provides the best stringScore of query against any of the targets
+func bestStringScore(query string, targets []string) float64 {
+	best := 0.0
+	for _, target := range targets {
+		best = max(best, stringScore(query, target))
+	}
+	return best
+}
+
 // similarityScore provides a normalized levenshtein distance between source and target strings
 func similarityScore(source, target string) float64 {
 	distance := damerauLevenshtein(source, target)
@@ -160,12 +214,32 @@ func recencyScore(last time.Time, now time.Time) float64 {
 	return 1.0 / (1.0 + hours/12.0)
 }
 
-// frequencyStore provides a normalized, logarithmic score based on frequency of usage of a record
-func frequencyScore(count int) float64 {
-	if count <= 0 {
+// frequencyScore provides a normalized, logarithmic score based on the decayed frequency of usage of a record
+func frequencyScore(frequency float64) float64 {
+	if frequency <= 0 {
+		return 0
+	}
+	return math.Log(frequency+1.0) / 15.0
+}
+
+// contextScore provides a score up to 1 based on the frequency of usage from the current working directory or git
+// repository, reaching half after a single search picked it from there
+func contextScore(context model.ContextFrequency) float64 {
+	frequency := max(context.Dir*DIR_CONTEXT_SHARE, context.GitRemote*REMOTE_CONTEXT_SHARE)
+	if frequency <= 0 {
+		return 0
+	}
+	return frequency / (frequency + CONTEXT_HALF_SCORE)
+}
+
+// selectionScore provides a score up to 1 based on how often the credential was picked for this query or longer
+// ones starting with it, reaching half after a single pick for this very query
+func selectionScore(selection model.SelectionFrequency) float64 {
+	frequency := selection.Exact*EXACT_SELECTION_SHARE + selection.Prefix*PREFIX_SELECTION_SHARE
+	if frequency <= 0 {
 		return 0
 	}
-	return math.Log(float64(count)+1.0) / 15.0
+	return frequency / (frequency + SELECTION_HALF_SCORE)
 }
 
 // helper functions
@@ -2,11 +2,13 @@ package search
 
 import (
 	"math"
+	"strings"
 	"testing"
 	"time"
 
 	"git.plutolab.org/plutolab/kosh/internal/constants"
 	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
 )
 
 func TestDamerauLevenshtein(t *testing.T) {
@@ -154,7 +156,7 @@ func TestScoreQuery_UserOnly(t *testing.T) {
 	now := time.Now()
 	last := time.Now().Add(-1 * time.Hour)
 
-	score := ScoreQuery("", "alice", "github", "alice", 0, last, now)
+	score := ScoreQuery("", "alice", "github", "alice", nil, "", 0, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
 
 	if score == 0 {
 		t.Fatal("expected non-zero score for matching user")
@@ -165,14 +167,42 @@ func TestScoreQuery_LabelAndUserBoost(t *testing.T) {
 	now := time.Now()
 	last := time.Now().Add(-1 * time.Hour)
 
-	labelOnly := ScoreQuery("git", "", "github", "alice", 10, last, now)
-	both := ScoreQuery("git", "alice", "github", "alice", 10, last, now)
+	labelOnly := ScoreQuery("git", "", "github", "alice", nil, "", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
+	both := ScoreQuery("git", "alice", "github", "alice", nil, "", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
 
 	if both <= labelOnly {
 		t.Fatal("combined label+user query should score higher")
 	}
 }
 
+func TestScoreQuery_TagsAndFolder(t *testing.T) {
+	now := time.Now()
+	last := time.Now().Add(-1 * time.Hour)
+
+	t.Run("tag match scores a credential whose label does not match", func(t *testing.T) {
+		untagged := ScoreQuery("work", "", "github", "alice", nil, "", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
+		tagged := ScoreQuery("work", "", "github", "alice", []string{"personal", "work"}, "", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
+		if tagged < MIN_SCORE_THRESHOLD || tagged <= untagged {
+			t.Errorf("tagged credential scored %f, untagged %f", tagged, untagged)
+		}
+	})
+
+	t.Run("folder segment match scores a credential", func(t *testing.T) {
+		got := ScoreQuery("acme", "", "vpn", "alice", nil, "clients/acme", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
+		if got < MIN_SCORE_THRESHOLD {
+			t.Errorf("folder match scored %f, must be at least %f", got, MIN_SCORE_THRESHOLD)
+		}
+	})
+
+	t.Run("exact label match beats exact tag match", func(t *testing.T) {
+		label := ScoreQuery("github", "", "github", "alice", nil, "", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
+		tag := ScoreQuery("github", "", "gitlab", "alice", []string{"github"}, "", 10, model.ContextFrequency{}, model.SelectionFrequency{}, last, now)
+		if tag >= label {
+			t.Errorf("tag match scored %f, must be less than label match %f", tag, label)
+		}
+	})
+}
+
 func TestSimilarityScore_EmptyStrings(t *testing.T) {
 	if got := similarityScore("", ""); got != 1.0 {
 		t.Fatalf(
@@ -246,14 +276,30 @@ func TestFrequencyScore(t *testing.T) {
 		}
 	})
 
-	t.Run("cred score on usage reset threshold must not cross 1.0", func(t *testing.T) {
-		got := frequencyScore(constants.AccessCountResetThreshold)
+	t.Run("cred score on a hundred gets a day must not cross 1.0", func(t *testing.T) {
+		// steady state of the decayed sum: daily weight × half-life / ln 2
+		got := frequencyScore(100 * 2 * constants.AccessFrequencyHalfLifeDays / math.Ln2)
 		if got > 1.0 {
-			t.Errorf("cred with max usage before reset has score %f, must be less than 1.0", got)
+			t.Errorf("cred with heavy daily usage has score %f, must be less than 1.0", got)
 		}
 	})
 }
 
+func TestContextScore(t *testing.T) {
+	if got := contextScore(model.ContextFrequency{}); got != 0 {
+		t.Errorf("expected 0 without context, got %f", got)
+	}
+
+	dir := contextScore(model.ContextFrequency{Dir: 2, GitRemote: 2})
+	remote := contextScore(model.ContextFrequency{GitRemote: 2})
+	if dir <= remote {
+		t.Errorf("expected use from the same directory (%f) to beat the same repository (%f)", dir, remote)
+	}
+	if got := contextScore(model.ContextFrequency{Dir: 1000}); got >= 1 {
+		t.Errorf("expected the context score to stay below 1, got %f", got)
+	}
+}
+
 func TestSearch_Ranking(t *testing.T) {
 	now := time.Now()
 
@@ -267,8 +313,8 @@ func TestSearch_Ranking(t *testing.T) {
 			name:  "higher frequency wins when string quality is similar",
 			query: "git",
 			creds: []model.Credential{
-				{Label: "github", AccessCount: 10, AccessedAt: now},
-				{Label: "gitlab", AccessCount: 50, AccessedAt: now},
+				{Label: "github", Frequency: 10, AccessedAt: now},
+				{Label: "gitlab", Frequency: 50, AccessedAt: now},
 			},
 			expected: "gitlab",
 		},
@@ -276,8 +322,8 @@ func TestSearch_Ranking(t *testing.T) {
 			name:  "more recent usage wins",
 			query: "git",
 			creds: []model.Credential{
-				{Label: "github", AccessCount: 10, AccessedAt: now.Add(-1 * time.Hour)},
-				{Label: "gitlab", AccessCount: 50, AccessedAt: now.Add(-7 * 24 * time.Hour)},
+				{Label: "github", Frequency: 10, AccessedAt: now.Add(-1 * time.Hour)},
+				{Label: "gitlab", Frequency: 50, AccessedAt: now.Add(-7 * 24 * time.Hour)},
 			},
 			expected: "github",
 		},
@@ -285,17 +331,26 @@ func TestSearch_Ranking(t *testing.T) {
 			name:  "prefix match beats fuzzy match",
 			query: "git",
 			creds: []model.Credential{
-				{Label: "github", AccessCount: 1, AccessedAt: now},
-				{Label: "digit", AccessCount: 1000, AccessedAt: now},
+				{Label: "github", Frequency: 1, AccessedAt: now},
+				{Label: "digit", Frequency: 1000, AccessedAt: now},
 			},
 			expected: "github",
 		},
+		{
+			name:  "use from the same repository beats a better string match",
+			query: "db",
+			creds: []model.Credential{
+				{Label: "db-prod", Frequency: 10, AccessedAt: now},
+				{Label: "acme-db", Frequency: 2, ContextFrequency: model.ContextFrequency{GitRemote: 2}, AccessedAt: now},
+			},
+			expected: "acme-db",
+		},
 		{
 			name:  "lexical order of label wins when score is same",
 			query: "git",
 			creds: []model.Credential{
-				{Label: "github", AccessCount: 10, AccessedAt: time.Now()},
-				{Label: "gitlab", AccessCount: 10, AccessedAt: time.Now()},
+				{Label: "github", Frequency: 10, AccessedAt: time.Now()},
+				{Label: "gitlab", Frequency: 10, AccessedAt: time.Now()},
 			},
 			expected: "github",
 		},
@@ -303,7 +358,7 @@ func TestSearch_Ranking(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			res := BestMatches(tt.query, "", tt.creds, now)
+			res := BestMatches(tt.query, "", tt.creds, nil, now)
 
 			if len(res) == 0 {
 				t.Fatal("expected results")
@@ -319,13 +374,110 @@ func TestSearch_Ranking(t *testing.T) {
 func TestSearch_ThresholdFiltering(t *testing.T) {
 	now := time.Now()
 	creds := []model.Credential{
-		{Label: "abc", AccessCount: 10, AccessedAt: time.Now()},
-		{Label: "github", AccessCount: 10, AccessedAt: time.Now()},
+		{Label: "abc", Frequency: 10, AccessedAt: time.Now()},
+		{Label: "github", Frequency: 10, AccessedAt: time.Now()},
 	}
 
-	res := search("git", "", creds, MIN_SCORE_THRESHOLD, now)
+	res := search("git", "", creds, nil, MIN_SCORE_THRESHOLD, now)
 
 	if len(res) != 1 || res[0].Credential.Label != "github" {
 		t.Fatalf("unexpected results: %+v", res)
 	}
 }
+
+func TestBestURLMatches(t *testing.T) {
+	now := time.Now()
+	visited, err := urlmatch.Parse("https://login.example.com/signin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds := []model.Credential{
+		{Label: "other", URLs: []string{"https://example.org"}, Frequency: 100, AccessedAt: now},
+		{Label: "sibling", URLs: []string{"https://mail.example.com"}, Frequency: 100, AccessedAt: now},
+		{Label: "exact-old", URLs: []string{"https://login.example.com"}, Frequency: 1, AccessedAt: now.Add(-30 * 24 * time.Hour)},
+		{Label: "exact-recent", URLs: []string{"example.net", "login.example.com"}, Frequency: 1, AccessedAt: now},
+	}
+
+	res := BestURLMatches(visited, creds, now)
+
+	want := []string{"exact-recent", "exact-old", "sibling"}
+	if len(res) != len(want) {
+		t.Fatalf("expected %d results, got %d: %+v", len(want), len(res), res)
+	}
+	for i, label := range want {
+		if res[i].Credential.Label != label {
+			t.Errorf("result %d is %s, want %s", i, res[i].Credential.Label, label)
+		}
+	}
+}
+
+func TestQueryHash(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	if got := QueryHash(key, " GitHub ", "Alice"); got != QueryHash(key, "github", "alice") {
+		t.Errorf("hash must ignore case and surrounding space, got %s", got)
+	}
+	if QueryHash(key, "git", "hub") == QueryHash(key, "gith", "ub") {
+		t.Error("label and user must stay apart in the hash")
+	}
+	if got := QueryHash(key, "", " "); got != "" {
+		t.Errorf("empty query hashed to %q, want empty", got)
+	}
+	if got := QueryHash(key, "github", ""); len(got) != 64 || strings.Contains(got, "github") {
+		t.Errorf("unexpected hash %q", got)
+	}
+	if QueryHash(key, "g", "") == QueryHash([]byte("another vault's key, 32 bytes..."), "g", "") {
+		t.Error("hash must depend on the key")
+	}
+	if got := QueryHash(nil, "github", ""); got != "" {
+		t.Errorf("query hashed without a key to %q, want empty", got)
+	}
+}
+
+func TestQueryPrefixHashes(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	hashes := QueryPrefixHashes(key, " GH ", "alice")
+	want := []string{QueryHash(key, "g", "alice"), QueryHash(key, "gh", "alice")}
+	if len(hashes) != len(want) || hashes[0] != want[0] || hashes[1] != want[1] {
+		t.Errorf("QueryPrefixHashes() = %v, want %v", hashes, want)
+	}
+	if got := QueryPrefixHashes(key, "", "alice"); len(got) != 1 || got[0] != QueryHash(key, "", "alice") {
+		t.Errorf("user only query gave %v", got)
+	}
+	if got := QueryPrefixHashes(key, " ", ""); got != nil {
+		t.Errorf("empty query gave %v, want nil", got)
+	}
+	if got := QueryPrefixHashes(nil, "gh", ""); got != nil {
+		t.Errorf("query without a key gave %v, want nil", got)
+	}
+
+	long := "production-database-primary"
+	hashes = QueryPrefixHashes(key, long, "")
+	if len(hashes) != maxPrefixLength+1 || hashes[maxPrefixLength] != QueryHash(key, long, "") {
+		t.Errorf("long query gave %d hashes, want %d ending with the whole query", len(hashes), maxPrefixLength+1)
+	}
+}
+
+func TestSearch_QuerySelections(t *testing.T) {
+	now := time.Now()
+	creds := []model.Credential{
+		{Id: 1, Label: "ghost-blog", Frequency: 10, AccessedAt: now},
+		{Id: 2, Label: "github", Frequency: 10, AccessedAt: now},
+	}
+
+	if res := BestMatches("gh", "", creds, nil, now); res[0].Credential.Label != "ghost-blog" {
+		t.Fatalf("expected the prefix match first without history, got %s", res[0].Credential.Label)
+	}
+
+	// for "gh", github was picked once for the query itself and ghost-blog once for "ghost"
+	selections := model.QuerySelections{
+		2: {Exact: 1},
+		1: {Prefix: 1},
+	}
+	if res := BestMatches("gh", "", creds, selections, now); res[0].Credential.Label != "github" {
+		t.Errorf("expected the credential picked for the query first, got %s", res[0].Credential.Label)
+	}
+	if res := BestMatches("ghost", "", creds, model.QuerySelections{1: {Exact: 1}}, now); res[0].Credential.Label != "ghost-blog" {
+		t.Errorf("expected the credential picked for the longer query first, got %s", res[0].Credential.Label)
+	}
+}
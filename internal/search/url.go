@@ -0,0 +1,41 @@
+package search
+
+import (
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/urlmatch"
+)
+
+// BestURLMatches ranks the credentials that have a URL matching the visited target. The best match
+// quality of a credential's URLs is boosted by recency and frequency of use, the same way as the
+// string score in ScoreQuery, so among several logins for a site the one in use comes first.
+func BestURLMatches(visited *urlmatch.Target, credentials []model.Credential, now time.Time) []SearchResult {
+	results := []SearchResult{}
+	for _, c := range credentials {
+		score := ScoreURL(visited, c.URLs, c.Frequency, c.AccessedAt, now)
+		if score > 0 {
+			results = append(results, SearchResult{c, score})
+		}
+	}
+
+	sortResults(results)
+	return results
+}
+
+// ScoreURL provides the score of a credential for a visited URL, zero when none of its URLs match
+func ScoreURL(visited *urlmatch.Target, urls []string, frequency float64, last time.Time, now time.Time) float64 {
+	matchScore := urlmatch.NO_MATCH
+	for _, raw := range urls {
+		saved, err := urlmatch.Parse(raw)
+		if err != nil {
+			continue
+		}
+		matchScore = max(matchScore, urlmatch.Match(saved, visited))
+	}
+
+	freqScore := frequencyScore(frequency) * FREQUENCY_WEIGHT
+	recScore := recencyScore(last, now) * RECENCY_WEIGHT
+
+	return matchScore * (1 + recScore + freqScore)
+}
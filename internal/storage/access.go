@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// decay halves for every constants.AccessFrequencyHalfLifeDays days that passed since e.at
+var decay = fmt.Sprintf(`pow(0.5, (julianday('now') - julianday(e.at)) / %d.0)`, constants.AccessFrequencyHalfLifeDays)
+
+// selectionDecay halves a count of query_selections for every constants.AccessFrequencyHalfLifeDays days
+// between its last pick and the one being recorded
+var selectionDecay = fmt.Sprintf(`pow(0.5, max(julianday(excluded.at) - julianday(query_selections.at), 0) / %d.0)`, constants.AccessFrequencyHalfLifeDays)
+
+// decayedWeight is the weight of an access event e, decayed since it happened
+var decayedWeight = `e.weight * ` + decay
+
+// credentialFrequencyColumn selects the frequency of use of a credential, the sum of its decayed weights
+var credentialFrequencyColumn = `COALESCE((
+	SELECT SUM(` + decayedWeight + `)
+	FROM access_events e WHERE e.credential_id = credentials.id
+), 0)`
+
+// RecordAccess saves an access of a credential and moves its access count and time
+func (v *VaultStore) RecordAccess(event *model.AccessEvent) error {
+	at := event.At.UTC().Format(time.DateTime)
+
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Debug("recordAccess:failed to start transaction: %s", err.Error())
+		return err
+	}
+	defer transaction.Rollback()
+
+	query := `
+		INSERT INTO access_events (credential_id, via, query_hash, weight, at, cwd, git_remote)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	if _, err := transaction.Exec(query, event.CredentialId, event.Via, event.QueryHash, event.Via.Weight(), at,
+		event.Context.Dir, event.Context.GitRemote); err != nil {
+		logger.Debug("recordAccess:failed to insert event: %s", err.Error())
+		return err
+	}
+
+	query = `UPDATE credentials SET access_count = access_count + 1, accessed_at = ? WHERE id = ?`
+	if _, err := transaction.Exec(query, at, event.CredentialId); err != nil {
+		logger.Debug("recordAccess:failed to update credential %d: %s", event.CredentialId, err.Error())
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// GetCredentialUsage fetches how much every credential outside the trash is used, with the number of
+// accesses since the given time, most frequently used first
+func (v *VaultStore) GetCredentialUsage(since time.Time) ([]model.CredentialUsage, error) {
+	query := `
+		SELECT id, label, user, access_count, (
+			SELECT COUNT(*) FROM access_events e
+			WHERE e.credential_id = credentials.id AND e.via != ? AND e.at >= ?
+		), ` + credentialFrequencyColumn + `, created_at, accessed_at
+		FROM credentials
+		WHERE deleted_at IS NULL
+		ORDER BY 6 DESC, label, user
+	`
+	rows, err := v.db.Query(query, model.AccessViaMigrated, since.UTC().Format(time.DateTime))
+	if err != nil {
+		logger.Debug("failed to fetch credential usage")
+		return nil, err
+	}
+	defer rows.Close()
+
+	usages := []model.CredentialUsage{}
+	for rows.Next() {
+		var usage model.CredentialUsage
+		var createdAtStr, accessedAtStr string
+
+		if err := rows.Scan(
+			&usage.Id,
+			&usage.Label,
+			&usage.User,
+			&usage.AccessCount,
+			&usage.Accesses,
+			&usage.Frequency,
+			&createdAtStr,
+			&accessedAtStr,
+		); err != nil {
+			logger.Debug("unable to scan credential usage")
+			return nil, err
+		}
+
+		usage.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+		if err != nil {
+			logger.Debug("unable to parse created at time: %s", createdAtStr)
+			return nil, err
+		}
+
+		usage.AccessedAt, err = time.Parse(time.RFC3339, accessedAtStr)
+		if err != nil {
+			logger.Debug("unable to parse accessed at time: %s", accessedAtStr)
+			return nil, err
+		}
+
+		usages = append(usages, usage)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return usages, nil
+}
+
+// GetAccessCounts fetches the number of accesses per local day and kind since the given time, oldest
+// first. Migrated access counts are left out, they have no time of their own.
+func (v *VaultStore) GetAccessCounts(since time.Time) ([]model.AccessCount, error) {
+	query := `
+		SELECT date(at, 'localtime'), via, COUNT(*) FROM access_events
+		WHERE via != ? AND at >= ?
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`
+	rows, err := v.db.Query(query, model.AccessViaMigrated, since.UTC().Format(time.DateTime))
+	if err != nil {
+		logger.Debug("failed to fetch access counts")
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []model.AccessCount{}
+	for rows.Next() {
+		var count model.AccessCount
+		var dayStr string
+
+		if err := rows.Scan(&dayStr, &count.Via, &count.Count); err != nil {
+			logger.Debug("unable to scan access count")
+			return nil, err
+		}
+
+		count.Day, err = time.ParseInLocation(time.DateOnly, dayStr, time.Local)
+		if err != nil {
+			logger.Debug("unable to parse access day: %s", dayStr)
+			return nil, err
+		}
+
+		counts = append(counts, count)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return counts, nil
+}
+
+// GetContextFrequencies fetches the frequency of every credential accessed from the given working
+// directory or git repository, counting only those accesses. Credentials never accessed from there are
+// left out.
+func (v *VaultStore) GetContextFrequencies(context model.AccessContext) (map[int]model.ContextFrequency, error) {
+	frequencies := map[int]model.ContextFrequency{}
+	if context.Dir == "" && context.GitRemote == "" {
+		return frequencies, nil
+	}
+
+	query := `
+		SELECT e.credential_id,
+			COALESCE(SUM(CASE WHEN ?1 != '' AND e.cwd = ?1 THEN ` + decayedWeight + ` END), 0),
+			COALESCE(SUM(CASE WHEN ?2 != '' AND e.git_remote = ?2 THEN ` + decayedWeight + ` END), 0)
+		FROM access_events e
+		WHERE (?1 != '' AND e.cwd = ?1) OR (?2 != '' AND e.git_remote = ?2)
+		GROUP BY e.credential_id
+	`
+	rows, err := v.db.Query(query, context.Dir, context.GitRemote)
+	if err != nil {
+		logger.Debug("getContextFrequencies:failed to execute query: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var frequency model.ContextFrequency
+		if err := rows.Scan(&id, &frequency.Dir, &frequency.GitRemote); err != nil {
+			logger.Debug("unable to scan context frequency")
+			return nil, err
+		}
+		frequencies[id] = frequency
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return frequencies, nil
+}
+
+// RecordQuerySelection saves that a credential was picked for a search query, given by the hashes of
+// its prefixes with the whole query last. The counts of each prefix and credential are decayed to the
+// time of the pick and updated in place.
+func (v *VaultStore) RecordQuerySelection(credentialId int, prefixHashes []string, at time.Time) error {
+	if len(prefixHashes) == 0 {
+		return nil
+	}
+
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Debug("recordQuerySelection:failed to start transaction: %s", err.Error())
+		return err
+	}
+	defer transaction.Rollback()
+
+	query := `
+		INSERT INTO query_selections (prefix_hash, credential_id, exact, prefix, at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (prefix_hash, credential_id) DO UPDATE SET
+			exact = exact * ` + selectionDecay + ` + excluded.exact,
+			prefix = prefix * ` + selectionDecay + ` + excluded.prefix,
+			at = MAX(at, excluded.at)
+	`
+	for i, hash := range prefixHashes {
+		exact, prefix := 0, 1
+		if i == len(prefixHashes)-1 {
+			exact, prefix = 1, 0
+		}
+		if _, err := transaction.Exec(query, hash, credentialId, exact, prefix, at.UTC().Format(time.DateTime)); err != nil {
+			logger.Debug("recordQuerySelection:failed to insert selection: %s", err.Error())
+			return err
+		}
+	}
+
+	return transaction.Commit()
+}
+
+// GetQuerySelections fetches the decayed number of picks of every credential picked for a query, given
+// by its hash
+func (v *VaultStore) GetQuerySelections(queryHash string) (model.QuerySelections, error) {
+	query := `
+		SELECT e.credential_id, e.exact * ` + decay + `, e.prefix * ` + decay + `
+		FROM query_selections e
+		WHERE e.prefix_hash = ?
+	`
+	rows, err := v.db.Query(query, queryHash)
+	if err != nil {
+		logger.Debug("getQuerySelections:failed to execute query: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	selections := model.QuerySelections{}
+	for rows.Next() {
+		var id int
+		var frequency model.SelectionFrequency
+		if err := rows.Scan(&id, &frequency.Exact, &frequency.Prefix); err != nil {
+			logger.Debug("unable to scan query selection")
+			return nil, err
+		}
+		selections[id] = frequency
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return selections, nil
+}
+
+// PruneAccessEvents deletes the access events and query selections before the given time, returns the
+// number of access events deleted
+func (v *VaultStore) PruneAccessEvents(before time.Time) (int, error) {
+	if _, err := v.db.Exec(`DELETE FROM query_selections WHERE at < ?`, before.UTC().Format(time.DateTime)); err != nil {
+		logger.Debug("pruneAccessEvents:failed to prune query selections: %s", err.Error())
+		return 0, err
+	}
+
+	result, err := v.db.Exec(`DELETE FROM access_events WHERE at < ?`, before.UTC().Format(time.DateTime))
+	if err != nil {
+		logger.Debug("pruneAccessEvents:failed to execute statement: %s", err.Error())
+		return 0, err
+	}
+
+	pruned, _ := result.RowsAffected()
+	return int(pruned), nil
+}
+
+// GetQueryKey returns the key search queries are hashed with, or nil before CreateQueryKey made one.
+// It is kept in a file next to the database, not in it, so a copy of the vault file alone does not let
+// anyone guess the queries a prefix at a time.
+func (v *VaultStore) GetQueryKey() ([]byte, error) {
+	content, err := os.ReadFile(v.queryKeyPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Debug("getQueryKey:unable to read query key: %s", err.Error())
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil || len(key) != 32 {
+		// also a file another process is still writing
+		return nil, errors.New("query key file is damaged")
+	}
+	return key, nil
+}
+
+// CreateQueryKey writes a new random query key, or returns the one that exists already, also when
+// another process just created it
+func (v *VaultStore) CreateQueryKey() ([]byte, error) {
+	if key, err := v.GetQueryKey(); key != nil || err != nil {
+		return key, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(v.queryKeyPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return v.GetQueryKey()
+	}
+	if err != nil {
+		logger.Debug("createQueryKey:unable to create query key: %s", err.Error())
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		logger.Debug("createQueryKey:unable to write query key: %s", err.Error())
+		os.Remove(v.queryKeyPath())
+		return nil, err
+	}
+	return key, nil
+}
+
+func (v *VaultStore) queryKeyPath() string {
+	return v.path + ".querykey"
+}
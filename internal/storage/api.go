@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// AddAPIClient saves a new API client, the name and token hash must be unique
+func (v *VaultStore) AddAPIClient(client *model.APIClient) error {
+	query := `INSERT INTO api_clients (name, token_hash, labels, can_read, can_write) VALUES (?, ?, ?, ?, ?)`
+	_, err := v.db.Exec(query, client.Name, client.TokenHash, strings.Join(client.Labels, ","), client.Read, client.Write)
+	if err != nil {
+		logger.Debug("addAPIClient:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// DeleteAPIClient removes an API client by name, returns sql.ErrNoRows if it does not exist
+func (v *VaultStore) DeleteAPIClient(name string) error {
+	result, err := v.db.Exec(`DELETE FROM api_clients WHERE name = ?`, name)
+	if err != nil {
+		logger.Debug("deleteAPIClient:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAPIClientByTokenHash fetches the client owning a token, returns sql.ErrNoRows for unknown tokens
+func (v *VaultStore) GetAPIClientByTokenHash(tokenHash string) (*model.APIClient, error) {
+	query := `
+		SELECT id, name, token_hash, labels, can_read, can_write, created_at, last_used_at
+		FROM api_clients WHERE token_hash = ?
+	`
+	client, err := scanAPIClient(v.db.QueryRow(query, tokenHash))
+	if err != nil && err != sql.ErrNoRows {
+		logger.Debug("getAPIClientByTokenHash:unable to fetch client: %s", err.Error())
+	}
+	return client, err
+}
+
+// GetAPIClients fetches every API client sorted by name
+func (v *VaultStore) GetAPIClients() ([]model.APIClient, error) {
+	query := `
+		SELECT id, name, token_hash, labels, can_read, can_write, created_at, last_used_at
+		FROM api_clients ORDER BY name
+	`
+	rows, err := v.db.Query(query)
+	if err != nil {
+		logger.Debug("failed to fetch api clients")
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []model.APIClient{}
+	for rows.Next() {
+		client, err := scanAPIClient(rows)
+		if err != nil {
+			logger.Debug("unable to scan api client")
+			return nil, err
+		}
+		clients = append(clients, *client)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return clients, nil
+}
+
+// TouchAPIClient sets the last used time of a client to now
+func (v *VaultStore) TouchAPIClient(id int) error {
+	if _, err := v.db.Exec(`UPDATE api_clients SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+		logger.Debug("touchAPIClient:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// AddAPIAuditEntry appends a call to the API audit trail
+func (v *VaultStore) AddAPIAuditEntry(entry *model.APIAuditEntry) error {
+	query := `
+		INSERT INTO api_audit (client, method, path, status, pid, uid, credential_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := v.db.Exec(query, entry.Client, entry.Method, entry.Path, entry.Status, entry.PID, entry.UID, entry.CredentialId)
+	if err != nil {
+		logger.Debug("addAPIAuditEntry:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetAPIAuditEntries fetches the newest limit entries of the API audit trail, newest first
+func (v *VaultStore) GetAPIAuditEntries(limit int) ([]model.APIAuditEntry, error) {
+	query := `
+		SELECT id, client, method, path, status, pid, uid, credential_id, at
+		FROM api_audit ORDER BY id DESC LIMIT ?
+	`
+	rows, err := v.db.Query(query, limit)
+	if err != nil {
+		logger.Debug("failed to fetch api audit entries")
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.APIAuditEntry{}
+	for rows.Next() {
+		var entry model.APIAuditEntry
+		var atStr string
+		if err := rows.Scan(
+			&entry.Id,
+			&entry.Client,
+			&entry.Method,
+			&entry.Path,
+			&entry.Status,
+			&entry.PID,
+			&entry.UID,
+			&entry.CredentialId,
+			&atStr,
+		); err != nil {
+			logger.Debug("unable to scan api audit entry")
+			return nil, err
+		}
+
+		entry.At, err = time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			logger.Debug("unable to parse audit time: %s", atStr)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return entries, nil
+}
+
+func scanAPIClient(row rowScanner) (*model.APIClient, error) {
+	var client model.APIClient
+	var labels, createdAtStr string
+	var lastUsedAtStr sql.NullString
+
+	err := row.Scan(
+		&client.Id,
+		&client.Name,
+		&client.TokenHash,
+		&labels,
+		&client.Read,
+		&client.Write,
+		&createdAtStr,
+		&lastUsedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if labels != "" {
+		client.Labels = strings.Split(labels, ",")
+	}
+
+	client.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		logger.Debug("unable to parse created at time: %s", createdAtStr)
+		return nil, err
+	}
+
+	if lastUsedAtStr.Valid {
+		client.LastUsedAt, err = time.Parse(time.RFC3339, lastUsedAtStr.String)
+		if err != nil {
+			logger.Debug("unable to parse last used at time: %s", lastUsedAtStr.String)
+			return nil, err
+		}
+	}
+
+	return &client, nil
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"database/sql"
+	"io"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// AddAttachment stores the attachment metadata and all of its chunks in a single transaction, so a
+// failed or interrupted upload leaves nothing behind. next is called until it returns io.EOF, each
+// call returns the nonce and ciphertext of the next chunk in order. attachment.Size is saved once all
+// chunks are written, so next may keep it up to date while streaming.
+func (v *VaultStore) AddAttachment(attachment *model.Attachment, next func() (nonce, data []byte, err error)) error {
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Error("failed to start transaction")
+		return err
+	}
+	defer transaction.Rollback()
+
+	result, err := transaction.Exec(`
+		INSERT INTO attachments (credential_id, name, size, chunk_size, key, key_ephemeral, key_nonce)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, attachment.CredentialId, attachment.Name, attachment.Size, attachment.ChunkSize, attachment.Key, attachment.KeyEphemeral, attachment.KeyNonce)
+	if err != nil {
+		logger.Debug("addAttachment:failed to insert attachment: %s", err.Error())
+		return err
+	}
+
+	attachmentId, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := transaction.Prepare(`INSERT INTO attachment_chunks (attachment_id, idx, nonce, data) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		logger.Error("error preparing statement")
+		return err
+	}
+	defer stmt.Close()
+
+	for index := 0; ; index++ {
+		nonce, data, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.Exec(attachmentId, index, nonce, data); err != nil {
+			logger.Debug("addAttachment:failed to insert chunk %d: %s", index, err.Error())
+			return err
+		}
+	}
+
+	if _, err := transaction.Exec(`UPDATE attachments SET size = ? WHERE id = ?`, attachment.Size, attachmentId); err != nil {
+		logger.Debug("addAttachment:failed to update size: %s", err.Error())
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		logger.Error("failed to commit transaction")
+		return err
+	}
+
+	attachment.Id = int(attachmentId)
+	return nil
+}
+
+// ReadAttachmentChunks calls fn for every chunk of an attachment in order
+func (v *VaultStore) ReadAttachmentChunks(attachmentId int, fn func(index int, nonce, data []byte) error) error {
+	rows, err := v.db.Query(`SELECT idx, nonce, data FROM attachment_chunks WHERE attachment_id = ? ORDER BY idx`, attachmentId)
+	if err != nil {
+		logger.Debug("failed to fetch attachment chunks")
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var index int
+		var nonce, data []byte
+		if err := rows.Scan(&index, &nonce, &data); err != nil {
+			logger.Debug("unable to scan attachment chunk")
+			return err
+		}
+		if err := fn(index, nonce, data); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetAttachment fetches attachment metadata by name, returns sql.ErrNoRows if it does not exist or
+// its credential is in the trash
+func (v *VaultStore) GetAttachment(credentialId int, name string) (*model.Attachment, error) {
+	query := `
+		SELECT a.id, a.credential_id, a.name, a.size, a.chunk_size, a.key, a.key_ephemeral, a.key_nonce, a.created_at
+		FROM attachments a
+		JOIN credentials c ON c.id = a.credential_id
+		WHERE a.credential_id = ? AND a.name = ? AND c.deleted_at IS NULL
+	`
+
+	attachment, err := scanAttachment(v.db.QueryRow(query, credentialId, name))
+	if err == sql.ErrNoRows {
+		logger.Debug("no matching attachment found")
+		return nil, err
+	}
+	if err != nil {
+		logger.Debug("getAttachment:unable to fetch attachment: %s", err.Error())
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// GetAttachments fetches the metadata of all attachments of a credential ordered by name, none while
+// the credential is in the trash
+func (v *VaultStore) GetAttachments(credentialId int) ([]model.Attachment, error) {
+	query := `
+		SELECT a.id, a.credential_id, a.name, a.size, a.chunk_size, a.key, a.key_ephemeral, a.key_nonce, a.created_at
+		FROM attachments a
+		JOIN credentials c ON c.id = a.credential_id
+		WHERE a.credential_id = ? AND c.deleted_at IS NULL
+		ORDER BY a.name
+	`
+
+	rows, err := v.db.Query(query, credentialId)
+	if err != nil {
+		logger.Debug("failed to fetch attachments")
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []model.Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			logger.Debug("unable to scan attachment")
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return attachments, nil
+}
+
+// DeleteAttachment deletes an attachment and its chunks, returns sql.ErrNoRows if it does not exist
+func (v *VaultStore) DeleteAttachment(attachmentId int) error {
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Error("failed to start transaction")
+		return err
+	}
+	defer transaction.Rollback()
+
+	if _, err := transaction.Exec(`DELETE FROM attachment_chunks WHERE attachment_id = ?`, attachmentId); err != nil {
+		logger.Debug("unable to delete attachment chunks")
+		return err
+	}
+
+	result, err := transaction.Exec(`DELETE FROM attachments WHERE id = ?`, attachmentId)
+	if err != nil {
+		logger.Debug("unable to delete attachment")
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+
+	return transaction.Commit()
+}
+
+func scanAttachment(row rowScanner) (*model.Attachment, error) {
+	var attachment model.Attachment
+	var createdAtStr string
+
+	err := row.Scan(
+		&attachment.Id,
+		&attachment.CredentialId,
+		&attachment.Name,
+		&attachment.Size,
+		&attachment.ChunkSize,
+		&attachment.Key,
+		&attachment.KeyEphemeral,
+		&attachment.KeyNonce,
+		&createdAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		logger.Debug("unable to parse created at time: %s", createdAtStr)
+		return nil, err
+	}
+
+	return &attachment, nil
+}
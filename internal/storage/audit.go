@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// AppendAuditEvent adds an event to the end of the audit log. Inside the transaction PrevHash is set
+// to the hash of the last entry and Hash to hash(event), so the chain cannot be forked by concurrent
+// writers.
+func (v *VaultStore) AppendAuditEvent(event *model.AuditEvent, hash func(event *model.AuditEvent) (string, error)) error {
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Debug("appendAuditEvent:failed to start transaction: %s", err.Error())
+		return err
+	}
+	defer transaction.Rollback()
+
+	err = transaction.QueryRow(`SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&event.PrevHash)
+	if err == sql.ErrNoRows {
+		event.PrevHash = ""
+	} else if err != nil {
+		logger.Debug("appendAuditEvent:unable to fetch last entry: %s", err.Error())
+		return err
+	}
+
+	event.At = event.At.UTC().Truncate(time.Second)
+	event.Hash, err = hash(event)
+	if err != nil {
+		logger.Debug("appendAuditEvent:unable to hash entry: %s", err.Error())
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_log (event, credential_id, label, user, command, pid, at, prev_hash, hash, keyed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := transaction.Exec(query,
+		event.Event,
+		event.CredentialId,
+		event.Label,
+		event.User,
+		event.Command,
+		event.PID,
+		event.At.Format(time.RFC3339),
+		event.PrevHash,
+		event.Hash,
+		event.Keyed,
+	)
+	if err != nil {
+		logger.Debug("appendAuditEvent:failed to execute statement: %s", err.Error())
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	event.Id = int(id)
+
+	return transaction.Commit()
+}
+
+// GetAuditEvents fetches the whole audit log, oldest first, as needed to verify the chain
+func (v *VaultStore) GetAuditEvents() ([]model.AuditEvent, error) {
+	query := `
+		SELECT id, event, credential_id, label, user, command, pid, at, prev_hash, hash, keyed
+		FROM audit_log ORDER BY id
+	`
+	rows, err := v.db.Query(query)
+	if err != nil {
+		logger.Debug("failed to fetch audit log")
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []model.AuditEvent{}
+	for rows.Next() {
+		var event model.AuditEvent
+		var atStr string
+		if err := rows.Scan(
+			&event.Id,
+			&event.Event,
+			&event.CredentialId,
+			&event.Label,
+			&event.User,
+			&event.Command,
+			&event.PID,
+			&atStr,
+			&event.PrevHash,
+			&event.Hash,
+			&event.Keyed,
+		); err != nil {
+			logger.Debug("unable to scan audit event")
+			return nil, err
+		}
+
+		event.At, err = time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			logger.Debug("unable to parse audit event time: %s", atStr)
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return events, nil
+}
+
+// GetAuditHead returns the number of entries in the audit log and the hash of the last one, empty for
+// an empty log
+func (v *VaultStore) GetAuditHead() (int, string, error) {
+	var length int
+	var head string
+	err := v.db.QueryRow(`
+		SELECT COUNT(*), COALESCE((SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1), '') FROM audit_log
+	`).Scan(&length, &head)
+	if err != nil {
+		logger.Debug("getAuditHead:unable to fetch head: %s", err.Error())
+		return 0, "", err
+	}
+	return length, head, nil
+}
@@ -12,13 +12,9 @@ import (
 )
 
 func (v *VaultStore) GetCredentialById(id int) (*model.Credential, error) {
-	var credential model.Credential
-	query := `
-		SELECT id, label, user, secret, ephemeral, nonce FROM credentials
-		WHERE id = ?
-	`
+	query := `SELECT ` + credentialColumns + ` FROM credentials WHERE id = ? AND deleted_at IS NULL`
 
-	err := v.db.QueryRow(query, id).Scan(&credential.Id, &credential.Label, &credential.User, &credential.Secret, &credential.Ephemeral, &credential.Nonce)
+	credential, err := scanCredential(v.db.QueryRow(query, id))
 
 	if err == sql.ErrNoRows {
 		logger.Debug("no matching credential found")
@@ -26,21 +22,17 @@ func (v *VaultStore) GetCredentialById(id int) (*model.Credential, error) {
 	}
 
 	if err != nil {
-		logger.Error("unable to fetch credential")
+		logger.Debug("getCredentialById:unable to fetch credential: %s", err.Error())
 		return nil, err
 	}
 
-	return &credential, nil
+	return credential, nil
 }
 
 func (v *VaultStore) GetCredentialByLabelAndUser(label, user string) (*model.Credential, error) {
-	var credential model.Credential
-	query := `
-		SELECT id, label, user, secret, ephemeral, nonce FROM credentials
-		WHERE label = ? AND user = ?
-	`
+	query := `SELECT ` + credentialColumns + ` FROM credentials WHERE label = ? AND user = ? AND deleted_at IS NULL`
 
-	err := v.db.QueryRow(query, label, user).Scan(&credential.Id, &credential.Label, &credential.User, &credential.Secret, &credential.Ephemeral, &credential.Nonce)
+	credential, err := scanCredential(v.db.QueryRow(query, label, user))
 
 	if err == sql.ErrNoRows {
 		logger.Debug("no matching credential found")
@@ -48,37 +40,118 @@ func (v *VaultStore) GetCredentialByLabelAndUser(label, user string) (*model.Cre
 	}
 
 	if err != nil {
-		logger.Error("unable to fetch credential")
+		logger.Debug("getCredentialByLabelAndUser:unable to fetch credential: %s", err.Error())
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// credentialColumns are the columns read by scanCredential
+var credentialColumns = `id, label, user, kind, access_count, ` + credentialFrequencyColumn + `, folder, ` + credentialTagsColumn + `,
+	secret, ephemeral, nonce, otp, otp_ephemeral, otp_nonce, created_at, updated_at, accessed_at,
+	secret_changed_at, metadata_changed_at`
+
+func scanCredential(row rowScanner) (*model.Credential, error) {
+	var credential model.Credential
+	var tagsStr, createdAtStr, updatedAtStr, accessedAtStr, secretChangedAtStr, metadataChangedAtStr string
+
+	err := row.Scan(
+		&credential.Id,
+		&credential.Label,
+		&credential.User,
+		&credential.Kind,
+		&credential.AccessCount,
+		&credential.Frequency,
+		&credential.Folder,
+		&tagsStr,
+		&credential.Secret,
+		&credential.Ephemeral,
+		&credential.Nonce,
+		&credential.Otp,
+		&credential.OtpEphemeral,
+		&credential.OtpNonce,
+		&createdAtStr,
+		&updatedAtStr,
+		&accessedAtStr,
+		&secretChangedAtStr,
+		&metadataChangedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	credential.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		logger.Debug("unable to parse created at time: %s", createdAtStr)
+		return nil, err
+	}
+
+	credential.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse updated at time: %s", updatedAtStr)
+		return nil, err
+	}
+
+	credential.AccessedAt, err = time.Parse(time.RFC3339, accessedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse accessed at time: %s", accessedAtStr)
 		return nil, err
 	}
 
+	credential.SecretChangedAt, err = time.Parse(time.RFC3339, secretChangedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse secret changed at time: %s", secretChangedAtStr)
+		return nil, err
+	}
+
+	credential.MetadataChangedAt, err = time.Parse(time.RFC3339, metadataChangedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse metadata changed at time: %s", metadataChangedAtStr)
+		return nil, err
+	}
+
+	credential.Tags = splitTags(tagsStr)
 	return &credential, nil
 }
 
+// AddCredential inserts a credential or replaces the secret of the credential with the same label and
+// user. A trashed credential with the same label and user is left alone, and constants.ErrCredentialInTrash
+// returned: replacing it would bring back its fields, tags and attachments with a secret nobody confirmed.
 func (v *VaultStore) AddCredential(credential *model.Credential) error {
 	query := `
-		INSERT INTO credentials (label, user, secret, ephemeral, nonce)
-		VALUES (?, ?, ?, ?, ?)
+		INSERT INTO credentials (label, user, kind, secret, ephemeral, nonce)
+		VALUES (?, ?, ?, ?, ?, ?)
 		ON CONFLICT (label, user)
 		DO UPDATE SET
+			kind = excluded.kind,
 			secret = excluded.secret,
 			ephemeral = excluded.ephemeral,
 			nonce = excluded.nonce
+		WHERE credentials.deleted_at IS NULL
 	`
 
 	stmt, err := v.db.Prepare(query)
 	if err != nil {
-		logger.Error("error preparing statement")
+		logger.Debug("addCredential:failed to prepare statement: %s", err.Error())
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(credential.Label, credential.User, credential.Secret, credential.Ephemeral, credential.Nonce)
+	kind := credential.Kind
+	if kind == "" {
+		kind = model.CredentialKindLogin
+	}
+
+	result, err := stmt.Exec(credential.Label, credential.User, kind, credential.Secret, credential.Ephemeral, credential.Nonce)
 	if err != nil {
-		logger.Error("error inserting credential")
 		logger.Debug("addCredential:failed to execute statement: %s", err.Error())
 		return err
 	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows == 0 {
+		logger.Debug("addCredential:%s (%s) is in the trash", credential.Label, credential.User)
+		return constants.ErrCredentialInTrash
+	}
 
 	return nil
 }
@@ -135,10 +208,30 @@ func (v *VaultStore) UpdateCredential(credential *model.Credential) error {
 	return nil
 }
 
-func (v *VaultStore) SearchCredentialByLabelOrUser(label, user string) ([]model.CredentialSummary, error) {
+// SetCredentialOTP replaces the encrypted one-time password seed of a credential. Passing empty
+// values removes the seed.
+func (v *VaultStore) SetCredentialOTP(credential *model.Credential) error {
+	query := `UPDATE credentials SET otp = ?, otp_ephemeral = ?, otp_nonce = ? WHERE id = ?`
+	result, err := v.db.Exec(query, credential.Otp, credential.OtpEphemeral, credential.OtpNonce, credential.Id)
+	if err != nil {
+		logger.Debug("setCredentialOTP:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		logger.Debug("setCredentialOTP:invalid credential id %d", credential.Id)
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SearchCredentialByLabelOrUser lists credentials whose label and user contain the given strings,
+// carrying every given tag and stored in the given folder or one of its sub-folders. Empty filters
+// match everything.
+func (v *VaultStore) SearchCredentialByLabelOrUser(label, user string, tags []string, folder string) ([]model.CredentialSummary, error) {
 	query := `
-		SELECT id, label, user, access_count, created_at, updated_at, accessed_at FROM credentials
-		WHERE TRUE 
+		SELECT id, label, user, kind, access_count, folder, ` + credentialTagsColumn + `, created_at, updated_at, accessed_at,
+		secret_changed_at, metadata_changed_at FROM credentials
+		WHERE deleted_at IS NULL
 	`
 
 	params := []any{}
@@ -153,6 +246,19 @@ func (v *VaultStore) SearchCredentialByLabelOrUser(label, user string) ([]model.
 		params = append(params, "%"+user+"%")
 	}
 
+	for _, tag := range tags {
+		query = query + ` AND EXISTS (
+			SELECT 1 FROM credential_tags ct JOIN tags t ON t.id = ct.tag_id
+			WHERE ct.credential_id = credentials.id AND t.name = ?
+		) `
+		params = append(params, tag)
+	}
+
+	if folder != "" {
+		query = query + ` AND (folder = ? OR folder LIKE ? ESCAPE '\') `
+		params = append(params, folder, escapeLike(folder)+"/%")
+	}
+
 	rows, err := v.db.Query(query, params...)
 	if err != nil {
 		logger.Debug("failed to fetch list of saved credentials")
@@ -163,16 +269,21 @@ func (v *VaultStore) SearchCredentialByLabelOrUser(label, user string) ([]model.
 	credentials := []model.CredentialSummary{}
 	for rows.Next() {
 		var credential model.CredentialSummary
-		var createdAtStr, updatedAtStr, accessedAtStr string
+		var tagsStr, createdAtStr, updatedAtStr, accessedAtStr, secretChangedAtStr, metadataChangedAtStr string
 
 		if err := rows.Scan(
 			&credential.Id,
 			&credential.Label,
 			&credential.User,
+			&credential.Kind,
 			&credential.AccessCount,
+			&credential.Folder,
+			&tagsStr,
 			&createdAtStr,
 			&updatedAtStr,
 			&accessedAtStr,
+			&secretChangedAtStr,
+			&metadataChangedAtStr,
 		); err != nil {
 			logger.Debug("unable to scan row")
 			return nil, err
@@ -196,6 +307,19 @@ func (v *VaultStore) SearchCredentialByLabelOrUser(label, user string) ([]model.
 			return nil, err
 		}
 
+		credential.SecretChangedAt, err = time.Parse(time.RFC3339, secretChangedAtStr)
+		if err != nil {
+			logger.Debug("unable to parse secret changed at time: %s", secretChangedAtStr)
+			return nil, err
+		}
+
+		credential.MetadataChangedAt, err = time.Parse(time.RFC3339, metadataChangedAtStr)
+		if err != nil {
+			logger.Debug("unable to parse metadata changed at time: %s", metadataChangedAtStr)
+			return nil, err
+		}
+
+		credential.Tags = splitTags(tagsStr)
 		credentials = append(credentials, credential)
 	}
 
@@ -207,7 +331,8 @@ func (v *VaultStore) SearchCredentialByLabelOrUser(label, user string) ([]model.
 	return credentials, nil
 }
 
-// DeleteCredentialById deletes a stored credential by its ID, returns error ID is invalid
+// DeleteCredentialById permanently deletes a stored credential by its ID, trashed or not, returns
+// error ID is invalid
 func (v *VaultStore) DeleteCredentialById(id int) error {
 	query := `DELETE FROM credentials WHERE id = ?`
 	result, err := v.db.Exec(query, id)
@@ -216,14 +341,14 @@ func (v *VaultStore) DeleteCredentialById(id int) error {
 		return err
 	}
 	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
-		logger.Error("invalid credential id %d", id)
+		logger.Debug("deleteCredentialById:invalid credential id %d", id)
 		return fmt.Errorf("no rows affected")
 	}
 	return nil
 }
 
 func (v *VaultStore) GetAllCredentials() ([]model.Credential, error) {
-	query := `SELECT id, label, user, access_count, secret, ephemeral, nonce, accessed_at FROM credentials`
+	query := `SELECT ` + credentialColumns + ` FROM credentials WHERE deleted_at IS NULL`
 	rows, err := v.db.Query(query)
 	if err != nil {
 		logger.Debug("error fetching all credentials from database")
@@ -233,29 +358,12 @@ func (v *VaultStore) GetAllCredentials() ([]model.Credential, error) {
 
 	var credentials []model.Credential
 	for rows.Next() {
-		var credential model.Credential
-		var accessedAtStr string
-		if err := rows.Scan(
-			&credential.Id,
-			&credential.Label,
-			&credential.User,
-			&credential.AccessCount,
-			&credential.Secret,
-			&credential.Ephemeral,
-			&credential.Nonce,
-			&accessedAtStr,
-		); err != nil {
-			logger.Debug("unable to scan credential")
-			return nil, err
-		}
-
-		credential.AccessedAt, err = time.Parse(time.RFC3339, accessedAtStr)
+		credential, err := scanCredential(rows)
 		if err != nil {
-			logger.Debug("unable to parse time string %s", accessedAtStr)
+			logger.Debug("unable to scan credential")
 			return nil, err
 		}
-
-		credentials = append(credentials, credential)
+		credentials = append(credentials, *credential)
 	}
 
 	if rows.Err() != nil {
@@ -265,46 +373,3 @@ func (v *VaultStore) GetAllCredentials() ([]model.Credential, error) {
 
 	return credentials, nil
 }
-
-func (v *VaultStore) UpdateCredentialAccessCount(id, delta int, accessTime time.Time) error {
-	query := `UPDATE credentials SET access_count = access_count + ?, accessed_at = ? WHERE id = ?`
-	_, err := v.db.Exec(query, delta, accessTime, id)
-	if err != nil {
-		logger.Debug("unable to update credential access info : %d at %s", id, accessTime)
-	}
-
-	if constants.AccessCountResetThreshold > 0 {
-		accessCount := 0
-		query := `SELECT access_count FROM credentials WHERE id = ?`
-		result := v.db.QueryRow(query, id)
-		result.Scan(&accessCount)
-		if err := result.Err(); err != nil {
-			logger.Debug("unable to get existing access count for id %d", id)
-			return err
-		}
-
-		if accessCount > constants.AccessCountResetThreshold {
-			logger.Debug("access count baseline reset triggered")
-			// reset access count base-line to prevent a single credentials from dominating
-			// the search un-fairly.
-			err := v.resetAccessCountBaseline()
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
-// ResetAccessCountBaseline resets the access count for all credentials by ACCESS_COUNT_RESET_THRESHOLD
-// clamping values between [0, ACCESS_COUNT_RESET_THRESHOLD]. Access count less than ACCESS_COUNT_RESET_THRESHOLD is
-// set to 0.
-func (v *VaultStore) resetAccessCountBaseline() error {
-	query := `UPDATE credentials SET access_count = MAX(access_count - ?, 0)`
-	_, err := v.db.Exec(query, constants.AccessCountResetThreshold)
-	if err != nil {
-		logger.Debug("failed to update access count baseline")
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"database/sql"
+	"slices"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// credentialExpiryQuery selects the max age rule and last secret change of credentials outside the
+// trash that have one
+const credentialExpiryQuery = `
+	SELECT * FROM (
+		SELECT
+			c.id,
+			c.label,
+			c.user,
+			strftime('%Y-%m-%dT%H:%M:%SZ', c.secret_changed_at) AS secret_changed_at,
+			COALESCE(m.max_age_days, (
+				SELECT MIN(t.max_age_days) FROM credential_tags ct
+				JOIN tags ON tags.id = ct.tag_id
+				JOIN tag_max_ages t ON t.tag = tags.name
+				WHERE ct.credential_id = c.id
+			), 0) AS max_age_days,
+			CASE WHEN m.max_age_days IS NULL THEN COALESCE((
+				SELECT t.tag FROM credential_tags ct
+				JOIN tags ON tags.id = ct.tag_id
+				JOIN tag_max_ages t ON t.tag = tags.name
+				WHERE ct.credential_id = c.id
+				ORDER BY t.max_age_days, t.tag LIMIT 1
+			), '') ELSE '' END AS tag
+		FROM credentials c
+		LEFT JOIN credential_max_ages m ON m.credential_id = c.id
+		WHERE c.deleted_at IS NULL
+	) WHERE max_age_days > 0
+`
+
+// SetCredentialMaxAge sets the max age of a credential's secret in days, replacing the one it had
+func (v *VaultStore) SetCredentialMaxAge(credentialId, days int) error {
+	query := `
+		INSERT INTO credential_max_ages (credential_id, max_age_days) VALUES (?, ?)
+		ON CONFLICT (credential_id) DO UPDATE SET max_age_days = excluded.max_age_days
+	`
+	if _, err := v.db.Exec(query, credentialId, days); err != nil {
+		logger.Debug("setCredentialMaxAge:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ClearCredentialMaxAge removes the max age of a credential, its tags apply again. Returns
+// sql.ErrNoRows if it had none.
+func (v *VaultStore) ClearCredentialMaxAge(credentialId int) error {
+	result, err := v.db.Exec(`DELETE FROM credential_max_ages WHERE credential_id = ?`, credentialId)
+	if err != nil {
+		logger.Debug("clearCredentialMaxAge:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetTagMaxAge sets the max age in days of the secrets of every credential with a tag, the tag does
+// not have to be in use yet
+func (v *VaultStore) SetTagMaxAge(tag string, days int) error {
+	query := `
+		INSERT INTO tag_max_ages (tag, max_age_days) VALUES (?, ?)
+		ON CONFLICT (tag) DO UPDATE SET max_age_days = excluded.max_age_days
+	`
+	if _, err := v.db.Exec(query, tag, days); err != nil {
+		logger.Debug("setTagMaxAge:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ClearTagMaxAge removes the max age of a tag, returns sql.ErrNoRows if it had none
+func (v *VaultStore) ClearTagMaxAge(tag string) error {
+	result, err := v.db.Exec(`DELETE FROM tag_max_ages WHERE tag = ?`, tag)
+	if err != nil {
+		logger.Debug("clearTagMaxAge:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetMaxAges fetches every max age rule, tags by name first, then credentials by label and user
+func (v *VaultStore) GetMaxAges() ([]model.MaxAge, error) {
+	query := `
+		SELECT 0, '', '', tag, max_age_days FROM tag_max_ages
+		UNION ALL
+		SELECT c.id, c.label, c.user, '', m.max_age_days FROM credential_max_ages m
+		JOIN credentials c ON c.id = m.credential_id
+		WHERE c.deleted_at IS NULL
+		ORDER BY 1, 4, 2, 3
+	`
+	rows, err := v.db.Query(query)
+	if err != nil {
+		logger.Debug("failed to fetch max ages")
+		return nil, err
+	}
+	defer rows.Close()
+
+	maxAges := []model.MaxAge{}
+	for rows.Next() {
+		var maxAge model.MaxAge
+		if err := rows.Scan(&maxAge.CredentialId, &maxAge.Label, &maxAge.User, &maxAge.Tag, &maxAge.Days); err != nil {
+			logger.Debug("unable to scan max age")
+			return nil, err
+		}
+		maxAges = append(maxAges, maxAge)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return maxAges, nil
+}
+
+// GetCredentialExpiries fetches the expiry of every credential outside the trash that has a max age,
+// by its own or through a tag, soonest due first
+func (v *VaultStore) GetCredentialExpiries() ([]model.CredentialExpiry, error) {
+	rows, err := v.db.Query(credentialExpiryQuery)
+	if err != nil {
+		logger.Debug("failed to fetch credential expiries")
+		return nil, err
+	}
+	defer rows.Close()
+
+	expiries := []model.CredentialExpiry{}
+	for rows.Next() {
+		expiry, err := scanCredentialExpiry(rows)
+		if err != nil {
+			logger.Debug("unable to scan credential expiry")
+			return nil, err
+		}
+		expiries = append(expiries, *expiry)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	slices.SortStableFunc(expiries, func(a, b model.CredentialExpiry) int { return a.DueAt().Compare(b.DueAt()) })
+	return expiries, nil
+}
+
+// GetCredentialExpiry fetches the expiry of a credential, returns sql.ErrNoRows if no max age applies
+func (v *VaultStore) GetCredentialExpiry(credentialId int) (*model.CredentialExpiry, error) {
+	expiry, err := scanCredentialExpiry(v.db.QueryRow(credentialExpiryQuery+` AND id = ?`, credentialId))
+	if err != nil && err != sql.ErrNoRows {
+		logger.Debug("getCredentialExpiry:unable to fetch expiry: %s", err.Error())
+	}
+	return expiry, err
+}
+
+func scanCredentialExpiry(row rowScanner) (*model.CredentialExpiry, error) {
+	var expiry model.CredentialExpiry
+	var secretChangedAtStr string
+
+	err := row.Scan(
+		&expiry.Id,
+		&expiry.Label,
+		&expiry.User,
+		&secretChangedAtStr,
+		&expiry.MaxAgeDays,
+		&expiry.Tag,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	expiry.SecretChangedAt, err = time.Parse(time.RFC3339, secretChangedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse secret changed at time: %s", secretChangedAtStr)
+		return nil, err
+	}
+
+	return &expiry, nil
+}
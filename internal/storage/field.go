@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// SetCredentialField adds a field to a credential or replaces the field with the same name
+func (v *VaultStore) SetCredentialField(field *model.CredentialField) error {
+	query := `
+		INSERT INTO credential_fields (credential_id, name, type, value, ephemeral, nonce)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (credential_id, name)
+		DO UPDATE SET
+			type = excluded.type,
+			value = excluded.value,
+			ephemeral = excluded.ephemeral,
+			nonce = excluded.nonce
+	`
+
+	_, err := v.db.Exec(query, field.CredentialId, field.Name, field.Type, field.Value, field.Ephemeral, field.Nonce)
+	if err != nil {
+		logger.Debug("setCredentialField:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetCredentialField fetches a single field of a credential by name, returns sql.ErrNoRows if it does not exist
+func (v *VaultStore) GetCredentialField(credentialId int, name string) (*model.CredentialField, error) {
+	query := `
+		SELECT id, credential_id, name, type, value, ephemeral, nonce, created_at, updated_at
+		FROM credential_fields
+		WHERE credential_id = ? AND name = ?
+	`
+
+	field, err := scanCredentialField(v.db.QueryRow(query, credentialId, name))
+	if err == sql.ErrNoRows {
+		logger.Debug("no matching credential field found")
+		return nil, err
+	}
+	if err != nil {
+		logger.Debug("getCredentialField:unable to fetch field: %s", err.Error())
+		return nil, err
+	}
+	return field, nil
+}
+
+// GetCredentialFields fetches all fields of a credential ordered by name
+func (v *VaultStore) GetCredentialFields(credentialId int) ([]model.CredentialField, error) {
+	query := `
+		SELECT id, credential_id, name, type, value, ephemeral, nonce, created_at, updated_at
+		FROM credential_fields
+		WHERE credential_id = ?
+		ORDER BY name
+	`
+
+	rows, err := v.db.Query(query, credentialId)
+	if err != nil {
+		logger.Debug("failed to fetch credential fields")
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []model.CredentialField{}
+	for rows.Next() {
+		field, err := scanCredentialField(rows)
+		if err != nil {
+			logger.Debug("unable to scan credential field")
+			return nil, err
+		}
+		fields = append(fields, *field)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return fields, nil
+}
+
+// DeleteCredentialField deletes a field of a credential by name, returns sql.ErrNoRows if it does not exist
+func (v *VaultStore) DeleteCredentialField(credentialId int, name string) error {
+	query := `DELETE FROM credential_fields WHERE credential_id = ? AND name = ?`
+	result, err := v.db.Exec(query, credentialId, name)
+	if err != nil {
+		logger.Debug("unable to delete credential field")
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCredentialField(row rowScanner) (*model.CredentialField, error) {
+	var field model.CredentialField
+	var createdAtStr, updatedAtStr string
+
+	err := row.Scan(
+		&field.Id,
+		&field.CredentialId,
+		&field.Name,
+		&field.Type,
+		&field.Value,
+		&field.Ephemeral,
+		&field.Nonce,
+		&createdAtStr,
+		&updatedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	field.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		logger.Debug("unable to parse created at time: %s", createdAtStr)
+		return nil, err
+	}
+
+	field.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse updated at time: %s", updatedAtStr)
+		return nil, err
+	}
+
+	return &field, nil
+}
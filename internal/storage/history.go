@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// GetCredentialHistory fetches the previous secrets of a credential, newest first. Versions are
+// archived by the archive_credential_secret trigger whenever the secret of a credential changes.
+func (v *VaultStore) GetCredentialHistory(credentialId int) ([]model.CredentialVersion, error) {
+	query := `
+		SELECT credential_id, version, secret, ephemeral, nonce, replaced_at
+		FROM credential_history
+		WHERE credential_id = ?
+		ORDER BY version DESC
+	`
+
+	rows, err := v.db.Query(query, credentialId)
+	if err != nil {
+		logger.Debug("failed to fetch credential history")
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []model.CredentialVersion{}
+	for rows.Next() {
+		version, err := scanCredentialVersion(rows)
+		if err != nil {
+			logger.Debug("unable to scan credential version")
+			return nil, err
+		}
+		versions = append(versions, *version)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return versions, nil
+}
+
+// GetCredentialVersion fetches a single previous secret, returns sql.ErrNoRows if it does not exist
+func (v *VaultStore) GetCredentialVersion(credentialId, version int) (*model.CredentialVersion, error) {
+	query := `
+		SELECT credential_id, version, secret, ephemeral, nonce, replaced_at
+		FROM credential_history
+		WHERE credential_id = ? AND version = ?
+	`
+
+	credentialVersion, err := scanCredentialVersion(v.db.QueryRow(query, credentialId, version))
+	if err == sql.ErrNoRows {
+		logger.Debug("no matching credential version found")
+		return nil, err
+	}
+	if err != nil {
+		logger.Debug("getCredentialVersion:unable to fetch version: %s", err.Error())
+		return nil, err
+	}
+	return credentialVersion, nil
+}
+
+// PruneCredentialHistory applies the retention policy to the history of every credential: only the
+// newest keep versions are kept, and versions replaced before the given time are dropped. A keep of
+// zero or a zero time disables the respective rule.
+func (v *VaultStore) PruneCredentialHistory(keep int, before time.Time) error {
+	if keep > 0 {
+		query := `
+			DELETE FROM credential_history
+			WHERE version <= (
+				SELECT MAX(h.version) FROM credential_history h
+				WHERE h.credential_id = credential_history.credential_id
+			) - ?
+		`
+		if _, err := v.db.Exec(query, keep); err != nil {
+			logger.Debug("pruneCredentialHistory:failed to drop old versions: %s", err.Error())
+			return err
+		}
+	}
+
+	if !before.IsZero() {
+		query := `DELETE FROM credential_history WHERE replaced_at < ?`
+		if _, err := v.db.Exec(query, before.UTC().Format(time.DateTime)); err != nil {
+			logger.Debug("pruneCredentialHistory:failed to drop expired versions: %s", err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanCredentialVersion(row rowScanner) (*model.CredentialVersion, error) {
+	var version model.CredentialVersion
+	var replacedAtStr string
+
+	err := row.Scan(
+		&version.CredentialId,
+		&version.Version,
+		&version.Secret,
+		&version.Ephemeral,
+		&version.Nonce,
+		&replacedAtStr,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	version.ReplacedAt, err = time.Parse(time.RFC3339, replacedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse replaced at time: %s", replacedAtStr)
+		return nil, err
+	}
+
+	return &version, nil
+}
+
+// SetPendingSecret saves the pending secret of a credential, replacing the one it had
+func (v *VaultStore) SetPendingSecret(pending *model.PendingSecret) error {
+	query := `
+		INSERT INTO pending_secrets (credential_id, secret, ephemeral, nonce) VALUES (?, ?, ?, ?)
+		ON CONFLICT (credential_id) DO UPDATE SET
+			secret = excluded.secret,
+			ephemeral = excluded.ephemeral,
+			nonce = excluded.nonce,
+			created_at = CURRENT_TIMESTAMP
+	`
+	if _, err := v.db.Exec(query, pending.CredentialId, pending.Secret, pending.Ephemeral, pending.Nonce); err != nil {
+		logger.Debug("setPendingSecret:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetPendingSecret fetches the pending secret of a credential, returns sql.ErrNoRows if there is none
+func (v *VaultStore) GetPendingSecret(credentialId int) (*model.PendingSecret, error) {
+	query := `
+		SELECT credential_id, secret, ephemeral, nonce, created_at
+		FROM pending_secrets
+		WHERE credential_id = ?
+	`
+
+	var pending model.PendingSecret
+	var createdAtStr string
+	err := v.db.QueryRow(query, credentialId).Scan(
+		&pending.CredentialId,
+		&pending.Secret,
+		&pending.Ephemeral,
+		&pending.Nonce,
+		&createdAtStr,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		logger.Debug("getPendingSecret:unable to fetch pending secret: %s", err.Error())
+		return nil, err
+	}
+
+	pending.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		logger.Debug("unable to parse created at time: %s", createdAtStr)
+		return nil, err
+	}
+	return &pending, nil
+}
+
+// DeletePendingSecret drops the pending secret of a credential, returns sql.ErrNoRows if there is none
+func (v *VaultStore) DeletePendingSecret(credentialId int) error {
+	result, err := v.db.Exec(`DELETE FROM pending_secrets WHERE credential_id = ?`, credentialId)
+	if err != nil {
+		logger.Debug("deletePendingSecret:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// GetIntegrityRecord fetches the record sealing the credential MACs, returns sql.ErrNoRows until the
+// vault is sealed the first time
+func (v *VaultStore) GetIntegrityRecord() (*model.IntegrityRecord, error) {
+	var record model.IntegrityRecord
+	var sealedAtStr string
+	err := v.db.QueryRow(`SELECT counter, mac, sealed_at, audit_length, audit_head FROM integrity WHERE id = 1`).Scan(
+		&record.Counter, &record.MAC, &sealedAtStr, &record.AuditLength, &record.AuditHead,
+	)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Debug("getIntegrityRecord:unable to fetch record: %s", err.Error())
+		}
+		return nil, err
+	}
+
+	record.SealedAt, err = time.Parse(time.RFC3339, sealedAtStr)
+	if err != nil {
+		logger.Debug("unable to parse sealed at time: %s", sealedAtStr)
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetCredentialMACs fetches every sealed credential MAC
+func (v *VaultStore) GetCredentialMACs() ([]model.CredentialMAC, error) {
+	rows, err := v.db.Query(`SELECT credential_id, label, user, mac FROM credential_macs ORDER BY credential_id`)
+	if err != nil {
+		logger.Debug("failed to fetch credential macs")
+		return nil, err
+	}
+	defer rows.Close()
+
+	macs := []model.CredentialMAC{}
+	for rows.Next() {
+		var mac model.CredentialMAC
+		if err := rows.Scan(&mac.CredentialId, &mac.Label, &mac.User, &mac.MAC); err != nil {
+			logger.Debug("unable to scan credential mac")
+			return nil, err
+		}
+		macs = append(macs, mac)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return macs, nil
+}
+
+// SaveIntegrity stores the MACs in set, drops the MACs of the credentials in remove and replaces the
+// integrity record in one transaction, then remembers the counter outside the database
+func (v *VaultStore) SaveIntegrity(record *model.IntegrityRecord, set []model.CredentialMAC, remove []int) error {
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Debug("saveIntegrity:failed to start transaction: %s", err.Error())
+		return err
+	}
+	defer transaction.Rollback()
+
+	for _, mac := range set {
+		_, err := transaction.Exec(`
+			INSERT INTO credential_macs (credential_id, label, user, mac) VALUES (?, ?, ?, ?)
+			ON CONFLICT (credential_id) DO UPDATE SET label = excluded.label, user = excluded.user, mac = excluded.mac
+		`, mac.CredentialId, mac.Label, mac.User, mac.MAC)
+		if err != nil {
+			logger.Debug("saveIntegrity:failed to save mac of credential %d: %s", mac.CredentialId, err.Error())
+			return err
+		}
+	}
+
+	for _, id := range remove {
+		if _, err := transaction.Exec(`DELETE FROM credential_macs WHERE credential_id = ?`, id); err != nil {
+			logger.Debug("saveIntegrity:failed to remove mac of credential %d: %s", id, err.Error())
+			return err
+		}
+	}
+
+	_, err = transaction.Exec(`
+		INSERT INTO integrity (id, counter, mac, sealed_at, audit_length, audit_head) VALUES (1, ?, ?, CURRENT_TIMESTAMP, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET counter = excluded.counter, mac = excluded.mac, sealed_at = excluded.sealed_at,
+			audit_length = excluded.audit_length, audit_head = excluded.audit_head
+	`, record.Counter, record.MAC, record.AuditLength, record.AuditHead)
+	if err != nil {
+		logger.Debug("saveIntegrity:failed to save record: %s", err.Error())
+		return err
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return err
+	}
+
+	return v.SetKnownIntegrityCounter(record.Counter)
+}
+
+// GetKnownIntegrityCounter returns the highest integrity counter this machine has seen for the vault,
+// kept in a file next to the database so replacing the database with an older copy does not reset it.
+// Zero when the file does not exist.
+func (v *VaultStore) GetKnownIntegrityCounter() (int, error) {
+	content, err := os.ReadFile(v.counterPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		logger.Debug("getKnownIntegrityCounter:unable to read counter: %s", err.Error())
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(content)))
+}
+
+// SetKnownIntegrityCounter replaces the counter file, never lowering the counter
+func (v *VaultStore) SetKnownIntegrityCounter(counter int) error {
+	if known, err := v.GetKnownIntegrityCounter(); err == nil && known >= counter {
+		return nil
+	}
+
+	// write then rename, a torn write must not lose the counter
+	temporary := v.counterPath() + ".tmp"
+	if err := os.WriteFile(temporary, []byte(strconv.Itoa(counter)+"\n"), 0600); err != nil {
+		logger.Debug("setKnownIntegrityCounter:unable to write counter: %s", err.Error())
+		return err
+	}
+	return os.Rename(temporary, v.counterPath())
+}
+
+func (v *VaultStore) counterPath() string {
+	return v.path + ".counter"
+}
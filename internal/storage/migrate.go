@@ -0,0 +1,489 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+)
+
+// migrations holds the schema changes applied on top of the base schema created by InitializeVault.
+// Each entry is applied once, in order, and the index of the last applied entry is tracked in
+// `PRAGMA user_version`. Never edit or reorder an entry once released, only append new ones.
+var migrations = []string{
+	// 1: encrypted otpauth:// seed per credential
+	`
+		ALTER TABLE credentials ADD COLUMN otp TEXT NOT NULL DEFAULT '';
+		ALTER TABLE credentials ADD COLUMN otp_ephemeral TEXT NOT NULL DEFAULT '';
+		ALTER TABLE credentials ADD COLUMN otp_nonce TEXT NOT NULL DEFAULT '';
+	`,
+	// 2: named custom fields per credential
+	`
+		CREATE TABLE IF NOT EXISTS credential_fields (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			value TEXT NOT NULL,
+			ephemeral TEXT NOT NULL DEFAULT '',
+			nonce TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(credential_id, name)
+		);
+
+		CREATE TRIGGER IF NOT EXISTS update_credential_field_timestamp
+		AFTER UPDATE ON credential_fields
+		FOR EACH ROW
+		BEGIN
+			UPDATE credential_fields SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+		END;
+	`,
+	// 3: user settings and encrypted file attachments
+	`
+		CREATE TABLE IF NOT EXISTS settings (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS attachments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			chunk_size INTEGER NOT NULL,
+			key TEXT NOT NULL,
+			key_ephemeral TEXT NOT NULL,
+			key_nonce TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(credential_id, name)
+		);
+
+		CREATE TABLE IF NOT EXISTS attachment_chunks (
+			attachment_id INTEGER NOT NULL REFERENCES attachments(id) ON DELETE CASCADE,
+			idx INTEGER NOT NULL,
+			nonce BLOB NOT NULL,
+			data BLOB NOT NULL,
+			PRIMARY KEY (attachment_id, idx)
+		);
+	`,
+	// 4: previous secrets of every credential, archived whenever the secret column changes
+	`
+		CREATE TABLE IF NOT EXISTS credential_history (
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			secret TEXT NOT NULL,
+			ephemeral TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			replaced_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (credential_id, version)
+		);
+
+		CREATE TRIGGER IF NOT EXISTS archive_credential_secret
+		AFTER UPDATE OF secret ON credentials
+		FOR EACH ROW
+		WHEN OLD.secret != NEW.secret
+		BEGIN
+			INSERT INTO credential_history (credential_id, version, secret, ephemeral, nonce)
+			VALUES (
+				OLD.id,
+				COALESCE((SELECT MAX(version) FROM credential_history WHERE credential_id = OLD.id), 0) + 1,
+				OLD.secret,
+				OLD.ephemeral,
+				OLD.nonce
+			);
+		END;
+	`,
+	// 5: soft delete, trashed credentials keep their row until purged
+	`
+		ALTER TABLE credentials ADD COLUMN deleted_at DATETIME;
+	`,
+	// 6: tags (many-to-many) and a folder path per credential
+	`
+		ALTER TABLE credentials ADD COLUMN folder TEXT NOT NULL DEFAULT '';
+
+		CREATE TABLE IF NOT EXISTS tags (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE
+		);
+
+		CREATE TABLE IF NOT EXISTS credential_tags (
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			PRIMARY KEY (credential_id, tag_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS credential_tags_tag_id ON credential_tags(tag_id);
+	`,
+	// 7: web URLs and Android app ids per credential, matched by `kosh match`
+	`
+		CREATE TABLE IF NOT EXISTS credential_urls (
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (credential_id, url)
+		);
+	`,
+	// 8: origins the browser extension may look up, fill and save credentials for
+	`
+		CREATE TABLE IF NOT EXISTS native_host_origins (
+			origin TEXT PRIMARY KEY,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	// 9: clients of the local JSON API and the audit trail of their calls
+	`
+		CREATE TABLE IF NOT EXISTS api_clients (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			token_hash TEXT NOT NULL UNIQUE,
+			labels TEXT NOT NULL,
+			can_read INTEGER NOT NULL DEFAULT 0,
+			can_write INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME
+		);
+
+		CREATE TABLE IF NOT EXISTS api_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			client TEXT NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			status INTEGER NOT NULL,
+			pid INTEGER NOT NULL,
+			uid INTEGER NOT NULL,
+			credential_id INTEGER NOT NULL DEFAULT 0,
+			at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	// 10: hash-chained, append-only log of vault operations. The time is kept as the exact RFC 3339
+	// text that was hashed, and prev_hash is unique so concurrent writers cannot fork the chain.
+	`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event TEXT NOT NULL,
+			credential_id INTEGER NOT NULL DEFAULT 0,
+			label TEXT NOT NULL DEFAULT '',
+			user TEXT NOT NULL DEFAULT '',
+			command TEXT NOT NULL,
+			pid INTEGER NOT NULL,
+			at TEXT NOT NULL,
+			prev_hash TEXT NOT NULL UNIQUE,
+			hash TEXT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_audit_log_credential ON audit_log(credential_id);
+
+		CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_log is append-only');
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN
+			SELECT RAISE(ABORT, 'audit_log is append-only');
+		END;
+	`,
+	// 11: per-row credential MACs and the record sealing them, no foreign key so removed rows are seen
+	`
+		CREATE TABLE IF NOT EXISTS credential_macs (
+			credential_id INTEGER PRIMARY KEY,
+			label TEXT NOT NULL,
+			user TEXT NOT NULL,
+			mac TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS integrity (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			counter INTEGER NOT NULL,
+			mac TEXT NOT NULL,
+			sealed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	// 12: named generator policies and the policy each credential is regenerated with
+	`
+		CREATE TABLE IF NOT EXISTS policies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			length INTEGER NOT NULL,
+			upper INTEGER NOT NULL,
+			lower INTEGER NOT NULL,
+			digit INTEGER NOT NULL,
+			symbol INTEGER NOT NULL,
+			require TEXT NOT NULL DEFAULT '',
+			exclude_ambiguous INTEGER NOT NULL DEFAULT 0,
+			symbols TEXT NOT NULL DEFAULT '',
+			no_repeat INTEGER NOT NULL DEFAULT 0,
+			start_with TEXT NOT NULL DEFAULT '',
+			pattern TEXT NOT NULL DEFAULT '',
+			passphrase INTEGER NOT NULL DEFAULT 0,
+			words INTEGER NOT NULL DEFAULT 0,
+			separator TEXT NOT NULL DEFAULT '',
+			capitalize INTEGER NOT NULL DEFAULT 0,
+			wordlist TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS credential_policies (
+			credential_id INTEGER PRIMARY KEY REFERENCES credentials(id) ON DELETE CASCADE,
+			policy_id INTEGER NOT NULL REFERENCES policies(id) ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS credential_policies_policy_id ON credential_policies(policy_id);
+	`,
+	// 13: secrets generated by kosh rotate, waiting for the site to accept them
+	`
+		CREATE TABLE IF NOT EXISTS pending_secrets (
+			credential_id INTEGER PRIMARY KEY REFERENCES credentials(id) ON DELETE CASCADE,
+			secret TEXT NOT NULL,
+			ephemeral TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	// 14: max age of secrets per credential and per tag name, kept when the tag falls out of use
+	`
+		CREATE TABLE IF NOT EXISTS credential_max_ages (
+			credential_id INTEGER PRIMARY KEY REFERENCES credentials(id) ON DELETE CASCADE,
+			max_age_days INTEGER NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS tag_max_ages (
+			tag TEXT PRIMARY KEY,
+			max_age_days INTEGER NOT NULL
+		);
+	`,
+	// 15: separate times for secret and metadata changes. Reads no longer move updated_at, which
+	// becomes the later of the two. Existing rows are backfilled from the history, the audit log and
+	// updated_at where it is clearly not the time of the last read. HOTP codes reseal the seed on every
+	// read, so only adding or removing a seed counts as a change.
+	`
+		DROP TRIGGER IF EXISTS update_credential_timestamp;
+
+		ALTER TABLE credentials ADD COLUMN secret_changed_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+		ALTER TABLE credentials ADD COLUMN metadata_changed_at DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+		UPDATE credentials SET secret_changed_at = COALESCE(
+			(SELECT MAX(h.replaced_at) FROM credential_history h WHERE h.credential_id = credentials.id),
+			created_at
+		);
+
+		UPDATE credentials SET metadata_changed_at = strftime('%Y-%m-%d %H:%M:%S', MAX(
+			julianday(created_at),
+			COALESCE((
+				SELECT julianday(MAX(a.at)) FROM audit_log a
+				WHERE a.credential_id = credentials.id AND a.event = 'update'
+			), 0),
+			CASE WHEN julianday(updated_at) - julianday(substr(accessed_at, 1, 19)) > 2.0 / 86400
+				THEN julianday(updated_at) ELSE 0 END
+		));
+
+		UPDATE credentials SET updated_at = MAX(secret_changed_at, metadata_changed_at);
+
+		CREATE TRIGGER IF NOT EXISTS credential_created_timestamps
+		AFTER INSERT ON credentials
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET secret_changed_at = NEW.created_at, metadata_changed_at = NEW.created_at
+			WHERE id = NEW.id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_secret_changed
+		AFTER UPDATE OF secret ON credentials
+		FOR EACH ROW
+		WHEN OLD.secret != NEW.secret
+		BEGIN
+			UPDATE credentials SET secret_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_metadata_changed
+		AFTER UPDATE OF label, user, folder, otp ON credentials
+		FOR EACH ROW
+		WHEN OLD.label != NEW.label OR OLD.user != NEW.user OR OLD.folder != NEW.folder
+			OR (OLD.otp = '') != (NEW.otp = '')
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_tag_added
+		AFTER INSERT ON credential_tags
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.credential_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_tag_removed
+		AFTER DELETE ON credential_tags
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = OLD.credential_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_field_added
+		AFTER INSERT ON credential_fields
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.credential_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_field_changed
+		AFTER UPDATE OF name, type, value ON credential_fields
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.credential_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_field_removed
+		AFTER DELETE ON credential_fields
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = OLD.credential_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_url_added
+		AFTER INSERT ON credential_urls
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = NEW.credential_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS credential_url_removed
+		AFTER DELETE ON credential_urls
+		FOR EACH ROW
+		BEGIN
+			UPDATE credentials SET metadata_changed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+			WHERE id = OLD.credential_id;
+		END;
+	`,
+	// 16: every access of a credential, the source of its frequency in search. The access counts from
+	// before become one event per credential at its last access.
+	`
+		CREATE TABLE IF NOT EXISTS access_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			via TEXT NOT NULL,
+			query_hash TEXT NOT NULL DEFAULT '',
+			weight REAL NOT NULL,
+			at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS access_events_credential_at ON access_events(credential_id, at);
+		CREATE INDEX IF NOT EXISTS access_events_at ON access_events(at);
+
+		INSERT INTO access_events (credential_id, via, weight, at)
+		SELECT id, 'migrated', access_count, substr(accessed_at, 1, 19) FROM credentials
+		WHERE access_count > 0;
+	`,
+	// 17: the working directory and git remote of each access, for ranking by context
+	`
+		ALTER TABLE access_events ADD COLUMN cwd TEXT NOT NULL DEFAULT '';
+		ALTER TABLE access_events ADD COLUMN git_remote TEXT NOT NULL DEFAULT '';
+
+		CREATE INDEX IF NOT EXISTS access_events_git_remote ON access_events(git_remote) WHERE git_remote != '';
+		CREATE INDEX IF NOT EXISTS access_events_cwd ON access_events(cwd) WHERE cwd != '';
+	`,
+	// 18: the credential picked for each prefix of a search query, hashed. Earlier searches count for
+	// their whole query.
+	`
+		CREATE TABLE IF NOT EXISTS query_selections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			prefix_hash TEXT NOT NULL,
+			complete INTEGER NOT NULL DEFAULT 0,
+			at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS query_selections_at ON query_selections(at);
+
+		INSERT INTO query_selections (credential_id, prefix_hash, complete, at)
+		SELECT credential_id, query_hash, 1, at FROM access_events
+		WHERE query_hash != '';
+	`,
+	// 19: the kind of credential, a login or a secure note. Notes saved before were logins without a
+	// user and cannot be told apart, they stay logins.
+	`
+		ALTER TABLE credentials ADD COLUMN kind TEXT NOT NULL DEFAULT 'login';
+	`,
+	// 20: audit log entries keyed with the integrity MAC, and the length and last hash of the audit log
+	// sealed in the integrity record. Earlier entries stay unkeyed, earlier records anchor nothing.
+	`
+		ALTER TABLE audit_log ADD COLUMN keyed INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE integrity ADD COLUMN audit_length INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE integrity ADD COLUMN audit_head TEXT NOT NULL DEFAULT '';
+	`,
+	// 21: one decayed count per hashed query prefix and credential, as of its last pick, in place of a
+	// row per pick. The hashes so far were not keyed and are dropped rather than carried over.
+	`
+		DROP TABLE IF EXISTS query_selections;
+
+		CREATE TABLE query_selections (
+			prefix_hash TEXT NOT NULL,
+			credential_id INTEGER NOT NULL REFERENCES credentials(id) ON DELETE CASCADE,
+			exact REAL NOT NULL DEFAULT 0,
+			prefix REAL NOT NULL DEFAULT 0,
+			at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (prefix_hash, credential_id)
+		);
+
+		CREATE INDEX IF NOT EXISTS query_selections_at ON query_selections(at);
+
+		UPDATE access_events SET query_hash = '' WHERE query_hash != '';
+	`,
+}
+
+// migrateDatabase brings an initialized vault up to the latest schema version. It is a no-op for a
+// database where the vault has not been initialized yet.
+func migrateDatabase(db *sql.DB) error {
+	var tableName string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type='table' AND name='credentials'`).Scan(&tableName)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		logger.Debug("migrateDatabase:unable to check credentials table: %s", err.Error())
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		logger.Debug("migrateDatabase:unable to read schema version: %s", err.Error())
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := applyMigration(db, i+1, migrations[i]); err != nil {
+			logger.Debug("migrateDatabase:failed to migrate to version %d: %s", i+1, err.Error())
+			return fmt.Errorf("migrate database to version %d: %w", i+1, err)
+		}
+		logger.Debug("migrated database to version %d", i+1)
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, version int, statements string) error {
+	transaction, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback()
+
+	if _, err := transaction.Exec(statements); err != nil {
+		logger.Debug("applyMigration:failed to execute migration %d: %s", version, err.Error())
+		return err
+	}
+
+	if _, err := transaction.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+		return err
+	}
+
+	return transaction.Commit()
+}
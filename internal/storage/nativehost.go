@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+)
+
+// AllowNativeHostOrigin adds an origin to the browser extension allowlist, allowing it twice is a no-op
+func (v *VaultStore) AllowNativeHostOrigin(origin string) error {
+	query := `INSERT INTO native_host_origins (origin) VALUES (?) ON CONFLICT (origin) DO NOTHING`
+	if _, err := v.db.Exec(query, origin); err != nil {
+		logger.Debug("allowNativeHostOrigin:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// RevokeNativeHostOrigin removes an origin from the allowlist, returns sql.ErrNoRows if it was not allowed
+func (v *VaultStore) RevokeNativeHostOrigin(origin string) error {
+	result, err := v.db.Exec(`DELETE FROM native_host_origins WHERE origin = ?`, origin)
+	if err != nil {
+		logger.Debug("revokeNativeHostOrigin:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetNativeHostOrigins fetches the allowlist sorted by origin
+func (v *VaultStore) GetNativeHostOrigins() ([]string, error) {
+	rows, err := v.db.Query(`SELECT origin FROM native_host_origins ORDER BY origin`)
+	if err != nil {
+		logger.Debug("failed to fetch native host origins")
+		return nil, err
+	}
+	defer rows.Close()
+
+	origins := []string{}
+	for rows.Next() {
+		var origin string
+		if err := rows.Scan(&origin); err != nil {
+			logger.Debug("unable to scan native host origin")
+			return nil, err
+		}
+		origins = append(origins, origin)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return origins, nil
+}
+
+// IsNativeHostOriginAllowed reports whether an origin is on the allowlist
+func (v *VaultStore) IsNativeHostOriginAllowed(origin string) (bool, error) {
+	var count int
+	if err := v.db.QueryRow(`SELECT COUNT(*) FROM native_host_origins WHERE origin = ?`, origin).Scan(&count); err != nil {
+		logger.Debug("isNativeHostOriginAllowed:failed to execute query: %s", err.Error())
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+const policyColumns = `
+	p.id, p.name, p.length, p.upper, p.lower, p.digit, p.symbol, p.require,
+	p.exclude_ambiguous, p.symbols, p.no_repeat, p.start_with, p.pattern,
+	p.passphrase, p.words, p.separator, p.capitalize, p.wordlist, p.created_at
+`
+
+// AddPolicy saves a new policy, the name must be unique regardless of case
+func (v *VaultStore) AddPolicy(policy *model.Policy) error {
+	query := `
+		INSERT INTO policies (
+			name, length, upper, lower, digit, symbol, require,
+			exclude_ambiguous, symbols, no_repeat, start_with, pattern,
+			passphrase, words, separator, capitalize, wordlist
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := v.db.Exec(query,
+		policy.Name, policy.Length, policy.Upper, policy.Lower, policy.Digit, policy.Symbol, policy.Require,
+		policy.ExcludeAmbiguous, policy.Symbols, policy.NoRepeat, policy.StartWith, policy.Pattern,
+		policy.Passphrase, policy.Words, policy.Separator, policy.Capitalize, policy.Wordlist,
+	)
+	if err != nil {
+		logger.Debug("addPolicy:failed to execute statement: %s", err.Error())
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	policy.Id = int(id)
+	return nil
+}
+
+// DeletePolicy removes a policy by name and unlinks its credentials, returns sql.ErrNoRows if it does
+// not exist
+func (v *VaultStore) DeletePolicy(name string) error {
+	result, err := v.db.Exec(`DELETE FROM policies WHERE name = ?`, name)
+	if err != nil {
+		logger.Debug("deletePolicy:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPolicy fetches a policy by name regardless of case, returns sql.ErrNoRows if it does not exist
+func (v *VaultStore) GetPolicy(name string) (*model.Policy, error) {
+	query := `SELECT ` + policyColumns + ` FROM policies p WHERE p.name = ?`
+	policy, err := scanPolicy(v.db.QueryRow(query, name))
+	if err != nil && err != sql.ErrNoRows {
+		logger.Debug("getPolicy:unable to fetch policy: %s", err.Error())
+	}
+	return policy, err
+}
+
+// GetPolicies fetches every policy sorted by name, with the number of credentials outside the trash
+// linked to it
+func (v *VaultStore) GetPolicies() ([]model.Policy, error) {
+	query := `
+		SELECT ` + policyColumns + `, COUNT(c.id) FROM policies p
+		LEFT JOIN credential_policies cp ON cp.policy_id = p.id
+		LEFT JOIN credentials c ON c.id = cp.credential_id AND c.deleted_at IS NULL
+		GROUP BY p.id
+		ORDER BY p.name
+	`
+	rows, err := v.db.Query(query)
+	if err != nil {
+		logger.Debug("failed to fetch policies")
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []model.Policy{}
+	for rows.Next() {
+		var count int
+		policy, err := scanPolicy(rows, &count)
+		if err != nil {
+			logger.Debug("unable to scan policy")
+			return nil, err
+		}
+		policy.Credentials = count
+		policies = append(policies, *policy)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return policies, nil
+}
+
+// SetCredentialPolicy links a credential to a policy, replacing the policy it had
+func (v *VaultStore) SetCredentialPolicy(credentialId, policyId int) error {
+	query := `
+		INSERT INTO credential_policies (credential_id, policy_id) VALUES (?, ?)
+		ON CONFLICT (credential_id) DO UPDATE SET policy_id = excluded.policy_id
+	`
+	if _, err := v.db.Exec(query, credentialId, policyId); err != nil {
+		logger.Debug("setCredentialPolicy:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ClearCredentialPolicy unlinks a credential from its policy, returns sql.ErrNoRows if it had none
+func (v *VaultStore) ClearCredentialPolicy(credentialId int) error {
+	result, err := v.db.Exec(`DELETE FROM credential_policies WHERE credential_id = ?`, credentialId)
+	if err != nil {
+		logger.Debug("clearCredentialPolicy:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCredentialPolicy fetches the policy a credential is linked to, returns sql.ErrNoRows if it has
+// none
+func (v *VaultStore) GetCredentialPolicy(credentialId int) (*model.Policy, error) {
+	query := `
+		SELECT ` + policyColumns + ` FROM policies p
+		JOIN credential_policies cp ON cp.policy_id = p.id
+		WHERE cp.credential_id = ?
+	`
+	policy, err := scanPolicy(v.db.QueryRow(query, credentialId))
+	if err != nil && err != sql.ErrNoRows {
+		logger.Debug("getCredentialPolicy:unable to fetch policy: %s", err.Error())
+	}
+	return policy, err
+}
+
+// scanPolicy scans policyColumns followed by extra columns
+func scanPolicy(row rowScanner, extra ...any) (*model.Policy, error) {
+	var policy model.Policy
+	var createdAtStr string
+
+	err := row.Scan(append([]any{
+		&policy.Id,
+		&policy.Name,
+		&policy.Length,
+		&policy.Upper,
+		&policy.Lower,
+		&policy.Digit,
+		&policy.Symbol,
+		&policy.Require,
+		&policy.ExcludeAmbiguous,
+		&policy.Symbols,
+		&policy.NoRepeat,
+		&policy.StartWith,
+		&policy.Pattern,
+		&policy.Passphrase,
+		&policy.Words,
+		&policy.Separator,
+		&policy.Capitalize,
+		&policy.Wordlist,
+		&createdAtStr,
+	}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+	if err != nil {
+		logger.Debug("unable to parse created at time: %s", createdAtStr)
+		return nil, err
+	}
+
+	return &policy, nil
+}
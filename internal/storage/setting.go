@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"database/sql"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+)
+
+// GetSetting returns the stored value of a setting, returns sql.ErrNoRows if it has not been set
+func (v *VaultStore) GetSetting(key string) (string, error) {
+	var value string
+	err := v.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Debug("getSetting:unable to fetch setting %s: %s", key, err.Error())
+	}
+	return value, err
+}
+
+// GetSettings returns all stored settings
+func (v *VaultStore) GetSettings() (map[string]string, error) {
+	rows, err := v.db.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		logger.Debug("failed to fetch settings")
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			logger.Debug("unable to scan setting")
+			return nil, err
+		}
+		settings[key] = value
+	}
+
+	return settings, rows.Err()
+}
+
+// SetSetting stores the value of a setting, an empty value removes the setting
+func (v *VaultStore) SetSetting(key, value string) error {
+	var err error
+	if value == "" {
+		_, err = v.db.Exec(`DELETE FROM settings WHERE key = ?`, key)
+	} else {
+		_, err = v.db.Exec(`
+			INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value
+		`, key, value)
+	}
+	if err != nil {
+		logger.Debug("setSetting:unable to save setting %s: %s", key, err.Error())
+	}
+	return err
+}
@@ -23,9 +23,112 @@ type Store interface {
 	GetAllCredentials() ([]model.Credential, error)
 	GetCredentialById(id int) (*model.Credential, error)
 	GetCredentialByLabelAndUser(label, user string) (*model.Credential, error)
-	SearchCredentialByLabelOrUser(label, user string) ([]model.CredentialSummary, error)
+	SearchCredentialByLabelOrUser(label, user string, tags []string, folder string) ([]model.CredentialSummary, error)
+	SetCredentialOTP(credential *model.Credential) error
 	UpdateCredential(credential *model.Credential) error
-	UpdateCredentialAccessCount(id, delta int, accessTime time.Time) error
+
+	// Credential field functions
+	DeleteCredentialField(credentialId int, name string) error
+	GetCredentialField(credentialId int, name string) (*model.CredentialField, error)
+	GetCredentialFields(credentialId int) ([]model.CredentialField, error)
+	SetCredentialField(field *model.CredentialField) error
+
+	// Tag and folder functions
+	AddCredentialTag(credentialId int, tag string) error
+	GetTags() ([]model.Tag, error)
+	RemoveCredentialTag(credentialId int, tag string) error
+	SetCredentialFolder(credentialId int, folder string) error
+
+	// Credential URL functions
+	AddCredentialURL(credentialId int, url string) error
+	GetCredentialURLs(credentialId int) ([]string, error)
+	GetCredentialsWithURLs() ([]model.Credential, error)
+	RemoveCredentialURL(credentialId int, url string) error
+
+	// Browser extension origin allowlist functions
+	AllowNativeHostOrigin(origin string) error
+	GetNativeHostOrigins() ([]string, error)
+	IsNativeHostOriginAllowed(origin string) (bool, error)
+	RevokeNativeHostOrigin(origin string) error
+
+	// Local API client and audit functions
+	AddAPIAuditEntry(entry *model.APIAuditEntry) error
+	AddAPIClient(client *model.APIClient) error
+	DeleteAPIClient(name string) error
+	GetAPIAuditEntries(limit int) ([]model.APIAuditEntry, error)
+	GetAPIClientByTokenHash(tokenHash string) (*model.APIClient, error)
+	GetAPIClients() ([]model.APIClient, error)
+	TouchAPIClient(id int) error
+
+	// Generator policy functions
+	AddPolicy(policy *model.Policy) error
+	ClearCredentialPolicy(credentialId int) error
+	DeletePolicy(name string) error
+	GetCredentialPolicy(credentialId int) (*model.Policy, error)
+	GetPolicies() ([]model.Policy, error)
+	GetPolicy(name string) (*model.Policy, error)
+	SetCredentialPolicy(credentialId, policyId int) error
+
+	// Max age functions
+	ClearCredentialMaxAge(credentialId int) error
+	ClearTagMaxAge(tag string) error
+	GetCredentialExpiries() ([]model.CredentialExpiry, error)
+	GetCredentialExpiry(credentialId int) (*model.CredentialExpiry, error)
+	GetMaxAges() ([]model.MaxAge, error)
+	SetCredentialMaxAge(credentialId, days int) error
+	SetTagMaxAge(tag string, days int) error
+
+	// Access event functions
+	GetAccessCounts(since time.Time) ([]model.AccessCount, error)
+	GetContextFrequencies(context model.AccessContext) (map[int]model.ContextFrequency, error)
+	GetCredentialUsage(since time.Time) ([]model.CredentialUsage, error)
+	CreateQueryKey() ([]byte, error)
+	GetQueryKey() ([]byte, error)
+	GetQuerySelections(queryHash string) (model.QuerySelections, error)
+	PruneAccessEvents(before time.Time) (int, error)
+	RecordAccess(event *model.AccessEvent) error
+	RecordQuerySelection(credentialId int, prefixHashes []string, at time.Time) error
+
+	// Audit log functions
+	AppendAuditEvent(event *model.AuditEvent, hash func(event *model.AuditEvent) (string, error)) error
+	GetAuditEvents() ([]model.AuditEvent, error)
+	GetAuditHead() (int, string, error)
+
+	// Integrity functions
+	GetCredentialMACs() ([]model.CredentialMAC, error)
+	GetIntegrityRecord() (*model.IntegrityRecord, error)
+	GetKnownIntegrityCounter() (int, error)
+	SaveIntegrity(record *model.IntegrityRecord, set []model.CredentialMAC, remove []int) error
+	SetKnownIntegrityCounter(counter int) error
+
+	// Trash functions
+	GetTrashedCredentialId(label, user string) (int, error)
+	GetTrashedCredentials() ([]model.CredentialSummary, error)
+	PurgeTrashedCredentials(before time.Time) (int, error)
+	RestoreCredentialById(id int) error
+	TrashCredentialById(id int) error
+
+	// Credential history functions
+	GetCredentialHistory(credentialId int) ([]model.CredentialVersion, error)
+	GetCredentialVersion(credentialId, version int) (*model.CredentialVersion, error)
+	PruneCredentialHistory(keep int, before time.Time) error
+
+	// Pending secret functions
+	DeletePendingSecret(credentialId int) error
+	GetPendingSecret(credentialId int) (*model.PendingSecret, error)
+	SetPendingSecret(pending *model.PendingSecret) error
+
+	// Attachment functions
+	AddAttachment(attachment *model.Attachment, next func() (nonce, data []byte, err error)) error
+	DeleteAttachment(attachmentId int) error
+	GetAttachment(credentialId int, name string) (*model.Attachment, error)
+	GetAttachments(credentialId int) ([]model.Attachment, error)
+	ReadAttachmentChunks(attachmentId int, fn func(index int, nonce, data []byte) error) error
+
+	// Setting functions
+	GetSetting(key string) (string, error)
+	GetSettings() (map[string]string, error)
+	SetSetting(key, value string) error
 
 	// Data Store functions
 	CloseStore() error
@@ -33,6 +136,9 @@ type Store interface {
 
 type VaultStore struct {
 	db *sql.DB
+
+	// path of the database file, the integrity counter and query key are kept next to it
+	path string
 }
 
 // InitializeStore establishes connection with database
@@ -51,27 +157,41 @@ func InitializeStore() (Store, error) {
 		return nil, err
 	}
 
-	dbFilePath := filepath.Join(koshDir, "kosh.db")
+	store, err := OpenStore(filepath.Join(koshDir, "kosh.db"))
+	if err != nil {
+		logger.Error("failed to connect to database: %s", err.Error())
+		return nil, err
+	}
+	return store, nil
+}
 
+// OpenStore connects to the vault database at path and brings it up to the latest schema
+func OpenStore(dbFilePath string) (Store, error) {
 	db, err := sql.Open("sqlite", dbFilePath)
-
 	if err != nil {
-		logger.Error("failed to connect to database")
+		return nil, err
 	}
 
 	// Set pragmas for this connection
 	if err := initDatabase(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Bring existing vaults up to the latest schema
+	if err := migrateDatabase(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &VaultStore{db}, nil
+	return &VaultStore{db: db, path: dbFilePath}, nil
 }
 
 // CloseStore closes existing connection to the database
 func (v *VaultStore) CloseStore() error {
 	if v != nil {
 		if err := v.db.Close(); err != nil {
-			logger.Error("failed to close database connection")
+			logger.Debug("closeStore:failed to close database connection: %s", err.Error())
 			return err
 		}
 	}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// credentialTagsColumn selects the tags of a credential as a comma separated list, tag names never
+// contain commas. Use with splitTags.
+const credentialTagsColumn = `COALESCE((
+	SELECT GROUP_CONCAT(t.name, ',') FROM credential_tags ct JOIN tags t ON t.id = ct.tag_id
+	WHERE ct.credential_id = credentials.id
+), '')`
+
+// AddCredentialTag tags a credential, creating the tag if needed. Adding a tag twice is a no-op.
+func (v *VaultStore) AddCredentialTag(credentialId int, tag string) error {
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Debug("addCredentialTag:failed to start transaction: %s", err.Error())
+		return err
+	}
+	defer transaction.Rollback()
+
+	if _, err := transaction.Exec(`INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`, tag); err != nil {
+		logger.Debug("addCredentialTag:failed to insert tag: %s", err.Error())
+		return err
+	}
+
+	query := `
+		INSERT INTO credential_tags (credential_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?
+		ON CONFLICT (credential_id, tag_id) DO NOTHING
+	`
+	if _, err := transaction.Exec(query, credentialId, tag); err != nil {
+		logger.Debug("addCredentialTag:failed to tag credential: %s", err.Error())
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// RemoveCredentialTag removes a tag from a credential and drops the tag once no credential carries
+// it, returns sql.ErrNoRows if the credential does not have the tag
+func (v *VaultStore) RemoveCredentialTag(credentialId int, tag string) error {
+	transaction, err := v.db.Begin()
+	if err != nil {
+		logger.Debug("removeCredentialTag:failed to start transaction: %s", err.Error())
+		return err
+	}
+	defer transaction.Rollback()
+
+	query := `
+		DELETE FROM credential_tags
+		WHERE credential_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)
+	`
+	result, err := transaction.Exec(query, credentialId, tag)
+	if err != nil {
+		logger.Debug("removeCredentialTag:failed to untag credential: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+
+	query = `DELETE FROM tags WHERE name = ? AND NOT EXISTS (SELECT 1 FROM credential_tags WHERE tag_id = tags.id)`
+	if _, err := transaction.Exec(query, tag); err != nil {
+		logger.Debug("removeCredentialTag:failed to drop unused tag: %s", err.Error())
+		return err
+	}
+
+	return transaction.Commit()
+}
+
+// GetTags fetches every tag in use with the number of credentials outside the trash carrying it,
+// ordered by name
+func (v *VaultStore) GetTags() ([]model.Tag, error) {
+	query := `
+		SELECT t.name, COUNT(*) FROM tags t
+		JOIN credential_tags ct ON ct.tag_id = t.id
+		JOIN credentials c ON c.id = ct.credential_id
+		WHERE c.deleted_at IS NULL
+		GROUP BY t.id
+		ORDER BY t.name
+	`
+
+	rows, err := v.db.Query(query)
+	if err != nil {
+		logger.Debug("failed to fetch tags")
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []model.Tag{}
+	for rows.Next() {
+		var tag model.Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			logger.Debug("unable to scan tag")
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return tags, nil
+}
+
+// SetCredentialFolder moves a credential to a folder, an empty folder moves it to the root. Returns
+// sql.ErrNoRows if the credential does not exist.
+func (v *VaultStore) SetCredentialFolder(credentialId int, folder string) error {
+	result, err := v.db.Exec(`UPDATE credentials SET folder = ? WHERE id = ? AND deleted_at IS NULL`, folder, credentialId)
+	if err != nil {
+		logger.Debug("setCredentialFolder:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// splitTags parses the result of credentialTagsColumn into a sorted list
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	list := strings.Split(tags, ",")
+	slices.Sort(list)
+	return list
+}
+
+// escapeLike escapes the LIKE wildcards of a string for use with ESCAPE '\'
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
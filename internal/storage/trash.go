@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// TrashCredentialById moves a credential to the trash, returns sql.ErrNoRows if there is no such
+// credential outside the trash
+func (v *VaultStore) TrashCredentialById(id int) error {
+	query := `UPDATE credentials SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL`
+	result, err := v.db.Exec(query, id)
+	if err != nil {
+		logger.Debug("trashCredentialById:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		logger.Debug("trashCredentialById:invalid credential id %d", id)
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTrashedCredentialId returns the id of the trashed credential with the given label and user, or
+// sql.ErrNoRows if there is none
+func (v *VaultStore) GetTrashedCredentialId(label, user string) (int, error) {
+	var id int
+	query := `SELECT id FROM credentials WHERE label = ? AND user = ? AND deleted_at IS NOT NULL`
+	if err := v.db.QueryRow(query, label, user).Scan(&id); err != nil {
+		logger.Debug("getTrashedCredentialId:no trashed credential %s (%s): %s", label, user, err.Error())
+		return 0, err
+	}
+	return id, nil
+}
+
+// RestoreCredentialById takes a credential out of the trash, returns sql.ErrNoRows if it is not in
+// the trash
+func (v *VaultStore) RestoreCredentialById(id int) error {
+	query := `UPDATE credentials SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`
+	result, err := v.db.Exec(query, id)
+	if err != nil {
+		logger.Debug("restoreCredentialById:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		logger.Debug("restoreCredentialById:credential %d is not in trash", id)
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTrashedCredentials fetches the credentials in the trash, most recently deleted first
+func (v *VaultStore) GetTrashedCredentials() ([]model.CredentialSummary, error) {
+	query := `
+		SELECT id, label, user, access_count, created_at, updated_at, accessed_at, deleted_at FROM credentials
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	rows, err := v.db.Query(query)
+	if err != nil {
+		logger.Debug("failed to fetch trashed credentials")
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []model.CredentialSummary{}
+	for rows.Next() {
+		var credential model.CredentialSummary
+		var createdAtStr, updatedAtStr, accessedAtStr, deletedAtStr string
+
+		if err := rows.Scan(
+			&credential.Id,
+			&credential.Label,
+			&credential.User,
+			&credential.AccessCount,
+			&createdAtStr,
+			&updatedAtStr,
+			&accessedAtStr,
+			&deletedAtStr,
+		); err != nil {
+			logger.Debug("unable to scan row")
+			return nil, err
+		}
+
+		credential.CreatedAt, err = time.Parse(time.RFC3339, createdAtStr)
+		if err != nil {
+			logger.Debug("unable to parse created at time: %s", createdAtStr)
+			return nil, err
+		}
+
+		credential.UpdatedAt, err = time.Parse(time.RFC3339, updatedAtStr)
+		if err != nil {
+			logger.Debug("unable to parse updated at time: %s", updatedAtStr)
+			return nil, err
+		}
+
+		credential.AccessedAt, err = time.Parse(time.RFC3339, accessedAtStr)
+		if err != nil {
+			logger.Debug("unable to parse accessed at time: %s", accessedAtStr)
+			return nil, err
+		}
+
+		credential.DeletedAt, err = time.Parse(time.RFC3339, deletedAtStr)
+		if err != nil {
+			logger.Debug("unable to parse deleted at time: %s", deletedAtStr)
+			return nil, err
+		}
+
+		credentials = append(credentials, credential)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return credentials, nil
+}
+
+// PurgeTrashedCredentials permanently deletes the credentials moved to the trash before the given
+// time, a zero time empties the whole trash. Returns the number of deleted credentials.
+func (v *VaultStore) PurgeTrashedCredentials(before time.Time) (int, error) {
+	query := `DELETE FROM credentials WHERE deleted_at IS NOT NULL`
+	params := []any{}
+	if !before.IsZero() {
+		query += ` AND deleted_at < ?`
+		params = append(params, before.UTC().Format(time.DateTime))
+	}
+
+	result, err := v.db.Exec(query, params...)
+	if err != nil {
+		logger.Debug("purgeTrashedCredentials:failed to execute statement: %s", err.Error())
+		return 0, err
+	}
+
+	purged, _ := result.RowsAffected()
+	return int(purged), nil
+}
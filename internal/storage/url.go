@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"database/sql"
+
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// AddCredentialURL saves a URL with a credential, adding the same URL twice is a no-op
+func (v *VaultStore) AddCredentialURL(credentialId int, url string) error {
+	query := `INSERT INTO credential_urls (credential_id, url) VALUES (?, ?) ON CONFLICT (credential_id, url) DO NOTHING`
+	if _, err := v.db.Exec(query, credentialId, url); err != nil {
+		logger.Debug("addCredentialURL:failed to execute statement: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// RemoveCredentialURL removes a URL from a credential, returns sql.ErrNoRows if it was not saved
+func (v *VaultStore) RemoveCredentialURL(credentialId int, url string) error {
+	result, err := v.db.Exec(`DELETE FROM credential_urls WHERE credential_id = ? AND url = ?`, credentialId, url)
+	if err != nil {
+		logger.Debug("removeCredentialURL:failed to execute statement: %s", err.Error())
+		return err
+	}
+	if affectedRows, _ := result.RowsAffected(); affectedRows != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetCredentialURLs fetches the URLs of a credential in the order they were added
+func (v *VaultStore) GetCredentialURLs(credentialId int) ([]string, error) {
+	rows, err := v.db.Query(`SELECT url FROM credential_urls WHERE credential_id = ? ORDER BY created_at, url`, credentialId)
+	if err != nil {
+		logger.Debug("failed to fetch credential urls")
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := []string{}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			logger.Debug("unable to scan credential url")
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	return urls, nil
+}
+
+// GetCredentialsWithURLs fetches every credential outside the trash that has at least one URL,
+// with its URLs loaded
+func (v *VaultStore) GetCredentialsWithURLs() ([]model.Credential, error) {
+	credentials, err := v.GetAllCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := v.db.Query(`SELECT credential_id, url FROM credential_urls ORDER BY created_at, url`)
+	if err != nil {
+		logger.Debug("failed to fetch credential urls")
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := map[int][]string{}
+	for rows.Next() {
+		var credentialId int
+		var url string
+		if err := rows.Scan(&credentialId, &url); err != nil {
+			logger.Debug("unable to scan credential url")
+			return nil, err
+		}
+		urls[credentialId] = append(urls[credentialId], url)
+	}
+
+	if rows.Err() != nil {
+		logger.Debug("error iterating over rows")
+		return nil, rows.Err()
+	}
+
+	withURLs := []model.Credential{}
+	for _, credential := range credentials {
+		if len(urls[credential.Id]) > 0 {
+			credential.URLs = urls[credential.Id]
+			withURLs = append(withURLs, credential)
+		}
+	}
+	return withURLs, nil
+}
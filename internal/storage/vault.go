@@ -23,7 +23,7 @@ func (v *VaultStore) IsVaultInitialized() (bool, error) {
 	}
 
 	if err != nil {
-		logger.Error("unable to fetch table name from database")
+		logger.Debug("isVaultInitialized:unable to fetch table name from database: %s", err.Error())
 		return false, err
 	}
 
@@ -32,7 +32,7 @@ func (v *VaultStore) IsVaultInitialized() (bool, error) {
 	query = `SELECT COUNT(*) FROM vault`
 	err = v.db.QueryRow(query).Scan(&count)
 	if err != nil {
-		logger.Error("failed to count the number of records in vault table")
+		logger.Debug("isVaultInitialized:failed to count the number of records in vault table: %s", err.Error())
 		return false, err
 	}
 	logger.Debug("found %d vault", count)
@@ -141,18 +141,19 @@ func (v *VaultStore) InitializeVault(vault model.Vault) error {
 		return err
 	}
 
-	return nil
+	// apply schema changes on top of the base tables
+	return migrateDatabase(v.db)
 }
 
 func (v *VaultStore) GetVaultInfo() (*model.Vault, error) {
 	initialized, err := v.IsVaultInitialized()
 	if err != nil {
-		logger.Error("error checking vault initialized status")
+		logger.Debug("getVaultInfo:error checking vault initialized status: %s", err.Error())
 		return nil, err
 	}
 
 	if !initialized {
-		logger.Error("vault is not initialized")
+		logger.Debug("getVaultInfo:vault is not initialized")
 		return nil, fmt.Errorf("vault is not initialized")
 	}
 
@@ -164,12 +165,12 @@ func (v *VaultStore) GetVaultInfo() (*model.Vault, error) {
 	`).Scan(&vault.PublicKey, &vault.Secret, &vault.Nonce, &vault.Salt)
 
 	if err == sql.ErrNoRows {
-		logger.Error("vault is not initialized")
+		logger.Debug("getVaultInfo:vault is not initialized")
 		return nil, err
 	}
 
 	if err != nil {
-		logger.Error("failed to get vault info")
+		logger.Debug("getVaultInfo:failed to get vault info: %s", err.Error())
 		return nil, err
 	}
 
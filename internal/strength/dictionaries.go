@@ -0,0 +1,83 @@
+package strength
+
+import (
+	"embed"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// frequency lists, one lowercase word per line, most common first
+//
+//go:embed dictionaries/*.txt
+var dictionaryFiles embed.FS
+
+// names of the frequency lists, also used to pick the feedback of a dictionary match
+const (
+	dictionaryPasswords  = "passwords"
+	dictionaryEnglish    = "english"
+	dictionaryNames      = "names"
+	dictionarySurnames   = "surnames"
+	dictionaryUserInputs = "user_inputs"
+)
+
+// rankedDictionary maps words to their rank in a frequency list, 1 being the most common
+type rankedDictionary struct {
+	name  string
+	ranks map[string]int
+
+	// length of the longest word in runes, longer tokens are not looked up
+	maxLength int
+}
+
+var (
+	loadDictionaries sync.Once
+	dictionaries     []*rankedDictionary
+)
+
+// frequencyDictionaries parses the embedded lists on first use
+func frequencyDictionaries() []*rankedDictionary {
+	loadDictionaries.Do(func() {
+		for _, name := range []string{dictionaryPasswords, dictionaryEnglish, dictionaryNames, dictionarySurnames} {
+			data, err := dictionaryFiles.ReadFile("dictionaries/" + name + ".txt")
+			if err != nil {
+				// the lists are compiled in, a missing one is a build error
+				panic(err)
+			}
+			dictionaries = append(dictionaries, newRankedDictionary(name, strings.Split(string(data), "\n")))
+		}
+	})
+	return dictionaries
+}
+
+func newRankedDictionary(name string, words []string) *rankedDictionary {
+	dictionary := &rankedDictionary{name: name, ranks: make(map[string]int, len(words))}
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		if _, ok := dictionary.ranks[word]; ok {
+			continue
+		}
+		dictionary.ranks[word] = len(dictionary.ranks) + 1
+		dictionary.maxLength = max(dictionary.maxLength, utf8.RuneCountInString(word))
+	}
+	return dictionary
+}
+
+// userInputsDictionary ranks words an attacker knows about the account, like the label and user of a
+// credential. Inputs are also split into their words, so "alice@example.com" matches "alice".
+func userInputsDictionary(inputs []string) *rankedDictionary {
+	var words []string
+	for _, input := range inputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		words = append(words, input)
+		for _, part := range strings.FieldsFunc(input, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if utf8.RuneCountInString(part) >= 3 {
+				words = append(words, part)
+			}
+		}
+	}
+	return newRankedDictionary(dictionaryUserInputs, words)
+}
@@ -0,0 +1,87 @@
+package strength
+
+import "strings"
+
+// keyboardRow is a row of keys, each key the unshifted and shifted character, starting offset keys
+// from the left edge of the layout
+type keyboardRow struct {
+	offset int
+	keys   string
+}
+
+// qwerty rows are slanted, every row below the digits is shifted half a key to the right
+var qwertyRows = []keyboardRow{
+	{0, "`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+"},
+	{1, "qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|"},
+	{1, "aA sS dD fF gG hH jJ kK lL ;: '\""},
+	{1, "zZ xX cC vV bB nN mM ,< .> /?"},
+}
+
+// keypad rows are aligned, the 0 key is counted as one key wide
+var keypadRows = []keyboardRow{
+	{1, "/ * -"},
+	{0, "7 8 9 +"},
+	{0, "4 5 6"},
+	{0, "1 2 3"},
+	{1, "0 ."},
+}
+
+// characters typed with shift on a qwerty keyboard
+const shiftedChars = "~!@#$%^&*()_+QWERTYUIOP{}|ASDFGHJKL:\"ZXCVBNM<>?"
+
+// keyboardGraph maps every character to the keys around it, in a fixed direction order with "" where
+// there is no key, so a change of direction in a walk is a turn
+type keyboardGraph struct {
+	name      string
+	adjacency map[rune][]string
+	shifted   bool
+
+	// number of characters and their average number of neighbours, for the guess estimate
+	startingPositions float64
+	averageDegree     float64
+}
+
+var keyboardGraphs = []*keyboardGraph{
+	buildGraph("qwerty", qwertyRows, true),
+	buildGraph("keypad", keypadRows, false),
+}
+
+func buildGraph(name string, rows []keyboardRow, slanted bool) *keyboardGraph {
+	type position struct{ x, y int }
+	keys := map[position]string{}
+	for y, row := range rows {
+		for x, key := range strings.Fields(row.keys) {
+			keys[position{row.offset + x, y}] = key
+		}
+	}
+
+	graph := &keyboardGraph{name: name, adjacency: map[rune][]string{}, shifted: slanted}
+	degrees := 0
+	for at, key := range keys {
+		var around []position
+		if slanted {
+			around = []position{{at.x - 1, at.y}, {at.x, at.y - 1}, {at.x + 1, at.y - 1}, {at.x + 1, at.y}, {at.x, at.y + 1}, {at.x - 1, at.y + 1}}
+		} else {
+			around = []position{
+				{at.x - 1, at.y}, {at.x - 1, at.y - 1}, {at.x, at.y - 1}, {at.x + 1, at.y - 1},
+				{at.x + 1, at.y}, {at.x + 1, at.y + 1}, {at.x, at.y + 1}, {at.x - 1, at.y + 1},
+			}
+		}
+
+		neighbours := make([]string, len(around))
+		count := 0
+		for i, p := range around {
+			neighbours[i] = keys[p]
+			if neighbours[i] != "" {
+				count++
+			}
+		}
+		for _, char := range key {
+			graph.adjacency[char] = neighbours
+			degrees += count
+		}
+	}
+	graph.startingPositions = float64(len(graph.adjacency))
+	graph.averageDegree = float64(degrees) / graph.startingPositions
+	return graph
+}
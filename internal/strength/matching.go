@@ -0,0 +1,591 @@
+package strength
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pattern is the kind of guess that covers a part of a secret
+type Pattern string
+
+const (
+	PatternDictionary Pattern = "dictionary"
+	PatternSpatial    Pattern = "spatial"
+	PatternRepeat     Pattern = "repeat"
+	PatternSequence   Pattern = "sequence"
+	PatternRegex      Pattern = "regex"
+	PatternDate       Pattern = "date"
+	PatternBruteforce Pattern = "bruteforce"
+)
+
+// Match is a part of a secret, from rune I to rune J, that fits a pattern. Only the fields of its
+// pattern are set.
+type Match struct {
+	Pattern Pattern
+	I, J    int
+	Token   string
+
+	// dictionary
+	MatchedWord string
+	Rank        int
+	Dictionary  string
+	Reversed    bool
+	L33t        bool
+	Sub         map[rune]rune
+
+	// spatial
+	Graph        string
+	Turns        int
+	ShiftedCount int
+
+	// repeat
+	BaseToken   string
+	BaseGuesses float64
+	RepeatCount int
+
+	// sequence
+	SequenceName  string
+	SequenceSpace int
+	Ascending     bool
+
+	// regex
+	RegexName string
+
+	// date
+	Separator        string
+	Year, Month, Day int
+
+	// guesses needed for this part alone, set while scoring
+	Guesses float64
+}
+
+// matcher finds every match of a secret against the frequency lists and the user inputs
+type matcher struct {
+	dictionaries []*rankedDictionary
+}
+
+func newMatcher(userInputs []string) *matcher {
+	return &matcher{dictionaries: append(slices.Clone(frequencyDictionaries()), userInputsDictionary(userInputs))}
+}
+
+// omnimatch returns all matches of every pattern, ordered by position
+func (m *matcher) omnimatch(password []rune) []*Match {
+	var matches []*Match
+	matches = append(matches, m.dictionaryMatch(password)...)
+	matches = append(matches, m.reverseDictionaryMatch(password)...)
+	matches = append(matches, m.l33tMatch(password)...)
+	matches = append(matches, spatialMatch(password)...)
+	matches = append(matches, m.repeatMatch(password)...)
+	matches = append(matches, sequenceMatch(password)...)
+	matches = append(matches, regexMatch(password)...)
+	matches = append(matches, dateMatch(password)...)
+	slices.SortStableFunc(matches, func(a, b *Match) int {
+		return cmp.Or(cmp.Compare(a.I, b.I), cmp.Compare(a.J, b.J))
+	})
+	return matches
+}
+
+func (m *matcher) dictionaryMatch(password []rune) []*Match {
+	var matches []*Match
+	lower := toLower(password)
+	for _, dictionary := range m.dictionaries {
+		for i := range password {
+			for j := i; j < len(password) && j-i < dictionary.maxLength; j++ {
+				word := string(lower[i : j+1])
+				if rank, ok := dictionary.ranks[word]; ok {
+					matches = append(matches, &Match{
+						Pattern:     PatternDictionary,
+						I:           i,
+						J:           j,
+						Token:       string(password[i : j+1]),
+						MatchedWord: word,
+						Rank:        rank,
+						Dictionary:  dictionary.name,
+					})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func (m *matcher) reverseDictionaryMatch(password []rune) []*Match {
+	reversed := slices.Clone(password)
+	slices.Reverse(reversed)
+
+	matches := m.dictionaryMatch(reversed)
+	for _, match := range matches {
+		token := []rune(match.Token)
+		slices.Reverse(token)
+		match.Token = string(token)
+		match.Reversed = true
+		match.I, match.J = len(password)-1-match.J, len(password)-1-match.I
+	}
+	return matches
+}
+
+// l33tLetters are the letters a character commonly stands in for
+var l33tLetters = map[rune][]rune{
+	'4': {'a'}, '@': {'a'},
+	'8': {'b'},
+	'(': {'c'}, '{': {'c'}, '[': {'c'}, '<': {'c'},
+	'3': {'e'},
+	'6': {'g'}, '9': {'g'},
+	'1': {'i', 'l'}, '!': {'i'}, '|': {'i', 'l'},
+	'7': {'l', 't'},
+	'0': {'o'},
+	'$': {'s'}, '5': {'s'},
+	'+': {'t'},
+	'%': {'x'},
+	'2': {'z'},
+}
+
+// maxL33tSubs bounds the substitutions tried, a secret full of digits and symbols has many
+const maxL33tSubs = 100
+
+// l33tSubs lists the ways to read the l33t characters of a secret back as letters
+func l33tSubs(password []rune) []map[rune]rune {
+	var chars []rune
+	for _, r := range password {
+		if _, ok := l33tLetters[r]; ok && !slices.Contains(chars, r) {
+			chars = append(chars, r)
+		}
+	}
+	if len(chars) == 0 {
+		return nil
+	}
+	slices.Sort(chars)
+
+	subs := []map[rune]rune{{}}
+	for _, char := range chars {
+		var next []map[rune]rune
+	extend:
+		for _, sub := range subs {
+			for _, letter := range l33tLetters[char] {
+				extended := maps.Clone(sub)
+				extended[char] = letter
+				next = append(next, extended)
+				if len(next) == maxL33tSubs {
+					break extend
+				}
+			}
+		}
+		subs = next
+	}
+	return subs
+}
+
+func (m *matcher) l33tMatch(password []rune) []*Match {
+	var matches []*Match
+	seen := map[string]bool{}
+	for _, sub := range l33tSubs(password) {
+		subbed := make([]rune, len(password))
+		for i, r := range password {
+			if letter, ok := sub[r]; ok {
+				subbed[i] = letter
+			} else {
+				subbed[i] = r
+			}
+		}
+
+		for _, match := range m.dictionaryMatch(subbed) {
+			token := password[match.I : match.J+1]
+			// only keep matches where a substitution was needed
+			if len(token) <= 1 || string(toLower(token)) == match.MatchedWord {
+				continue
+			}
+			key := fmt.Sprintf("%d:%d:%s:%s", match.I, match.J, match.Dictionary, match.MatchedWord)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			match.Token = string(token)
+			match.L33t = true
+			match.Sub = map[rune]rune{}
+			for _, r := range token {
+				if letter, ok := sub[r]; ok {
+					match.Sub[r] = letter
+				}
+			}
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+func spatialMatch(password []rune) []*Match {
+	var matches []*Match
+	for _, graph := range keyboardGraphs {
+		matches = append(matches, spatialMatchGraph(password, graph)...)
+	}
+	return matches
+}
+
+// spatialMatchGraph finds walks of three or more adjacent keys, counting turns and shifted keys
+func spatialMatchGraph(password []rune, graph *keyboardGraph) []*Match {
+	var matches []*Match
+	for i := 0; i < len(password)-1; {
+		j := i + 1
+		lastDirection := -1
+		turns := 0
+		shiftedCount := 0
+		if graph.shifted && strings.ContainsRune(shiftedChars, password[i]) {
+			shiftedCount = 1
+		}
+
+		for {
+			found := false
+			if j < len(password) {
+				for direction, neighbour := range graph.adjacency[password[j-1]] {
+					index := strings.IndexRune(neighbour, password[j])
+					if index < 0 {
+						continue
+					}
+					found = true
+					if index == 1 {
+						shiftedCount++
+					}
+					if direction != lastDirection {
+						turns++
+						lastDirection = direction
+					}
+					break
+				}
+			}
+			if found {
+				j++
+				continue
+			}
+
+			if j-i > 2 {
+				matches = append(matches, &Match{
+					Pattern:      PatternSpatial,
+					I:            i,
+					J:            j - 1,
+					Token:        string(password[i:j]),
+					Graph:        graph.name,
+					Turns:        turns,
+					ShiftedCount: shiftedCount,
+				})
+			}
+			i = j
+			break
+		}
+	}
+	return matches
+}
+
+// repeatMatch finds a token repeated back to back, like "aaa" or "abcabc". At each position the
+// longest run is taken and guessed as its shortest base times the number of repeats.
+func (m *matcher) repeatMatch(password []rune) []*Match {
+	var matches []*Match
+	for i := 0; i < len(password); {
+		start, length := nextRepeat(password, i)
+		if length == 0 {
+			break
+		}
+		token := password[start : start+length]
+		size := period(token)
+		base := token[:size]
+
+		analysis := mostGuessableMatchSequence(base, m.omnimatch(base))
+		matches = append(matches, &Match{
+			Pattern:     PatternRepeat,
+			I:           start,
+			J:           start + length - 1,
+			Token:       string(token),
+			BaseToken:   string(base),
+			BaseGuesses: analysis.guesses,
+			RepeatCount: length / size,
+		})
+		i = start + length
+	}
+	return matches
+}
+
+// nextRepeat returns the first position from i where a base repeats at least twice and the length
+// of the longest such run there, 0 when there is none
+func nextRepeat(password []rune, from int) (int, int) {
+	for i := from; i < len(password); i++ {
+		length := 0
+		for size := 1; size <= (len(password)-i)/2; size++ {
+			if count := repeats(password[i:], size); count >= 2 {
+				length = max(length, count*size)
+			}
+		}
+		if length > 0 {
+			return i, length
+		}
+	}
+	return 0, 0
+}
+
+// repeats counts how often the first size runes repeat back to back
+func repeats(password []rune, size int) int {
+	count := 1
+	for (count+1)*size <= len(password) && slices.Equal(password[:size], password[count*size:(count+1)*size]) {
+		count++
+	}
+	return count
+}
+
+// period is the length of the shortest base the token is made of
+func period(token []rune) int {
+	for size := 1; size <= len(token)/2; size++ {
+		if len(token)%size == 0 && repeats(token, size)*size == len(token) {
+			return size
+		}
+	}
+	return len(token)
+}
+
+// maxSequenceDelta is the largest step between characters still taken as a sequence, like "aceg"
+const maxSequenceDelta = 5
+
+// sequenceMatch finds runs of characters with the same code point step, like "abcd", "9753" or "zyx"
+func sequenceMatch(password []rune) []*Match {
+	if len(password) <= 1 {
+		return nil
+	}
+
+	var matches []*Match
+	add := func(i, j int, delta rune) {
+		absolute := delta
+		if absolute < 0 {
+			absolute = -absolute
+		}
+		if (j-i <= 1 && absolute != 1) || absolute == 0 || absolute > maxSequenceDelta {
+			return
+		}
+
+		token := string(password[i : j+1])
+		name, space := "unicode", 26
+		switch {
+		case allIn(token, 'a', 'z'):
+			name, space = "lower", 26
+		case allIn(token, 'A', 'Z'):
+			name, space = "upper", 26
+		case allIn(token, '0', '9'):
+			name, space = "digits", 10
+		}
+		matches = append(matches, &Match{
+			Pattern:       PatternSequence,
+			I:             i,
+			J:             j,
+			Token:         token,
+			SequenceName:  name,
+			SequenceSpace: space,
+			Ascending:     delta > 0,
+		})
+	}
+
+	i := 0
+	lastDelta := password[1] - password[0]
+	for k := 2; k < len(password); k++ {
+		delta := password[k] - password[k-1]
+		if delta == lastDelta {
+			continue
+		}
+		add(i, k-1, lastDelta)
+		i = k - 1
+		lastDelta = delta
+	}
+	add(i, len(password)-1, lastDelta)
+	return matches
+}
+
+func allIn(token string, low, high rune) bool {
+	for _, r := range token {
+		if r < low || r > high {
+			return false
+		}
+	}
+	return true
+}
+
+var recentYear = regexp.MustCompile(`19\d\d|20[0-4]\d`)
+
+// regexMatch finds years, people put the year they were born or signed up in their secrets
+func regexMatch(password []rune) []*Match {
+	var matches []*Match
+	text := string(password)
+	for _, loc := range recentYear.FindAllStringIndex(text, -1) {
+		i := utf8.RuneCountInString(text[:loc[0]])
+		matches = append(matches, &Match{
+			Pattern:   PatternRegex,
+			I:         i,
+			J:         i + utf8.RuneCountInString(text[loc[0]:loc[1]]) - 1,
+			Token:     text[loc[0]:loc[1]],
+			RegexName: "recent_year",
+		})
+	}
+	return matches
+}
+
+const (
+	dateMinYear = 1000
+	dateMaxYear = 2050
+)
+
+// dateSplits are the ways to cut a date without separators into day, month and year, by its length
+var dateSplits = map[int][][2]int{
+	4: {{1, 2}, {2, 3}},
+	5: {{1, 3}, {2, 3}},
+	6: {{1, 2}, {2, 4}, {4, 5}},
+	7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+	8: {{2, 4}, {4, 6}},
+}
+
+var (
+	dateWithoutSeparator = regexp.MustCompile(`^\d{4,8}$`)
+	dateWithSeparator    = regexp.MustCompile(`^(\d{1,4})([\s/\\_.-])(\d{1,2})([\s/\\_.-])(\d{1,4})$`)
+)
+
+type dmy struct{ day, month, year int }
+
+// dateMatch finds dates like 13.5.1985, 1985-05-13, 130585 or 5131985
+func dateMatch(password []rune) []*Match {
+	var matches []*Match
+
+	for i := 0; i+3 < len(password); i++ {
+		for j := i + 3; j <= i+7 && j < len(password); j++ {
+			token := string(password[i : j+1])
+			if !dateWithoutSeparator.MatchString(token) {
+				continue
+			}
+
+			var best *dmy
+			for _, split := range dateSplits[len(token)] {
+				a, _ := strconv.Atoi(token[:split[0]])
+				b, _ := strconv.Atoi(token[split[0]:split[1]])
+				c, _ := strconv.Atoi(token[split[1]:])
+				date := mapIntsToDMY(a, b, c)
+				// prefer the reading closest to now
+				if date != nil && (best == nil || yearDistance(date.year) < yearDistance(best.year)) {
+					best = date
+				}
+			}
+			if best == nil {
+				continue
+			}
+			matches = append(matches, &Match{
+				Pattern: PatternDate, I: i, J: j, Token: token,
+				Year: best.year, Month: best.month, Day: best.day,
+			})
+		}
+	}
+
+	for i := 0; i+5 < len(password); i++ {
+		for j := i + 5; j <= i+9 && j < len(password); j++ {
+			token := string(password[i : j+1])
+			parts := dateWithSeparator.FindStringSubmatch(token)
+			if parts == nil || parts[2] != parts[4] {
+				continue
+			}
+			a, _ := strconv.Atoi(parts[1])
+			b, _ := strconv.Atoi(parts[3])
+			c, _ := strconv.Atoi(parts[5])
+			date := mapIntsToDMY(a, b, c)
+			if date == nil {
+				continue
+			}
+			matches = append(matches, &Match{
+				Pattern: PatternDate, I: i, J: j, Token: token, Separator: parts[2],
+				Year: date.year, Month: date.month, Day: date.day,
+			})
+		}
+	}
+
+	// drop dates inside longer dates, "1985" in "13.5.1985" says nothing new
+	return slices.DeleteFunc(matches, func(match *Match) bool {
+		for _, other := range matches {
+			if other != match && other.I <= match.I && other.J >= match.J {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// mapIntsToDMY reads three numbers as a date, with the year first or last and day and month in
+// either order. Two digit years are taken as 1951 to 2050.
+func mapIntsToDMY(a, b, c int) *dmy {
+	if b > 31 || b <= 0 {
+		return nil
+	}
+	over12, over31, under1 := 0, 0, 0
+	for _, n := range []int{a, b, c} {
+		if (n > 99 && n < dateMinYear) || n > dateMaxYear {
+			return nil
+		}
+		if n > 31 {
+			over31++
+		}
+		if n > 12 {
+			over12++
+		}
+		if n <= 0 {
+			under1++
+		}
+	}
+	if over31 >= 2 || over12 == 3 || under1 >= 2 {
+		return nil
+	}
+
+	splits := [][3]int{{c, a, b}, {a, b, c}}
+	for _, split := range splits {
+		if split[0] >= dateMinYear && split[0] <= dateMaxYear {
+			day, month, ok := mapIntsToDM(split[1], split[2])
+			if !ok {
+				return nil
+			}
+			return &dmy{day, month, split[0]}
+		}
+	}
+	for _, split := range splits {
+		if day, month, ok := mapIntsToDM(split[1], split[2]); ok {
+			year := split[0]
+			switch {
+			case year > 99:
+			case year > 50:
+				year += 1900
+			default:
+				year += 2000
+			}
+			return &dmy{day, month, year}
+		}
+	}
+	return nil
+}
+
+func mapIntsToDM(a, b int) (int, int, bool) {
+	for _, dm := range [][2]int{{a, b}, {b, a}} {
+		if dm[0] >= 1 && dm[0] <= 31 && dm[1] >= 1 && dm[1] <= 12 {
+			return dm[0], dm[1], true
+		}
+	}
+	return 0, 0, false
+}
+
+func yearDistance(year int) int {
+	if year > referenceYear {
+		return year - referenceYear
+	}
+	return referenceYear - year
+}
+
+func toLower(password []rune) []rune {
+	lower := make([]rune, len(password))
+	for i, r := range password {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
@@ -0,0 +1,328 @@
+package strength
+
+import (
+	"cmp"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// guesses per character of a part no pattern covers
+	bruteforceCardinality = 10
+
+	// every extra part of a sequence costs at least this many guesses, so a long password is not
+	// scored as many short brute forced pieces
+	minGuessesBeforeGrowingSequence = 10000
+
+	// a part of a longer secret is never cheaper than this
+	minSubmatchGuessesSingleChar = 10
+	minSubmatchGuessesMultiChar  = 50
+
+	// years are guessed at least this far around the reference year
+	minYearSpace = 20
+)
+
+// referenceYear is the year dates and years are guessed around
+var referenceYear = time.Now().Year()
+
+// analysis is the cheapest way to guess a secret as a sequence of matches covering all of it
+type analysis struct {
+	guesses  float64
+	sequence []*Match
+}
+
+// mostGuessableMatchSequence picks the non-overlapping matches, padded with brute forced parts, that
+// guess the whole secret with the fewest guesses. For every end position k and sequence length l it
+// keeps the best last match, the product of the guesses so far and the total with the l! orderings
+// and the growth penalty of a sequence that long.
+func mostGuessableMatchSequence(password []rune, matches []*Match) analysis {
+	n := len(password)
+	if n == 0 {
+		return analysis{guesses: 1}
+	}
+
+	matchesByJ := make([][]*Match, n)
+	for _, match := range matches {
+		matchesByJ[match.J] = append(matchesByJ[match.J], match)
+	}
+	for _, byJ := range matchesByJ {
+		slices.SortStableFunc(byJ, func(a, b *Match) int { return cmp.Compare(a.I, b.I) })
+	}
+
+	type step struct {
+		match *Match
+		pi, g float64
+	}
+	optimal := make([][]*step, n)
+	for k := range optimal {
+		optimal[k] = make([]*step, n+1)
+	}
+
+	update := func(match *Match, l int) {
+		k := match.J
+		pi := estimateGuesses(match, n)
+		if l > 1 {
+			pi *= optimal[match.I-1][l-1].pi
+		}
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+		// a shorter or equal sequence that is at least as cheap wins
+		for competing := 1; competing <= l; competing++ {
+			if other := optimal[k][competing]; other != nil && other.g <= g {
+				return
+			}
+		}
+		optimal[k][l] = &step{match, pi, g}
+	}
+
+	bruteforceUpdate := func(k int) {
+		update(bruteforceMatch(password, 0, k), 1)
+		for i := 1; i <= k; i++ {
+			match := bruteforceMatch(password, i, k)
+			for l, last := range optimal[i-1] {
+				// two brute forced parts next to each other are one longer part
+				if last != nil && last.match.Pattern != PatternBruteforce {
+					update(match, l+1)
+				}
+			}
+		}
+	}
+
+	for k := range n {
+		for _, match := range matchesByJ[k] {
+			if match.I == 0 {
+				update(match, 1)
+				continue
+			}
+			for l, last := range optimal[match.I-1] {
+				if last != nil {
+					update(match, l+1)
+				}
+			}
+		}
+		bruteforceUpdate(k)
+	}
+
+	best := 0
+	for l, last := range optimal[n-1] {
+		if last != nil && (best == 0 || last.g < optimal[n-1][best].g) {
+			best = l
+		}
+	}
+	result := analysis{guesses: optimal[n-1][best].g}
+	for k, l := n-1, best; k >= 0; k, l = result.sequence[0].I-1, l-1 {
+		result.sequence = slices.Insert(result.sequence, 0, optimal[k][l].match)
+	}
+	return result
+}
+
+func bruteforceMatch(password []rune, i, j int) *Match {
+	return &Match{Pattern: PatternBruteforce, I: i, J: j, Token: string(password[i : j+1])}
+}
+
+// estimateGuesses returns the guesses for a match on its own, caching them in the match
+func estimateGuesses(match *Match, passwordLength int) float64 {
+	if match.Guesses != 0 {
+		return match.Guesses
+	}
+
+	minGuesses := 1.0
+	if length := utf8.RuneCountInString(match.Token); length < passwordLength {
+		minGuesses = minSubmatchGuessesMultiChar
+		if length == 1 {
+			minGuesses = minSubmatchGuessesSingleChar
+		}
+	}
+
+	var guesses float64
+	switch match.Pattern {
+	case PatternBruteforce:
+		guesses = bruteforceGuesses(match)
+	case PatternDictionary:
+		guesses = dictionaryGuesses(match)
+	case PatternSpatial:
+		guesses = spatialGuesses(match)
+	case PatternRepeat:
+		guesses = match.BaseGuesses * float64(match.RepeatCount)
+	case PatternSequence:
+		guesses = sequenceGuesses(match)
+	case PatternRegex:
+		guesses = regexGuesses(match)
+	case PatternDate:
+		guesses = dateGuesses(match)
+	}
+	match.Guesses = max(guesses, minGuesses)
+	return match.Guesses
+}
+
+func bruteforceGuesses(match *Match) float64 {
+	length := utf8.RuneCountInString(match.Token)
+	guesses := math.Pow(bruteforceCardinality, float64(length))
+	if math.IsInf(guesses, 1) {
+		guesses = math.MaxFloat64
+	}
+	// a brute forced part must cost more than any other match of the same length
+	if length == 1 {
+		return max(guesses, minSubmatchGuessesSingleChar+1)
+	}
+	return max(guesses, minSubmatchGuessesMultiChar+1)
+}
+
+func dictionaryGuesses(match *Match) float64 {
+	guesses := float64(match.Rank) * uppercaseVariations(match.Token) * l33tVariations(match)
+	if match.Reversed {
+		guesses *= 2
+	}
+	return guesses
+}
+
+var (
+	startUpper = regexp.MustCompile(`^[A-Z][^A-Z]+$`)
+	endUpper   = regexp.MustCompile(`^[^A-Z]+[A-Z]$`)
+	allUpper   = regexp.MustCompile(`^[^a-z]+$`)
+	allLower   = regexp.MustCompile(`^[^A-Z]+$`)
+)
+
+// uppercaseVariations is the number of ways to capitalize a word with as many capitals. Only the
+// first, the last or all letters capitalized are common enough to count double.
+func uppercaseVariations(word string) float64 {
+	if allLower.MatchString(word) || strings.ToLower(word) == word {
+		return 1
+	}
+	if startUpper.MatchString(word) || endUpper.MatchString(word) || allUpper.MatchString(word) {
+		return 2
+	}
+
+	upper, lower := 0, 0
+	for _, r := range word {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	variations := 0.0
+	for i := 1; i <= min(upper, lower); i++ {
+		variations += nCk(upper+lower, i)
+	}
+	return variations
+}
+
+// l33tVariations is the number of ways to substitute as many letters of the word
+func l33tVariations(match *Match) float64 {
+	if !match.L33t {
+		return 1
+	}
+	variations := 1.0
+	lower := []rune(strings.ToLower(match.Token))
+	for subbed, unsubbed := range match.Sub {
+		s, u := 0, 0
+		for _, r := range lower {
+			switch r {
+			case subbed:
+				s++
+			case unsubbed:
+				u++
+			}
+		}
+		if s == 0 || u == 0 {
+			// all or none substituted is about as easy as one way
+			variations *= 2
+			continue
+		}
+		possibilities := 0.0
+		for i := 1; i <= min(s, u); i++ {
+			possibilities += nCk(s+u, i)
+		}
+		variations *= possibilities
+	}
+	return variations
+}
+
+// spatialGuesses counts the walks of at most the token's length and turns from any key, and the ways
+// to shift its keys
+func spatialGuesses(match *Match) float64 {
+	var graph *keyboardGraph
+	for _, g := range keyboardGraphs {
+		if g.name == match.Graph {
+			graph = g
+		}
+	}
+
+	length := utf8.RuneCountInString(match.Token)
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(match.Turns, i-1); j++ {
+			guesses += nCk(i-1, j-1) * graph.startingPositions * math.Pow(graph.averageDegree, float64(j))
+		}
+	}
+
+	if shifted := match.ShiftedCount; shifted > 0 {
+		unshifted := length - shifted
+		if unshifted == 0 {
+			guesses *= 2
+		} else {
+			variations := 0.0
+			for i := 1; i <= min(shifted, unshifted); i++ {
+				variations += nCk(shifted+unshifted, i)
+			}
+			guesses *= variations
+		}
+	}
+	return guesses
+}
+
+func sequenceGuesses(match *Match) float64 {
+	first, _ := utf8.DecodeRuneInString(match.Token)
+	base := 26.0
+	switch {
+	case strings.ContainsRune("aAzZ019", first):
+		// obvious starting points
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	}
+	if !match.Ascending {
+		base *= 2
+	}
+	return base * float64(utf8.RuneCountInString(match.Token))
+}
+
+func regexGuesses(match *Match) float64 {
+	year, _ := strconv.Atoi(match.Token)
+	return float64(max(yearDistance(year), minYearSpace))
+}
+
+func dateGuesses(match *Match) float64 {
+	guesses := float64(max(yearDistance(match.Year), minYearSpace)) * 365
+	if match.Separator != "" {
+		guesses *= 4
+	}
+	return guesses
+}
+
+func nCk(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	result := 1.0
+	for d := 1; d <= k; d++ {
+		result *= float64(n - d + 1)
+		result /= float64(d)
+	}
+	return result
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+	return result
+}
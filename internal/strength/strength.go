@@ -0,0 +1,226 @@
+// Package strength estimates how many guesses an attacker needs for a secret, following the zxcvbn
+// approach (Dropbox, MIT licensed, word lists from its Go port): the secret is matched against
+// frequency lists of common passwords, English words and names, keyboard walks, repeats, sequences,
+// years and dates, also reversed or with l33t substitutions, and scored as the cheapest sequence of
+// those patterns that covers it.
+//
+// Estimating copies parts of the secret into strings, which cannot be wiped.
+package strength
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLength bounds the runes analysed, the rest of a longer secret is ignored. Guesses only grow with
+// length, so a truncated estimate is a lower bound.
+const maxLength = 256
+
+// OfflineGuessesPerSecond is the assumed speed of an attacker with a stolen vault file, guessing
+// against a slow key derivation on many cores
+const OfflineGuessesPerSecond = 1e4
+
+// Result is the estimate for a secret
+type Result struct {
+	Guesses float64
+
+	// 0 to 4, see Label
+	Score int
+
+	// seconds to guess the secret at OfflineGuessesPerSecond
+	CrackSeconds float64
+
+	// the patterns the estimate is made of, in order
+	Sequence []*Match
+
+	// what makes the secret weak, empty for strong secrets
+	Warning     string
+	Suggestions []string
+}
+
+// Bits is the estimate in bits, log2 of the guesses
+func (r Result) Bits() float64 {
+	return math.Log2(r.Guesses)
+}
+
+// Label names the score
+func (r Result) Label() string {
+	return []string{"very weak", "weak", "fair", "strong", "very strong"}[r.Score]
+}
+
+// CrackTime is CrackSeconds for humans
+func (r Result) CrackTime() string {
+	return DisplayTime(r.CrackSeconds)
+}
+
+// Estimate rates a secret. User inputs are words an attacker would try first for this account, like
+// the label and user of the credential.
+func Estimate(secret []byte, userInputs ...string) Result {
+	password := []rune(string(secret))
+	if len(password) > maxLength {
+		password = password[:maxLength]
+	}
+
+	matches := newMatcher(userInputs).omnimatch(password)
+	best := mostGuessableMatchSequence(password, matches)
+
+	result := Result{
+		Guesses:      best.guesses,
+		Score:        score(best.guesses),
+		CrackSeconds: best.guesses / OfflineGuessesPerSecond,
+		Sequence:     best.sequence,
+	}
+	result.Warning, result.Suggestions = feedback(result.Score, best.sequence)
+	return result
+}
+
+// score maps guesses to 0 to 4, too guessable, very guessable, somewhat guessable, safely
+// unguessable and very unguessable. The margin keeps a secret right at a bound in the lower score.
+func score(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0
+	case guesses < 1e6+delta:
+		return 1
+	case guesses < 1e8+delta:
+		return 2
+	case guesses < 1e10+delta:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// DisplayTime rounds seconds to the largest unit, up to centuries
+func DisplayTime(seconds float64) string {
+	const (
+		minute  = 60
+		hour    = minute * 60
+		day     = hour * 24
+		month   = day * 31
+		year    = month * 12
+		century = year * 100
+	)
+
+	var amount float64
+	var unit string
+	switch {
+	case seconds < 1:
+		return "less than a second"
+	case seconds < minute:
+		amount, unit = seconds, "second"
+	case seconds < hour:
+		amount, unit = seconds/minute, "minute"
+	case seconds < day:
+		amount, unit = seconds/hour, "hour"
+	case seconds < month:
+		amount, unit = seconds/day, "day"
+	case seconds < year:
+		amount, unit = seconds/month, "month"
+	case seconds < century:
+		amount, unit = seconds/year, "year"
+	default:
+		return "centuries"
+	}
+
+	rounded := int(math.Round(amount))
+	if rounded != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", rounded, unit)
+}
+
+const suggestionAddWord = "add another word or two, uncommon words are better"
+
+// feedback explains the longest pattern of a weak secret
+func feedback(score int, sequence []*Match) (string, []string) {
+	if len(sequence) == 0 {
+		return "", []string{"use a few words, avoid common phrases", "no need for symbols, digits or uppercase letters"}
+	}
+	if score > 2 {
+		return "", nil
+	}
+
+	longest := sequence[0]
+	for _, match := range sequence[1:] {
+		if utf8.RuneCountInString(match.Token) > utf8.RuneCountInString(longest.Token) {
+			longest = match
+		}
+	}
+
+	warning, suggestions := matchFeedback(longest, len(sequence) == 1)
+	return warning, append([]string{suggestionAddWord}, suggestions...)
+}
+
+func matchFeedback(match *Match, soleMatch bool) (string, []string) {
+	switch match.Pattern {
+	case PatternDictionary:
+		return dictionaryFeedback(match, soleMatch)
+	case PatternSpatial:
+		warning := "short keyboard patterns are easy to guess"
+		if match.Turns == 1 {
+			warning = "straight rows of keys are easy to guess"
+		}
+		return warning, []string{"use a longer keyboard pattern with more turns"}
+	case PatternRepeat:
+		warning := `repeats like "abcabcabc" are only slightly harder to guess than "abc"`
+		if utf8.RuneCountInString(match.BaseToken) == 1 {
+			warning = `repeats like "aaa" are easy to guess`
+		}
+		return warning, []string{"avoid repeated words and characters"}
+	case PatternSequence:
+		return "sequences like abc or 6543 are easy to guess", []string{"avoid sequences"}
+	case PatternRegex:
+		return "recent years are easy to guess", []string{"avoid recent years", "avoid years that are associated with you"}
+	case PatternDate:
+		return "dates are often easy to guess", []string{"avoid dates and years that are associated with you"}
+	}
+	return "", nil
+}
+
+func dictionaryFeedback(match *Match, soleMatch bool) (string, []string) {
+	warning := ""
+	switch match.Dictionary {
+	case dictionaryPasswords:
+		switch {
+		case soleMatch && !match.L33t && !match.Reversed && match.Rank <= 10:
+			warning = "this is a top-10 common password"
+		case soleMatch && !match.L33t && !match.Reversed && match.Rank <= 100:
+			warning = "this is a top-100 common password"
+		case soleMatch && !match.L33t && !match.Reversed:
+			warning = "this is a very common password"
+		case math.Log10(match.Guesses) <= 4:
+			warning = "this is similar to a commonly used password"
+		}
+	case dictionaryEnglish:
+		if soleMatch {
+			warning = "a word by itself is easy to guess"
+		}
+	case dictionaryNames, dictionarySurnames:
+		warning = "common names and surnames are easy to guess"
+		if soleMatch {
+			warning = "names and surnames by themselves are easy to guess"
+		}
+	case dictionaryUserInputs:
+		warning = "secrets containing the account's name are easy to guess"
+	}
+
+	var suggestions []string
+	word := match.Token
+	switch {
+	case startUpper.MatchString(word):
+		suggestions = append(suggestions, "capitalization doesn't help very much")
+	case allUpper.MatchString(word) && strings.ToLower(word) != word:
+		suggestions = append(suggestions, "all-uppercase is almost as easy to guess as all-lowercase")
+	}
+	if match.Reversed && utf8.RuneCountInString(word) >= 4 {
+		suggestions = append(suggestions, "reversed words aren't much harder to guess")
+	}
+	if match.L33t {
+		suggestions = append(suggestions, "predictable substitutions like '@' instead of 'a' don't help very much")
+	}
+	return warning, suggestions
+}
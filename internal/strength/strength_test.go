@@ -0,0 +1,123 @@
+package strength
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		secret   string
+		inputs   []string
+		maxScore int
+		minScore int
+		pattern  Pattern
+	}{
+		{"password", nil, 0, 0, PatternDictionary},
+		{"p@ssw0rd", nil, 0, 0, PatternDictionary},
+		{"drowssap", nil, 0, 0, PatternDictionary},
+		{"zxcvfr", nil, 1, 0, PatternSpatial},
+		{"aaaaaaaa", nil, 0, 0, PatternRepeat},
+		{"abcdefgh", nil, 0, 0, PatternSequence},
+		{"13.05.1985", nil, 1, 0, PatternDate},
+		{"1985", nil, 0, 0, PatternRegex},
+		{"github", []string{"github", "alice@example.com"}, 0, 0, PatternDictionary},
+		{"correcthorsebatterystaple", nil, 4, 4, PatternDictionary},
+		{"kP9#xL2@vQ7!mN4$zR8w", nil, 4, 4, PatternBruteforce},
+	}
+	for _, tt := range tests {
+		result := Estimate([]byte(tt.secret), tt.inputs...)
+		if result.Score < tt.minScore || result.Score > tt.maxScore {
+			t.Errorf("Estimate(%q).Score = %d, want %d to %d", tt.secret, result.Score, tt.minScore, tt.maxScore)
+		}
+		if len(result.Sequence) == 0 || result.Sequence[0].Pattern != tt.pattern {
+			t.Errorf("Estimate(%q) starts with %v, want %s", tt.secret, result.Sequence, tt.pattern)
+		}
+	}
+}
+
+func TestEstimateCoversSecret(t *testing.T) {
+	secret := "Summer2023!qwerty"
+	result := Estimate([]byte(secret))
+
+	var covered strings.Builder
+	next := 0
+	for _, match := range result.Sequence {
+		if match.I != next {
+			t.Fatalf("match %q starts at %d, want %d", match.Token, match.I, next)
+		}
+		covered.WriteString(match.Token)
+		next = match.J + 1
+	}
+	if covered.String() != secret {
+		t.Errorf("sequence covers %q, want %q", covered.String(), secret)
+	}
+}
+
+func TestEstimateFeedback(t *testing.T) {
+	if result := Estimate([]byte("password")); result.Warning != "this is a top-10 common password" {
+		t.Errorf("Warning = %q", result.Warning)
+	}
+	if result := Estimate([]byte("kP9#xL2@vQ7!mN4$zR8w")); result.Warning != "" || len(result.Suggestions) != 0 {
+		t.Errorf("strong secret got feedback %q %v", result.Warning, result.Suggestions)
+	}
+}
+
+func TestSpatialMatch(t *testing.T) {
+	matches := spatialMatch([]rune("xqwerty1"))
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+	if match := matches[0]; match.Token != "qwerty" || match.Graph != "qwerty" || match.Turns != 1 {
+		t.Errorf("got %q on %s with %d turns", match.Token, match.Graph, match.Turns)
+	}
+
+	matches = spatialMatch([]rune("!QAZ"))
+	if len(matches) != 1 || matches[0].ShiftedCount != 4 {
+		t.Errorf("got %v, want one fully shifted walk", matches)
+	}
+}
+
+func TestDateMatch(t *testing.T) {
+	tests := []struct {
+		token            string
+		day, month, year int
+	}{
+		{"13.05.1985", 13, 5, 1985},
+		{"1985-05-13", 13, 5, 1985},
+		{"130585", 13, 5, 1985},
+		{"5/13/09", 13, 5, 2009},
+	}
+	for _, tt := range tests {
+		matches := dateMatch([]rune(tt.token))
+		if len(matches) != 1 {
+			t.Errorf("dateMatch(%q) = %d matches, want 1", tt.token, len(matches))
+			continue
+		}
+		if m := matches[0]; m.Day != tt.day || m.Month != tt.month || m.Year != tt.year {
+			t.Errorf("dateMatch(%q) = %d.%d.%d, want %d.%d.%d", tt.token, m.Day, m.Month, m.Year, tt.day, tt.month, tt.year)
+		}
+	}
+
+	if matches := dateMatch([]rune("1.2.3")); len(matches) != 0 {
+		t.Errorf("dateMatch(1.2.3) = %v, want none", matches)
+	}
+}
+
+func TestDisplayTime(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{0.5, "less than a second"},
+		{1, "1 second"},
+		{90, "2 minutes"},
+		{3 * 24 * 3600, "3 days"},
+		{1e12, "centuries"},
+	}
+	for _, tt := range tests {
+		if got := DisplayTime(tt.seconds); got != tt.want {
+			t.Errorf("DisplayTime(%v) = %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -26,6 +27,32 @@ func ReadStringField(prompt string) (string, error) {
 	return strings.TrimSpace(data), nil
 }
 
+// ReadMultilineField prompts the user and reads lines from the standard input until a line with a single
+// '.' or the end of input
+func ReadMultilineField(prompt string) (string, error) {
+	logger.Prompt("%s\n", prompt)
+	reader := bufio.NewReader(os.Stdin)
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "." {
+			break
+		}
+		if err != nil && line == "" {
+			if err == io.EOF {
+				break
+			}
+			return "", fmt.Errorf("failed to read input: %w", err)
+		}
+		lines = append(lines, line)
+		if err == io.EOF {
+			break
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
 // ReadStringFieldWithRetry prompts with automatic retry on error
 func ReadStringFieldWithRetry(prompt string) string {
 	for range INPUT_MAX_RETRY {
@@ -12,6 +12,7 @@ import (
 )
 
 const (
+	ansiiTab = 9
 	ansiiEnter = 13
 	ansiiEscape = 27
 	ansiiControl = 3
@@ -24,6 +25,14 @@ type Searchable interface {
 	Display() string
 }
 
+// SearchAction tells the caller how the user confirmed the selected item
+type SearchAction int
+
+const (
+	SearchActionSelect SearchAction = iota // Enter: use the item's primary value
+	SearchActionOTP                        // Tab: use the item's one-time password
+)
+
 // InteractiveSearch presents an interactive, type-to-filter list selector in
 // the terminal and returns the item the user picks.
 //
@@ -34,6 +43,7 @@ type Searchable interface {
 // Controls:
 //   - Up / Down: move the selection
 //   - Enter: select the highlighted item
+//   - Tab: select the highlighted item for its one-time password
 //   - Esc / Ctrl-C: cancel
 //
 // Type parameter:
@@ -45,7 +55,8 @@ type Searchable interface {
 //   - searchFn: returns the results matching a query; called on each keystroke.
 //     Return an empty slice to display no results (e.g. for an empty query).
 //
-// It returns the chosen item on Enter. If the user cancels, it returns the zero
+// It returns the chosen item on Enter or Tab along with the SearchAction that
+// selected it. If the user cancels, it returns the zero
 // value of T with constants.ErrSearchCancelled; callers should treat this as a
 // normal, quiet exit rather than a failure. Any error entering raw mode or
 // reading input is returned with the zero value of T. Requires an interactive
@@ -53,11 +64,11 @@ type Searchable interface {
 func InteractiveSearch[T Searchable](
 	prompt string,
 	searchFn func(query string) []T,
-) (T, error) {
+) (T, SearchAction, error) {
 	var zero T
 	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
 	if err != nil {
-		return zero, err
+		return zero, SearchActionSelect, err
 	}
 	defer term.Restore(int(os.Stdin.Fd()), oldState)
 	
@@ -84,7 +95,7 @@ func InteractiveSearch[T Searchable](
 
 		// Navigation hint (only when there's a list to move through).
 		if len(filtered) > 0 {
-			buf.WriteString("\033[90m↑/↓ navigate · enter select · esc cancel\033[0m\033[K\r\n")
+			buf.WriteString("\033[90m↑/↓ navigate · enter select · tab otp · esc cancel\033[0m\033[K\r\n")
 			curLines++
 		}
 		
@@ -112,22 +123,26 @@ func InteractiveSearch[T Searchable](
 
 		c1, c2, c3, err := readKey(reader)
 		if err != nil {
-			return zero, err
+			return zero, SearchActionSelect, err
 		}
 
 		switch {
-		case c1 == ansiiEnter:
+		case c1 == ansiiEnter || c1 == ansiiTab:
 			if len(filtered) > 0 {
 				fmt.Printf(ansiiMoveUp, prevLines)
 				fmt.Print(ansiiClearBelow)
-				return filtered[selectedIndex], nil
+				action := SearchActionSelect
+				if c1 == ansiiTab {
+					action = SearchActionOTP
+				}
+				return filtered[selectedIndex], action, nil
 			}
 			continue
 
 		case c1 == ansiiControl || (c1 == ansiiEscape && c2 == 0):
 			fmt.Printf(ansiiMoveUp, prevLines)
 			fmt.Print(ansiiClearBelow)
-			return zero, constants.ErrSearchCancelled
+			return zero, SearchActionSelect, constants.ErrSearchCancelled
 
 		case c1 == ansiiBacksapce:
 			if len(query) > 0 {
@@ -0,0 +1,143 @@
+// Package urlmatch decides whether a URL saved with a credential applies to a visited URL or app.
+// Web URLs are compared by host, port and registrable domain (eTLD+1, from the public suffix list
+// embedded in golang.org/x/net/publicsuffix); Android apps by their package name.
+package urlmatch
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	// match qualities, from best to worst
+	EXACT_MATCH     = 1.0 // same host (or app), same port
+	SUBDOMAIN_MATCH = 0.8 // visited host is below the saved host
+	DOMAIN_MATCH    = 0.6 // same registrable domain, e.g. sibling sub-domains
+	NO_MATCH        = 0.0
+)
+
+const SchemeAndroidApp = "androidapp"
+
+var ErrInvalidURL = errors.New("invalid url")
+
+// defaultPorts maps schemes to the port used when a URL does not name one
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+	"ssh":   "22",
+}
+
+// Target is a parsed, normalized URL or Android app id
+type Target struct {
+	Scheme string
+	Host   string // lower case, without port or trailing dot, empty for apps
+	Port   string // explicit port, empty when it is the scheme's default
+	Domain string // registrable domain, the host itself for IPs and single label hosts
+	App    string // Android package name, empty for web URLs
+}
+
+// Parse parses a URL, a bare host like "github.com:8443" or an Android app id given as
+// "androidapp://com.example.app" or "android://<cert hash>@com.example.app"
+func Parse(raw string) (*Target, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrInvalidURL
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+
+	if scheme == SchemeAndroidApp || scheme == "android" {
+		app := strings.ToLower(parsed.Hostname())
+		if app == "" || !strings.Contains(app, ".") {
+			return nil, ErrInvalidURL
+		}
+		return &Target{Scheme: SchemeAndroidApp, App: app}, nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	port := parsed.Port()
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+
+	return &Target{
+		Scheme: scheme,
+		Host:   host,
+		Port:   port,
+		Domain: registrableDomain(host),
+	}, nil
+}
+
+// String returns the normalized form of the target
+func (t *Target) String() string {
+	if t.App != "" {
+		return SchemeAndroidApp + "://" + t.App
+	}
+	host := t.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if t.Port != "" {
+		host += ":" + t.Port
+	}
+	return t.Scheme + "://" + host
+}
+
+// Match rates how well a URL saved with a credential applies to a visited target, one of the match
+// quality constants. The scheme is ignored, but different explicit ports never match, they usually
+// are different services.
+func Match(saved, visited *Target) float64 {
+	if saved.App != "" || visited.App != "" {
+		if saved.App == visited.App {
+			return EXACT_MATCH
+		}
+		return NO_MATCH
+	}
+
+	if saved.Port != "" && visited.Port != "" && saved.Port != visited.Port {
+		return NO_MATCH
+	}
+
+	switch {
+	case saved.Host == visited.Host:
+		return EXACT_MATCH
+	case strings.HasSuffix(visited.Host, "."+saved.Host) && !isIP(saved.Host):
+		return SUBDOMAIN_MATCH
+	case saved.Domain == visited.Domain && !isIP(saved.Host):
+		return DOMAIN_MATCH
+	}
+	return NO_MATCH
+}
+
+// registrableDomain returns the eTLD+1 of a host, or the host itself when it has none (IP
+// addresses, "localhost", a bare public suffix)
+func registrableDomain(host string) string {
+	if isIP(host) {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+func isIP(host string) bool {
+	return net.ParseIP(host) != nil
+}
@@ -0,0 +1,115 @@
+package urlmatch
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		want   Target
+		string string
+	}{
+		{
+			name:   "bare host defaults to https",
+			raw:    "GitHub.com",
+			want:   Target{Scheme: "https", Host: "github.com", Domain: "github.com"},
+			string: "https://github.com",
+		},
+		{
+			name:   "registrable domain uses the public suffix list",
+			raw:    "https://login.example.co.uk/path?q=1",
+			want:   Target{Scheme: "https", Host: "login.example.co.uk", Domain: "example.co.uk"},
+			string: "https://login.example.co.uk",
+		},
+		{
+			name:   "private suffixes are respected",
+			raw:    "https://alice.github.io",
+			want:   Target{Scheme: "https", Host: "alice.github.io", Domain: "alice.github.io"},
+			string: "https://alice.github.io",
+		},
+		{
+			name:   "explicit port is kept",
+			raw:    "http://localhost:8080/",
+			want:   Target{Scheme: "http", Host: "localhost", Port: "8080", Domain: "localhost"},
+			string: "http://localhost:8080",
+		},
+		{
+			name:   "ip address is its own domain",
+			raw:    "https://192.168.1.1:8443",
+			want:   Target{Scheme: "https", Host: "192.168.1.1", Port: "8443", Domain: "192.168.1.1"},
+			string: "https://192.168.1.1:8443",
+		},
+		{
+			name:   "android app id",
+			raw:    "androidapp://com.Example.App",
+			want:   Target{Scheme: SchemeAndroidApp, App: "com.example.app"},
+			string: "androidapp://com.example.app",
+		},
+		{
+			name:   "android app with certificate hash",
+			raw:    "android://c2hhMjU2@com.example.app/",
+			want:   Target{Scheme: SchemeAndroidApp, App: "com.example.app"},
+			string: "androidapp://com.example.app",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error %v", tt.raw, err)
+			}
+			if *got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.raw, *got, tt.want)
+			}
+			if got.String() != tt.string {
+				t.Errorf("String() = %q, want %q", got.String(), tt.string)
+			}
+		})
+	}
+
+	for _, raw := range []string{"", "   ", "https://", "androidapp://nodots"} {
+		if _, err := Parse(raw); err != ErrInvalidURL {
+			t.Errorf("Parse(%q) error = %v, want %v", raw, err, ErrInvalidURL)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		saved, visited string
+		want           float64
+	}{
+		{"same host", "https://github.com/login", "https://github.com/settings", EXACT_MATCH},
+		{"scheme and path are ignored", "github.com", "http://github.com/", EXACT_MATCH},
+		{"visited sub-domain of saved domain", "example.com", "https://login.example.com", SUBDOMAIN_MATCH},
+		{"visited sub-domain of saved sub-domain", "accounts.example.com", "https://eu.accounts.example.com", SUBDOMAIN_MATCH},
+		{"sibling sub-domains", "https://mail.example.com", "https://calendar.example.com", DOMAIN_MATCH},
+		{"saved sub-domain, visited domain", "https://mail.example.com", "https://example.com", DOMAIN_MATCH},
+		{"different registrable domain", "https://example.com", "https://example.org", NO_MATCH},
+		{"public suffix is not a shared domain", "https://alice.github.io", "https://bob.github.io", NO_MATCH},
+		{"different country domains", "https://a.example.co.uk", "https://b.other.co.uk", NO_MATCH},
+		{"different ports", "http://localhost:8080", "http://localhost:9090", NO_MATCH},
+		{"default port is implied", "https://example.com:443", "https://example.com", EXACT_MATCH},
+		{"different ip addresses", "https://10.0.0.1", "https://10.0.0.2", NO_MATCH},
+		{"same android app", "androidapp://com.example.app", "android://hash@com.example.app", EXACT_MATCH},
+		{"android app never matches a web url", "androidapp://com.example.app", "https://app.example.com", NO_MATCH},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved, err := Parse(tt.saved)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error %v", tt.saved, err)
+			}
+			visited, err := Parse(tt.visited)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error %v", tt.visited, err)
+			}
+			if got := Match(saved, visited); got != tt.want {
+				t.Errorf("Match(%q, %q) = %.1f, want %.1f", tt.saved, tt.visited, got, tt.want)
+			}
+		})
+	}
+}
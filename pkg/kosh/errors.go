@@ -0,0 +1,76 @@
+package kosh
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"git.plutolab.org/plutolab/kosh/internal/model"
+)
+
+// Kinds of errors returned by the SDK, test for them with errors.Is
+var (
+	ErrNotInitialized = errors.New("vault not initialized")
+	ErrWrongPassword  = errors.New("incorrect master password")
+	ErrLocked         = errors.New("vault is locked")
+	ErrClosed         = errors.New("vault is closed")
+	ErrNotFound       = errors.New("credential not found")
+	ErrExists         = errors.New("credential already exists")
+	ErrInvalid        = errors.New("invalid argument")
+	ErrTampered       = errors.New("vault file was changed outside kosh")
+)
+
+// Error describes a failed vault operation. Kind is one of the Err* values above, or nil for
+// unexpected storage and crypto failures, which are kept in Err.
+type Error struct {
+	Op   string // the method that failed, e.g. "Get"
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Kind != nil && e.Err != nil:
+		return fmt.Sprintf("kosh: %s: %s: %s", e.Op, e.Kind, e.Err)
+	case e.Kind != nil:
+		return fmt.Sprintf("kosh: %s: %s", e.Op, e.Kind)
+	}
+	return fmt.Sprintf("kosh: %s: %s", e.Op, e.Err)
+}
+
+// Is matches the kind of the error, so errors.Is(err, kosh.ErrNotFound) works
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func opError(op string, kind error) error {
+	return &Error{Op: op, Kind: kind}
+}
+
+func wrapError(op string, err error) error {
+	return &Error{Op: op, Err: err}
+}
+
+// integritySummary describes the changes of a failed integrity check in one line
+func integritySummary(report *model.IntegrityReport) error {
+	parts := []string{}
+	if report.RolledBack {
+		parts = append(parts, fmt.Sprintf("older copy, counter %d < %d", report.Counter, report.KnownCounter))
+	}
+	if report.RecordInvalid {
+		parts = append(parts, "integrity record altered")
+	}
+	for _, change := range []struct {
+		kind string
+		macs []model.CredentialMAC
+	}{{"added", report.Added}, {"modified", report.Modified}, {"removed", report.Removed}} {
+		for _, credential := range change.macs {
+			parts = append(parts, fmt.Sprintf("%s #%d", change.kind, credential.CredentialId))
+		}
+	}
+	return errors.New(strings.Join(parts, ", "))
+}
@@ -0,0 +1,67 @@
+package kosh
+
+import (
+	"errors"
+	"fmt"
+
+	"git.plutolab.org/plutolab/kosh/internal/generator"
+)
+
+// GenerateOptions configures Generate. Character groups are enabled unless excluded; Min* ask for
+// at least that many characters of a group.
+type GenerateOptions struct {
+	Length int // defaults to 20
+
+	NoUpper  bool
+	NoLower  bool
+	NoDigit  bool
+	NoSymbol bool
+
+	MinUpper  int
+	MinLower  int
+	MinDigit  int
+	MinSymbol int
+}
+
+// Generate creates a random password, the same way as `kosh generate`
+func Generate(options GenerateOptions) ([]byte, error) {
+	length := options.Length
+	if length == 0 {
+		length = 20
+	}
+
+	groups := []struct {
+		name    generator.CharGroup
+		enabled bool
+		min     int
+	}{
+		{generator.UpperCharGroup, !options.NoUpper, options.MinUpper},
+		{generator.LowerCharGroup, !options.NoLower, options.MinLower},
+		{generator.DigitCharGroup, !options.NoDigit, options.MinDigit},
+		{generator.SymbolCharGroup, !options.NoSymbol, options.MinSymbol},
+	}
+
+	required, anyEnabled := 0, false
+	require := generator.RequireConfig{}
+	for _, group := range groups {
+		if group.min < 0 || (group.min > 0 && !group.enabled) {
+			return nil, &Error{Op: "Generate", Kind: ErrInvalid, Err: fmt.Errorf("invalid minimum for %s characters", group.name)}
+		}
+		required += group.min
+		anyEnabled = anyEnabled || group.enabled
+		require[group.name] = group.min
+	}
+
+	if !anyEnabled {
+		return nil, &Error{Op: "Generate", Kind: ErrInvalid, Err: errors.New("no character group enabled")}
+	}
+	if length < 0 || required > length {
+		return nil, &Error{Op: "Generate", Kind: ErrInvalid, Err: fmt.Errorf("length %d is shorter than the %d required characters", length, required)}
+	}
+
+	password, err := generator.Password(length, !options.NoUpper, !options.NoLower, !options.NoDigit, !options.NoSymbol, require)
+	if err != nil {
+		return nil, wrapError("Generate", err)
+	}
+	return password, nil
+}
@@ -0,0 +1,399 @@
+// Package kosh is the Go SDK for kosh vaults. It opens a vault file directly, without the CLI: no
+// terminal prompts, no output, and errors of type *Error instead of log lines.
+//
+//	vault, err := kosh.Open(path)
+//	if err != nil { ... }
+//	defer vault.Close()
+//
+//	if err := vault.Unlock(masterPassword); err != nil { ... }
+//	matches, err := vault.Find("github", "")
+//	credential, err := vault.Get(matches[0].Id)
+//
+// Reading metadata only needs an open vault. Reading a secret, and any change, needs the vault to be
+// unlocked with the master password: changes are sealed with a key derived from the vault private key
+// so edits made to the vault file outside kosh are detected, see ErrTampered.
+// A Vault is safe for concurrent use.
+package kosh
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"git.plutolab.org/plutolab/kosh/internal/constants"
+	"git.plutolab.org/plutolab/kosh/internal/core"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/search"
+	"git.plutolab.org/plutolab/kosh/internal/storage"
+)
+
+// Credential is a saved credential. Secret is only set by Get and GetByLabel, callers may clear it
+// once done.
+type Credential struct {
+	Id          int
+	Label       string
+	User        string
+	Secret      []byte
+	Folder      string
+	Tags        []string
+	AccessCount int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	AccessedAt  time.Time
+
+	// when the secret, and when anything else about the credential, last changed
+	SecretChangedAt   time.Time
+	MetadataChangedAt time.Time
+}
+
+// Match is a result of Find, best match first
+type Match struct {
+	Credential
+	Score float64
+}
+
+// Changes describes an update, empty fields are left unchanged
+type Changes struct {
+	Label  string
+	User   string
+	Secret []byte
+}
+
+// Vault is an open kosh vault
+type Vault struct {
+	mu      sync.RWMutex
+	store   storage.Store
+	service *core.VaultService
+	key     []byte
+}
+
+// DefaultPath returns the path of the vault used by the CLI, ~/.kosh/kosh.db
+func DefaultPath() (string, error) {
+	userDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userDir, ".kosh", "kosh.db"), nil
+}
+
+// Open opens the vault database at path, created beforehand with `kosh init`. Older vaults are
+// migrated to the current schema.
+func Open(path string) (*Vault, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, opError("Open", ErrNotInitialized)
+	}
+
+	store, err := storage.OpenStore(path)
+	if err != nil {
+		return nil, wrapError("Open", err)
+	}
+
+	initialized, err := store.IsVaultInitialized()
+	if err != nil {
+		store.CloseStore()
+		return nil, wrapError("Open", err)
+	}
+	if !initialized {
+		store.CloseStore()
+		return nil, opError("Open", ErrNotInitialized)
+	}
+
+	return &Vault{store: store, service: core.NewVaultService(store)}, nil
+}
+
+// Unlock verifies the master password and keeps the vault private key in memory until Lock or Close.
+// A vault file changed outside kosh since it was last sealed is ErrTampered, with the changes in the
+// message; the vault is unlocked regardless and the changes stay unsealed until `kosh integrity
+// --accept`.
+func (v *Vault) Unlock(masterPassword []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.store == nil {
+		return opError("Unlock", ErrClosed)
+	}
+
+	if err := v.service.VerifyMasterPassword(masterPassword); err != nil {
+		if errors.Is(err, constants.ErrIncorrectMasterPassword) {
+			return opError("Unlock", ErrWrongPassword)
+		}
+		return wrapError("Unlock", err)
+	}
+
+	key, err := v.service.UnlockVault(masterPassword)
+	if err != nil {
+		return wrapError("Unlock", err)
+	}
+	v.wipe()
+	v.key = key
+
+	// the vault stays usable, the caller decides whether to trust it
+	if report := v.service.IntegrityReport(); report != nil && !report.OK() {
+		return &Error{Op: "Unlock", Kind: ErrTampered, Err: integritySummary(report)}
+	}
+	return nil
+}
+
+// Lock wipes the vault private key, Get needs another Unlock afterwards
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.wipe()
+}
+
+// Close locks the vault and closes the database
+func (v *Vault) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.wipe()
+	if v.store == nil {
+		return nil
+	}
+	err := v.store.CloseStore()
+	v.store = nil
+	if err != nil {
+		return wrapError("Close", err)
+	}
+	return nil
+}
+
+// Get returns a credential with its decrypted secret
+func (v *Vault) Get(id int) (*Credential, error) {
+	return v.get("Get", func() (*model.Credential, error) {
+		return v.store.GetCredentialById(id)
+	})
+}
+
+// GetByLabel returns the credential with exactly this label and user, with its decrypted secret
+func (v *Vault) GetByLabel(label, user string) (*Credential, error) {
+	return v.get("GetByLabel", func() (*model.Credential, error) {
+		return v.store.GetCredentialByLabelAndUser(label, user)
+	})
+}
+
+// Find ranks credentials by a fuzzy match of label (also matching tags and folders) and user, the same
+// search the CLI uses. Secrets are not decrypted.
+func (v *Vault) Find(label, user string) ([]Match, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.store == nil {
+		return nil, opError("Find", ErrClosed)
+	}
+
+	credentials, err := v.store.GetAllCredentials()
+	if err != nil {
+		return nil, wrapError("Find", err)
+	}
+
+	results := search.BestMatches(label, user, credentials, nil, time.Now())
+	matches := make([]Match, 0, len(results))
+	for _, result := range results {
+		matches = append(matches, Match{Credential: fromModel(&result.Credential), Score: result.Score})
+	}
+	return matches, nil
+}
+
+// List returns every credential outside the trash, without secrets, sorted by id
+func (v *Vault) List() ([]Credential, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.store == nil {
+		return nil, opError("List", ErrClosed)
+	}
+
+	summaries, err := v.store.SearchCredentialByLabelOrUser("", "", nil, "")
+	if err != nil {
+		return nil, wrapError("List", err)
+	}
+
+	credentials := make([]Credential, 0, len(summaries))
+	for _, summary := range summaries {
+		credentials = append(credentials, Credential{
+			Id:          summary.Id,
+			Label:       summary.Label,
+			User:        summary.User,
+			Folder:      summary.Folder,
+			Tags:        summary.Tags,
+			AccessCount: summary.AccessCount,
+			CreatedAt:   summary.CreatedAt,
+			UpdatedAt:   summary.UpdatedAt,
+			AccessedAt:  summary.AccessedAt,
+
+			SecretChangedAt:   summary.SecretChangedAt,
+			MetadataChangedAt: summary.MetadataChangedAt,
+		})
+	}
+	return credentials, nil
+}
+
+// Add saves a new credential. Unlike `kosh add` it never overwrites, an existing label and user is
+// ErrExists, also when that credential is in the trash.
+func (v *Vault) Add(label, user string, secret []byte) (*Credential, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.store == nil {
+		return nil, opError("Add", ErrClosed)
+	}
+	if v.key == nil {
+		return nil, opError("Add", ErrLocked)
+	}
+	if label == "" || user == "" || len(secret) == 0 {
+		return nil, &Error{Op: "Add", Kind: ErrInvalid, Err: errors.New("label, user and secret are required")}
+	}
+
+	if _, err := v.store.GetCredentialByLabelAndUser(label, user); err == nil {
+		return nil, opError("Add", ErrExists)
+	} else if err != sql.ErrNoRows {
+		return nil, wrapError("Add", err)
+	}
+
+	if err := v.service.AddCredential(label, user, secret); errors.Is(err, constants.ErrCredentialInTrash) {
+		return nil, &Error{Op: "Add", Kind: ErrExists, Err: err}
+	} else if err != nil {
+		return nil, wrapError("Add", err)
+	}
+
+	credential, err := v.store.GetCredentialByLabelAndUser(label, user)
+	if err != nil {
+		return nil, wrapError("Add", err)
+	}
+	v.service.RecordEvent(model.AuditEventAdd, credential.Id, credential.Label, credential.User)
+	result := fromModel(credential)
+	return &result, nil
+}
+
+// Update changes the label, user and/or secret of a credential. A replaced secret is kept in the
+// credential history, like in the CLI.
+func (v *Vault) Update(id int, changes Changes) (*Credential, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.store == nil {
+		return nil, opError("Update", ErrClosed)
+	}
+	if v.key == nil {
+		return nil, opError("Update", ErrLocked)
+	}
+
+	credential, err := v.store.GetCredentialById(id)
+	if err == sql.ErrNoRows {
+		return nil, opError("Update", ErrNotFound)
+	}
+	if err != nil {
+		return nil, wrapError("Update", err)
+	}
+
+	if changes.Label != "" || changes.User != "" {
+		label, user := credential.Label, credential.User
+		if changes.Label != "" {
+			label = changes.Label
+		}
+		if changes.User != "" {
+			user = changes.User
+		}
+		if existing, err := v.store.GetCredentialByLabelAndUser(label, user); err == nil && existing.Id != id {
+			return nil, opError("Update", ErrExists)
+		}
+		if err := v.store.UpdateCredential(&model.Credential{Id: id, Label: changes.Label, User: changes.User}); err != nil {
+			return nil, wrapError("Update", err)
+		}
+		v.service.SealCredentials(id)
+	}
+
+	if len(changes.Secret) > 0 {
+		if err := v.service.UpdateCredentialSecret(id, changes.Secret); err != nil {
+			return nil, wrapError("Update", err)
+		}
+	}
+
+	updated, err := v.store.GetCredentialById(id)
+	if err != nil {
+		return nil, wrapError("Update", err)
+	}
+	v.service.RecordEvent(model.AuditEventUpdate, updated.Id, updated.Label, updated.User)
+	result := fromModel(updated)
+	return &result, nil
+}
+
+// Delete moves a credential to the trash, from where `kosh trash restore` can bring it back
+func (v *Vault) Delete(id int) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.store == nil {
+		return opError("Delete", ErrClosed)
+	}
+	if v.key == nil {
+		return opError("Delete", ErrLocked)
+	}
+
+	credential, err := v.store.GetCredentialById(id)
+	if err == sql.ErrNoRows {
+		return opError("Delete", ErrNotFound)
+	}
+	if err != nil {
+		return wrapError("Delete", err)
+	}
+
+	if err := v.store.TrashCredentialById(id); err != nil {
+		return wrapError("Delete", err)
+	}
+	v.service.SealCredentials(id)
+	v.service.RecordEvent(model.AuditEventTrash, credential.Id, credential.Label, credential.User)
+	return nil
+}
+
+func (v *Vault) get(op string, fetch func() (*model.Credential, error)) (*Credential, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.store == nil {
+		return nil, opError(op, ErrClosed)
+	}
+	if v.key == nil {
+		return nil, opError(op, ErrLocked)
+	}
+
+	credential, err := fetch()
+	if err == sql.ErrNoRows {
+		return nil, opError(op, ErrNotFound)
+	}
+	if err != nil {
+		return nil, wrapError(op, err)
+	}
+
+	secret, err := v.service.DecryptCredentialWithKey(credential, v.key)
+	if err != nil {
+		return nil, wrapError(op, err)
+	}
+	v.store.RecordAccess(&model.AccessEvent{CredentialId: credential.Id, Via: model.AccessViaSDK, At: time.Now()})
+	v.service.RecordEvent(model.AuditEventRead, credential.Id, credential.Label, credential.User)
+
+	result := fromModel(credential)
+	result.Secret = []byte(secret)
+	return &result, nil
+}
+
+// wipe clears the vault private key, v.mu must be held for writing
+func (v *Vault) wipe() {
+	if v.key != nil {
+		clear(v.key)
+		v.key = nil
+	}
+}
+
+func fromModel(c *model.Credential) Credential {
+	return Credential{
+		Id:          c.Id,
+		Label:       c.Label,
+		User:        c.User,
+		Folder:      c.Folder,
+		Tags:        c.Tags,
+		AccessCount: c.AccessCount,
+		CreatedAt:   c.CreatedAt,
+		UpdatedAt:   c.UpdatedAt,
+		AccessedAt:  c.AccessedAt,
+
+		SecretChangedAt:   c.SecretChangedAt,
+		MetadataChangedAt: c.MetadataChangedAt,
+	}
+}
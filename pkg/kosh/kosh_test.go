@@ -0,0 +1,272 @@
+package kosh
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git.plutolab.org/plutolab/kosh/internal/crypto"
+	"git.plutolab.org/plutolab/kosh/internal/logger"
+	"git.plutolab.org/plutolab/kosh/internal/model"
+	"git.plutolab.org/plutolab/kosh/internal/storage"
+)
+
+// newTestVault initializes a vault the same way `kosh init` does
+func newTestVault(t *testing.T, password string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kosh.db")
+
+	store, err := storage.OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.CloseStore()
+
+	salt := crypto.GenerateSalt()
+	key := crypto.GenerateSymmetricKey([]byte(password), salt)
+	priv, pub := crypto.GenerateAsymmetricKeyPair()
+	cipher, nonce, err := crypto.EncryptSecret(key, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vault := &model.VaultData{Salt: salt, PublicKey: pub, Nonce: nonce, Secret: cipher}
+	if err := store.InitializeVault(*vault.EncodeToString()); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenNotInitialized(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "missing.db"))
+	if !errors.Is(err, ErrNotInitialized) {
+		t.Fatalf("Open() error = %v, want ErrNotInitialized", err)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	vault, err := Open(newTestVault(t, "pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	if err := vault.Unlock([]byte("wrong")); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("Unlock() error = %v, want ErrWrongPassword", err)
+	}
+	if err := vault.Unlock([]byte("pw")); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+}
+
+func TestCredentialRoundTrip(t *testing.T) {
+	vault, err := Open(newTestVault(t, "pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	// changes are sealed with the master password
+	if _, err := vault.Add("github", "alice", []byte("s3cret")); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Add() locked error = %v, want ErrLocked", err)
+	}
+	if err := vault.Unlock([]byte("pw")); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := vault.Add("github", "alice", []byte("s3cret"))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, err := vault.Add("github", "alice", []byte("other")); !errors.Is(err, ErrExists) {
+		t.Fatalf("Add() duplicate error = %v, want ErrExists", err)
+	}
+
+	got, err := vault.Get(added.Id)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if string(got.Secret) != "s3cret" || got.Label != "github" || got.User != "alice" {
+		t.Fatalf("Get() = %+v", got)
+	}
+
+	matches, err := vault.Find("gthub", "")
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(matches) == 0 || matches[0].Id != added.Id || matches[0].Secret != nil {
+		t.Fatalf("Find() = %+v", matches)
+	}
+
+	if _, err := vault.Update(added.Id, Changes{User: "bob", Secret: []byte("n3w")}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err = vault.GetByLabel("github", "bob")
+	if err != nil {
+		t.Fatalf("GetByLabel() error = %v", err)
+	}
+	if string(got.Secret) != "n3w" {
+		t.Fatalf("GetByLabel() secret = %q, want %q", got.Secret, "n3w")
+	}
+
+	if err := vault.Delete(added.Id); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := vault.Get(added.Id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() deleted error = %v, want ErrNotFound", err)
+	}
+
+	vault.Lock()
+	if _, err := vault.Get(added.Id); !errors.Is(err, ErrLocked) {
+		t.Fatalf("Get() after Lock error = %v, want ErrLocked", err)
+	}
+}
+
+func TestAddAfterTrash(t *testing.T) {
+	path := newTestVault(t, "pw")
+	vault, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+	if err := vault.Unlock([]byte("pw")); err != nil {
+		t.Fatal(err)
+	}
+
+	trashed, err := vault.Add("github", "alice", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Delete(trashed.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	// the trashed credential is in the way until it is restored or purged, not silently replaced
+	if _, err := vault.Add("github", "alice", []byte("other")); !errors.Is(err, ErrExists) {
+		t.Fatalf("Add() over trashed error = %v, want ErrExists", err)
+	}
+	if _, err := vault.GetByLabel("github", "alice"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByLabel() after Add() over trashed error = %v, want ErrNotFound", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`UPDATE credentials SET deleted_at = NULL WHERE id = ?`, trashed.Id)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := vault.Get(trashed.Id)
+	if err != nil {
+		t.Fatalf("Get() restored error = %v", err)
+	}
+	if string(restored.Secret) != "s3cret" {
+		t.Fatalf("Get() restored secret = %q, want %q", restored.Secret, "s3cret")
+	}
+}
+
+func TestUnlockTampered(t *testing.T) {
+	path := newTestVault(t, "pw")
+	vault, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Unlock([]byte("pw")); err != nil {
+		t.Fatal(err)
+	}
+	github, err := vault.Add("github", "alice", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vpn, err := vault.Add("vpn", "bob", []byte("0ther"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.Update(vpn.Id, Changes{Secret: []byte("r0tated")}); err != nil {
+		t.Fatal(err)
+	}
+	vault.Close()
+
+	// put the secret of one credential in place of another, without the master password
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`UPDATE credentials SET (secret, ephemeral, nonce) = (SELECT secret, ephemeral, nonce FROM credentials WHERE id = ?) WHERE id = ?`, github.Id, vpn.Id)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vault, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	err = vault.Unlock([]byte("pw"))
+	if !errors.Is(err, ErrTampered) {
+		t.Fatalf("Unlock() error = %v, want ErrTampered", err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("modified #%d", vpn.Id)) || strings.Contains(err.Error(), fmt.Sprintf("#%d", github.Id)) {
+		t.Fatalf("Unlock() error = %v, want only #%d modified", err, vpn.Id)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	password, err := Generate(GenerateOptions{Length: 12, NoSymbol: true, MinDigit: 3})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(password) != 12 {
+		t.Fatalf("Generate() length = %d, want 12", len(password))
+	}
+
+	if _, err := Generate(GenerateOptions{NoUpper: true, NoLower: true, NoDigit: true, NoSymbol: true}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Generate() error = %v, want ErrInvalid", err)
+	}
+	if _, err := Generate(GenerateOptions{Length: 4, MinDigit: 5}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Generate() error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestNoLoggerOutput(t *testing.T) {
+	path := newTestVault(t, "pw")
+	var output strings.Builder
+	defer logger.Redirect(&output)()
+
+	// the SDK reports through errors only, also when calls fail
+	Open(filepath.Join(t.TempDir(), "missing.db"))
+	vault, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Unlock([]byte("wrong"))
+	if err := vault.Unlock([]byte("pw")); err != nil {
+		t.Fatal(err)
+	}
+	added, err := vault.Add("github", "alice", []byte("s3cret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Get(added.Id)
+	vault.Get(added.Id + 1)
+	vault.GetByLabel("gitlab", "alice")
+	vault.Find("git", "")
+	vault.List()
+	vault.Update(added.Id, Changes{Secret: []byte("n3w")})
+	vault.Delete(added.Id)
+	vault.Delete(added.Id)
+	Generate(GenerateOptions{Length: 4, MinDigit: 5})
+	vault.Close()
+
+	if got := output.String(); got != "" {
+		t.Fatalf("logger output = %q, want none", got)
+	}
+}